@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// ResponseRepairer generates the missing response of a prompt.
+type ResponseRepairer interface {
+	RepairResponse(ctx context.Context, prompt weaviate.PromptSummary) error
+}
+
+// ConsistencyReport lists the orphans a check found by class. Prompts are
+// orphans if their response is missing, all other objects if the prompt they
+// belong to is. Skipped counts the prompts whose response was not regenerated
+// because of the limit per check or because the model server is unavailable.
+type ConsistencyReport struct {
+	Checked  map[string]int      `json:"checked"`
+	Orphans  map[string][]string `json:"orphans"`
+	Repaired int                 `json:"repaired"`
+	Failed   int                 `json:"failed"`
+	Skipped  int                 `json:"skipped"`
+}
+
+// Checker finds objects left behind by writes that failed halfway, or by
+// resets of single classes, and repairs them.
+type Checker struct {
+	Store weaviate.Store
+	// Responses regenerates missing responses. Without it prompts missing
+	// their response are only reported.
+	Responses ResponseRepairer
+	// MinAge leaves out objects younger than this, which may belong to a
+	// write still in progress. Ten minutes by default.
+	MinAge time.Duration
+	// MaxRepairs is the number of responses regenerated at most per check,
+	// as every one of them calls the model. Ten by default.
+	MaxRepairs int
+}
+
+// maxRepairPause is the number of runs Run skips regenerating responses at
+// most while the model server is unavailable.
+const maxRepairPause = 24
+
+// checkedClasses are the classes whose objects can be orphans.
+var checkedClasses = []string{
+	weaviate.PromptClass,
+	weaviate.ResponseClass,
+	weaviate.SemanticMeaningClass,
+	weaviate.MessageClass,
+	weaviate.SuggestionClass,
+}
+
+// Check looks for orphans and, if repair is set, deletes dangling objects and
+// regenerates missing responses. Regenerating stops at the first response the
+// model server is unavailable for.
+func (ch *Checker) Check(ctx context.Context, repair bool) (ConsistencyReport, error) {
+	report, _, err := ch.check(ctx, repair, repair)
+	return report, err
+}
+
+// check implements Check. It reports whether regenerating stopped because the
+// model server is unavailable.
+func (ch *Checker) check(ctx context.Context, repair bool, regenerate bool) (ConsistencyReport, bool, error) {
+	minAge := ch.MinAge
+	if minAge == 0 {
+		minAge = 10 * time.Minute
+	}
+	cutoff := time.Now().Add(-minAge)
+
+	report := ConsistencyReport{Checked: map[string]int{}, Orphans: map[string][]string{}}
+
+	// the classes are streamed one after another, only the IDs needed to
+	// resolve references are kept
+	each := func(class string, fn func(weaviate.Object)) error {
+		err := weaviate.EachObject(ctx, ch.Store, class, pageSize, func(object weaviate.Object) error {
+			report.Checked[class]++
+			fn(object)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not list %s objects: %w", class, err)
+		}
+		return nil
+	}
+	dangling := map[string][]string{}
+	old := func(object weaviate.Object) bool {
+		return object.CreatedAt.Before(cutoff)
+	}
+
+	responses := idSet{}
+	var oldResponses []string
+	err := each(weaviate.ResponseClass, func(response weaviate.Object) {
+		responses[response.ID] = struct{}{}
+		if old(response) {
+			oldResponses = append(oldResponses, response.ID)
+		}
+	})
+	if err != nil {
+		return report, false, err
+	}
+
+	// referenced holds the responses and semantic meanings some existing
+	// prompt points to
+	prompts := idSet{}
+	referenced := idSet{}
+	var missingResponse []weaviate.PromptSummary
+	err = each(weaviate.PromptClass, func(prompt weaviate.Object) {
+		prompts[prompt.ID] = struct{}{}
+
+		responseIDs := prompt.ReferencedIDs("hasResponse")
+		for _, id := range responseIDs {
+			referenced[id] = struct{}{}
+		}
+		for _, id := range prompt.ReferencedIDs("hasSemanticMeaning") {
+			referenced[id] = struct{}{}
+		}
+		if old(prompt) && !responses.any(responseIDs) {
+			missingResponse = append(missingResponse, promptSummary(prompt))
+		}
+	})
+	if err != nil {
+		return report, false, err
+	}
+	responses = nil
+
+	for _, id := range oldResponses {
+		if !referenced.has(id) {
+			dangling[weaviate.ResponseClass] = append(dangling[weaviate.ResponseClass], id)
+		}
+	}
+
+	err = each(weaviate.SemanticMeaningClass, func(meaning weaviate.Object) {
+		if old(meaning) && !referenced.has(meaning.ID) && !prompts.any(meaning.ReferencedIDs("hasPrompt")) {
+			dangling[weaviate.SemanticMeaningClass] = append(dangling[weaviate.SemanticMeaningClass], meaning.ID)
+		}
+	})
+	if err != nil {
+		return report, false, err
+	}
+
+	for _, class := range []string{weaviate.MessageClass, weaviate.SuggestionClass} {
+		err = each(class, func(object weaviate.Object) {
+			if old(object) && !prompts.any(object.ReferencedIDs("ofPrompt")) {
+				dangling[class] = append(dangling[class], object.ID)
+			}
+		})
+		if err != nil {
+			return report, false, err
+		}
+	}
+
+	for _, prompt := range missingResponse {
+		report.Orphans[weaviate.PromptClass] = append(report.Orphans[weaviate.PromptClass], prompt.ID)
+	}
+	for class, ids := range dangling {
+		report.Orphans[class] = ids
+	}
+
+	if !repair {
+		return report, false, nil
+	}
+
+	maxRepairs := ch.MaxRepairs
+	if maxRepairs == 0 {
+		maxRepairs = 10
+	}
+
+	unavailable := false
+	for i, prompt := range missingResponse {
+		if ch.Responses == nil {
+			break
+		}
+		if !regenerate || unavailable || i >= maxRepairs {
+			report.Skipped++
+			continue
+		}
+
+		err := ch.Responses.RepairResponse(ctx, prompt)
+		// the other prompts would fail the same way
+		unavailable = errkind.Is(err, errkind.Unavailable) || errkind.Is(err, errkind.Timeout)
+		report.record(err, "could not regenerate response of prompt %s: %v", prompt.ID)
+	}
+	for _, class := range checkedClasses {
+		for _, id := range dangling[class] {
+			err := ch.Store.DeleteObject(ctx, class, id)
+			if errkind.Is(err, errkind.NotFound) {
+				err = nil
+			}
+			report.record(err, "could not delete orphaned "+class+" %s: %v", id)
+		}
+	}
+
+	return report, unavailable, nil
+}
+
+// Run repairs orphans every interval until ctx is done. While the model
+// server is unavailable, responses are only regenerated every second, fourth
+// and so on run, up to every maxRepairPause runs.
+func (ch *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pause repairPause
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ch.runOnce(ctx, &pause)
+	}
+}
+
+// repairPause counts the runs left before responses are regenerated again
+// and the runs to wait after the next failure.
+type repairPause struct {
+	skip    int
+	backoff int
+}
+
+// runOnce repairs orphans once, regenerating responses unless pause says to
+// wait for the model server.
+func (ch *Checker) runOnce(ctx context.Context, pause *repairPause) ConsistencyReport {
+	regenerate := pause.skip == 0
+	if !regenerate {
+		pause.skip--
+	}
+
+	report, unavailable, err := ch.check(ctx, true, regenerate)
+	if err != nil {
+		log.Printf("consistency check failed: %v", err)
+		return report
+	}
+
+	switch {
+	case unavailable:
+		pause.backoff = min(max(2*pause.backoff, 1), maxRepairPause)
+		pause.skip = pause.backoff
+		log.Printf("consistency check stopped regenerating responses, the model server is unavailable; retrying in %d runs", pause.skip)
+	case regenerate:
+		pause.backoff = 0
+	}
+
+	if report.Repaired > 0 || report.Failed > 0 {
+		log.Printf("consistency check repaired %d orphans, %d failed, %d skipped", report.Repaired, report.Failed, report.Skipped)
+	}
+
+	return report
+}
+
+func (r *ConsistencyReport) record(err error, format string, id string) {
+	if err == nil {
+		r.Repaired++
+		return
+	}
+
+	r.Failed++
+	log.Printf(format, id, err)
+}
+
+// idSet holds the IDs of objects without their properties.
+type idSet map[string]struct{}
+
+func (s idSet) has(id string) bool {
+	_, ok := s[id]
+	return ok
+}
+
+// any reports whether one of ids is in s.
+func (s idSet) any(ids []string) bool {
+	for _, id := range ids {
+		if s.has(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// promptSummary reads the properties a response is generated from.
+func promptSummary(prompt weaviate.Object) weaviate.PromptSummary {
+	summary := weaviate.PromptSummary{ID: prompt.ID}
+	summary.Code, _ = prompt.Properties["code"].(string)
+	summary.Instruct, _ = prompt.Properties["instruct"].(string)
+	summary.InstructType, _ = prompt.Properties["instructType"].(string)
+	summary.GitURL, _ = prompt.Properties["gitURL"].(string)
+	summary.Model, _ = prompt.Properties["model"].(string)
+
+	return summary
+}
@@ -0,0 +1,218 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/weaviate"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
+)
+
+// fakeRepairer regenerates responses with a fixed text, or fails with err.
+type fakeRepairer struct {
+	store weaviate.Store
+	err   error
+	calls int
+}
+
+func (r *fakeRepairer) RepairResponse(ctx context.Context, prompt weaviate.PromptSummary) error {
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+	return r.store.ReplaceResponse(ctx, prompt.ID, "regenerated")
+}
+
+// orphans holds the objects left behind by writes that failed halfway.
+type orphans struct {
+	// unanswered are prompts that lost their response
+	unanswered []string
+	response   string
+	message    string
+}
+
+// newOrphans stores n prompts without response, a response and a message
+// whose prompt is gone, and a complete prompt.
+func newOrphans(t *testing.T, store weaviate.Store, n int) orphans {
+	t.Helper()
+	ctx := context.Background()
+
+	create := func(name string) string {
+		id, _, err := store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: "func " + name + "() {}", Instruct: "Explain this:", Model: "m"}, "response")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	var o orphans
+	for i := 0; i < n; i++ {
+		id := create(fmt.Sprintf("unanswered%d", i))
+		if err := store.DeleteObject(ctx, weaviate.ResponseClass, weaviate.ResponseUUID(id)); err != nil {
+			t.Fatal(err)
+		}
+		o.unanswered = append(o.unanswered, id)
+	}
+
+	deleted := create("deleted")
+	var err error
+	o.message, err = store.CreateMessageObject(ctx, weaviate.MessageObject{PromptID: deleted, Role: weaviate.RoleUser, Content: "Why?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteObject(ctx, weaviate.PromptClass, deleted); err != nil {
+		t.Fatal(err)
+	}
+	o.response = weaviate.ResponseUUID(deleted)
+
+	create("complete")
+
+	// every object is older than the minimum age of the checker
+	time.Sleep(2 * time.Millisecond)
+
+	return o
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(nil)
+	o := newOrphans(t, store, 2)
+	repairer := &fakeRepairer{store: store}
+	checker := &Checker{Store: store, Responses: repairer, MinAge: time.Millisecond}
+
+	report, err := checker.Check(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans[weaviate.PromptClass]) != 2 || len(report.Orphans[weaviate.ResponseClass]) != 1 || len(report.Orphans[weaviate.MessageClass]) != 1 {
+		t.Errorf("orphans = %v, want 2 prompts, a response and a message", report.Orphans)
+	}
+	if repairer.calls != 0 || report.Repaired != 0 {
+		t.Errorf("a check without repair regenerated %d responses and repaired %d orphans", repairer.calls, report.Repaired)
+	}
+
+	report, err = checker.Check(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 4 || report.Failed != 0 || report.Skipped != 0 {
+		t.Errorf("report = %+v, want 4 repaired orphans", report)
+	}
+
+	for _, id := range o.unanswered {
+		properties, err := store.RetrieveProperties(ctx, id)
+		if err != nil || properties.HasResponse != "regenerated" {
+			t.Errorf("prompt %s has response %q, %v, want the regenerated one", id, properties.HasResponse, err)
+		}
+	}
+	if _, err := store.RetrieveMessage(ctx, o.message); !errkind.Is(err, errkind.NotFound) {
+		t.Errorf("orphaned message still exists: %v", err)
+	}
+
+	report, err = checker.Check(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 0 {
+		t.Errorf("orphans after repair = %v, want none", report.Orphans)
+	}
+}
+
+func TestCheckYoungObjects(t *testing.T) {
+	store := memory.New(nil)
+	newOrphans(t, store, 1)
+
+	// the objects may belong to writes still in progress
+	report, err := (&Checker{Store: store, MinAge: time.Hour}).Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Orphans) != 0 || report.Repaired != 0 {
+		t.Errorf("report = %+v, want young objects left alone", report)
+	}
+}
+
+func TestCheckMaxRepairs(t *testing.T) {
+	store := memory.New(nil)
+	newOrphans(t, store, 3)
+	repairer := &fakeRepairer{store: store}
+	checker := &Checker{Store: store, Responses: repairer, MinAge: time.Millisecond, MaxRepairs: 2}
+
+	report, err := checker.Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repairer.calls != 2 || report.Skipped != 1 {
+		t.Errorf("regenerated %d responses and skipped %d, want 2 and 1", repairer.calls, report.Skipped)
+	}
+
+	// the next check picks up the rest
+	report, err = checker.Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repairer.calls != 3 || report.Skipped != 0 {
+		t.Errorf("regenerated %d responses and skipped %d, want 3 and 0", repairer.calls, report.Skipped)
+	}
+}
+
+func TestCheckUnavailable(t *testing.T) {
+	store := memory.New(nil)
+	o := newOrphans(t, store, 3)
+	repairer := &fakeRepairer{store: store, err: errkind.New(errkind.Unavailable, "model server is down")}
+	checker := &Checker{Store: store, Responses: repairer, MinAge: time.Millisecond}
+
+	report, err := checker.Check(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repairer.calls != 1 || report.Failed != 1 || report.Skipped != 2 {
+		t.Errorf("called the model %d times, report = %+v, want one failed call and two skipped prompts", repairer.calls, report)
+	}
+	// dangling objects are deleted without the model
+	if report.Repaired != 2 {
+		t.Errorf("repaired %d orphans, want the response and the message", report.Repaired)
+	}
+	if _, err := store.RetrieveMessage(context.Background(), o.message); !errkind.Is(err, errkind.NotFound) {
+		t.Errorf("orphaned message still exists: %v", err)
+	}
+}
+
+func TestRunBacksOff(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(nil)
+	newOrphans(t, store, 2)
+	repairer := &fakeRepairer{store: store, err: errkind.New(errkind.Unavailable, "model server is down")}
+	checker := &Checker{Store: store, Responses: repairer, MinAge: time.Millisecond}
+
+	// runs calling the model while it stays unavailable
+	var calls []int
+	var pause repairPause
+	for run := 0; run < 12; run++ {
+		before := repairer.calls
+		checker.runOnce(ctx, &pause)
+		if repairer.calls > before {
+			calls = append(calls, run)
+		}
+	}
+	want := []int{0, 2, 5, 10}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("runs calling the model = %v, want %v", calls, want)
+	}
+
+	// once the model is back, every run regenerates again
+	repairer.err = nil
+	for run := 0; run < 8 && repairer.calls == 4; run++ {
+		checker.runOnce(ctx, &pause)
+	}
+	report := checker.runOnce(ctx, &pause)
+	if len(report.Orphans[weaviate.PromptClass]) != 0 {
+		t.Errorf("orphans after the model is back = %v, want none", report.Orphans)
+	}
+	if pause != (repairPause{}) {
+		t.Errorf("pause = %+v after a successful run, want none", pause)
+	}
+}
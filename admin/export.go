@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// Transfer exports the whole knowledge base, the objects of every class with
+// their references, the votes and the instruct sets, and imports it again.
+// Exports use the snapshot format, so snapshots taken before resets can be
+// imported, too.
+type Transfer struct {
+	Store weaviate.Store
+	Sets  SetStore
+	Votes VoteRecords
+}
+
+// VoteRecords holds the vote of every client and the tallies of prompts and
+// messages.
+type VoteRecords interface {
+	GetVotes(ctx context.Context, promptID string) (map[string]redis.Vote, error)
+	GetVoteTally(ctx context.Context, promptID string) (tally redis.VoteTally, ok bool, err error)
+	RestoreVotes(ctx context.Context, promptID string, votes map[string]redis.Vote, tally redis.VoteTally) error
+}
+
+// ExportOptions selects what an export contains besides the objects and the
+// instruct sets. Without vectors the importing deployment vectorizes the
+// objects again.
+type ExportOptions struct {
+	Vectors bool
+}
+
+// TransferSummary counts the objects of every class, the instruct sets and
+// the prompts and messages with votes an export or import covered.
+type TransferSummary struct {
+	Objects map[string]int `json:"objects"`
+	Sets    int            `json:"sets"`
+	Votes   int            `json:"votes"`
+}
+
+// Export writes everything as newline delimited JSON to w.
+func (t *Transfer) Export(ctx context.Context, w io.Writer, opts ExportOptions) (TransferSummary, error) {
+	summary := TransferSummary{Objects: map[string]int{}}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	now := time.Now().UTC()
+	err := enc.Encode(snapshotRecord{Type: "export", Version: SnapshotVersion, CreatedAt: &now, Vectors: opts.Vectors})
+	if err != nil {
+		return summary, err
+	}
+
+	list := t.Store.ListObjects
+	if opts.Vectors {
+		list = t.Store.ListObjectsWithVectors
+	}
+
+	for _, class := range weaviate.Classes {
+		after := ""
+		for {
+			objects, err := list(ctx, class, after, pageSize)
+			if err != nil {
+				return summary, fmt.Errorf("could not list %s objects: %w", class, err)
+			}
+
+			for i := range objects {
+				err = enc.Encode(snapshotRecord{Type: "object", Object: &objects[i]})
+				if err != nil {
+					return summary, err
+				}
+
+				if class != weaviate.PromptClass && class != weaviate.MessageClass {
+					continue
+				}
+				voted, err := t.exportVotes(ctx, enc, objects[i].ID)
+				if err != nil {
+					return summary, fmt.Errorf("could not export the votes of %s %s: %w", class, objects[i].ID, err)
+				}
+				if voted {
+					summary.Votes++
+				}
+			}
+			summary.Objects[class] += len(objects)
+
+			if len(objects) < pageSize {
+				break
+			}
+			after = objects[len(objects)-1].ID
+		}
+	}
+
+	sets, err := t.Sets.Sets(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("could not read instruct sets: %w", err)
+	}
+
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		members := sets[name]
+		sort.Strings(members)
+		err = enc.Encode(snapshotRecord{Type: "set", Name: name, Members: members})
+		if err != nil {
+			return summary, err
+		}
+	}
+	summary.Sets = len(names)
+
+	return summary, bw.Flush()
+}
+
+// exportVotes writes the votes of the prompt or message id, if anybody voted
+// for it.
+func (t *Transfer) exportVotes(ctx context.Context, enc *json.Encoder, id string) (bool, error) {
+	tally, ok, err := t.Votes.GetVoteTally(ctx, id)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	clients, err := t.Votes.GetVotes(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	record := &voteRecord{Base: tally.Base, Up: tally.Up, Down: tally.Down, Clients: clients}
+	return true, enc.Encode(snapshotRecord{Type: "votes", ID: id, Votes: record})
+}
+
+// Import reads an export or a snapshot from r and stores its objects under
+// their IDs, the members of its instruct sets and the votes. Objects, members
+// and votes that exist already are overwritten, so an import can be repeated
+// after it stopped halfway.
+func (t *Transfer) Import(ctx context.Context, r io.Reader) (TransferSummary, error) {
+	summary := TransferSummary{Objects: map[string]int{}}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header snapshotRecord
+	err := dec.Decode(&header)
+	if errors.Is(err, io.EOF) {
+		return summary, errkind.New(errkind.Invalid, "import is empty")
+	}
+	if err != nil {
+		return summary, errkind.New(errkind.Invalid, "invalid header: %w", err)
+	}
+	if header.Type != "export" && header.Type != "snapshot" {
+		return summary, errkind.New(errkind.Invalid, "expected an export or a snapshot, got %q", header.Type)
+	}
+	if header.Version < 1 || header.Version > SnapshotVersion {
+		return summary, errkind.New(errkind.Invalid, "unsupported version %d, expected at most %d", header.Version, SnapshotVersion)
+	}
+
+	known := map[string]bool{}
+	for _, class := range weaviate.Classes {
+		known[class] = true
+	}
+
+	var batch []weaviate.Object
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := t.Store.ImportObjects(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, object := range batch {
+			summary.Objects[object.Class]++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for line := 2; ; line++ {
+		var record snapshotRecord
+		err = dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return summary, errkind.New(errkind.Invalid, "invalid record %d: %w", line, err)
+		}
+
+		switch record.Type {
+		case "object":
+			object := record.Object
+			if object == nil || !known[object.Class] {
+				return summary, errkind.New(errkind.Invalid, "record %d holds no object of a known class", line)
+			}
+			if _, err := uuid.Parse(object.ID); err != nil {
+				return summary, errkind.New(errkind.Invalid, "record %d: invalid ID %q", line, object.ID)
+			}
+
+			batch = append(batch, *object)
+			if len(batch) == pageSize {
+				err = flush()
+				if err != nil {
+					return summary, err
+				}
+			}
+		case "set":
+			for _, member := range record.Members {
+				err = t.Sets.AddSetMember(ctx, record.Name, member)
+				if err != nil {
+					return summary, fmt.Errorf("could not import instruct set %s: %w", record.Name, err)
+				}
+			}
+			summary.Sets++
+		case "votes":
+			if _, err := uuid.Parse(record.ID); err != nil || record.Votes == nil {
+				return summary, errkind.New(errkind.Invalid, "record %d holds no votes of a valid ID", line)
+			}
+
+			votes := record.Votes
+			tally := redis.VoteTally{Base: votes.Base, Up: votes.Up, Down: votes.Down}
+			err = t.Votes.RestoreVotes(ctx, record.ID, votes.Clients, tally)
+			if err != nil {
+				return summary, fmt.Errorf("could not import the votes of %s: %w", record.ID, err)
+			}
+			summary.Votes++
+		default:
+			return summary, errkind.New(errkind.Invalid, "record %d has unknown type %q", line, record.Type)
+		}
+	}
+
+	return summary, flush()
+}
@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/redis"
+	redismemory "github.com/rwth-acis/modernizer/redis/memory"
+	"github.com/rwth-acis/modernizer/weaviate"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
+)
+
+// listAll returns the objects of every class by ID.
+func listAll(t *testing.T, store weaviate.Store) map[string]weaviate.Object {
+	t.Helper()
+
+	objects := map[string]weaviate.Object{}
+	for _, class := range weaviate.Classes {
+		err := weaviate.EachObject(context.Background(), store, class, pageSize, func(object weaviate.Object) error {
+			objects[object.ID] = object
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return objects
+}
+
+func TestTransferRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New(nil)
+	sets := redismemory.New()
+	sets.InitRedis(ctx)
+
+	promptID, _, err := store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: "func one() int { return 1 }", Instruct: "Explain this:", InstructType: "developer", Model: "m"}, "It returns one.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageID, err := store.CreateMessageObject(ctx, weaviate.MessageObject{PromptID: promptID, Role: weaviate.RoleAssistant, Content: "Because.", Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the prompt was ranked 3 before votes were recorded per client
+	for client, vote := range map[string]redis.Vote{"token:alice": redis.Upvote, "ip:192.0.2.1": redis.Downvote} {
+		if _, err := sets.CastVote(ctx, promptID, client, vote, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sets.CastVote(ctx, messageID, "token:alice", redis.Upvote, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported bytes.Buffer
+	summary, err := (&Transfer{Store: store, Sets: sets, Votes: sets}).Export(ctx, &exported, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Votes != 2 {
+		t.Errorf("exported the votes of %d prompts and messages, want 2", summary.Votes)
+	}
+
+	// creation times survive, so resets with a cutoff select the same objects
+	time.Sleep(time.Millisecond)
+
+	imported := memory.New(nil)
+	importedSets := redismemory.New()
+	transfer := &Transfer{Store: imported, Sets: importedSets, Votes: importedSets}
+	summary, err = transfer.Import(ctx, bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Votes != 2 || summary.Sets != len(redis.DefaultSets) {
+		t.Errorf("imported %d vote records and %d sets, want 2 and %d", summary.Votes, summary.Sets, len(redis.DefaultSets))
+	}
+
+	want := listAll(t, store)
+	got := listAll(t, imported)
+	if len(got) != len(want) {
+		t.Fatalf("imported %d objects, want %d", len(got), len(want))
+	}
+	for id, object := range want {
+		if !got[id].CreatedAt.Equal(object.CreatedAt) {
+			t.Errorf("%s %s was created at %s, want %s", object.Class, id, got[id].CreatedAt, object.CreatedAt)
+		}
+	}
+
+	for _, id := range []string{promptID, messageID} {
+		wantTally, _, _ := sets.GetVoteTally(ctx, id)
+		gotTally, ok, err := importedSets.GetVoteTally(ctx, id)
+		if err != nil || !ok || gotTally != wantTally {
+			t.Errorf("tally of %s is %+v, %v, %v, want %+v", id, gotTally, ok, err, wantTally)
+		}
+
+		wantVotes, _ := sets.GetVotes(ctx, id)
+		gotVotes, _ := importedSets.GetVotes(ctx, id)
+		if !reflect.DeepEqual(gotVotes, wantVotes) {
+			t.Errorf("votes of %s are %v, want %v", id, gotVotes, wantVotes)
+		}
+	}
+
+	// a client who voted before the export replaces its vote
+	tally, err := importedSets.CastVote(ctx, promptID, "token:alice", redis.Upvote, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally != (redis.VoteTally{Base: 3, Up: 1, Down: 1}) {
+		t.Errorf("tally after voting again is %+v, want the exported one", tally)
+	}
+
+	// importing twice changes nothing
+	_, err = transfer.Import(ctx, bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := listAll(t, imported); len(again) != len(want) {
+		t.Errorf("second import left %d objects, want %d", len(again), len(want))
+	}
+}
+
+func TestImportVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{name: "current", header: `{"type":"export","version":2}`},
+		{name: "without votes", header: `{"type":"export","version":1}`},
+		{name: "snapshot", header: `{"type":"snapshot","version":2}`},
+		{name: "no version", header: `{"type":"export"}`, wantErr: true},
+		{name: "newer", header: `{"type":"export","version":3}`, wantErr: true},
+		{name: "unknown type", header: `{"type":"backup","version":2}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sets := redismemory.New()
+			transfer := &Transfer{Store: memory.New(nil), Sets: sets, Votes: sets}
+
+			_, err := transfer.Import(context.Background(), strings.NewReader(tt.header+"\n"))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Import() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errkind.Is(err, errkind.Invalid) {
+				t.Errorf("Import() = %v, want an invalid input error", err)
+			}
+		})
+	}
+}
+
+func TestImportInvalidVotes(t *testing.T) {
+	sets := redismemory.New()
+	transfer := &Transfer{Store: memory.New(nil), Sets: sets, Votes: sets}
+
+	input := `{"type":"export","version":2}` + "\n" + `{"type":"votes","id":"not-a-uuid","votes":{"base":1}}` + "\n"
+	_, err := transfer.Import(context.Background(), strings.NewReader(input))
+	if !errkind.Is(err, errkind.Invalid) {
+		t.Errorf("Import() = %v, want an invalid input error", err)
+	}
+}
@@ -0,0 +1,347 @@
+// Package admin implements the maintenance operations behind the admin API.
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// Scope selects which data a reset deletes.
+type Scope string
+
+const (
+	// ScopePrompts deletes prompts together with their responses, semantic
+	// meanings, conversations and suggestions.
+	ScopePrompts Scope = "prompts"
+	// ScopeResponses deletes responses only. The prompts stay without votes
+	// and their responses are regenerated by the consistency check or the
+	// backfill command.
+	ScopeResponses Scope = "responses"
+	// ScopeInstructs restores the default instruct sets.
+	ScopeInstructs Scope = "instructs"
+	// ScopeAll deletes everything. Without a cutoff the schema and the
+	// instruct sets are recreated from scratch.
+	ScopeAll Scope = "all"
+)
+
+// SetStore holds the instruct sets.
+type SetStore interface {
+	Sets(ctx context.Context) (map[string][]string, error)
+	AddSetMember(ctx context.Context, set string, instruct string) error
+	DeleteAllSets(ctx context.Context) error
+	InitRedis(ctx context.Context)
+}
+
+// VoteStore holds the votes of prompts and messages.
+type VoteStore interface {
+	DeleteVotes(ctx context.Context, ids ...string) error
+	DeleteAllVotes(ctx context.Context) error
+}
+
+// ResponseCache maps generation requests to the prompts answering them.
+type ResponseCache interface {
+	ForgetPrompts(ctx context.Context, promptIDs ...string) error
+	Clear(ctx context.Context) error
+}
+
+// ResetRequest describes a reset. OlderThan restricts it to objects created
+// before the given time.
+type ResetRequest struct {
+	Scope     Scope      `json:"scope"`
+	OlderThan *time.Time `json:"olderThan,omitempty"`
+}
+
+func (req ResetRequest) Validate() error {
+	switch req.Scope {
+	case ScopePrompts, ScopeResponses, ScopeAll:
+	case ScopeInstructs:
+		if req.OlderThan != nil {
+			return errkind.New(errkind.Invalid, "instruct sets have no creation time, olderThan cannot be used")
+		}
+	default:
+		return errkind.New(errkind.Invalid, "unknown scope %q, expected prompts, responses, instructs or all", req.Scope)
+	}
+
+	return nil
+}
+
+// Plan lists how many objects of every class, how many instruct sets and the
+// votes of how many prompts and messages a reset deletes.
+type Plan struct {
+	Request ResetRequest   `json:"request"`
+	Objects map[string]int `json:"objects"`
+	Sets    int            `json:"sets"`
+	Votes   int            `json:"votes"`
+}
+
+// Result is a completed reset with the snapshot taken before it.
+type Result struct {
+	Plan
+	Snapshot string `json:"snapshot"`
+}
+
+// Resetter deletes data selectively and exports a snapshot of everything it
+// is about to delete first. The votes and the cache entries of deleted
+// prompts go with them, they are not part of the snapshot.
+type Resetter struct {
+	Store weaviate.Store
+	Sets  SetStore
+	Votes VoteStore
+	// Cache is nil if responses are not cached.
+	Cache       ResponseCache
+	SnapshotDir string
+}
+
+// pageSize is the number of objects fetched at once while collecting.
+const pageSize = 100
+
+// selection is the data a reset deletes, objects in deletion order.
+type selection struct {
+	objects map[string][]weaviate.Object
+	sets    map[string][]string
+	// dropSchema recreates the classes instead of deleting object by object
+	dropSchema bool
+	// voted lists the prompts and messages whose votes are deleted, cached
+	// the prompts whose cache entries are dropped
+	voted  []string
+	cached []string
+	// unvoted lists the prompts that stay but lose their votes and their
+	// references to their response because it is deleted
+	unvoted []string
+}
+
+func (sel *selection) plan(req ResetRequest) Plan {
+	plan := Plan{Request: req, Objects: map[string]int{}, Sets: len(sel.sets), Votes: len(sel.voted)}
+	for _, class := range weaviate.Classes {
+		plan.Objects[class] = len(sel.objects[class])
+	}
+	return plan
+}
+
+// Plan previews the reset without changing anything.
+func (r *Resetter) Plan(ctx context.Context, req ResetRequest) (Plan, error) {
+	sel, err := r.collect(ctx, req)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return sel.plan(req), nil
+}
+
+// Reset exports a snapshot of the selected data and deletes it. Nothing is
+// deleted if the snapshot cannot be written.
+func (r *Resetter) Reset(ctx context.Context, req ResetRequest) (Result, error) {
+	sel, err := r.collect(ctx, req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Plan: sel.plan(req)}
+
+	result.Snapshot, err = r.writeSnapshot(req, sel)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not write snapshot, nothing was deleted: %w", err)
+	}
+	log.Printf("wrote snapshot %s before %s reset", result.Snapshot, req.Scope)
+
+	if sel.sets != nil {
+		err = r.Sets.DeleteAllSets(ctx)
+		if err != nil {
+			return result, fmt.Errorf("could not delete instruct sets: %w", err)
+		}
+		r.Sets.InitRedis(ctx)
+	}
+
+	if sel.dropSchema {
+		r.Store.DeleteAllClasses(ctx)
+		err = r.Store.InitSchema(ctx)
+		if err != nil {
+			return result, fmt.Errorf("could not recreate schema: %w", err)
+		}
+		return result, r.forget(ctx, sel)
+	}
+
+	for _, class := range weaviate.Classes {
+		for _, object := range sel.objects[class] {
+			err = r.Store.DeleteObject(ctx, class, object.ID)
+			if err != nil {
+				return result, fmt.Errorf("could not delete %s %s: %w", class, object.ID, err)
+			}
+		}
+	}
+
+	return result, r.forget(ctx, sel)
+}
+
+// forget deletes the votes and the cache entries of the selection and resets
+// the votes stored on prompts that lost their responses.
+func (r *Resetter) forget(ctx context.Context, sel *selection) error {
+	if sel.dropSchema {
+		err := r.Votes.DeleteAllVotes(ctx)
+		if err != nil {
+			return fmt.Errorf("could not delete votes: %w", err)
+		}
+		if r.Cache != nil {
+			err = r.Cache.Clear(ctx)
+			if err != nil {
+				return fmt.Errorf("could not clear the response cache: %w", err)
+			}
+		}
+		return nil
+	}
+
+	err := r.Votes.DeleteVotes(ctx, sel.voted...)
+	if err != nil {
+		return fmt.Errorf("could not delete votes: %w", err)
+	}
+	for _, id := range sel.unvoted {
+		// a prompt has one response, which was deleted
+		err = r.Store.ClearResponsesPrompt(ctx, id)
+		if err != nil {
+			return fmt.Errorf("could not clear the response of prompt %s: %w", id, err)
+		}
+		// the rank of a prompt nobody voted on
+		err = r.Store.SetVotesPrompt(ctx, id, weaviate.Votes{Rank: 1})
+		if err != nil {
+			return fmt.Errorf("could not reset the votes of prompt %s: %w", id, err)
+		}
+	}
+	if r.Cache != nil {
+		err = r.Cache.ForgetPrompts(ctx, sel.cached...)
+		if err != nil {
+			return fmt.Errorf("could not clear the response cache: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Resetter) collect(ctx context.Context, req ResetRequest) (*selection, error) {
+	err := req.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	sel := &selection{objects: map[string][]weaviate.Object{}}
+
+	if req.Scope == ScopeInstructs || (req.Scope == ScopeAll && req.OlderThan == nil) {
+		sel.sets, err = r.Sets.Sets(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Scope == ScopeInstructs {
+		return sel, nil
+	}
+
+	sel.dropSchema = req.Scope == ScopeAll && req.OlderThan == nil
+
+	// the classes are streamed one after another, only the selected objects
+	// and the IDs needed to follow references are kept
+	selected := idSet{}
+	each := func(class string, fn func(weaviate.Object) bool) error {
+		err := weaviate.EachObject(ctx, r.Store, class, pageSize, func(object weaviate.Object) error {
+			if !selected.has(object.ID) && fn(object) {
+				selected[object.ID] = struct{}{}
+				sel.objects[class] = append(sel.objects[class], object)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not list %s objects: %w", class, err)
+		}
+		return nil
+	}
+	older := func(object weaviate.Object) bool {
+		return req.OlderThan == nil || object.CreatedAt.Before(*req.OlderThan)
+	}
+	prompts := req.Scope == ScopePrompts || req.Scope == ScopeAll
+	responses := req.Scope == ScopeResponses || req.Scope == ScopeAll
+	all := req.Scope == ScopeAll
+
+	// votes and cached answers belong to the responses, so they go with
+	// the prompt as well as with its responses
+	forget := func(promptID string) {
+		sel.voted = append(sel.voted, promptID)
+		sel.cached = append(sel.cached, promptID)
+	}
+
+	// the responses and semantic meanings of selected prompts go with them,
+	// owner maps the responses of the other prompts to their prompt
+	referenced := idSet{}
+	owner := map[string]string{}
+	err = each(weaviate.PromptClass, func(prompt weaviate.Object) bool {
+		if !prompts || !older(prompt) {
+			for _, id := range prompt.ReferencedIDs("hasResponse") {
+				owner[id] = prompt.ID
+			}
+			return false
+		}
+		for _, id := range prompt.ReferencedIDs("hasResponse") {
+			referenced[id] = struct{}{}
+		}
+		for _, id := range prompt.ReferencedIDs("hasSemanticMeaning") {
+			referenced[id] = struct{}{}
+		}
+		forget(prompt.ID)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	unanswered := idSet{}
+	err = each(weaviate.ResponseClass, func(response weaviate.Object) bool {
+		if referenced.has(response.ID) {
+			return true
+		}
+		if !responses || !older(response) {
+			return false
+		}
+		if promptID, ok := owner[response.ID]; ok && !unanswered.has(promptID) {
+			unanswered[promptID] = struct{}{}
+			forget(promptID)
+			sel.unvoted = append(sel.unvoted, promptID)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	owner = nil
+
+	err = each(weaviate.SemanticMeaningClass, func(meaning weaviate.Object) bool {
+		return referenced.has(meaning.ID) || (all && older(meaning))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// conversations and suggestions go with the prompt they are about
+	for _, class := range []string{weaviate.MessageClass, weaviate.SuggestionClass} {
+		err = each(class, func(object weaviate.Object) bool {
+			return selected.any(object.ReferencedIDs("ofPrompt")) || (all && older(object))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, message := range sel.objects[weaviate.MessageClass] {
+		sel.voted = append(sel.voted, message.ID)
+	}
+
+	err = each(weaviate.AnalysisClass, func(analysis weaviate.Object) bool {
+		return all && older(analysis)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sel, nil
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rwth-acis/modernizer/redis"
+	redismemory "github.com/rwth-acis/modernizer/redis/memory"
+	"github.com/rwth-acis/modernizer/weaviate"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
+)
+
+func TestResetForgetsVotesAndCache(t *testing.T) {
+	tests := []struct {
+		scope Scope
+		// cutoff selects the older of the two prompts only
+		cutoff bool
+		// forgotten lists the prompts that lose their votes and cache entries
+		forgotten []string
+		// kept lists the prompts that still exist afterwards
+		kept []string
+	}{
+		{scope: ScopePrompts, cutoff: true, forgotten: []string{"old"}, kept: []string{"new"}},
+		{scope: ScopeResponses, cutoff: true, forgotten: []string{"old"}, kept: []string{"old", "new"}},
+		{scope: ScopeAll, cutoff: true, forgotten: []string{"old"}, kept: []string{"new"}},
+		{scope: ScopeAll, forgotten: []string{"old", "new"}},
+	}
+
+	for _, tt := range tests {
+		name := string(tt.scope)
+		if tt.cutoff {
+			name += " older than"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New(nil)
+			votes := redismemory.New()
+			cache := redismemory.NewCache()
+
+			ids := map[string]string{}
+			var cutoff time.Time
+			for _, name := range []string{"old", "new"} {
+				if name == "new" {
+					time.Sleep(time.Millisecond)
+					cutoff = time.Now()
+					time.Sleep(time.Millisecond)
+				}
+
+				id, _, err := store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: "func " + name + "() {}", Instruct: "Explain this:", Model: "m"}, "response")
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids[name] = id
+
+				if _, err := votes.CastVote(ctx, id, "token:alice", redis.Upvote, 1); err != nil {
+					t.Fatal(err)
+				}
+				if err := store.SetVotesPrompt(ctx, id, weaviate.Votes{Rank: 2, Upvotes: 1}); err != nil {
+					t.Fatal(err)
+				}
+				if err := cache.Remember(ctx, name, id); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resetter := &Resetter{Store: store, Sets: votes, Votes: votes, Cache: cache, SnapshotDir: t.TempDir()}
+			req := ResetRequest{Scope: tt.scope}
+			if tt.cutoff {
+				req.OlderThan = &cutoff
+			}
+			result, err := resetter.Reset(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Votes != len(tt.forgotten) {
+				t.Errorf("plan deletes the votes of %d prompts, want %d", result.Votes, len(tt.forgotten))
+			}
+
+			forgotten := map[string]bool{}
+			for _, name := range tt.forgotten {
+				forgotten[name] = true
+			}
+			for name, id := range ids {
+				_, voted, err := votes.GetVoteTally(ctx, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if voted == forgotten[name] {
+					t.Errorf("%s prompt has a vote tally: %v, want %v", name, voted, !forgotten[name])
+				}
+
+				_, cached, err := cache.Lookup(ctx, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cached == forgotten[name] {
+					t.Errorf("%s prompt is cached: %v, want %v", name, cached, !forgotten[name])
+				}
+			}
+
+			objects := listAll(t, store)
+			for _, name := range tt.kept {
+				prompt, ok := objects[ids[name]]
+				if !ok {
+					t.Fatalf("%s prompt was deleted", name)
+				}
+				want := 2
+				if forgotten[name] {
+					want = 1
+				}
+				if rank, _ := prompt.Properties["rank"].(int); rank != want {
+					t.Errorf("%s prompt has rank %v, want %d", name, prompt.Properties["rank"], want)
+				}
+				// a prompt whose response was deleted must not reference it
+				for _, id := range prompt.ReferencedIDs("hasResponse") {
+					if _, ok := objects[id]; !ok {
+						t.Errorf("%s prompt references the deleted response %s", name, id)
+					}
+				}
+			}
+		})
+	}
+}
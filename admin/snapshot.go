@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// SnapshotVersion is the version of the format of the snapshots written by
+// Reset and of exports, which share it. Version 2 added the votes of prompts
+// and messages, files of version 1 are still imported.
+const SnapshotVersion = 2
+
+// snapshotRecord is one line of a snapshot or an export. The first line
+// describes the file, every following line holds an object, an instruct set
+// or the votes of a prompt or message.
+type snapshotRecord struct {
+	Type string `json:"type"`
+
+	Version   int           `json:"version,omitempty"`
+	Request   *ResetRequest `json:"request,omitempty"`
+	CreatedAt *time.Time    `json:"createdAt,omitempty"`
+	Vectors   bool          `json:"vectors,omitempty"`
+
+	Object *weaviate.Object `json:"object,omitempty"`
+
+	Name    string   `json:"name,omitempty"`
+	Members []string `json:"members,omitempty"`
+
+	ID    string      `json:"id,omitempty"`
+	Votes *voteRecord `json:"votes,omitempty"`
+}
+
+// voteRecord holds the vote of every client for a prompt or message and the
+// tally derived from them. Base is the rank a prompt had before votes were
+// recorded per client.
+type voteRecord struct {
+	Base    int                   `json:"base"`
+	Up      int                   `json:"upvotes"`
+	Down    int                   `json:"downvotes"`
+	Clients map[string]redis.Vote `json:"clients,omitempty"`
+}
+
+// writeSnapshot exports the selection as newline delimited JSON into the
+// snapshot directory and returns the path of the file.
+func (r *Resetter) writeSnapshot(req ResetRequest, sel *selection) (string, error) {
+	err := os.MkdirAll(r.SnapshotDir, 0o750)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("snapshot-%s-%s.ndjson", now.Format("20060102T150405.000Z"), req.Scope)
+	path := filepath.Join(r.SnapshotDir, name)
+
+	// write to a temporary file first so a crash never leaves a truncated
+	// snapshot behind that looks complete
+	tmp, err := os.CreateTemp(r.SnapshotDir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	err = enc.Encode(snapshotRecord{Type: "snapshot", Version: SnapshotVersion, Request: &req, CreatedAt: &now})
+	if err != nil {
+		return "", err
+	}
+
+	for _, class := range weaviate.Classes {
+		for i := range sel.objects[class] {
+			err = enc.Encode(snapshotRecord{Type: "object", Object: &sel.objects[class][i]})
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(sel.sets))
+	for name := range sel.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = enc.Encode(snapshotRecord{Type: "set", Name: name, Members: sel.sets[name]})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return "", err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return "", err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
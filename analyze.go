@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ollama"
+)
+
+// Limits of the analysis API. Larger inputs are rejected instead of tying up
+// the LLM for hours.
+const (
+	maxFileSize    = 1 << 20
+	maxArchiveSize = 64 << 20
+	maxRepoFiles   = 500
+)
+
+// skippedDirs are directories of an archive that hold no code of the
+// repository itself.
+var skippedDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "third_party": true,
+	"dist": true, "build": true, "__pycache__": true, ".venv": true,
+}
+
+// checkSource fails unless content is non-empty UTF-8 text.
+func checkSource(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errkind.New(errkind.Invalid, "file is empty")
+	}
+	if !utf8.ValidString(content) {
+		return errkind.New(errkind.Invalid, "file is not UTF-8 text")
+	}
+	return nil
+}
+
+// readArchive extracts the source files of a tar archive, which may be gzip
+// compressed. Files in skippedDirs, files of unknown languages and files that
+// are not UTF-8 text are left out. A single top level directory, as created by
+// git archive or GitHub downloads, is stripped from the paths.
+func readArchive(r io.Reader) ([]ollama.SourceFile, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, errors.New("archive is empty")
+	}
+
+	var tr *tar.Reader
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		tr = tar.NewReader(gz)
+	} else {
+		tr = tar.NewReader(br)
+	}
+
+	var files []ollama.SourceFile
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if skipped(name) || header.Size > maxFileSize {
+			continue
+		}
+		if codeanalysis.Detect(name, "") == codeanalysis.Unknown {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if !utf8.Valid(content) || strings.TrimSpace(string(content)) == "" {
+			continue
+		}
+
+		if len(files) == maxRepoFiles {
+			return nil, fmt.Errorf("archive contains more than %d source files", maxRepoFiles)
+		}
+		files = append(files, ollama.SourceFile{Path: name, Content: string(content)})
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("archive contains no source files of a supported language")
+	}
+
+	stripCommonRoot(files)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+func skipped(name string) bool {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if skippedDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCommonRoot removes the top level directory from all paths if every
+// file is inside it.
+func stripCommonRoot(files []ollama.SourceFile) {
+	root, _, ok := strings.Cut(files[0].Path, "/")
+	if !ok {
+		return
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Path, root+"/") {
+			return
+		}
+	}
+	for i := range files {
+		files[i].Path = strings.TrimPrefix(files[i].Path, root+"/")
+	}
+}
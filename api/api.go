@@ -0,0 +1,67 @@
+// Package api describes version 1 of the HTTP API: the request and response
+// bodies, the error envelope and the OpenAPI document generated from them.
+//
+// Fields of request types tagged uri are path parameters and fields tagged
+// form are query parameters, both are left out of the body with json:"-".
+// Body fields are required unless they are omitempty.
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Version is the version of the API served under Prefix.
+const (
+	Version = "1.0.0"
+	Prefix  = "/api/v1"
+)
+
+// ErrorCode classifies errors so that clients need not parse messages.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest  ErrorCode = "invalid_request"
+	CodeNotFound        ErrorCode = "not_found"
+	CodeTooLarge        ErrorCode = "too_large"
+	CodeContextOverflow ErrorCode = "context_overflow"
+	CodeConflict        ErrorCode = "conflict"
+	CodeUnauthorized    ErrorCode = "unauthorized"
+	CodeForbidden       ErrorCode = "forbidden"
+	CodeUnavailable     ErrorCode = "unavailable"
+	CodeTimeout         ErrorCode = "timeout"
+	CodeInternal        ErrorCode = "internal"
+)
+
+// Error is the body of every failed request, wrapped in ErrorResponse.
+// Details carries additional machine readable information for some codes.
+type Error struct {
+	Status  int         `json:"-"`
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf creates an error answered with the given status.
+func Errorf(status int, code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidRequest reports a request that does not match its description.
+func InvalidRequest(format string, args ...interface{}) *Error {
+	return Errorf(http.StatusBadRequest, CodeInvalidRequest, format, args...)
+}
+
+// NotFound reports a missing object.
+func NotFound(format string, args ...interface{}) *Error {
+	return Errorf(http.StatusNotFound, CodeNotFound, format, args...)
+}
+
+// ErrorResponse is the envelope of Error.
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
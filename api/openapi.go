@@ -0,0 +1,370 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route documents an endpoint. Request holds the parameters and the body as
+// described in the package comment, Response the body answered with Status.
+// Both are nil if there is none.
+type Route struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	// Description adds details to the summary, like known limitations.
+	Description string
+	Tag         string
+	Request     reflect.Type
+	Response    reflect.Type
+	Status      int
+	// BodyType replaces the JSON body by a raw body of the given media type.
+	BodyType string
+	// Stream is set if the endpoint answers with Server-Sent Events when the
+	// request asks for it.
+	Stream bool
+}
+
+// OpenAPIPath returns the path with parameters in braces instead of the
+// colon syntax of the router.
+func (r Route) OpenAPIPath() string {
+	segments := strings.Split(r.Path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Document is an OpenAPI 3 document, limited to the parts used here.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// NewDocument generates the document describing routes from their request
+// and response types.
+func NewDocument(title string, routes []Route) *Document {
+	g := &generator{
+		schemas: map[string]*Schema{},
+		names:   map[reflect.Type]string{},
+	}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: title, Version: Version},
+		Servers: []Server{{URL: Prefix}},
+		Paths:   map[string]map[string]*Operation{},
+	}
+
+	errorResponse := Response{
+		Description: "The request failed",
+		Content:     jsonContent(g.schema(reflect.TypeOf(ErrorResponse{}))),
+	}
+
+	for _, route := range routes {
+		operation := &Operation{
+			OperationID: route.OperationID,
+			Summary:     route.Summary,
+			Description: route.Description,
+			Responses:   map[string]Response{"default": errorResponse},
+		}
+		if route.Tag != "" {
+			operation.Tags = []string{route.Tag}
+		}
+
+		if route.Request != nil {
+			operation.Parameters = g.parameters(route.Request)
+			if route.BodyType == "" && HasBody(route.Request) {
+				operation.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.schema(route.Request))}
+			}
+		}
+		if route.BodyType != "" {
+			operation.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{route.BodyType: {Schema: &Schema{Type: "string", Format: "binary"}}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(status)}
+		if route.Response != nil {
+			success.Content = jsonContent(g.schema(route.Response))
+		}
+		if route.Stream {
+			if success.Content == nil {
+				success.Content = map[string]MediaType{}
+			}
+			success.Content["text/event-stream"] = MediaType{Schema: &Schema{Type: "string"}}
+		}
+		operation.Responses[strconv.Itoa(status)] = success
+
+		path := route.OpenAPIPath()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+
+	doc.Components.Schemas = g.schemas
+
+	return doc
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// HasBody reports whether a request type has fields read from the body.
+func HasBody(t reflect.Type) bool {
+	has := false
+	eachField(t, func(field reflect.StructField) {
+		if _, ok := field.Tag.Lookup("uri"); ok {
+			return
+		}
+		if _, ok := field.Tag.Lookup("form"); ok {
+			return
+		}
+		if field.Tag.Get("json") != "-" {
+			has = true
+		}
+	})
+	return has
+}
+
+// eachField calls fn for every exported field of the struct t, descending
+// into embedded structs.
+func eachField(t reflect.Type, fn func(field reflect.StructField)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			eachField(field.Type, fn)
+			continue
+		}
+		fn(field)
+	}
+}
+
+// generator collects the schemas of named types as components.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (g *generator) parameters(t reflect.Type) []Parameter {
+	var parameters []Parameter
+	eachField(t, func(field reflect.StructField) {
+		if name, ok := field.Tag.Lookup("uri"); ok {
+			parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: g.fieldSchema(field)})
+			return
+		}
+		if tag, ok := field.Tag.Lookup("form"); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			parameters = append(parameters, Parameter{Name: name, In: "query", Required: required(field), Schema: g.fieldSchema(field)})
+		}
+	})
+	return parameters
+}
+
+// required reports whether the binding tag of field requires it.
+func required(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldSchema adds the values allowed by the binding and form tags of field
+// to the schema of its type.
+func (g *generator) fieldSchema(field reflect.StructField) *Schema {
+	schema := g.schema(field.Type)
+	if schema.Ref != "" {
+		return schema
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			schema.Enum = strings.Fields(values)
+		}
+	}
+	for _, option := range strings.Split(field.Tag.Get("form"), ",")[1:] {
+		value, ok := strings.CutPrefix(option, "default=")
+		if !ok {
+			continue
+		}
+		schema.Default = value
+		switch schema.Type {
+		case "integer":
+			schema.Default, _ = strconv.Atoi(value)
+		case "boolean":
+			schema.Default, _ = strconv.ParseBool(value)
+		}
+	}
+
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		return g.structSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	default:
+		// interfaces hold any value
+		return &Schema{}
+	}
+}
+
+// structSchema returns a reference to the component of a named struct, which
+// is generated on first use, or the schema of an anonymous one.
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.objectSchema(t)
+	}
+
+	if name, ok := g.names[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// registered before its fields, which may refer to it again
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.objectSchema(t)
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *generator) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	eachField(t, func(field reflect.StructField) {
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			return
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = g.fieldSchema(field)
+		if required(field) || !strings.Contains(","+options+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+
+	return schema
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Paging is embedded like the exported query types of the package.
+type Paging struct {
+	Limit int    `form:"limit,default=20" json:"-"`
+	After string `form:"after" json:"-"`
+}
+
+type testNode struct {
+	Name     string            `json:"name"`
+	Children []testNode        `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+type testRequest struct {
+	Paging
+	ID      string      `uri:"id" json:"-"`
+	Model   string      `form:"model" binding:"omitempty,oneof=a b" json:"-"`
+	Code    string      `json:"code" binding:"required"`
+	Comment string      `json:"comment,omitempty"`
+	Data    []byte      `json:"data,omitempty"`
+	Tree    *testNode   `json:"tree,omitempty"`
+	Since   time.Time   `json:"since"`
+	Extra   interface{} `json:"extra,omitempty"`
+	Ranks   []float64   `json:"ranks,omitempty"`
+	Counts  [2]int64    `json:"counts,omitempty"`
+	Done    bool        `json:"done,omitempty"`
+	Nodes   []testNode  `json:"nodes,omitempty"`
+}
+
+// toJSON marshals v for comparison.
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument("test", []Route{
+		{
+			Method:      http.MethodPost,
+			Path:        "/things/:id",
+			OperationID: "createThing",
+			Tag:         "things",
+			Request:     reflect.TypeOf(testRequest{}),
+			Response:    reflect.TypeOf(testNode{}),
+			Status:      http.StatusCreated,
+			Stream:      true,
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/things/:id/file",
+			OperationID: "uploadThing",
+			Request: reflect.TypeOf(struct {
+				ID string `uri:"id" json:"-"`
+			}{}),
+			BodyType: "application/zip",
+		},
+	})
+
+	if doc.Servers[0].URL != Prefix || doc.Info.Version != Version {
+		t.Errorf("document is not served under %s in version %s: %+v %+v", Prefix, Version, doc.Servers, doc.Info)
+	}
+
+	create := doc.Paths["/things/{id}"]["post"]
+	if create == nil {
+		t.Fatalf("paths = %v, want POST /things/{id}", doc.Paths)
+	}
+	wantParameters := `[` +
+		`{"name":"limit","in":"query","schema":{"type":"integer","default":20}},` +
+		`{"name":"after","in":"query","schema":{"type":"string"}},` +
+		`{"name":"id","in":"path","required":true,"schema":{"type":"string"}},` +
+		`{"name":"model","in":"query","schema":{"type":"string","enum":["a","b"]}}]`
+	if got := toJSON(t, create.Parameters); got != wantParameters {
+		t.Errorf("parameters =\n%s\nwant\n%s", got, wantParameters)
+	}
+	if create.Tags[0] != "things" || create.RequestBody == nil || !create.RequestBody.Required {
+		t.Errorf("operation = %+v, want a tag and a required body", create)
+	}
+	created := create.Responses["201"]
+	if created.Content["application/json"].Schema.Ref != "#/components/schemas/testNode" || created.Content["text/event-stream"].Schema == nil {
+		t.Errorf("201 response = %s, want a testNode and an event stream", toJSON(t, created))
+	}
+	if create.Responses["default"].Content["application/json"].Schema.Ref != "#/components/schemas/ErrorResponse" {
+		t.Errorf("default response = %s, want the error envelope", toJSON(t, create.Responses["default"]))
+	}
+
+	request := doc.Components.Schemas["testRequest"]
+	if request == nil {
+		t.Fatalf("schemas = %v, want testRequest", doc.Components.Schemas)
+	}
+	// only the required and not omitted fields are required, parameters are
+	// not part of the body
+	if got, want := toJSON(t, request.Required), `["code","since"]`; got != want {
+		t.Errorf("required = %s, want %s", got, want)
+	}
+	if _, ok := request.Properties["limit"]; ok {
+		t.Error("query parameter limit is part of the body")
+	}
+	wantProperties := map[string]string{
+		"data":   `{"type":"string","format":"byte"}`,
+		"since":  `{"type":"string","format":"date-time"}`,
+		"tree":   `{"$ref":"#/components/schemas/testNode"}`,
+		"extra":  `{}`,
+		"ranks":  `{"type":"array","items":{"type":"number"}}`,
+		"counts": `{"type":"array","items":{"type":"integer","format":"int64"}}`,
+		"done":   `{"type":"boolean"}`,
+	}
+	for name, want := range wantProperties {
+		if got := toJSON(t, request.Properties[name]); got != want {
+			t.Errorf("property %s = %s, want %s", name, got, want)
+		}
+	}
+
+	// recursive types refer to their own component
+	node := doc.Components.Schemas["testNode"]
+	if got, want := toJSON(t, node.Properties["children"]), `{"type":"array","items":{"$ref":"#/components/schemas/testNode"}}`; got != want {
+		t.Errorf("children = %s, want %s", got, want)
+	}
+	if got, want := toJSON(t, node.Properties["labels"]), `{"type":"object","additionalProperties":{"type":"string"}}`; got != want {
+		t.Errorf("labels = %s, want %s", got, want)
+	}
+
+	upload := doc.Paths["/things/{id}/file"]["put"]
+	if upload == nil {
+		t.Fatalf("paths = %v, want PUT /things/{id}/file", doc.Paths)
+	}
+	if got, want := toJSON(t, upload.RequestBody), `{"required":true,"content":{"application/zip":{"schema":{"type":"string","format":"binary"}}}}`; got != want {
+		t.Errorf("upload body = %s, want %s", got, want)
+	}
+	if _, ok := upload.Responses["200"]; !ok {
+		t.Errorf("upload responses = %v, want 200 by default", upload.Responses)
+	}
+}
+
+func TestHasBody(t *testing.T) {
+	if HasBody(reflect.TypeOf(Paging{})) {
+		t.Error("HasBody() = true for a type with only query parameters")
+	}
+	if !HasBody(reflect.TypeOf(&testRequest{})) {
+		t.Error("HasBody() = false for a type with body fields")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	err := InvalidRequest("limit must be at most %d", 100)
+	if err.Status != http.StatusBadRequest || err.Code != CodeInvalidRequest || err.Error() != "limit must be at most 100" {
+		t.Errorf("InvalidRequest() = %+v", err)
+	}
+	if got, want := toJSON(t, ErrorResponse{Error: NotFound("prompt %s not found", "p")}), `{"error":{"code":"not_found","message":"prompt p not found"}}`; got != want {
+		t.Errorf("envelope = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// HealthResponse reports the state of every backing service.
+type HealthResponse struct {
+	Weaviate string `json:"weaviate"`
+	Redis    string `json:"redis"`
+}
+
+// CodeQuery selects stored prompts by their code. Match is exact, hash or
+// similar, Function and Repo restrict the prompts to those about a function
+// or from a repository. Without Code all prompts about Function are selected.
+type CodeQuery struct {
+	Code     string `form:"code" json:"-" binding:"required_without=Function"`
+	Match    string `form:"match" json:"-"`
+	Function string `form:"function" json:"-"`
+	Repo     string `form:"repo" json:"-"`
+	Model    string `form:"model" json:"-"`
+}
+
+type PromptCountResponse struct {
+	Count int `json:"count"`
+}
+
+type ResponseListQuery struct {
+	CodeQuery
+	InstructType string `form:"instructType" json:"-"`
+}
+
+type ResponseListResponse struct {
+	Responses []string `json:"responses"`
+}
+
+type PromptPath struct {
+	PromptID string `uri:"promptID" json:"-" binding:"required"`
+}
+
+type PromptResponse struct {
+	PromptID string `json:"promptID"`
+	Response string `json:"response"`
+}
+
+type SimilarMeaningQuery struct {
+	Meaning string `form:"meaning" json:"-" binding:"required"`
+}
+
+// SimilarResponse lists the repositories of prompts with a similar semantic
+// meaning.
+type SimilarResponse struct {
+	GitURLs []string `json:"gitURLs"`
+}
+
+type InstructTypesResponse struct {
+	InstructTypes []string `json:"instructTypes"`
+}
+
+// GenerateRequest asks for the answer to a prompt. Without Instruct a random
+// instruct of InstructType is used. Force skips the response cache, RAG adds
+// up to RAGExamples upvoted answers about similar code as examples and
+// Structured answers with suggestions carrying patches.
+type GenerateRequest struct {
+	Code         string `json:"code" binding:"required"`
+	Instruct     string `json:"instruct,omitempty"`
+	InstructType string `json:"instructType,omitempty"`
+	GitURL       string `json:"gitURL,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Force        bool   `json:"force,omitempty"`
+	RAG          bool   `json:"rag,omitempty"`
+	RAGExamples  int    `json:"ragExamples,omitempty"`
+	Structured   bool   `json:"structured,omitempty"`
+	Stream       bool   `json:"stream,omitempty"`
+}
+
+// Vote is up, down or none to withdraw an earlier vote.
+type VoteRequest struct {
+	PromptID string `json:"promptID" binding:"required"`
+	Vote     string `json:"vote" binding:"required,oneof=up down none"`
+}
+
+// VoteResponse holds the vote of the client together with the votes of all
+// clients.
+type VoteResponse struct {
+	PromptID  string `json:"promptID"`
+	MessageID string `json:"messageID,omitempty"`
+	Vote      string `json:"vote"`
+	Upvotes   int    `json:"upvotes"`
+	Downvotes int    `json:"downvotes"`
+	Rank      int    `json:"rank"`
+}
+
+type InstructSetsResponse struct {
+	Sets []string `json:"sets"`
+}
+
+// InstructSetQuery reads a random instruct of a set, or all with All.
+type InstructSetQuery struct {
+	Set string `uri:"set" json:"-" binding:"required"`
+	All bool   `form:"all" json:"-"`
+}
+
+type InstructsResponse struct {
+	Set       string   `json:"set"`
+	Instructs []string `json:"instructs"`
+}
+
+type AddInstructRequest struct {
+	Set      string `uri:"set" json:"-" binding:"required"`
+	Instruct string `json:"instruct" binding:"required"`
+}
+
+type DeleteInstructRequest struct {
+	Set      string `uri:"set" json:"-" binding:"required"`
+	Instruct string `form:"instruct" json:"-" binding:"required"`
+}
+
+type AnalyzeFileRequest struct {
+	Path       string `json:"path,omitempty"`
+	Content    string `json:"content" binding:"required"`
+	Instruct   string `json:"instruct,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+// AnalyzeRepoQuery holds the options of a repository analysis, whose body is
+// the tarball of the repository.
+type AnalyzeRepoQuery struct {
+	Instruct   string `form:"instruct" json:"-"`
+	Model      string `form:"model" json:"-"`
+	Repository string `form:"repository" json:"-"`
+}
+
+type AnalyzeRepoResponse struct {
+	ID    string `json:"id"`
+	Files int    `json:"files"`
+}
+
+// AnalysisQuery reads an analysis with its parts down to Depth.
+type AnalysisQuery struct {
+	ID    string `uri:"id" json:"-" binding:"required"`
+	Depth int    `form:"depth,default=1" json:"-" binding:"min=0"`
+}
+
+type MessageRequest struct {
+	PromptID string `uri:"promptID" json:"-" binding:"required"`
+	Content  string `json:"content" binding:"required"`
+	Model    string `json:"model,omitempty"`
+	Stream   bool   `json:"stream,omitempty"`
+}
+
+type MessagesResponse struct {
+	PromptID string             `json:"promptID"`
+	Messages []weaviate.Message `json:"messages"`
+}
+
+type MessageVoteRequest struct {
+	PromptID  string `uri:"promptID" json:"-" binding:"required"`
+	MessageID string `uri:"messageID" json:"-" binding:"required"`
+	Vote      string `json:"vote" binding:"required,oneof=up down none"`
+}
+
+type SuggestionsResponse struct {
+	PromptID    string                `json:"promptID"`
+	Suggestions []weaviate.Suggestion `json:"suggestions"`
+}
+
+type SuggestionPath struct {
+	PromptID     string `uri:"promptID" json:"-" binding:"required"`
+	SuggestionID string `uri:"suggestionID" json:"-" binding:"required"`
+}
+
+// SuggestionResponse holds a suggestion with the code of the prompt after
+// applying it, if its diff applies.
+type SuggestionResponse struct {
+	Suggestion weaviate.Suggestion `json:"suggestion"`
+	Patched    string              `json:"patched,omitempty"`
+}
@@ -0,0 +1,603 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/ollama"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// endpoint couples a documented route of the versioned API with its handler.
+type endpoint struct {
+	api.Route
+	handler gin.HandlerFunc
+}
+
+// typed creates an endpoint from a handler taking the bound request and
+// returning the response body, which is answered with the status of route.
+func typed[Req any, Resp any](route api.Route, fn func(c *gin.Context, req *Req) (Resp, error)) endpoint {
+	route = documented[Req, Resp](route)
+
+	return endpoint{Route: route, handler: func(c *gin.Context) {
+		var req Req
+		if err := bindRequest(c, &req); err != nil {
+			c.Error(err)
+			return
+		}
+
+		resp, err := fn(c, &req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if route.Response == nil {
+			c.Status(route.Status)
+			return
+		}
+		c.JSON(route.Status, resp)
+	}}
+}
+
+// documented fills in the request and response types of route. Empty structs
+// stand for no parameters or no response body.
+func documented[Req any, Resp any](route api.Route) api.Route {
+	if t := reflect.TypeOf((*Req)(nil)).Elem(); t.Kind() != reflect.Struct || t.NumField() > 0 {
+		route.Request = t
+	}
+	if t := reflect.TypeOf((*Resp)(nil)).Elem(); t.Kind() != reflect.Struct || t.NumField() > 0 {
+		route.Response = t
+	}
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+	return route
+}
+
+// bindRequest fills req from the path parameters, the query and the JSON body
+// as described in the api package, and validates it once all are read.
+func bindRequest(c *gin.Context, req interface{}) error {
+	params := make(map[string][]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = []string{param.Value}
+	}
+	if err := binding.MapFormWithTag(req, params, "uri"); err != nil {
+		return api.InvalidRequest("invalid path parameter: %v", err)
+	}
+
+	if err := binding.MapFormWithTag(req, c.Request.URL.Query(), "form"); err != nil {
+		return api.InvalidRequest("invalid query parameter: %v", err)
+	}
+
+	if api.HasBody(reflect.TypeOf(req)) {
+		err := json.NewDecoder(c.Request.Body).Decode(req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return api.InvalidRequest("invalid request body: %v", err)
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return api.InvalidRequest("%v", err)
+	}
+
+	return nil
+}
+
+// successors maps the routes that predate the versioned API to their
+// replacements. They keep working but announce their successor.
+var successors = map[string]string{
+	"/weaviate/promptcount":             "/api/v1/prompts/count",
+	"/weaviate/retrieveresponse":        "/api/v1/responses/best",
+	"/weaviate/retrieveresponselist":    "/api/v1/responses",
+	"/weaviate/responsebyid":            "/api/v1/prompts/{promptID}/response",
+	"/weaviate/propertiesbyid":          "/api/v1/prompts/{promptID}",
+	"/get-similar-meaning":              "/api/v1/similar/meaning",
+	"/get-similar-code":                 "/api/v1/similar/code",
+	"/get-instructtype":                 "/api/v1/instruct-types",
+	"/models":                           "/api/v1/models",
+	"/generate":                         "/api/v1/generate",
+	"/vote":                             "/api/v1/votes",
+	"/get-instruct":                     "/api/v1/instruct-sets/{set}",
+	"/add-instruct":                     "/api/v1/instruct-sets/{set}/instructs",
+	"/del-instruct":                     "/api/v1/instruct-sets/{set}/instructs",
+	"/get-all-sets":                     "/api/v1/instruct-sets",
+	"/analyze/file":                     "/api/v1/analyze/file",
+	"/analyze/repo":                     "/api/v1/analyze/repo",
+	"/analyze/:id":                      "/api/v1/analyze/{id}",
+	"/conversations/:promptID/messages": "/api/v1/conversations/{promptID}/messages",
+	"/conversations/:promptID/messages/:messageID/vote": "/api/v1/conversations/{promptID}/messages/{messageID}/vote",
+	"/suggestions/:promptID":                            "/api/v1/suggestions/{promptID}",
+	"/suggestions/:promptID/:suggestionID":              "/api/v1/suggestions/{promptID}/{suggestionID}",
+}
+
+// deprecated marks the answers of deprecated routes with the Deprecation
+// header and links their successor.
+func deprecated(c *gin.Context) {
+	if successor, ok := successors[c.FullPath()]; ok {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+	c.Next()
+}
+
+// registerV1 serves the versioned API together with its OpenAPI document. It
+// returns the handlers by their operation ID.
+func (s *server) registerV1(router *gin.Engine) map[string]gin.HandlerFunc {
+	endpoints := s.v1Endpoints()
+
+	routes := make([]api.Route, 0, len(endpoints))
+	handlers := make(map[string]gin.HandlerFunc, len(endpoints))
+	group := router.Group(api.Prefix)
+	for _, e := range endpoints {
+		group.Handle(e.Method, e.Path, e.handler)
+		routes = append(routes, e.Route)
+		handlers[e.OperationID] = e.handler
+	}
+
+	document := api.NewDocument("modernizer", routes)
+	group.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+
+	return handlers
+}
+
+// voterDescription documents how the vote routes tell voters apart.
+const voterDescription = "Requests with an API token vote as the token, anonymous requests as their client address. " +
+	"Anonymous clients behind the same address therefore share one vote."
+
+func (s *server) v1Endpoints() []endpoint {
+	return []endpoint{
+		typed(api.Route{Method: http.MethodGet, Path: "/health", OperationID: "health", Summary: "Report the state of the backing services", Tag: "service"}, s.v1Health),
+		typed(api.Route{Method: http.MethodGet, Path: "/models", OperationID: "listModels", Summary: "List the models prompts may ask for", Tag: "service"}, s.v1Models),
+
+		typed(api.Route{Method: http.MethodGet, Path: "/prompts/count", OperationID: "countPrompts", Summary: "Count the prompts about code", Tag: "prompts"}, s.v1PromptCount),
+		typed(api.Route{Method: http.MethodGet, Path: "/prompts/:promptID", OperationID: "getPrompt", Summary: "Get the properties of a prompt", Tag: "prompts"}, s.v1Prompt),
+		typed(api.Route{Method: http.MethodGet, Path: "/prompts/:promptID/response", OperationID: "getPromptResponse", Summary: "Get the response to a prompt", Tag: "prompts"}, s.v1PromptResponse),
+		typed(api.Route{Method: http.MethodGet, Path: "/responses", OperationID: "listResponses", Summary: "List the responses to prompts about code", Tag: "prompts"}, s.v1ResponseList),
+		typed(api.Route{Method: http.MethodGet, Path: "/responses/best", OperationID: "getBestResponse", Summary: "Get the best rated response to a prompt about code", Tag: "prompts"}, s.v1BestResponse),
+		typed(api.Route{Method: http.MethodGet, Path: "/responses/random", OperationID: "getRandomResponse", Summary: "Get a random response to a prompt about code", Tag: "prompts"}, s.v1RandomResponse),
+		typed(api.Route{Method: http.MethodGet, Path: "/instruct-types", OperationID: "listInstructTypes", Summary: "List the instruct types of the prompts about code", Tag: "prompts"}, s.v1InstructTypes),
+		typed(api.Route{Method: http.MethodGet, Path: "/similar/meaning", OperationID: "similarByMeaning", Summary: "Find repositories with code of a similar meaning", Tag: "prompts"}, s.v1SimilarMeaning),
+		typed(api.Route{Method: http.MethodGet, Path: "/similar/code", OperationID: "similarByCode", Summary: "Find repositories with code similar to code", Tag: "prompts"}, s.v1SimilarCode),
+
+		{Route: documented[api.GenerateRequest, weaviate.ResponseData](api.Route{Method: http.MethodPost, Path: "/generate", OperationID: "generate", Summary: "Answer a prompt about code", Tag: "generation", Stream: true}), handler: s.v1Generate},
+		typed(api.Route{Method: http.MethodPost, Path: "/votes", OperationID: "vote", Summary: "Vote on the response to a prompt", Description: voterDescription, Tag: "generation"}, s.v1Vote),
+		typed(api.Route{Method: http.MethodGet, Path: "/votes/:promptID", OperationID: "getVotes", Summary: "Get the votes on the response to a prompt", Description: voterDescription, Tag: "generation"}, s.v1Votes),
+
+		typed(api.Route{Method: http.MethodGet, Path: "/instruct-sets", OperationID: "listInstructSets", Summary: "List the instruct sets", Tag: "instructs"}, s.v1InstructSets),
+		typed(api.Route{Method: http.MethodGet, Path: "/instruct-sets/:set", OperationID: "getInstructs", Summary: "Get a random or all instructs of a set", Tag: "instructs"}, s.v1Instructs),
+		typed(api.Route{Method: http.MethodPost, Path: "/instruct-sets/:set/instructs", OperationID: "addInstruct", Summary: "Add an instruct to a set", Tag: "instructs", Status: http.StatusCreated}, s.v1AddInstruct),
+		typed(api.Route{Method: http.MethodDelete, Path: "/instruct-sets/:set/instructs", OperationID: "deleteInstruct", Summary: "Remove an instruct from a set", Tag: "instructs", Status: http.StatusNoContent}, s.v1DeleteInstruct),
+
+		typed(api.Route{Method: http.MethodPost, Path: "/analyze/file", OperationID: "analyzeFile", Summary: "Summarize a file function by function", Tag: "analysis"}, s.v1AnalyzeFile),
+		{Route: documented[api.AnalyzeRepoQuery, api.AnalyzeRepoResponse](api.Route{Method: http.MethodPost, Path: "/analyze/repo", OperationID: "analyzeRepository", Summary: "Start the analysis of a repository tarball", Tag: "analysis", Status: http.StatusAccepted, BodyType: "application/x-tar"}), handler: s.v1AnalyzeRepo},
+		typed(api.Route{Method: http.MethodGet, Path: "/analyze/:id", OperationID: "getAnalysis", Summary: "Get an analysis with its parts", Tag: "analysis"}, s.v1Analysis),
+
+		{Route: documented[api.MessageRequest, api.MessagesResponse](api.Route{Method: http.MethodPost, Path: "/conversations/:promptID/messages", OperationID: "postMessage", Summary: "Ask a follow-up question about a response", Tag: "conversations", Stream: true}), handler: s.v1PostMessage},
+		typed(api.Route{Method: http.MethodGet, Path: "/conversations/:promptID/messages", OperationID: "listMessages", Summary: "Get the conversation about a response", Tag: "conversations"}, s.v1Messages),
+		typed(api.Route{Method: http.MethodPost, Path: "/conversations/:promptID/messages/:messageID/vote", OperationID: "voteMessage", Summary: "Vote on an answer within a conversation", Description: voterDescription, Tag: "conversations"}, s.v1VoteMessage),
+
+		typed(api.Route{Method: http.MethodGet, Path: "/suggestions/:promptID", OperationID: "listSuggestions", Summary: "List the suggestions of a structured response", Tag: "suggestions"}, s.v1Suggestions),
+		typed(api.Route{Method: http.MethodGet, Path: "/suggestions/:promptID/:suggestionID", OperationID: "getSuggestion", Summary: "Get a suggestion with the patched code", Tag: "suggestions"}, s.v1Suggestion),
+	}
+}
+
+func (s *server) v1Health(c *gin.Context, _ *struct{}) (api.HealthResponse, error) {
+	health := api.HealthResponse{Weaviate: "ok", Redis: "ok"}
+	failed := false
+
+	if err := s.store.Health(c.Request.Context()); err != nil {
+		health.Weaviate = err.Error()
+		failed = true
+	}
+	if err := s.redis.Health(c.Request.Context()); err != nil {
+		health.Redis = err.Error()
+		failed = true
+	}
+
+	if failed {
+		e := api.Errorf(http.StatusServiceUnavailable, api.CodeUnavailable, "a backing service is unavailable")
+		e.Details = health
+		return api.HealthResponse{}, e
+	}
+
+	return health, nil
+}
+
+func (s *server) v1Models(c *gin.Context, _ *struct{}) (*registry.Registry, error) {
+	return s.models, nil
+}
+
+// match converts query into the code match of the store.
+func match(query api.CodeQuery) (weaviate.CodeMatch, error) {
+	mode, err := weaviate.ParseMatchMode(query.Match)
+	if err != nil {
+		return weaviate.CodeMatch{}, api.InvalidRequest("%v", err)
+	}
+
+	return weaviate.CodeMatch{
+		Code:       query.Code,
+		Mode:       mode,
+		Function:   query.Function,
+		Repository: query.Repo,
+	}, nil
+}
+
+func (s *server) v1PromptCount(c *gin.Context, query *api.CodeQuery) (api.PromptCountResponse, error) {
+	m, err := match(*query)
+	if err != nil {
+		return api.PromptCountResponse{}, err
+	}
+
+	count, err := s.store.RetrievePromptCount(c.Request.Context(), m, query.Model)
+	return api.PromptCountResponse{Count: count}, err
+}
+
+func (s *server) v1Prompt(c *gin.Context, path *api.PromptPath) (weaviate.PromptProperties, error) {
+	properties, err := s.store.RetrieveProperties(c.Request.Context(), path.PromptID)
+	if err != nil {
+		return weaviate.PromptProperties{}, err
+	}
+	return properties, nil
+}
+
+func (s *server) v1PromptResponse(c *gin.Context, path *api.PromptPath) (api.PromptResponse, error) {
+	response, err := s.store.RetrieveResponseByID(c.Request.Context(), path.PromptID)
+	if err != nil {
+		return api.PromptResponse{}, err
+	}
+	return api.PromptResponse{PromptID: path.PromptID, Response: response}, nil
+}
+
+func (s *server) v1ResponseList(c *gin.Context, query *api.ResponseListQuery) (api.ResponseListResponse, error) {
+	m, err := match(query.CodeQuery)
+	if err != nil {
+		return api.ResponseListResponse{}, err
+	}
+
+	responses, err := s.store.ResponseList(c.Request.Context(), m, query.InstructType, query.Model)
+	return api.ResponseListResponse{Responses: responses}, err
+}
+
+func (s *server) v1BestResponse(c *gin.Context, query *api.CodeQuery) (weaviate.ResponseData, error) {
+	m, err := match(*query)
+	if err != nil {
+		return weaviate.ResponseData{}, err
+	}
+
+	return s.store.RetrieveBestResponse(c.Request.Context(), m, query.Model, s.strategy)
+}
+
+func (s *server) v1RandomResponse(c *gin.Context, query *api.CodeQuery) (weaviate.ResponseData, error) {
+	m, err := match(*query)
+	if err != nil {
+		return weaviate.ResponseData{}, err
+	}
+
+	return s.store.RetrieveRandomResponse(c.Request.Context(), m, query.Model)
+}
+
+func (s *server) v1InstructTypes(c *gin.Context, query *api.CodeQuery) (api.InstructTypesResponse, error) {
+	m, err := match(*query)
+	if err != nil {
+		return api.InstructTypesResponse{}, err
+	}
+
+	instructTypes, err := s.store.GetInstructTypes(c.Request.Context(), m, query.Model)
+	return api.InstructTypesResponse{InstructTypes: instructTypes}, err
+}
+
+func (s *server) v1SimilarMeaning(c *gin.Context, query *api.SimilarMeaningQuery) (api.SimilarResponse, error) {
+	gitURLs, err := s.SemanticSimilarityByMeaning(c.Request.Context(), query.Meaning)
+	return api.SimilarResponse{GitURLs: gitURLs}, err
+}
+
+func (s *server) v1SimilarCode(c *gin.Context, query *api.CodeQuery) (api.SimilarResponse, error) {
+	m, err := match(*query)
+	if err != nil {
+		return api.SimilarResponse{}, err
+	}
+
+	gitURLs, err := s.SemanticSimilarityByCode(c.Request.Context(), m)
+	return api.SimilarResponse{GitURLs: gitURLs}, err
+}
+
+// generatePrompt converts req into the prompt the generator reads.
+func generatePrompt(req api.GenerateRequest) map[string]interface{} {
+	prompt := map[string]interface{}{
+		"prompt":     req.Code,
+		"gitURL":     req.GitURL,
+		"force":      req.Force,
+		"rag":        req.RAG,
+		"structured": req.Structured,
+	}
+	if req.Instruct != "" {
+		prompt["instruct"] = req.Instruct
+	}
+	if req.InstructType != "" {
+		prompt["instructType"] = req.InstructType
+	}
+	if req.Model != "" {
+		prompt["model"] = req.Model
+	}
+	if req.RAGExamples != 0 {
+		// like numbers decoded from JSON
+		prompt["ragExamples"] = float64(req.RAGExamples)
+	}
+
+	return prompt
+}
+
+func (s *server) v1Generate(c *gin.Context) {
+	var req api.GenerateRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := s.generate(c, req); err != nil {
+		c.Error(err)
+	}
+}
+
+// generate answers req, with Server-Sent Events if it asks for a stream. The
+// errors of answers that are not streamed are returned.
+func (s *server) generate(c *gin.Context, req api.GenerateRequest) error {
+	prompt := generatePrompt(req)
+
+	if req.Stream {
+		streamEvents(c, func(onToken llm.TokenHandler) (interface{}, error) {
+			return s.generator.GenerateResponseStream(c.Request.Context(), prompt, onToken)
+		})
+		return nil
+	}
+
+	response, err := s.generator.GenerateResponse(c.Request.Context(), prompt)
+	if err != nil {
+		return err
+	}
+
+	c.JSON(http.StatusOK, response)
+	return nil
+}
+
+func (s *server) v1Vote(c *gin.Context, req *api.VoteRequest) (api.VoteResponse, error) {
+	vote, err := redis.ParseVote(req.Vote)
+	if err != nil {
+		return api.VoteResponse{}, api.InvalidRequest("%v", err)
+	}
+
+	votes, err := s.castVote(c.Request.Context(), req.PromptID, s.clientID(c), vote)
+	if err != nil {
+		return api.VoteResponse{}, err
+	}
+
+	return voteResponse(req.PromptID, "", vote, votes), nil
+}
+
+func (s *server) v1Votes(c *gin.Context, path *api.PromptPath) (api.VoteResponse, error) {
+	vote, votes, err := s.currentVotes(c.Request.Context(), path.PromptID, s.clientID(c))
+	if err != nil {
+		return api.VoteResponse{}, err
+	}
+
+	return voteResponse(path.PromptID, "", vote, votes), nil
+}
+
+func voteResponse(promptID string, messageID string, vote redis.Vote, votes weaviate.Votes) api.VoteResponse {
+	return api.VoteResponse{
+		PromptID:  promptID,
+		MessageID: messageID,
+		Vote:      vote.String(),
+		Upvotes:   votes.Upvotes,
+		Downvotes: votes.Downvotes,
+		Rank:      votes.Rank,
+	}
+}
+
+func (s *server) v1InstructSets(c *gin.Context, _ *struct{}) (api.InstructSetsResponse, error) {
+	sets, err := s.redis.SetNames(c.Request.Context())
+	return api.InstructSetsResponse{Sets: sets}, err
+}
+
+func (s *server) v1Instructs(c *gin.Context, query *api.InstructSetQuery) (api.InstructsResponse, error) {
+	ctx := c.Request.Context()
+
+	if query.All {
+		instructs, err := s.redis.GetSet(ctx, query.Set)
+		return api.InstructsResponse{Set: query.Set, Instructs: instructs}, err
+	}
+
+	instruct, err := s.redis.GetSetMember(ctx, query.Set)
+	if err != nil {
+		return api.InstructsResponse{}, err
+	}
+
+	return api.InstructsResponse{Set: query.Set, Instructs: []string{instruct}}, nil
+}
+
+func (s *server) v1AddInstruct(c *gin.Context, req *api.AddInstructRequest) (api.InstructsResponse, error) {
+	ctx := c.Request.Context()
+
+	if err := s.redis.AddSetMember(ctx, req.Set, req.Instruct); err != nil {
+		return api.InstructsResponse{}, err
+	}
+
+	instructs, err := s.redis.GetSet(ctx, req.Set)
+	return api.InstructsResponse{Set: req.Set, Instructs: instructs}, err
+}
+
+func (s *server) v1DeleteInstruct(c *gin.Context, req *api.DeleteInstructRequest) (struct{}, error) {
+	return struct{}{}, s.redis.RemoveSetMember(c.Request.Context(), req.Set, req.Instruct)
+}
+
+func (s *server) v1AnalyzeFile(c *gin.Context, req *api.AnalyzeFileRequest) (weaviate.Analysis, error) {
+	if len(req.Content) > maxFileSize {
+		return weaviate.Analysis{}, api.Errorf(http.StatusRequestEntityTooLarge, api.CodeTooLarge, "file is larger than %d bytes", maxFileSize)
+	}
+	if err := checkSource(req.Content); err != nil {
+		return weaviate.Analysis{}, err
+	}
+
+	opts := ollama.AnalysisOptions{Instruct: req.Instruct, Model: req.Model, Repository: req.Repository}
+	analysis, err := s.generator.AnalyzeFile(c.Request.Context(), opts, ollama.SourceFile{Path: req.Path, Content: req.Content})
+	if err != nil && analysis.ID != "" {
+		// the analysis holds the failure together with the finished parts,
+		// the status follows from the kind of the failure as elsewhere
+		e := *apiError(err)
+		e.Details = analysis
+		return weaviate.Analysis{}, &e
+	}
+
+	return analysis, err
+}
+
+func (s *server) v1AnalyzeRepo(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize)
+
+	var query api.AnalyzeRepoQuery
+	if err := bindRequest(c, &query); err != nil {
+		c.Error(err)
+		return
+	}
+
+	resp, err := s.startAnalysis(c, query, c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", api.Prefix+"/analyze/"+resp.ID)
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// startAnalysis starts the analysis of the repository in archive.
+func (s *server) startAnalysis(c *gin.Context, query api.AnalyzeRepoQuery, archive io.Reader) (api.AnalyzeRepoResponse, error) {
+	files, err := readArchive(archive)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			err = api.InvalidRequest("%v", err)
+		}
+		return api.AnalyzeRepoResponse{}, err
+	}
+
+	opts := ollama.AnalysisOptions{Instruct: query.Instruct, Model: query.Model, Repository: query.Repository}
+	id, err := s.generator.StartRepositoryAnalysis(c.Request.Context(), opts, files)
+	if err != nil {
+		return api.AnalyzeRepoResponse{}, err
+	}
+
+	return api.AnalyzeRepoResponse{ID: id, Files: len(files)}, nil
+}
+
+func (s *server) v1Analysis(c *gin.Context, query *api.AnalysisQuery) (weaviate.Analysis, error) {
+	analysis, err := s.store.RetrieveAnalysis(c.Request.Context(), query.ID, query.Depth)
+	if err != nil {
+		return weaviate.Analysis{}, err
+	}
+	return analysis, nil
+}
+
+func (s *server) v1PostMessage(c *gin.Context) {
+	var req api.MessageRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := s.converse(c, req); err != nil {
+		c.Error(err)
+	}
+}
+
+// converse answers the follow-up question of req, with Server-Sent Events if
+// it asks for a stream. The errors of answers that are not streamed are
+// returned.
+func (s *server) converse(c *gin.Context, req api.MessageRequest) error {
+	if strings.TrimSpace(req.Content) == "" {
+		return api.InvalidRequest("content must not be empty")
+	}
+	if len(req.Content) > maxMessageSize {
+		return api.Errorf(http.StatusRequestEntityTooLarge, api.CodeTooLarge, "content is longer than %d bytes", maxMessageSize)
+	}
+
+	ctx := c.Request.Context()
+	if err := s.requireAnswered(ctx, req.PromptID); err != nil {
+		return err
+	}
+
+	converse := func(onToken llm.TokenHandler) (interface{}, error) {
+		messages, err := s.generator.Converse(ctx, req.PromptID, req.Content, req.Model, onToken)
+		return api.MessagesResponse{PromptID: req.PromptID, Messages: messages}, err
+	}
+
+	if req.Stream {
+		streamEvents(c, converse)
+		return nil
+	}
+
+	response, err := converse(nil)
+	if err != nil {
+		return err
+	}
+
+	c.JSON(http.StatusOK, response)
+	return nil
+}
+
+func (s *server) v1Messages(c *gin.Context, path *api.PromptPath) (api.MessagesResponse, error) {
+	ctx := c.Request.Context()
+	if err := s.requireAnswered(ctx, path.PromptID); err != nil {
+		return api.MessagesResponse{}, err
+	}
+
+	messages, err := s.store.RetrieveConversation(ctx, path.PromptID)
+	if messages == nil {
+		messages = []weaviate.Message{}
+	}
+	return api.MessagesResponse{PromptID: path.PromptID, Messages: messages}, err
+}
+
+func (s *server) v1VoteMessage(c *gin.Context, req *api.MessageVoteRequest) (api.VoteResponse, error) {
+	vote, err := redis.ParseVote(req.Vote)
+	if err != nil {
+		return api.VoteResponse{}, api.InvalidRequest("%v", err)
+	}
+
+	votes, err := s.voteOnMessage(c.Request.Context(), req.PromptID, req.MessageID, s.clientID(c), vote)
+	if err != nil {
+		return api.VoteResponse{}, err
+	}
+
+	return voteResponse(req.PromptID, req.MessageID, vote, votes), nil
+}
+
+func (s *server) v1Suggestions(c *gin.Context, path *api.PromptPath) (api.SuggestionsResponse, error) {
+	ctx := c.Request.Context()
+	if err := s.requireAnswered(ctx, path.PromptID); err != nil {
+		return api.SuggestionsResponse{}, err
+	}
+
+	suggestions, err := s.store.RetrieveSuggestions(ctx, path.PromptID)
+	if suggestions == nil {
+		suggestions = []weaviate.Suggestion{}
+	}
+	return api.SuggestionsResponse{PromptID: path.PromptID, Suggestions: suggestions}, err
+}
+
+func (s *server) v1Suggestion(c *gin.Context, path *api.SuggestionPath) (api.SuggestionResponse, error) {
+	return s.suggestion(c.Request.Context(), path.PromptID, path.SuggestionID)
+}
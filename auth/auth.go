@@ -0,0 +1,173 @@
+// Package auth authenticates API tokens and authorizes them by role.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// Role grants access to a group of routes. Every role includes the
+// permissions of the roles below it.
+type Role int
+
+const (
+	// RoleViewer may inspect the job queue.
+	RoleViewer Role = iota + 1
+	// RoleOperator may additionally requeue failed jobs.
+	RoleOperator
+	// RoleAdmin may additionally delete data.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole returns the role with the given name.
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if roleName == name {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q, expected viewer, operator or admin", name)
+}
+
+// Token is an API token. Only the SHA-256 hash of its secret is kept.
+type Token struct {
+	Name string
+	Role Role
+	hash [sha256.Size]byte
+}
+
+// ParseTokens parses a comma separated list of name:role:secret entries, for
+// example "ci:operator:s3cr3t,alice:admin:t0ps3cr3t".
+func ParseTokens(spec string) ([]Token, error) {
+	var tokens []Token
+	names := map[string]bool{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, errors.New("tokens must be given as name:role:secret")
+		}
+
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", parts[0], err)
+		}
+		if names[parts[0]] {
+			return nil, fmt.Errorf("token %s is defined twice", parts[0])
+		}
+		names[parts[0]] = true
+
+		tokens = append(tokens, Token{
+			Name: parts[0],
+			Role: role,
+			hash: sha256.Sum256([]byte(parts[2])),
+		})
+	}
+
+	return tokens, nil
+}
+
+// Authenticator looks up the token of a request.
+type Authenticator struct {
+	tokens []Token
+}
+
+// NewAuthenticator accepts the given tokens. Without tokens every request is
+// rejected.
+func NewAuthenticator(tokens []Token) *Authenticator {
+	return &Authenticator{tokens: tokens}
+}
+
+// Authenticate returns the token with the given secret. All tokens are
+// compared in constant time so the response time does not leak which one
+// matched.
+func (a *Authenticator) Authenticate(secret string) (Token, bool) {
+	hash := sha256.Sum256([]byte(secret))
+
+	var found Token
+	ok := false
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare(hash[:], token.hash[:]) == 1 {
+			found, ok = token, true
+		}
+	}
+
+	return found, ok
+}
+
+// tokenKey stores the authenticated Token in the gin context.
+const tokenKey = "auth.token"
+
+// Require rejects requests without a bearer token of at least the given role.
+// It sets the status and leaves the body to the error handler of the router.
+func (a *Authenticator) Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := a.Identify(c)
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.Status(http.StatusUnauthorized)
+			c.Error(errkind.New(errkind.Unauthorized, "unauthorized"))
+			c.Abort()
+			return
+		}
+
+		if token.Role < role {
+			c.Status(http.StatusForbidden)
+			c.Error(errkind.New(errkind.Forbidden, "role %s required", role))
+			c.Abort()
+			return
+		}
+
+		c.Set(tokenKey, token)
+		c.Next()
+	}
+}
+
+// Identify authenticates the bearer token of a request on routes that do not
+// require one. It reports false if the request has no valid token.
+func (a *Authenticator) Identify(c *gin.Context) (Token, bool) {
+	if token, ok := FromContext(c); ok {
+		return token, true
+	}
+
+	secret, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || secret == "" {
+		return Token{}, false
+	}
+	return a.Authenticate(secret)
+}
+
+// FromContext returns the token that authenticated the request.
+func FromContext(c *gin.Context) (Token, bool) {
+	token, ok := c.Get(tokenKey)
+	if !ok {
+		return Token{}, false
+	}
+	t, ok := token.(Token)
+	return t, ok
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]Role
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: map[string]Role{}},
+		{name: "roles", spec: "ci:operator:s3cr3t, alice:admin:t0p:s3cr3t,,bob:viewer:x", want: map[string]Role{"ci": RoleOperator, "alice": RoleAdmin, "bob": RoleViewer}},
+		{name: "unknown role", spec: "ci:root:s3cr3t", wantErr: true},
+		{name: "missing secret", spec: "ci:operator:", wantErr: true},
+		{name: "missing role", spec: "ci:s3cr3t", wantErr: true},
+		{name: "duplicate name", spec: "ci:viewer:a,ci:admin:b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := ParseTokens(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTokens(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := map[string]Role{}
+			for _, token := range tokens {
+				got[token.Name] = token.Role
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTokens(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+			for name, role := range tt.want {
+				if got[name] != role {
+					t.Errorf("token %s has role %s, want %s", name, got[name], role)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tokens, err := ParseTokens("ci:operator:s3cr3t,alice:admin:t0p:s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := NewAuthenticator(tokens)
+
+	// the secret may contain colons
+	if token, ok := authenticator.Authenticate("t0p:s3cr3t"); !ok || token.Name != "alice" {
+		t.Errorf("Authenticate() = %v, %v, want alice", token, ok)
+	}
+	for _, secret := range []string{"", "s3cr3", "S3CR3T", "ci"} {
+		if token, ok := authenticator.Authenticate(secret); ok {
+			t.Errorf("Authenticate(%q) = %v, want no token", secret, token)
+		}
+	}
+	if _, ok := NewAuthenticator(nil).Authenticate(""); ok {
+		t.Error("Authenticate() without tokens accepted a request")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens, err := ParseTokens("viewer:viewer:v,operator:operator:o,admin:admin:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := NewAuthenticator(tokens)
+
+	router := gin.New()
+	router.POST("/requeue", authenticator.Require(RoleOperator), func(c *gin.Context) {
+		token, _ := FromContext(c)
+		c.String(http.StatusOK, token.Name)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "no header", want: http.StatusUnauthorized},
+		{name: "basic auth", header: "Basic bzpv", want: http.StatusUnauthorized},
+		{name: "empty bearer", header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "unknown token", header: "Bearer x", want: http.StatusUnauthorized},
+		{name: "lower role", header: "Bearer v", want: http.StatusForbidden},
+		{name: "required role", header: "Bearer o", want: http.StatusOK},
+		{name: "higher role", header: "Bearer a", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/requeue", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("unauthorized response does not ask for a bearer token")
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range []Role{RoleViewer, RoleOperator, RoleAdmin} {
+		parsed, err := ParseRole(role.String())
+		if err != nil || parsed != role {
+			t.Errorf("ParseRole(%q) = %v, %v, want %v", role.String(), parsed, err, role)
+		}
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Error("ParseRole(root) succeeded")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/ollama"
+)
+
+// runBackfill implements the backfill command, which regenerates responses
+// and semantic meanings that were never linked to their prompt and stores the
+// code fingerprints of old prompts.
+func runBackfill(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report prompts with missing references")
+	rate := flags.Float64("rate", 30, "maximum number of LLM calls per minute, 0 disables the limit")
+	batchSize := flags.Int("batch", 100, "number of prompts fetched per page")
+	only := flags.String("only", "all", "what to repair: all, responses, semantic or fingerprints")
+	cfg := loadConfig(flags, args)
+
+	opts := ollama.BackfillOptions{
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	}
+
+	switch *only {
+	case "all":
+		opts.Responses, opts.SemanticMeanings, opts.Fingerprints = true, true, true
+	case "responses":
+		opts.Responses = true
+	case "semantic":
+		opts.SemanticMeanings = true
+	case "fingerprints":
+		opts.Fingerprints = true
+	default:
+		log.Fatalf("invalid value %q for -only, expected all, responses, semantic or fingerprints", *only)
+	}
+
+	if *rate < 0 {
+		log.Fatal("-rate must not be negative")
+	}
+	if *rate > 0 {
+		opts.Interval = time.Duration(float64(time.Minute) / *rate)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeLogged("store", store)
+
+	provider, err := llm.New(cfg.LLM.Provider, cfg.LLM.URL, cfg.LLM.APIKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	models, err := openModels(cfg)
+	if err != nil {
+		log.Fatalf("could not load model registry: %v", err)
+	}
+
+	generator := &ollama.Generator{
+		Store:    store,
+		Provider: provider,
+		Models:   models,
+	}
+
+	start := time.Now()
+	report, err := generator.Backfill(ctx, opts, func(report ollama.BackfillReport, err error) {
+		if err != nil {
+			log.Printf("backfill failed: %v", err)
+			return
+		}
+		log.Printf("progress: %s", report)
+	})
+	if err != nil {
+		log.Fatalf("backfill aborted after %s: %s: %v", time.Since(start).Round(time.Second), report, err)
+	}
+
+	if *dryRun {
+		log.Printf("dry run finished in %s: %s", time.Since(start).Round(time.Second), report)
+		return
+	}
+	log.Printf("backfill finished in %s: %s", time.Since(start).Round(time.Second), report)
+}
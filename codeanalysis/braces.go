@@ -0,0 +1,175 @@
+package codeanalysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The patterns below are matched against the collapsed header of a braced
+// block, that is everything between the end of the previous top level
+// statement and the opening brace.
+var (
+	classHeader      = regexp.MustCompile(`\b(?:class|interface|enum|record)\s+([A-Za-z_$][\w$]*)`)
+	cTypeHeader      = regexp.MustCompile(`\b(?:struct|union|enum)\s+([A-Za-z_]\w*)$`)
+	jsFunctionHeader = regexp.MustCompile(`\bfunction\b\s*\*?\s*([A-Za-z_$][\w$]*)\s*\(`)
+	jsAssignHeader   = regexp.MustCompile(`\b(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function\b.*|.*=>)$`)
+	callableHeader   = regexp.MustCompile(`([A-Za-z_$][\w$]*)\s*\((?:[^()]|\([^()]*\))*\)\s*(?:throws\s+[\w.,\s]+|const)?$`)
+)
+
+// keywords followed by a parenthesized expression and a block, which look
+// like a function header to callableHeader.
+var keywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"synchronized": true, "with": true, "return": true, "sizeof": true,
+	"function": true,
+}
+
+// analyzeBraces finds the top level blocks of Java, JavaScript or C code whose
+// header defines a class, type or function.
+func analyzeBraces(language Language, code string) []Function {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	stripped, masked := mask(language, code)
+
+	var definitions []Function
+	start := 0
+	for i := 0; i < len(masked); i++ {
+		switch masked[i] {
+		case ';', '}':
+			start = i + 1
+		case '{':
+			end := closing(masked, i)
+			fn, ok := classify(language, collapse(masked[start:i]))
+			if ok {
+				// the header starts at its first non-blank character
+				first := start + len(masked[start:i]) - len(strings.TrimLeft(masked[start:i], " \t\n"))
+				fn.Signature = collapse(stripped[start:i])
+				fn.Lines = Span{Start: lineAt(code, first), End: lineAt(code, end)}
+				fn.Body = Span{Start: lineAt(code, i), End: lineAt(code, end)}
+				definitions = append(definitions, fn)
+			}
+			i = end
+			start = end + 1
+		}
+	}
+
+	return definitions
+}
+
+func classify(language Language, header string) (Function, bool) {
+	if language != C {
+		if m := classHeader.FindStringSubmatch(header); m != nil {
+			return Function{Kind: KindClass, Name: m[1]}, true
+		}
+	} else if m := cTypeHeader.FindStringSubmatch(header); m != nil {
+		return Function{Kind: KindType, Name: m[1]}, true
+	}
+
+	if language == JavaScript {
+		if m := jsFunctionHeader.FindStringSubmatch(header); m != nil {
+			return Function{Kind: KindFunction, Name: m[1]}, true
+		}
+		if m := jsAssignHeader.FindStringSubmatch(header); m != nil {
+			return Function{Kind: KindFunction, Name: m[1]}, true
+		}
+	}
+
+	m := callableHeader.FindStringSubmatch(header)
+	if m == nil || keywords[m[1]] {
+		return Function{}, false
+	}
+
+	switch language {
+	case C:
+		return Function{Kind: KindFunction, Name: m[1]}, true
+	default:
+		// Java has no free functions and a JavaScript header of this form
+		// is a method of a class or an object literal
+		return Function{Kind: KindMethod, Name: m[1]}, true
+	}
+}
+
+// mask returns code with comments blanked out, and additionally with the
+// contents of string and character literals blanked out. C preprocessor lines
+// count as comments. Newlines are kept so offsets and lines stay valid.
+func mask(language Language, code string) (stripped string, masked string) {
+	s := []byte(code)
+	m := []byte(code)
+
+	blank := func(b []byte, from int, to int) {
+		for k := from; k < to && k < len(b); k++ {
+			if b[k] != '\n' {
+				b[k] = ' '
+			}
+		}
+	}
+
+	lineStart := true
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '/' && i+1 < len(code) && code[i+1] == '/',
+			language == C && lineStart && c == '#':
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			blank(s, i, i+end)
+			blank(m, i, i+end)
+			i += end - 1
+		case c == '/' && i+1 < len(code) && code[i+1] == '*':
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				end = len(code) - i - 2
+			}
+			blank(s, i, i+end+4)
+			blank(m, i, i+end+4)
+			i += end + 3
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for ; j < len(code) && code[j] != c; j++ {
+				if code[j] == '\\' {
+					j++
+				} else if code[j] == '\n' && c != '`' {
+					break
+				}
+			}
+			blank(m, i+1, j)
+			i = j
+		}
+
+		if i < len(code) {
+			switch code[i] {
+			case '\n':
+				lineStart = true
+			case ' ', '\t':
+			default:
+				lineStart = false
+			}
+		}
+	}
+
+	return string(s), string(m)
+}
+
+// closing returns the index of the brace closing the one at open, or the last
+// index if it is never closed.
+func closing(masked string, open int) int {
+	depth := 0
+	for i := open; i < len(masked); i++ {
+		switch masked[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(masked) - 1
+}
+
+// lineAt returns the line of the byte at offset, counted from one.
+func lineAt(code string, offset int) int {
+	return strings.Count(code[:offset], "\n") + 1
+}
@@ -0,0 +1,160 @@
+package codeanalysis
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk is a part of a file small enough to be summarized on its own.
+type Chunk struct {
+	// Function is the definition the chunk belongs to. It only carries the
+	// language for code between definitions, such as imports and globals.
+	Function Function `json:"function"`
+	// Lines is the span of the chunk within the file.
+	Lines Span   `json:"lines"`
+	Code  string `json:"-"`
+}
+
+// Split divides a file into chunks of at most maxSize bytes along its top
+// level definitions. Classes that are too large are split into their members,
+// any other definition that is too large into runs of lines. Blank code
+// between definitions and the brace closing a split class are dropped.
+func Split(filename string, code string, maxSize int) (Language, []Chunk) {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	language, defs := Definitions(filename, code)
+
+	s := splitter{language: language, lines: strings.Split(code, "\n"), maxSize: maxSize}
+	s.split(1, len(s.lines), defs)
+
+	return language, s.chunks
+}
+
+type splitter struct {
+	language Language
+	lines    []string
+	maxSize  int
+	chunks   []Chunk
+}
+
+// split chunks the lines first to last, which hold defs.
+func (s *splitter) split(first int, last int, defs []Function) {
+	next := first
+	for _, def := range defs {
+		s.byLines(next, def.Lines.Start-1, Function{Language: s.language})
+		s.definition(def)
+		next = def.Lines.End + 1
+	}
+	s.byLines(next, last, Function{Language: s.language})
+}
+
+func (s *splitter) definition(def Function) {
+	if len(s.text(def.Lines.Start, def.Lines.End)) <= s.maxSize {
+		s.add(def, def.Lines.Start, def.Lines.End)
+		return
+	}
+
+	if def.Kind == KindClass || def.Kind == KindType {
+		// members start below the line opening the body and end above the
+		// line closing it, except in Python where there are no braces
+		first, last := def.Body.Start, def.Body.End
+		if s.language != Python {
+			first, last = first+1, last-1
+		}
+
+		// a body on the header line, as in "class A: x = 1", holds the class
+		// itself, so the members must start below the first line of the
+		// definition or splitting them would find the class again forever
+		if def.Lines.Start < first && first <= last {
+			members := analyze(s.language, s.text(first, last))
+			if len(members) > 0 {
+				for i := range members {
+					members[i].Language = s.language
+					members[i].Lines = shift(members[i].Lines, first-1)
+					members[i].Body = shift(members[i].Body, first-1)
+				}
+				// the header of the class is a chunk of its own
+				s.byLines(def.Lines.Start, first-1, def)
+				s.split(first, last, members)
+				return
+			}
+		}
+	}
+
+	s.byLines(def.Lines.Start, def.Lines.End, def)
+}
+
+// byLines chunks the lines first to last into runs of at most maxSize bytes,
+// cutting lines that are longer on their own. Blank runs are dropped.
+func (s *splitter) byLines(first int, last int, fn Function) {
+	start := first
+	size := 0
+	for i := first; i <= last; i++ {
+		line := s.lines[i-1]
+		if size > 0 && size+len(line)+1 > s.maxSize {
+			s.add(fn, start, i-1)
+			start, size = i, 0
+		}
+		if len(line) > s.maxSize {
+			for _, piece := range cut(line, s.maxSize) {
+				s.chunks = append(s.chunks, Chunk{Function: fn, Lines: Span{Start: i, End: i}, Code: piece})
+			}
+			start, size = i+1, 0
+			continue
+		}
+		size += len(line) + 1
+	}
+	if start <= last {
+		s.add(fn, start, last)
+	}
+}
+
+// add appends the lines first to last as a chunk unless they are blank.
+func (s *splitter) add(fn Function, first int, last int) {
+	text := s.text(first, last)
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	s.chunks = append(s.chunks, Chunk{Function: fn, Lines: Span{Start: first, End: last}, Code: text})
+}
+
+func (s *splitter) text(first int, last int) string {
+	if first > last {
+		return ""
+	}
+	return strings.Join(s.lines[first-1:last], "\n")
+}
+
+// analyze finds the definitions of code in a known language.
+func analyze(language Language, code string) []Function {
+	switch language {
+	case Go:
+		return analyzeGo(code)
+	case Python:
+		return analyzePython(code)
+	case Java, JavaScript, C:
+		return analyzeBraces(language, code)
+	default:
+		return nil
+	}
+}
+
+func shift(span Span, lines int) Span {
+	return Span{Start: span.Start + lines, End: span.End + lines}
+}
+
+// cut splits s into pieces of at most size bytes without splitting runes.
+func cut(s string, size int) []string {
+	var pieces []string
+	for len(s) > size {
+		end := size
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		if end == 0 {
+			end = size
+		}
+		pieces = append(pieces, s[:end])
+		s = s[end:]
+	}
+	return append(pieces, s)
+}
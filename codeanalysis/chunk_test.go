@@ -0,0 +1,131 @@
+package codeanalysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// spans lists the lines and function names of chunks, for example
+// "1-3 Config".
+func spans(chunks []Chunk) []string {
+	var got []string
+	for _, chunk := range chunks {
+		got = append(got, fmt.Sprintf("%d-%d %s", chunk.Lines.Start, chunk.Lines.End, chunk.Function.Name))
+	}
+	return got
+}
+
+func TestSplit(t *testing.T) {
+	// members has methods of roughly 100 bytes each
+	members := ""
+	for _, name := range []string{"a", "b", "c"} {
+		members += "    def " + name + "(self):\n        return '" + strings.Repeat(name, 70) + "'\n\n"
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		code     string
+		maxSize  int
+		want     []string
+	}{
+		{
+			name:     "small definitions",
+			filename: "main.go",
+			code:     "package main\n\nfunc a() {}\n\nfunc b() {\n\treturn\n}\n",
+			maxSize:  256,
+			want:     []string{"1-2 ", "3-3 a", "5-7 b"},
+		},
+		{
+			name:     "python class split into members",
+			filename: "config.py",
+			code:     "class Config:\n" + members,
+			maxSize:  256,
+			want:     []string{"1-1 Config", "2-3 a", "5-6 b", "8-9 c"},
+		},
+		{
+			name:     "java class split into members",
+			filename: "A.java",
+			code:     "class A {\n    void a() {\n        run(\"" + strings.Repeat("a", 100) + "\");\n    }\n    void b() {\n        run(\"" + strings.Repeat("b", 100) + "\");\n    }\n}\n",
+			maxSize:  160,
+			want:     []string{"1-1 A", "2-4 a", "5-7 b"},
+		},
+		{
+			name:     "large function split by lines",
+			filename: "main.go",
+			code:     "func f() {\n" + strings.Repeat("\tx := 1234567890\n", 20) + "}\n",
+			maxSize:  100,
+			want:     []string{"1-6 f", "7-11 f", "12-16 f", "17-22 f"},
+		},
+		{
+			name:     "python class with its body on the header line",
+			filename: "config.py",
+			code:     "class Config: DEFAULTS = {" + strings.Repeat("'key': 'value', ", 32) + "}\n",
+			maxSize:  256,
+			want:     []string{"1-1 Config", "1-1 Config", "1-1 Config"},
+		},
+		{
+			name:     "decorated python class with its body on the header line",
+			filename: "config.py",
+			code:     "@dataclass\nclass Config: DEFAULTS = {" + strings.Repeat("'key': 'value', ", 32) + "}\n",
+			maxSize:  256,
+			want:     []string{"1-1 Config", "2-2 Config", "2-2 Config", "2-2 Config"},
+		},
+		{
+			name:     "java class on one line",
+			filename: "A.java",
+			code:     "class A { int[] values = {" + strings.Repeat("1234567, ", 40) + "}; }\n",
+			maxSize:  256,
+			want:     []string{"1-1 A", "1-1 A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, chunks := Split(tt.filename, tt.code, tt.maxSize)
+
+			if got := spans(chunks); strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range chunks {
+				if len(chunk.Code) > tt.maxSize {
+					t.Errorf("chunk %d-%d has %d bytes, want at most %d", chunk.Lines.Start, chunk.Lines.End, len(chunk.Code), tt.maxSize)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitKeepsCode(t *testing.T) {
+	code := "import os\n\n\nclass Config:\n" + strings.Repeat("    x = 1\n", 40) + "\ndef main():\n    pass\n"
+
+	_, chunks := Split("config.py", code, 64)
+
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		joined.WriteString(chunk.Code)
+	}
+	if strip := func(s string) string { return strings.Join(strings.Fields(s), "") }; strip(joined.String()) != strip(code) {
+		t.Errorf("chunks hold %q, want all code of %q", joined.String(), code)
+	}
+}
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int
+		want []string
+	}{
+		{s: "abcdef", size: 4, want: []string{"abcd", "ef"}},
+		{s: "abcd", size: 4, want: []string{"abcd"}},
+		// ä takes two bytes and must not be split
+		{s: "abcäd", size: 4, want: []string{"abc", "äd"}},
+	}
+
+	for _, tt := range tests {
+		if got := cut(tt.s, tt.size); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("cut(%q, %d) = %q, want %q", tt.s, tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package codeanalysis detects the language of a code snippet and extracts
+// the function or class it defines, so prompts can be identified by what the
+// code is rather than by its literal text.
+package codeanalysis
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Language is a programming language the analysis understands.
+type Language string
+
+const (
+	Go         Language = "go"
+	Python     Language = "python"
+	Java       Language = "java"
+	JavaScript Language = "javascript"
+	C          Language = "c"
+	// Unknown is the language of code that could not be recognized.
+	Unknown Language = "unknown"
+)
+
+// Kind is the kind of definition a Function describes.
+type Kind string
+
+const (
+	KindFunction Kind = "function"
+	KindMethod   Kind = "method"
+	KindClass    Kind = "class"
+	KindType     Kind = "type"
+)
+
+// Span is a range of lines, both inclusive and counted from one.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Function describes the first top level definition of a snippet.
+type Function struct {
+	Language Language `json:"language"`
+	Kind     Kind     `json:"kind,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	// Signature is the header of the definition with whitespace collapsed,
+	// for example "func (s *Store) Close() error".
+	Signature string `json:"signature,omitempty"`
+	// Lines is the span of the whole definition and Body the span of its
+	// body within the snippet.
+	Lines Span `json:"lines"`
+	Body  Span `json:"body"`
+}
+
+// ErrNoDefinition is returned by Analyze if the code defines no function or
+// class.
+var ErrNoDefinition = errors.New("no function or class definition found")
+
+// extensions maps file extensions to languages. TypeScript is close enough to
+// JavaScript for the definitions the analysis looks for.
+var extensions = map[string]Language{
+	".go":   Go,
+	".py":   Python,
+	".pyw":  Python,
+	".java": Java,
+	".js":   JavaScript,
+	".jsx":  JavaScript,
+	".mjs":  JavaScript,
+	".cjs":  JavaScript,
+	".ts":   JavaScript,
+	".tsx":  JavaScript,
+	".c":    C,
+	".h":    C,
+}
+
+// contentHints recognize a language by its typical constructs, in the order
+// they are tried.
+var contentHints = []struct {
+	language Language
+	pattern  *regexp.Regexp
+}{
+	{Go, regexp.MustCompile(`(?m)^\s*(package\s+\w+|func\s*(\([^)]*\)\s*)?\w+\s*\(|type\s+\w+\s+(struct|interface)\b)`)},
+	{Python, regexp.MustCompile(`(?m)^\s*((async\s+)?def\s+\w+\s*\(|class\s+\w+.*:\s*(#.*)?$)`)},
+	{Java, regexp.MustCompile(`(?m)^\s*((public|private|protected)\s+|@\w+|import\s+java\.|package\s+[\w.]+;)`)},
+	{JavaScript, regexp.MustCompile(`(?m)(\bfunction\b|=>|^\s*(const|let|var)\s+\w+\s*=|^\s*(export|import)\s)`)},
+	{C, regexp.MustCompile(`(?m)(^\s*#\s*(include|define)\b|^\s*\w[\w\s\*]*\b\w+\s*\([^;{]*\)\s*\{)`)},
+}
+
+// Detect determines the language of code. The extension of filename decides
+// if it is known, otherwise the content is inspected.
+func Detect(filename string, code string) Language {
+	if language, ok := extensions[strings.ToLower(path.Ext(filename))]; ok {
+		return language
+	}
+
+	for _, hint := range contentHints {
+		if hint.pattern.MatchString(code) {
+			return hint.language
+		}
+	}
+
+	return Unknown
+}
+
+// Analyze detects the language of code and describes its first top level
+// definition. filename may be empty. The returned Function always carries the
+// detected language, even together with ErrNoDefinition.
+func Analyze(filename string, code string) (Function, error) {
+	language, definitions := Definitions(filename, code)
+	if len(definitions) == 0 {
+		return Function{Language: language}, ErrNoDefinition
+	}
+
+	return definitions[0], nil
+}
+
+// Definitions detects the language of code and describes all of its top level
+// definitions in the order they appear.
+func Definitions(filename string, code string) (Language, []Function) {
+	language := Detect(filename, code)
+
+	definitions := analyze(language, code)
+	for i := range definitions {
+		definitions[i].Language = language
+	}
+
+	return language, definitions
+}
+
+// collapse joins the fields of s with single spaces.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,151 @@
+package codeanalysis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		filename string
+		code     string
+		want     Language
+	}{
+		{filename: "main.go", code: "def f(): pass", want: Go},
+		{filename: "src/App.TSX", want: JavaScript},
+		{filename: "lib.h", want: C},
+		{code: "package main\n\nfunc main() {}\n", want: Go},
+		{code: "func (s *Store) Close() error {\n\treturn nil\n}\n", want: Go},
+		{code: "type Store struct {\n\tdb *sql.DB\n}\n", want: Go},
+		{code: "async def fetch(url):\n    return await get(url)\n", want: Python},
+		{code: "class Config(Base):  # settings\n    pass\n", want: Python},
+		{code: "public class Main {\n}\n", want: Java},
+		{code: "@Override\nString name() { return \"a\"; }\n", want: Java},
+		{code: "const add = (a, b) => a + b;\n", want: JavaScript},
+		{code: "function add(a, b) {\n  return a + b;\n}\n", want: JavaScript},
+		{code: "#include <stdio.h>\n", want: C},
+		{code: "int main(void) {\n  return 0;\n}\n", want: C},
+		{filename: "notes.txt", code: "just some words", want: Unknown},
+		{code: "", want: Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.filename, tt.code); got != tt.want {
+			t.Errorf("Detect(%q, %q) = %s, want %s", tt.filename, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		code     string
+		want     []Function
+	}{
+		{
+			name: "go functions and methods",
+			code: "package p\n\n// Add adds.\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc (s *Store) Close() error { return nil }\n",
+			want: []Function{
+				{Language: Go, Kind: KindFunction, Name: "Add", Signature: "func Add(a, b int) int", Lines: Span{3, 6}, Body: Span{4, 6}},
+				{Language: Go, Kind: KindMethod, Name: "Close", Signature: "func (s *Store) Close() error", Lines: Span{8, 8}, Body: Span{8, 8}},
+			},
+		},
+		{
+			name: "go types without package clause",
+			code: "type Store struct {\n\tdb *sql.DB\n}\n\ntype ID string\n\nfunc New() *Store {\n\treturn &Store{}\n}\n",
+			want: []Function{
+				{Language: Go, Kind: KindType, Name: "Store", Signature: "type Store struct", Lines: Span{1, 3}, Body: Span{1, 3}},
+				{Language: Go, Kind: KindType, Name: "ID", Signature: "type ID", Lines: Span{5, 5}, Body: Span{5, 5}},
+				{Language: Go, Kind: KindFunction, Name: "New", Signature: "func New() *Store", Lines: Span{7, 9}, Body: Span{7, 9}},
+			},
+		},
+		{
+			name: "python functions and classes",
+			code: "import os\n\n@cached\n# loads the settings\ndef load(path,\n         strict=False):\n    return os.read(path)\n\n\nclass Config(Base):\n    def get(self):\n        return 1\n\n    def set(self):\n        pass\n",
+			want: []Function{
+				{Language: Python, Kind: KindFunction, Name: "load", Signature: "def load(path, strict=False)", Lines: Span{3, 7}, Body: Span{7, 7}},
+				{Language: Python, Kind: KindClass, Name: "Config", Signature: "class Config(Base)", Lines: Span{10, 15}, Body: Span{11, 15}},
+			},
+		},
+		{
+			name: "python bodies on the header line",
+			code: "def f(): pass\nasync def g(x: int): return x\nclass Empty: pass\ndef h(a,\n      b): return a\n",
+			want: []Function{
+				{Language: Python, Kind: KindFunction, Name: "f", Signature: "def f()", Lines: Span{1, 1}, Body: Span{1, 1}},
+				{Language: Python, Kind: KindFunction, Name: "g", Signature: "async def g(x: int)", Lines: Span{2, 2}, Body: Span{2, 2}},
+				{Language: Python, Kind: KindClass, Name: "Empty", Signature: "class Empty", Lines: Span{3, 3}, Body: Span{3, 3}},
+				{Language: Python, Kind: KindFunction, Name: "h", Signature: "def h(a, b)", Lines: Span{4, 5}, Body: Span{5, 5}},
+			},
+		},
+		{
+			name: "python method",
+			code: "    def area(self):\n        # width times height\n        return self.w * self.h\n",
+			want: []Function{
+				{Language: Python, Kind: KindMethod, Name: "area", Signature: "def area(self)", Lines: Span{1, 3}, Body: Span{2, 3}},
+			},
+		},
+		{
+			name:     "java class",
+			filename: "Main.java",
+			code:     "import java.util.List;\n\n/* entry point */\npublic class Main {\n    public static void main(String[] args) {\n        System.out.println(\"}\");\n    }\n}\n",
+			want: []Function{
+				{Language: Java, Kind: KindClass, Name: "Main", Signature: "public class Main", Lines: Span{4, 8}, Body: Span{4, 8}},
+			},
+		},
+		{
+			name: "java method",
+			code: "@Override\npublic String toString() {\n    return name;\n}\n",
+			want: []Function{
+				{Language: Java, Kind: KindMethod, Name: "toString", Signature: "@Override public String toString()", Lines: Span{1, 4}, Body: Span{2, 4}},
+			},
+		},
+		{
+			name:     "javascript",
+			filename: "app.js",
+			code:     "export async function load(url) {\n  return fetch(url);\n}\n\nclass View extends Base {\n  render() {}\n}\n\nconst add = (a, b) => {\n  return a + b;\n};\n",
+			want: []Function{
+				{Language: JavaScript, Kind: KindFunction, Name: "load", Signature: "export async function load(url)", Lines: Span{1, 3}, Body: Span{1, 3}},
+				{Language: JavaScript, Kind: KindClass, Name: "View", Signature: "class View extends Base", Lines: Span{5, 7}, Body: Span{5, 7}},
+				{Language: JavaScript, Kind: KindFunction, Name: "add", Signature: "const add = (a, b) =>", Lines: Span{9, 11}, Body: Span{9, 11}},
+			},
+		},
+		{
+			name:     "c",
+			filename: "main.c",
+			code:     "#include <stdio.h>\n\nstruct point {\n  int x, y;\n};\n\nstatic int\nmax(int a, int b)\n{\n  if (a > b) { return a; }\n  return b;\n}\n",
+			want: []Function{
+				{Language: C, Kind: KindType, Name: "point", Signature: "struct point", Lines: Span{3, 5}, Body: Span{3, 5}},
+				{Language: C, Kind: KindFunction, Name: "max", Signature: "static int max(int a, int b)", Lines: Span{7, 12}, Body: Span{9, 12}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			language, got := Definitions(tt.filename, tt.code)
+			if language != tt.want[0].Language {
+				t.Errorf("Definitions() detected %s, want %s", language, tt.want[0].Language)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Definitions() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	fn, err := Analyze("", "def f(): pass\ndef g(): pass\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fn.Name != "f" {
+		t.Errorf("Analyze() = %s, want the first definition f", fn.Name)
+	}
+
+	fn, err = Analyze("main.go", "var x = 1\n")
+	if !errors.Is(err, ErrNoDefinition) || fn.Language != Go {
+		t.Errorf("Analyze() = %+v, %v, want language go and %v", fn, err, ErrNoDefinition)
+	}
+}
@@ -0,0 +1,93 @@
+package codeanalysis
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// analyzeGo parses code with the Go parser. Snippets without a package clause
+// are parsed as the declarations of a file in an arbitrary package. The lines
+// of a definition include its doc comment.
+func analyzeGo(code string) []Function {
+	prefix := ""
+	if !strings.HasPrefix(strings.TrimSpace(code), "package") {
+		prefix = "package p\n"
+	}
+	src := prefix + code
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution|parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	// line numbers are relative to the snippet, not to the prefixed source
+	offset := strings.Count(prefix, "\n")
+	line := func(pos token.Pos) int {
+		return fset.Position(pos).Line - offset
+	}
+	text := func(from token.Pos, to token.Pos) string {
+		return src[fset.Position(from).Offset:fset.Position(to).Offset]
+	}
+	start := func(doc *ast.CommentGroup, pos token.Pos) int {
+		if doc != nil {
+			return line(doc.Pos())
+		}
+		return line(pos)
+	}
+
+	var definitions []Function
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Body == nil {
+				continue
+			}
+			fn := Function{
+				Kind:      KindFunction,
+				Name:      decl.Name.Name,
+				Signature: collapse(text(decl.Pos(), decl.Body.Lbrace)),
+				Lines:     Span{Start: start(decl.Doc, decl.Pos()), End: line(decl.Body.Rbrace)},
+				Body:      Span{Start: line(decl.Body.Lbrace), End: line(decl.Body.Rbrace)},
+			}
+			if decl.Recv != nil {
+				fn.Kind = KindMethod
+			}
+			definitions = append(definitions, fn)
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.TypeSpec)
+				fn := Function{
+					Kind:      KindType,
+					Name:      spec.Name.Name,
+					Signature: collapse("type " + text(spec.Pos(), spec.Type.Pos()) + typeKeyword(spec.Type)),
+					Lines:     Span{Start: start(spec.Doc, spec.Pos()), End: line(spec.Type.End())},
+					Body:      Span{Start: line(spec.Type.Pos()), End: line(spec.Type.End())},
+				}
+				if len(decl.Specs) == 1 {
+					fn.Lines.Start = start(decl.Doc, decl.Pos())
+				}
+				definitions = append(definitions, fn)
+			}
+		}
+	}
+
+	return definitions
+}
+
+// typeKeyword names the kind of a type definition without its fields.
+func typeKeyword(expr ast.Expr) string {
+	switch expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,22 @@
+package codeanalysis
+
+import "strings"
+
+// ParseGitURL splits a link to a git blob as created by the extension, for
+// example https://github.com/owner/repo/blob/<sha>/dir/file.go#L3-L9, into the
+// repository URL and the path of the file. Links to other places keep their
+// fragment-free form as the repository and have no path.
+func ParseGitURL(gitURL string) (repository string, file string) {
+	gitURL, _, _ = strings.Cut(gitURL, "#")
+	gitURL, _, _ = strings.Cut(gitURL, "?")
+
+	repository, blob, ok := strings.Cut(gitURL, "/blob/")
+	if !ok {
+		return strings.TrimSuffix(gitURL, "/"), ""
+	}
+
+	// the first segment of the blob is the commit or branch
+	_, file, _ = strings.Cut(blob, "/")
+
+	return repository, file
+}
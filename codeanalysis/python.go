@@ -0,0 +1,126 @@
+package codeanalysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var pythonDefinition = regexp.MustCompile(`^(\s*)(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)`)
+
+// analyzePython finds the def and class statements that are not nested in
+// another one. The body of a statement consists of the following lines that
+// are indented deeper than the statement itself. Decorators and comments
+// directly above a statement belong to its lines.
+func analyzePython(code string) []Function {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+
+	var definitions []Function
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		m := pythonDefinition.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+
+		fn := Function{Kind: KindFunction, Name: m[3]}
+		if m[2] == "class" {
+			fn.Kind = KindClass
+		} else if m[1] != "" {
+			// an indented def in a snippet is most likely a method
+			fn.Kind = KindMethod
+		}
+
+		// the header ends at the colon outside of any parentheses, which
+		// may be several lines further down
+		var header strings.Builder
+		end := i
+		for ; end < len(lines); end++ {
+			header.WriteString(lines[end])
+			header.WriteByte('\n')
+			if colon := headerColon(header.String()); colon >= 0 {
+				fn.Signature = collapse(header.String()[:colon])
+				break
+			}
+		}
+		if end == len(lines) {
+			break
+		}
+
+		indent := indentation(l)
+		fn.Body = Span{Start: end + 2, End: end + 1}
+		for j := end + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "" {
+				continue
+			}
+			if indentation(lines[j]) <= indent {
+				break
+			}
+			fn.Body.End = j + 1
+		}
+		// a body on the same line as the header, as in "def f(): pass"
+		if fn.Body.End < fn.Body.Start {
+			fn.Body = Span{Start: end + 1, End: end + 1}
+		}
+
+		first := i
+		for first > 0 {
+			previous := strings.TrimSpace(lines[first-1])
+			if !strings.HasPrefix(previous, "@") && !strings.HasPrefix(previous, "#") {
+				break
+			}
+			first--
+		}
+		fn.Lines = Span{Start: first + 1, End: fn.Body.End}
+
+		definitions = append(definitions, fn)
+		i = fn.Body.End - 1
+	}
+
+	return definitions
+}
+
+// headerColon returns the index of the colon ending a def or class header,
+// or -1 if the header continues on the next line.
+func headerColon(header string) int {
+	depth := 0
+	var quote rune
+	comment := false
+	for i, r := range header {
+		switch {
+		case comment:
+			comment = r != '\n'
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		case r == '#':
+			comment = true
+		case r == ':' && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// indentation counts the leading whitespace of a line, a tab counting as
+// eight columns like in the Python tokenizer.
+func indentation(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n = n/8*8 + 8
+		default:
+			return n
+		}
+	}
+	return n
+}
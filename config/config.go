@@ -0,0 +1,465 @@
+// Package config loads the backend configuration from defaults, an optional
+// configuration file, environment variables and command line flags, in that
+// order of precedence.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwth-acis/modernizer/auth"
+	"github.com/rwth-acis/modernizer/ranking"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete configuration of the backend.
+type Config struct {
+	Port            int
+	ShutdownTimeout time.Duration
+	// APITokens lists the tokens of the admin API as name:role:secret
+	// entries. The admin routes stay closed if it is empty.
+	APITokens string
+	// TrustedProxies lists the reverse proxies, as comma separated addresses
+	// or CIDR ranges, whose X-Forwarded-For header names the client. Without
+	// proxies the client is the address of the connection.
+	TrustedProxies string
+	// SnapshotDir receives a snapshot of the data before every reset.
+	SnapshotDir string
+	// ConsistencyInterval is the time between two repairs of orphaned
+	// objects, zero disables them.
+	ConsistencyInterval time.Duration
+	// ConsistencyMaxRepairs is the number of missing responses a repair
+	// regenerates at most.
+	ConsistencyMaxRepairs int
+
+	// Store selects the storage backend, either weaviate or memory.
+	Store    string
+	Weaviate weaviate.Config
+	Redis    redis.Config
+
+	LLM    LLM
+	Models Models
+	Queue  Queue
+
+	// Ranking names the strategy used to pick the best response.
+	Ranking string
+}
+
+// LLM selects the model server.
+type LLM struct {
+	Provider string
+	URL      string
+	APIKey   string
+}
+
+// Models names the model registry file, or the models of the built-in
+// registry if no file is given.
+type Models struct {
+	Registry        string
+	Default         string
+	SemanticMeaning string
+}
+
+// Queue configures the semantic meaning job queue and its workers.
+type Queue struct {
+	Workers    int
+	Policy     redis.RetryPolicy
+	Visibility time.Duration
+}
+
+// Default returns the configuration used for every value that is not set.
+func Default() *Config {
+	return &Config{
+		Port:                  8080,
+		ShutdownTimeout:       25 * time.Second,
+		SnapshotDir:           "snapshots",
+		ConsistencyInterval:   time.Hour,
+		ConsistencyMaxRepairs: 10,
+		Store:                 "weaviate",
+		Weaviate: weaviate.Config{
+			Scheme:         "http",
+			Timeout:        time.Minute,
+			StartupTimeout: 30 * time.Second,
+			MaxIdleConns:   16,
+		},
+		Redis: redis.Config{
+			MinIdleConns: 2,
+			DialTimeout:  5 * time.Second,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 3 * time.Second,
+		},
+		LLM: LLM{
+			Provider: "ollama",
+		},
+		Models: Models{
+			Default:         "codellama:13b-instruct",
+			SemanticMeaning: "semantic-meaning",
+		},
+		Ranking: "wilson",
+		Queue: Queue{
+			Workers: 2,
+			Policy: redis.RetryPolicy{
+				MaxAttempts: 5,
+				Backoff:     10 * time.Second,
+				MaxBackoff:  10 * time.Minute,
+			},
+			Visibility: 5 * time.Minute,
+		},
+	}
+}
+
+// option binds one configuration value to its flag, file key and environment
+// variables. The first environment variable that is set wins.
+type option struct {
+	name   string
+	env    []string
+	usage  string
+	secret bool
+	value  func(*Config) interface{}
+}
+
+var options = []option{
+	{"port", []string{"PORT"}, "port the HTTP server listens on", false, func(c *Config) interface{} { return &c.Port }},
+	{"shutdown-timeout", []string{"SHUTDOWN_TIMEOUT"}, "time to drain requests and jobs on shutdown", false, func(c *Config) interface{} { return &c.ShutdownTimeout }},
+	{"api-tokens", []string{"API_TOKENS"}, "admin API tokens as comma separated name:role:secret entries", true, func(c *Config) interface{} { return &c.APITokens }},
+	{"trusted-proxies", []string{"TRUSTED_PROXIES"}, "comma separated addresses or CIDR ranges of reverse proxies allowed to set X-Forwarded-For", false, func(c *Config) interface{} { return &c.TrustedProxies }},
+	{"snapshot-dir", []string{"SNAPSHOT_DIR"}, "directory for snapshots taken before resets", false, func(c *Config) interface{} { return &c.SnapshotDir }},
+	{"consistency-interval", []string{"CONSISTENCY_INTERVAL"}, "time between repairs of orphaned objects, 0 disables them", false, func(c *Config) interface{} { return &c.ConsistencyInterval }},
+	{"consistency-max-repairs", []string{"CONSISTENCY_MAX_REPAIRS"}, "missing responses regenerated at most per repair", false, func(c *Config) interface{} { return &c.ConsistencyMaxRepairs }},
+	{"store", []string{"STORE"}, "storage backend: weaviate or memory", false, func(c *Config) interface{} { return &c.Store }},
+
+	{"weaviate-host", []string{"WEAVIATE_HOST"}, "weaviate host and port", false, func(c *Config) interface{} { return &c.Weaviate.Host }},
+	{"weaviate-scheme", []string{"WEAVIATE_SCHEME"}, "weaviate scheme: http or https", false, func(c *Config) interface{} { return &c.Weaviate.Scheme }},
+	{"weaviate-key", []string{"WEAVIATE_KEY"}, "weaviate API key", true, func(c *Config) interface{} { return &c.Weaviate.APIKey }},
+	{"weaviate-timeout", []string{"WEAVIATE_TIMEOUT"}, "timeout of weaviate requests", false, func(c *Config) interface{} { return &c.Weaviate.Timeout }},
+	{"weaviate-startup-timeout", []string{"WEAVIATE_STARTUP_TIMEOUT"}, "time to wait for weaviate on startup", false, func(c *Config) interface{} { return &c.Weaviate.StartupTimeout }},
+	{"weaviate-max-idle-conns", []string{"WEAVIATE_MAX_IDLE_CONNS"}, "idle connections kept to weaviate", false, func(c *Config) interface{} { return &c.Weaviate.MaxIdleConns }},
+
+	{"redis-addr", []string{"REDIS_ADDR"}, "redis host:port", false, func(c *Config) interface{} { return &c.Redis.Addr }},
+	{"redis-password", []string{"REDIS_PASSWORD"}, "redis password", true, func(c *Config) interface{} { return &c.Redis.Password }},
+	{"redis-db", []string{"REDIS_DB"}, "redis database number", false, func(c *Config) interface{} { return &c.Redis.DB }},
+	{"redis-pool-size", []string{"REDIS_POOL_SIZE"}, "redis connection pool size, 0 uses the driver default", false, func(c *Config) interface{} { return &c.Redis.PoolSize }},
+	{"redis-min-idle-conns", []string{"REDIS_MIN_IDLE_CONNS"}, "idle connections kept to redis", false, func(c *Config) interface{} { return &c.Redis.MinIdleConns }},
+	{"redis-dial-timeout", []string{"REDIS_DIAL_TIMEOUT"}, "timeout for connecting to redis", false, func(c *Config) interface{} { return &c.Redis.DialTimeout }},
+	{"redis-read-timeout", []string{"REDIS_READ_TIMEOUT"}, "timeout of redis reads", false, func(c *Config) interface{} { return &c.Redis.ReadTimeout }},
+	{"redis-write-timeout", []string{"REDIS_WRITE_TIMEOUT"}, "timeout of redis writes", false, func(c *Config) interface{} { return &c.Redis.WriteTimeout }},
+
+	{"llm-provider", []string{"LLM_PROVIDER"}, "model server API: ollama, openai or fake", false, func(c *Config) interface{} { return &c.LLM.Provider }},
+	{"llm-url", []string{"LLM_URL", "OLLAMA_URL"}, "base URL of the model server", false, func(c *Config) interface{} { return &c.LLM.URL }},
+	{"llm-api-key", []string{"LLM_API_KEY"}, "API key of the model server", true, func(c *Config) interface{} { return &c.LLM.APIKey }},
+
+	{"model-registry", []string{"MODEL_REGISTRY"}, "JSON or YAML file listing the allowed models", false, func(c *Config) interface{} { return &c.Models.Registry }},
+	{"model", []string{"OLLAMA_MODEL"}, "default generation model without a registry file", false, func(c *Config) interface{} { return &c.Models.Default }},
+	{"semantic-model", []string{"SEMANTIC_MODEL"}, "semantic meaning model without a registry file", false, func(c *Config) interface{} { return &c.Models.SemanticMeaning }},
+
+	{"ranking", []string{"RANKING_STRATEGY"}, "best response ranking: wilson, bayesian or thompson", false, func(c *Config) interface{} { return &c.Ranking }},
+
+	{"semantic-workers", []string{"SEMANTIC_WORKERS"}, "number of semantic meaning workers", false, func(c *Config) interface{} { return &c.Queue.Workers }},
+	{"job-max-attempts", []string{"JOB_MAX_ATTEMPTS"}, "attempts before a job is dead-lettered", false, func(c *Config) interface{} { return &c.Queue.Policy.MaxAttempts }},
+	{"job-backoff", []string{"JOB_BACKOFF"}, "delay before the first retry of a job", false, func(c *Config) interface{} { return &c.Queue.Policy.Backoff }},
+	{"job-max-backoff", []string{"JOB_MAX_BACKOFF"}, "upper bound of the retry delay", false, func(c *Config) interface{} { return &c.Queue.Policy.MaxBackoff }},
+	{"job-visibility-timeout", []string{"JOB_VISIBILITY_TIMEOUT"}, "time after which an unacknowledged job is retried", false, func(c *Config) interface{} { return &c.Queue.Visibility }},
+}
+
+// Load registers the configuration flags on fs, parses args and returns the
+// validated configuration. Values are taken from the defaults, the file named
+// by -config or CONFIG_FILE, the environment and finally the flags. Callers
+// may register their own flags on fs before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg, err := parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// LoadStore is Load for commands that only connect to the store, such as the
+// schema migrations. Settings of the server, the LLM and Redis are not
+// validated, so they need not be set.
+func LoadStore(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg, err := parse(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, errors.Join(cfg.validateStore()...)
+}
+
+func parse(fs *flag.FlagSet, args []string) (*Config, error) {
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "optional JSON or YAML configuration file")
+	for _, opt := range options {
+		usage := fmt.Sprintf("%s (env %s)", opt.usage, strings.Join(opt.env, ", "))
+		fs.String(opt.name, "", usage)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	if *file != "" {
+		err = cfg.loadFile(*file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, opt := range options {
+		for _, env := range opt.env {
+			value, ok := os.LookupEnv(env)
+			if !ok || value == "" {
+				continue
+			}
+			err = set(opt.value(cfg), value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			break
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		opt, ok := lookup(f.Name)
+		if !ok || flagErr != nil {
+			return
+		}
+		if err := set(opt.value(cfg), f.Value.String()); err != nil {
+			flagErr = fmt.Errorf("invalid -%s: %w", f.Name, err)
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	return cfg, nil
+}
+
+// loadFile applies a flat file of option names to values, for example
+// "redis-addr: localhost:6379".
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	for name, value := range values {
+		opt, ok := lookup(name)
+		if !ok {
+			return fmt.Errorf("unknown option %q in config file %s", name, path)
+		}
+
+		err = set(opt.value(c), fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("invalid %s in config file %s: %w", name, path, err)
+		}
+	}
+
+	return nil
+}
+
+func lookup(name string) (option, bool) {
+	for _, opt := range options {
+		if opt.name == name {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
+
+func set(target interface{}, value string) error {
+	switch target := target.(type) {
+	case *string:
+		*target = value
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*target = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 5s", value)
+		}
+		*target = parsed
+	default:
+		panic(fmt.Sprintf("unsupported option type %T", target))
+	}
+	return nil
+}
+
+// Validate reports every invalid value at once so a misconfigured deployment
+// can be fixed in one go.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+
+	if _, err := auth.ParseTokens(c.APITokens); err != nil {
+		errs = append(errs, fmt.Errorf("API_TOKENS: %w", err))
+	}
+	if _, err := ParseProxies(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+	}
+	if c.SnapshotDir == "" {
+		errs = append(errs, errors.New("SNAPSHOT_DIR must not be empty"))
+	}
+	if c.ConsistencyInterval < 0 {
+		errs = append(errs, errors.New("CONSISTENCY_INTERVAL must not be negative"))
+	}
+	if c.ConsistencyMaxRepairs < 1 {
+		errs = append(errs, fmt.Errorf("CONSISTENCY_MAX_REPAIRS must be at least 1, got %d", c.ConsistencyMaxRepairs))
+	}
+
+	errs = append(errs, c.validateStore()...)
+
+	if err := c.Redis.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("REDIS_ADDR: %w", err))
+	}
+
+	switch c.LLM.Provider {
+	case "ollama", "openai":
+		if c.LLM.URL == "" {
+			errs = append(errs, fmt.Errorf("LLM_URL or OLLAMA_URL must be set for the %s provider", c.LLM.Provider))
+		} else if err := checkURL(c.LLM.URL); err != nil {
+			errs = append(errs, fmt.Errorf("LLM_URL: %w", err))
+		}
+	case "fake":
+	default:
+		errs = append(errs, fmt.Errorf("LLM_PROVIDER must be ollama, openai or fake, got %q", c.LLM.Provider))
+	}
+
+	if c.Models.Registry == "" && (c.Models.Default == "" || c.Models.SemanticMeaning == "") {
+		errs = append(errs, errors.New("OLLAMA_MODEL and SEMANTIC_MODEL must not be empty without MODEL_REGISTRY"))
+	}
+
+	if _, err := ranking.New(c.Ranking); err != nil {
+		errs = append(errs, fmt.Errorf("RANKING_STRATEGY: %w", err))
+	}
+
+	if c.Queue.Workers < 1 {
+		errs = append(errs, fmt.Errorf("SEMANTIC_WORKERS must be at least 1, got %d", c.Queue.Workers))
+	}
+	if c.Queue.Policy.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("JOB_MAX_ATTEMPTS must be at least 1, got %d", c.Queue.Policy.MaxAttempts))
+	}
+	if c.Queue.Policy.Backoff <= 0 || c.Queue.Policy.MaxBackoff < c.Queue.Policy.Backoff {
+		errs = append(errs, errors.New("JOB_BACKOFF must be positive and not exceed JOB_MAX_BACKOFF"))
+	}
+	if c.Queue.Visibility <= 0 {
+		errs = append(errs, errors.New("JOB_VISIBILITY_TIMEOUT must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateStore() []error {
+	var errs []error
+
+	switch c.Store {
+	case "weaviate":
+		if err := c.Weaviate.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("WEAVIATE_HOST/WEAVIATE_SCHEME: %w", err))
+		} else if err := checkURL(c.Weaviate.Scheme + "://" + c.Weaviate.Host); err != nil {
+			errs = append(errs, fmt.Errorf("WEAVIATE_HOST: %w", err))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("STORE must be weaviate or memory, got %q", c.Store))
+	}
+
+	return errs
+}
+
+// ParseProxies parses a comma separated list of addresses and CIDR ranges,
+// for example "10.0.0.1,172.16.0.0/12". An empty list trusts no proxy.
+func ParseProxies(spec string) ([]string, error) {
+	var proxies []string
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			return nil, fmt.Errorf("%q is neither an address nor a CIDR range", entry)
+		}
+		proxies = append(proxies, entry)
+	}
+
+	return proxies, nil
+}
+
+// checkURL makes sure raw is an absolute http or https URL with a host.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("malformed URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", raw)
+	}
+	return nil
+}
+
+// String lists the effective configuration, one option per line, with secrets
+// redacted.
+func (c *Config) String() string {
+	lines := make([]string, 0, len(options))
+	for _, opt := range options {
+		value := fmt.Sprint(get(opt.value(c)))
+		if opt.secret && value != "" {
+			value = "[redacted]"
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s", opt.name, value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func get(target interface{}) interface{} {
+	switch target := target.(type) {
+	case *string:
+		return *target
+	case *int:
+		return *target
+	case *time.Duration:
+		return *target
+	default:
+		panic(fmt.Sprintf("unsupported option type %T", target))
+	}
+}
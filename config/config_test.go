@@ -0,0 +1,264 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides the environment of the process from Load, so only the
+// variables a test sets count.
+func clearEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for _, opt := range options {
+		for _, env := range opt.env {
+			t.Setenv(env, "")
+		}
+	}
+}
+
+// setRequired sets the variables without a default.
+func setRequired(t *testing.T) {
+	t.Setenv("WEAVIATE_HOST", "weaviate:8080")
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	t.Setenv("OLLAMA_URL", "http://ollama:11434")
+}
+
+func load(args ...string) (*Config, error) {
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestLoad(t *testing.T) {
+	clearEnv(t)
+	setRequired(t)
+	t.Setenv("PORT", "9000")
+	t.Setenv("JOB_BACKOFF", "1m")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 8000\nranking: bayesian\nsemantic-workers: 4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load("-config", path, "-semantic-workers", "8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment overrides the file, flags override both
+	if cfg.Port != 9000 {
+		t.Errorf("Port = %d, want 9000 from the environment", cfg.Port)
+	}
+	if cfg.Ranking != "bayesian" {
+		t.Errorf("Ranking = %q, want bayesian from the file", cfg.Ranking)
+	}
+	if cfg.Queue.Workers != 8 {
+		t.Errorf("Queue.Workers = %d, want 8 from the flag", cfg.Queue.Workers)
+	}
+	if cfg.Queue.Policy.Backoff != time.Minute {
+		t.Errorf("Queue.Policy.Backoff = %v, want 1m", cfg.Queue.Policy.Backoff)
+	}
+	if cfg.LLM.URL != "http://ollama:11434" {
+		t.Errorf("LLM.URL = %q, want the value of OLLAMA_URL", cfg.LLM.URL)
+	}
+	if cfg.ShutdownTimeout != Default().ShutdownTimeout {
+		t.Errorf("ShutdownTimeout = %v, want the default %v", cfg.ShutdownTimeout, Default().ShutdownTimeout)
+	}
+}
+
+func TestLoadMissingRequired(t *testing.T) {
+	clearEnv(t)
+
+	_, err := load()
+	if err == nil {
+		t.Fatal("Load() succeeded without any configuration")
+	}
+	for _, want := range []string{"WEAVIATE_HOST", "REDIS_ADDR", "LLM_URL or OLLAMA_URL must be set"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error %q does not mention %s", err, want)
+		}
+	}
+
+	// the memory store and the fake provider need no services
+	t.Setenv("STORE", "memory")
+	t.Setenv("LLM_PROVIDER", "fake")
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	if _, err := load(); err != nil {
+		t.Errorf("Load() = %v, want no error without weaviate and a model server", err)
+	}
+}
+
+func TestLoadStore(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("WEAVIATE_HOST", "weaviate:8080")
+	t.Setenv("PORT", "0")
+
+	cfg, err := LoadStore(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("LoadStore() without server, LLM and Redis settings: %v", err)
+	}
+	if cfg.Weaviate.Host != "weaviate:8080" {
+		t.Errorf("Weaviate.Host = %q, want weaviate:8080", cfg.Weaviate.Host)
+	}
+
+	t.Setenv("WEAVIATE_HOST", "")
+	_, err = LoadStore(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err == nil || !strings.Contains(err.Error(), "WEAVIATE_HOST") {
+		t.Errorf("LoadStore() without WEAVIATE_HOST = %v, want an error mentioning it", err)
+	}
+}
+
+func TestLoadInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		file    string
+		wantErr string
+	}{
+		{
+			name:    "duration in the environment",
+			env:     map[string]string{"SHUTDOWN_TIMEOUT": "25"},
+			wantErr: `invalid SHUTDOWN_TIMEOUT: "25" is not a duration such as 5s`,
+		},
+		{
+			name:    "duration flag",
+			args:    []string{"-job-visibility-timeout", "five minutes"},
+			wantErr: `invalid -job-visibility-timeout: "five minutes" is not a duration such as 5s`,
+		},
+		{
+			name:    "duration in the file",
+			file:    "weaviate-timeout: soon\n",
+			wantErr: `invalid weaviate-timeout in config file`,
+		},
+		{
+			name:    "integer",
+			env:     map[string]string{"PORT": "http"},
+			wantErr: `invalid PORT: "http" is not an integer`,
+		},
+		{
+			name:    "unknown file option",
+			file:    "colour: blue\n",
+			wantErr: `unknown option "colour"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			setRequired(t)
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"-config", path}, args...)
+			}
+
+			_, err := load(args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{name: "url without scheme", change: func(c *Config) { c.LLM.URL = "ollama:11434" }, wantErr: `LLM_URL: URL "ollama:11434" must use http or https`},
+		{name: "url with other scheme", change: func(c *Config) { c.LLM.URL = "ftp://ollama" }, wantErr: `LLM_URL: URL "ftp://ollama" must use http or https`},
+		{name: "url without host", change: func(c *Config) { c.LLM.URL = "http://" }, wantErr: `LLM_URL: URL "http://" has no host`},
+		{name: "malformed url", change: func(c *Config) { c.LLM.URL = "http://ollama:port" }, wantErr: "LLM_URL: malformed URL"},
+		{name: "weaviate host with path", change: func(c *Config) { c.Weaviate.Host = "weaviate:80 80" }, wantErr: "WEAVIATE_HOST: malformed URL"},
+		{name: "weaviate scheme", change: func(c *Config) { c.Weaviate.Scheme = "grpc" }, wantErr: "WEAVIATE_HOST/WEAVIATE_SCHEME"},
+		{name: "redis address without port", change: func(c *Config) { c.Redis.Addr = "redis" }, wantErr: "REDIS_ADDR: invalid redis address"},
+		{name: "port", change: func(c *Config) { c.Port = 70000 }, wantErr: "PORT must be between 1 and 65535, got 70000"},
+		{name: "shutdown timeout", change: func(c *Config) { c.ShutdownTimeout = 0 }, wantErr: "SHUTDOWN_TIMEOUT must be positive"},
+		{name: "negative interval", change: func(c *Config) { c.ConsistencyInterval = -time.Second }, wantErr: "CONSISTENCY_INTERVAL must not be negative"},
+		{name: "no repairs", change: func(c *Config) { c.ConsistencyMaxRepairs = 0 }, wantErr: "CONSISTENCY_MAX_REPAIRS must be at least 1, got 0"},
+		{name: "backoff above maximum", change: func(c *Config) { c.Queue.Policy.Backoff = time.Hour }, wantErr: "JOB_BACKOFF must be positive and not exceed JOB_MAX_BACKOFF"},
+		{name: "trusted proxies", change: func(c *Config) { c.TrustedProxies = "10.0.0.1, proxy.local" }, wantErr: `TRUSTED_PROXIES: "proxy.local" is neither an address nor a CIDR range`},
+		{name: "api tokens", change: func(c *Config) { c.APITokens = "alice" }, wantErr: "API_TOKENS"},
+		{name: "provider", change: func(c *Config) { c.LLM.Provider = "llama" }, wantErr: `LLM_PROVIDER must be ollama, openai or fake, got "llama"`},
+		{name: "ranking", change: func(c *Config) { c.Ranking = "votes" }, wantErr: "RANKING_STRATEGY"},
+		{name: "models", change: func(c *Config) { c.Models.Default = "" }, wantErr: "OLLAMA_MODEL and SEMANTIC_MODEL must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Weaviate.Host = "weaviate:8080"
+			cfg.Redis.Addr = "redis:6379"
+			cfg.LLM.URL = "http://ollama:11434"
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAll(t *testing.T) {
+	cfg := Default()
+	cfg.Port = 0
+	cfg.Queue.Workers = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() succeeded")
+	}
+	for _, want := range []string{"PORT", "WEAVIATE_HOST", "REDIS_ADDR", "LLM_URL", "SEMANTIC_WORKERS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	got, err := ParseProxies(" 10.0.0.1, ,172.16.0.0/12,::1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10.0.0.1|172.16.0.0/12|::1"; strings.Join(got, "|") != want {
+		t.Errorf("ParseProxies() = %q, want %q", got, want)
+	}
+
+	if got, err := ParseProxies(""); err != nil || got != nil {
+		t.Errorf("ParseProxies(\"\") = %q, %v, want no proxies", got, err)
+	}
+}
+
+func TestString(t *testing.T) {
+	cfg := Default()
+	cfg.APITokens = "alice:admin:secret"
+	cfg.Redis.Addr = "redis:6379"
+
+	got := cfg.String()
+	if strings.Contains(got, "secret") {
+		t.Errorf("String() reveals a secret:\n%s", got)
+	}
+	for _, want := range []string{"api-tokens=[redacted]", "redis-addr=redis:6379", "redis-password=\n", "port=8080"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("String() does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// maxMessageSize limits the length of a follow-up question.
+const maxMessageSize = 64 << 10
+
+// requireAnswered fails with not found unless promptID has a response to
+// follow up on.
+func (s *server) requireAnswered(ctx context.Context, promptID string) error {
+	_, err := s.store.RetrieveProperties(ctx, promptID)
+	return err
+}
+
+// voteOnMessage records the vote of client for an answer within the
+// conversation about promptID.
+func (s *server) voteOnMessage(ctx context.Context, promptID string, messageID string, client string, vote redis.Vote) (weaviate.Votes, error) {
+	message, err := s.store.RetrieveMessage(ctx, messageID)
+	if err != nil {
+		return weaviate.Votes{}, err
+	}
+	if message.PromptID != promptID {
+		return weaviate.Votes{}, errkind.New(errkind.NotFound, "message not found in the conversation about %s", promptID)
+	}
+	if message.Role != weaviate.RoleAssistant {
+		return weaviate.Votes{}, errkind.New(errkind.Invalid, "only answers can be voted on")
+	}
+
+	return s.castMessageVote(ctx, message.ID, client, vote)
+}
@@ -0,0 +1,152 @@
+// Package errkind classifies errors, so that callers can tell a missing object
+// or a bad request apart from a broken backend without parsing messages.
+package errkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+)
+
+// Kind is the class of an error.
+type Kind int
+
+const (
+	// Internal errors are not classified.
+	Internal Kind = iota
+	// NotFound means the requested object does not exist.
+	NotFound
+	// Invalid means the input was rejected.
+	Invalid
+	// Unavailable means an upstream service could not be reached or failed.
+	Unavailable
+	// Timeout means an upstream service did not answer in time.
+	Timeout
+	// Conflict means the object was changed concurrently or already exists.
+	Conflict
+	// Unauthorized means the request did not authenticate.
+	Unauthorized
+	// Forbidden means the request authenticated without the required rights.
+	Forbidden
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NotFound:
+		return "not found"
+	case Invalid:
+		return "invalid"
+	case Unavailable:
+		return "unavailable"
+	case Timeout:
+		return "timeout"
+	case Conflict:
+		return "conflict"
+	case Unauthorized:
+		return "unauthorized"
+	case Forbidden:
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
+
+// Error attaches a kind to an error. Its message is the one of Err.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates an error of kind. Like fmt.Errorf, %w wraps an error.
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap attaches kind to err, nil stays nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Is reports whether err is of kind.
+func Is(err error, kind Kind) bool {
+	return Of(err) == kind
+}
+
+// Of returns the kind of err. Besides errors of this package it recognizes
+// deadlines, network failures and the status codes of Weaviate. Anything else
+// is internal.
+func Of(err error) Kind {
+	if err == nil {
+		return Internal
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	var weaviateErr *fault.WeaviateClientError
+	if errors.As(err, &weaviateErr) {
+		if weaviateErr.IsUnexpectedStatusCode {
+			// objects created with a taken ID are unprocessable
+			if weaviateErr.StatusCode == http.StatusUnprocessableEntity && strings.Contains(weaviateErr.Msg, "already exists") {
+				return Conflict
+			}
+			return FromStatus(weaviateErr.StatusCode)
+		}
+		// the client does not unwrap the cause
+		if weaviateErr.DerivedFromError != nil {
+			return Of(weaviateErr.DerivedFromError)
+		}
+		return Internal
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return Timeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return Unavailable
+	}
+
+	return Internal
+}
+
+// FromStatus returns the kind of an error answered by an upstream service with
+// the HTTP status code. Other client errors are internal, as they mean the
+// request sent upstream was wrong rather than the input of the caller.
+func FromStatus(code int) Kind {
+	switch {
+	case code == http.StatusNotFound:
+		return NotFound
+	case code == http.StatusConflict:
+		return Conflict
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return Timeout
+	case code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+		return Unavailable
+	default:
+		return Internal
+	}
+}
@@ -0,0 +1,92 @@
+package errkind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+)
+
+// timeoutError is a network error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestOf(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{name: "nil", err: nil, want: Internal},
+		{name: "plain", err: errors.New("boom"), want: Internal},
+		{name: "kind", err: New(NotFound, "prompt %s not found", "p"), want: NotFound},
+		{name: "wrapped kind", err: fmt.Errorf("retrieving: %w", New(Invalid, "bad")), want: Invalid},
+		{name: "outermost kind", err: Wrap(Conflict, New(NotFound, "gone")), want: Conflict},
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: Timeout},
+		{name: "network timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, want: Timeout},
+		{name: "connection refused", err: refused, want: Unavailable},
+		{name: "weaviate not found", err: &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusNotFound}, want: NotFound},
+		{name: "weaviate already exists", err: &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusUnprocessableEntity, Msg: `id "x" already exists`}, want: Conflict},
+		{name: "weaviate unprocessable", err: &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusUnprocessableEntity, Msg: "invalid property"}, want: Internal},
+		{name: "weaviate down", err: &fault.WeaviateClientError{IsUnexpectedStatusCode: true, StatusCode: http.StatusServiceUnavailable}, want: Unavailable},
+		{name: "weaviate unreachable", err: &fault.WeaviateClientError{DerivedFromError: refused}, want: Unavailable},
+		{name: "weaviate client", err: &fault.WeaviateClientError{Msg: "no class"}, want: Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Of(tt.err); got != tt.want {
+				t.Errorf("Of(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cause := errors.New("cause")
+	err := New(Unavailable, "model server: %w", cause)
+
+	if err.Error() != "model server: cause" {
+		t.Errorf("Error() = %q, want the formatted message", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("New() does not wrap the %w argument")
+	}
+	if !Is(err, Unavailable) || Is(err, Internal) {
+		t.Errorf("Is() does not report the kind of %v", err)
+	}
+	if Wrap(NotFound, nil) != nil {
+		t.Error("Wrap(nil) is not nil")
+	}
+}
+
+func TestFromStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want Kind
+	}{
+		{code: http.StatusBadRequest, want: Internal},
+		{code: http.StatusNotFound, want: NotFound},
+		{code: http.StatusRequestTimeout, want: Timeout},
+		{code: http.StatusConflict, want: Conflict},
+		{code: http.StatusTooManyRequests, want: Unavailable},
+		{code: http.StatusInternalServerError, want: Unavailable},
+		{code: http.StatusBadGateway, want: Unavailable},
+		{code: http.StatusGatewayTimeout, want: Timeout},
+	}
+
+	for _, tt := range tests {
+		if got := FromStatus(tt.code); got != tt.want {
+			t.Errorf("FromStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ollama"
+)
+
+// kindErrors maps the kinds of classified errors to their status and code.
+var kindErrors = map[errkind.Kind]struct {
+	status int
+	code   api.ErrorCode
+}{
+	errkind.NotFound:     {http.StatusNotFound, api.CodeNotFound},
+	errkind.Invalid:      {http.StatusBadRequest, api.CodeInvalidRequest},
+	errkind.Conflict:     {http.StatusConflict, api.CodeConflict},
+	errkind.Unauthorized: {http.StatusUnauthorized, api.CodeUnauthorized},
+	errkind.Forbidden:    {http.StatusForbidden, api.CodeForbidden},
+	errkind.Unavailable:  {http.StatusServiceUnavailable, api.CodeUnavailable},
+	errkind.Timeout:      {http.StatusGatewayTimeout, api.CodeTimeout},
+}
+
+// apiError classifies err for the error envelope. Errors that are not
+// classified are internal.
+func apiError(err error) *api.Error {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var overflow *ollama.ContextOverflowError
+	if errors.As(err, &overflow) {
+		e := api.Errorf(http.StatusRequestEntityTooLarge, api.CodeContextOverflow, "%s", overflow.Error())
+		e.Details = gin.H{
+			"model":  overflow.Model,
+			"tokens": overflow.Tokens,
+			"limit":  overflow.Limit,
+			"over":   overflow.Over(),
+		}
+		return e
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return api.Errorf(http.StatusRequestEntityTooLarge, api.CodeTooLarge, "request body is larger than %d bytes", tooLarge.Limit)
+	}
+
+	if mapped, ok := kindErrors[errkind.Of(err)]; ok {
+		return api.Errorf(mapped.status, mapped.code, "%s", err.Error())
+	}
+
+	return api.Errorf(http.StatusInternalServerError, api.CodeInternal, "%s", err.Error())
+}
+
+// errorBody returns the body answering err. Routes of the versioned API use
+// the error envelope, the others keep their plain error field and gain the
+// code next to it.
+func errorBody(c *gin.Context, err error) interface{} {
+	e := apiError(err)
+	if strings.HasPrefix(c.FullPath(), api.Prefix) {
+		return api.ErrorResponse{Error: e}
+	}
+
+	body := gin.H{"error": e.Message, "code": e.Code}
+	if e.Details != nil {
+		body["details"] = e.Details
+	}
+	return body
+}
+
+// handleErrors answers requests whose handler failed with c.Error and did not
+// respond itself. The status follows from the last error.
+func handleErrors(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	e := apiError(err)
+	if e.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	c.JSON(e.Status, errorBody(c, err))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/admin"
+	"github.com/rwth-acis/modernizer/config"
+)
+
+// exportKnowledgeBase streams all prompts, responses, semantic meanings,
+// analyses, conversations, suggestions, votes and instruct sets as newline
+// delimited JSON. The query parameter vectors includes the vectors of the objects.
+func (s *server) exportKnowledgeBase(c *gin.Context) {
+	vectors, _ := strconv.ParseBool(c.Query("vectors"))
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="modernizer-%s.ndjson"`, time.Now().UTC().Format("20060102T150405Z")))
+
+	_, err := s.transfer.Export(c.Request.Context(), c.Writer, admin.ExportOptions{Vectors: vectors})
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Disposition", "")
+			c.Error(err)
+			return
+		}
+		// the status is sent already, the truncated body has to tell
+		log.Printf("export stopped: %v", err)
+		c.Abort()
+	}
+}
+
+// importKnowledgeBase stores an export or a snapshot sent as request body.
+func (s *server) importKnowledgeBase(c *gin.Context) {
+	summary, err := s.transfer.Import(c.Request.Context(), c.Request.Body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// runExport implements the export command, which writes the knowledge base to
+// a file or to standard output.
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the export to, standard output by default")
+	vectors := flags.Bool("vectors", false, "include the vectors of the objects")
+	cfg := loadConfig(flags, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	transfer, closeAll := openTransfer(ctx, cfg)
+	defer closeAll()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	summary, err := transfer.Export(ctx, w, admin.ExportOptions{Vectors: *vectors})
+	if err != nil {
+		if *output != "" {
+			os.Remove(*output)
+		}
+		log.Fatalf("export failed: %v", err)
+	}
+
+	log.Printf("exported %d instruct sets, the votes of %d prompts and messages and objects %v", summary.Sets, summary.Votes, summary.Objects)
+}
+
+// runImport implements the import command, which reads an export or a
+// snapshot from a file or from standard input.
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	input := flags.String("i", "", "file to read the import from, standard input by default")
+	cfg := loadConfig(flags, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	transfer, closeAll := openTransfer(ctx, cfg)
+	defer closeAll()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	err := transfer.Store.InitSchema(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	summary, err := transfer.Import(ctx, r)
+	if err != nil {
+		log.Fatalf("import failed after objects %v: %v", summary.Objects, err)
+	}
+
+	log.Printf("imported %d instruct sets, the votes of %d prompts and messages and objects %v", summary.Sets, summary.Votes, summary.Objects)
+}
+
+// openTransfer connects to the store and to Redis. The returned function
+// disconnects again.
+func openTransfer(ctx context.Context, cfg *config.Config) (*admin.Transfer, func()) {
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rdb, err := openRedis(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return &admin.Transfer{Store: store, Sets: rdb, Votes: rdb}, func() {
+		closeLogged("redis", rdb)
+		closeLogged("store", store)
+	}
+}
@@ -217,7 +217,7 @@ async function fetchPromptCount(functionName: string): Promise<number | string>
     const promptCountPath: string = '/weaviate/promptcount';
     const url: string = `${baseUrl}${promptCountPath}`;
 
-    const queryParams = new URLSearchParams({ query: functionName });
+    const queryParams = new URLSearchParams({ function: functionName });
     const urlQuery = `${url}?${queryParams.toString()}`;
 
     const response = await fetch(urlQuery);
@@ -0,0 +1,116 @@
+// Package fingerprint derives stable keys from source code, so that the same
+// code is recognized regardless of formatting and comments.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+
+	"github.com/rwth-acis/modernizer/codeanalysis"
+)
+
+// Normalize removes comments, unifies line endings and collapses every run of
+// whitespace into a single space. Comment markers inside string literals are
+// left alone. Comments follow the syntax of the language codeanalysis.Detect
+// recognizes in code: // and /* */ in Go, Java, JavaScript and C, # in
+// Python. Code in an unknown language keeps its comments, because a marker
+// such as // may just as well be an operator there. The language is detected
+// from the content only, so that stored code and the code of a request are
+// normalized alike.
+func Normalize(code string) string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	lineComment, blockComments := commentSyntax(codeanalysis.Detect("", code))
+
+	var b strings.Builder
+	b.Grow(len(code))
+
+	runes := []rune(code)
+	space := false
+	emit := func(r rune) {
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			return
+		}
+		if space {
+			b.WriteRune(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '"' || r == '\'' || r == '`':
+			// copy the literal up to the closing quote, honoring escapes
+			emit(r)
+			for i++; i < len(runes); i++ {
+				b.WriteRune(runes[i])
+				if runes[i] == '\\' && r != '`' && i+1 < len(runes) {
+					i++
+					b.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == r || (runes[i] == '\n' && r != '`') {
+					break
+				}
+			}
+		case lineComment != "" && at(runes, i, lineComment):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			space = b.Len() > 0
+		case blockComments && at(runes, i, "/*"):
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			space = b.Len() > 0
+		default:
+			emit(r)
+		}
+	}
+
+	return b.String()
+}
+
+// at reports whether marker starts at runes[i].
+func at(runes []rune, i int, marker string) bool {
+	for _, m := range marker {
+		if i >= len(runes) || runes[i] != m {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// commentSyntax returns the marker of line comments in language and whether
+// it has /* */ block comments.
+func commentSyntax(language codeanalysis.Language) (lineComment string, blockComments bool) {
+	switch language {
+	case codeanalysis.Go, codeanalysis.Java, codeanalysis.JavaScript, codeanalysis.C:
+		return "//", true
+	case codeanalysis.Python:
+		return "#", false
+	default:
+		return "", false
+	}
+}
+
+// Key identifies a prompt by its normalized code, its instruct and the model
+// answering it.
+func Key(code string, instruct string, model string) string {
+	h := sha256.New()
+	h.Write([]byte(Normalize(code)))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(strings.Fields(instruct), " ")))
+	h.Write([]byte{0})
+	h.Write([]byte(model))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,121 @@
+package fingerprint
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "go comments",
+			code: "func f() int {\r\n\t// answer\r\n\treturn 42 /* always */\r\n}\r\n",
+			want: "func f() int { return 42 }",
+		},
+		{
+			name: "go comment markers in strings",
+			code: "func f() string {\n\treturn \"http://host\" + `/* raw */`\n}",
+			want: "func f() string { return \"http://host\" + `/* raw */` }",
+		},
+		{
+			name: "python comments",
+			code: "def f(n):\n    # half\n    return n // 2  #rounded down\n",
+			want: "def f(n): return n // 2",
+		},
+		{
+			name: "python comment markers in strings",
+			code: "def f():\n    return '# not a comment'\n",
+			want: "def f(): return '# not a comment'",
+		},
+		{
+			name: "c preprocessor",
+			code: "#include <stdio.h>\nint main() { return 0; } // done\n",
+			want: "#include <stdio.h> int main() { return 0; }",
+		},
+		{
+			name: "unknown language keeps comments",
+			code: "x = n // 2\n# note\n",
+			want: "x = n // 2 # note",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.code); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "formatting",
+			a:    "func f() {\n\treturn\n}",
+			b:    "func f() {   return }",
+			same: true,
+		},
+		{
+			name: "go comments",
+			a:    "func f() int {\n\treturn 1 // one\n}",
+			b:    "func f() int {\n\t/* one */ return 1\n}",
+			same: true,
+		},
+		{
+			name: "python comments",
+			a:    "def f(n):\n    return n # n\n",
+			b:    "def f(n):\n    #n\n    return n\n",
+			same: true,
+		},
+		{
+			name: "python floor division",
+			a:    "def half(n):\n    return n // 2\n",
+			b:    "def half(n):\n    return n // 3\n",
+		},
+		{
+			name: "python floor division without definition",
+			a:    "return n // 2",
+			b:    "return n // 3",
+		},
+		{
+			name: "go code",
+			a:    "func f() int { return 1 }",
+			b:    "func f() int { return 2 }",
+		},
+		{
+			name: "string contents",
+			a:    `func f() string { return "a  b" }`,
+			b:    `func f() string { return "a b" }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Key(tt.a, "Explain this:", "m"), Key(tt.b, "Explain this:", "m")
+			if (a == b) != tt.same {
+				t.Errorf("Key(%q) == Key(%q) is %v, want %v", tt.a, tt.b, a == b, tt.same)
+			}
+		})
+	}
+}
+
+func TestKeyInstructAndModel(t *testing.T) {
+	code := "func f() {}"
+	key := Key(code, "Explain this:", "m")
+
+	if got := Key(code, "  Explain\n this: ", "m"); got != key {
+		t.Errorf("whitespace in the instruct changed the key")
+	}
+	if got := Key(code, "Refactor this:", "m"); got == key {
+		t.Errorf("a different instruct has the same key")
+	}
+	if got := Key(code, "Explain this:", "other"); got == key {
+		t.Errorf("a different model has the same key")
+	}
+}
@@ -0,0 +1,168 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// CodeHash identifies code by its normalized form.
+func CodeHash(code string) string {
+	sum := sha256.Sum256([]byte(Normalize(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+const (
+	// SignatureSize is the number of hash functions of a MinHash signature.
+	SignatureSize = 64
+	// bandRows is the number of signature values combined into one band.
+	bandRows = 4
+	// shingleSize is the number of tokens per shingle.
+	shingleSize = 3
+)
+
+// FuzzyThreshold is the estimated Jaccard similarity above which two pieces of
+// code count as near duplicates.
+const FuzzyThreshold = 0.8
+
+// Signature is the MinHash signature of the token shingles of code. The share
+// of equal values of two signatures estimates the Jaccard similarity of their
+// shingle sets.
+type Signature []int64
+
+// MinHash computes the signature of code. Code without tokens has an empty
+// signature.
+func MinHash(code string) Signature {
+	shingles := shingle(tokenize(Normalize(code)))
+	if len(shingles) == 0 {
+		return nil
+	}
+
+	signature := make(Signature, SignatureSize)
+	for i := range signature {
+		signature[i] = math.MaxInt64
+	}
+
+	for _, s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		x := h.Sum64()
+
+		for i := range signature {
+			// 32 bits keep the values exact in JSON numbers
+			v := int64(mix(x^seeds[i]) >> 32)
+			if v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+
+	return signature
+}
+
+// Similarity estimates the Jaccard similarity of the code behind a and b.
+func Similarity(a Signature, b Signature) float64 {
+	if len(a) != SignatureSize || len(b) != SignatureSize {
+		return 0
+	}
+
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+
+	return float64(equal) / SignatureSize
+}
+
+// Bands splits the signature into locality-sensitive bands. Code sharing at
+// least one band is a near-duplicate candidate, pairs above FuzzyThreshold
+// share a band with a probability of more than 99.9%.
+func (s Signature) Bands() []string {
+	if len(s) != SignatureSize {
+		return nil
+	}
+
+	bands := make([]string, 0, SignatureSize/bandRows)
+	for start := 0; start < SignatureSize; start += bandRows {
+		h := fnv.New64a()
+		for _, v := range s[start : start+bandRows] {
+			fmt.Fprintf(h, "%d,", v)
+		}
+		bands = append(bands, fmt.Sprintf("%02d-%016x", start/bandRows, h.Sum64()))
+	}
+
+	return bands
+}
+
+// tokenize splits normalized code into identifiers, numbers and single
+// punctuation characters.
+func tokenize(code string) []string {
+	var tokens []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range code {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens = append(tokens, string(r))
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func shingle(tokens []string) []string {
+	if len(tokens) == 0 {
+		return nil
+	}
+	if len(tokens) < shingleSize {
+		return []string{strings.Join(tokens, " ")}
+	}
+
+	shingles := make([]string, 0, len(tokens)-shingleSize+1)
+	for i := 0; i+shingleSize <= len(tokens); i++ {
+		shingles = append(shingles, strings.Join(tokens[i:i+shingleSize], " "))
+	}
+
+	return shingles
+}
+
+// seeds derive the hash functions of the signature. They are fixed so that
+// signatures stay comparable across restarts.
+var seeds = func() [SignatureSize]uint64 {
+	var seeds [SignatureSize]uint64
+	state := uint64(0x6d6f6465726e697a)
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix(state)
+	}
+	return seeds
+}()
+
+// mix is the splitmix64 finalizer.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
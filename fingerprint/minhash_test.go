@@ -0,0 +1,155 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCodeHash(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "go comments and formatting",
+			a:    "func f(a int) int {\n\t// double\n\treturn a * 2\n}",
+			b:    "func f(a int) int { return a * 2 /* double */ }",
+			same: true,
+		},
+		{
+			name: "python comments",
+			a:    "def f(n):\n    return n * 2  # double\n",
+			b:    "def f(n):\n    return n * 2\n",
+			same: true,
+		},
+		{
+			name: "python floor division",
+			a:    "def f(n):\n    return n // 2\n",
+			b:    "def f(n):\n    return n // 4\n",
+		},
+		{
+			name: "go code",
+			a:    "func f(a int) int { return a * 2 }",
+			b:    "func f(a int) int { return a * 3 }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := CodeHash(tt.a), CodeHash(tt.b)
+			if len(a) != 64 {
+				t.Errorf("CodeHash(%q) = %q, want a hex encoded SHA-256", tt.a, a)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("CodeHash(%q) == CodeHash(%q) is %v, want %v", tt.a, tt.b, a == b, tt.same)
+			}
+		})
+	}
+}
+
+// function returns a Go function of n statements, with the statements from
+// changed on altered.
+func function(n int, changed int) string {
+	var b strings.Builder
+	b.WriteString("func process(items []string) error {\n")
+	for i := 0; i < n; i++ {
+		if i >= changed {
+			fmt.Fprintf(&b, "\tlog.Printf(\"other %d\", other%d)\n", i, i)
+			continue
+		}
+		fmt.Fprintf(&b, "\tif err := step%d(items); err != nil {\n\t\treturn err\n\t}\n", i)
+	}
+	b.WriteString("\treturn nil\n}\n")
+	return b.String()
+}
+
+func TestMinHash(t *testing.T) {
+	original := function(40, 40)
+
+	tests := []struct {
+		name string
+		code string
+		// similar is whether code must count as a near duplicate
+		similar bool
+	}{
+		{name: "same code reformatted", code: strings.ReplaceAll(original, "\t", "    "), similar: true},
+		{name: "one statement changed", code: function(40, 39), similar: true},
+		{name: "half the statements changed", code: function(40, 20)},
+		{name: "unrelated code", code: "class Parser:\n    def parse(self, text):\n        return text.split(',')\n"},
+	}
+
+	signature := MinHash(original)
+	if len(signature) != SignatureSize {
+		t.Fatalf("MinHash() has %d values, want %d", len(signature), SignatureSize)
+	}
+	if got := Similarity(signature, signature); got != 1 {
+		t.Errorf("Similarity() of a signature with itself = %f, want 1", got)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := MinHash(tt.code)
+			similarity := Similarity(signature, other)
+			if (similarity >= FuzzyThreshold) != tt.similar {
+				t.Errorf("Similarity() = %f, want similar %v", similarity, tt.similar)
+			}
+			if tt.similar && !shareBand(signature.Bands(), other.Bands()) {
+				t.Errorf("near duplicates share no band")
+			}
+		})
+	}
+}
+
+func TestMinHashEmpty(t *testing.T) {
+	for _, code := range []string{"", "  \n\t"} {
+		signature := MinHash(code)
+		if signature != nil || signature.Bands() != nil {
+			t.Errorf("MinHash(%q) = %v with bands %v, want neither", code, signature, signature.Bands())
+		}
+	}
+
+	if got := Similarity(nil, MinHash("func f() {}")); got != 0 {
+		t.Errorf("Similarity() with an empty signature = %f, want 0", got)
+	}
+}
+
+func TestBands(t *testing.T) {
+	a := MinHash(function(20, 20))
+	bands := a.Bands()
+
+	if len(bands) != SignatureSize/bandRows {
+		t.Fatalf("Bands() returned %d bands, want %d", len(bands), SignatureSize/bandRows)
+	}
+	seen := map[string]bool{}
+	for i, band := range bands {
+		// the band index keeps equal rows in different bands apart
+		if !strings.HasPrefix(band, fmt.Sprintf("%02d-", i)) {
+			t.Errorf("band %d = %q, want the prefix %02d-", i, band, i)
+		}
+		seen[band] = true
+	}
+	if len(seen) != len(bands) {
+		t.Errorf("Bands() returned duplicates: %q", bands)
+	}
+
+	// bands only depend on the signature
+	if again := MinHash(function(20, 20)).Bands(); strings.Join(again, ",") != strings.Join(bands, ",") {
+		t.Errorf("Bands() is not deterministic")
+	}
+	if shareBand(bands, MinHash("def unrelated():\n    return 'something else entirely'\n").Bands()) {
+		t.Errorf("unrelated code shares a band")
+	}
+}
+
+func shareBand(a []string, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
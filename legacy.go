@@ -0,0 +1,438 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rwth-acis/modernizer/api"
+)
+
+// The routes that predate the versioned API are adapters: they read the
+// parameters they always took into the request of their successor, call its
+// handler and answer in the shape they always answered with. Failures are
+// classified like those of the versioned API.
+
+// adapt creates the handler of a deprecated route from the handler of its
+// successor. read fills the request, respond writes the answer.
+func adapt[Req any, Resp any](fn func(c *gin.Context, req *Req) (Resp, error), read func(c *gin.Context, req *Req) error, respond func(c *gin.Context, req *Req, resp Resp)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req Req
+		if err := read(c, &req); err != nil {
+			c.Error(err)
+			return
+		}
+		if err := binding.Validator.ValidateStruct(&req); err != nil {
+			c.Error(api.InvalidRequest("%v", err))
+			return
+		}
+
+		resp, err := fn(c, &req)
+		if err != nil {
+			legacyError(c, err)
+			return
+		}
+
+		respond(c, &req, resp)
+	}
+}
+
+// legacyError answers prompts that did not fit into the context of the model
+// with the token counts next to the error, as the deprecated routes always
+// did. Other errors are left to the error middleware.
+func legacyError(c *gin.Context, err error) {
+	e := apiError(err)
+	details, ok := e.Details.(gin.H)
+	if e.Code != api.CodeContextOverflow || !ok {
+		c.Error(err)
+		return
+	}
+
+	body := gin.H{"error": e.Message, "code": e.Code}
+	for key, value := range details {
+		body[key] = value
+	}
+	c.JSON(e.Status, body)
+}
+
+// respondJSON answers with the response of the successor as it is.
+func respondJSON[Req any, Resp any](c *gin.Context, _ *Req, resp Resp) {
+	c.JSON(http.StatusOK, resp)
+}
+
+// readNothing is the read function of routes without parameters.
+func readNothing(*gin.Context, *struct{}) error {
+	return nil
+}
+
+// legacyQuery reads a query parameter the deprecated routes unescape once
+// more, as older clients escape it twice.
+func legacyQuery(c *gin.Context, key string) (string, error) {
+	value, err := url.QueryUnescape(c.Query(key))
+	if err != nil {
+		return "", api.InvalidRequest("invalid search query")
+	}
+	return value, nil
+}
+
+// legacyCodeQuery reads the code from the query parameter key, the other
+// parameters are named as in the versioned API.
+func legacyCodeQuery(key string) func(c *gin.Context, query *api.CodeQuery) error {
+	return func(c *gin.Context, query *api.CodeQuery) error {
+		code, err := legacyQuery(c, key)
+		if err != nil {
+			return err
+		}
+
+		*query = api.CodeQuery{
+			Code:     code,
+			Match:    c.Query("match"),
+			Function: c.Query("function"),
+			Repo:     c.Query("repo"),
+			Model:    c.Query("model"),
+		}
+		return nil
+	}
+}
+
+// readLegacyPromptCount reads the query of the prompt count. Older clients
+// pass the name of a function as query and count the prompts whose code
+// contains it, which the function name stored with every prompt answers now.
+// Queries of more than one word are code.
+func readLegacyPromptCount(c *gin.Context, query *api.CodeQuery) error {
+	if err := legacyCodeQuery("query")(c, query); err != nil {
+		return err
+	}
+
+	if query.Function == "" && query.Match == "" && len(strings.Fields(query.Code)) == 1 {
+		query.Function, query.Code = strings.TrimSpace(query.Code), ""
+	}
+	return nil
+}
+
+// legacySet is the instruct set of the deprecated routes if none is given.
+func legacySet(set string) string {
+	if set == "" {
+		return "default"
+	}
+	return set
+}
+
+// registerLegacy serves the routes that predate the versioned API. Routes
+// whose parameters and answers did not change use the handler of their
+// successor, found by its operation ID in v1.
+func (s *server) registerLegacy(router *gin.Engine, v1 map[string]gin.HandlerFunc) {
+	router.GET("/health", s.legacyHealth)
+
+	router.GET("/weaviate/promptcount", adapt(s.v1PromptCount, readLegacyPromptCount, func(c *gin.Context, _ *api.CodeQuery, resp api.PromptCountResponse) {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(strconv.Itoa(resp.Count)))
+	}))
+	router.GET("/weaviate/retrieveresponse", adapt(s.legacyResponse, legacyCodeQuery("query"), respondJSON))
+	router.GET("/weaviate/retrieveresponselist", adapt(s.v1ResponseList, func(c *gin.Context, query *api.ResponseListQuery) error {
+		query.InstructType = c.Query("instructType")
+		return legacyCodeQuery("query")(c, &query.CodeQuery)
+	}, func(c *gin.Context, _ *api.ResponseListQuery, resp api.ResponseListResponse) {
+		c.JSON(http.StatusOK, resp.Responses)
+	}))
+	router.GET("/weaviate/responsebyid", adapt(s.v1PromptResponse, func(c *gin.Context, path *api.PromptPath) error {
+		var err error
+		path.PromptID, err = legacyQuery(c, "id")
+		return err
+	}, func(c *gin.Context, _ *api.PromptPath, resp api.PromptResponse) {
+		c.JSON(http.StatusOK, resp.Response)
+	}))
+	router.GET("/weaviate/propertiesbyid", adapt(s.v1Prompt, func(c *gin.Context, path *api.PromptPath) error {
+		path.PromptID = c.Query("id")
+		return nil
+	}, respondJSON))
+
+	router.GET("/get-similar-meaning", adapt(s.v1SimilarMeaning, func(c *gin.Context, query *api.SimilarMeaningQuery) error {
+		var err error
+		query.Meaning, err = legacyQuery(c, "meaning")
+		return err
+	}, respondGitURLs[api.SimilarMeaningQuery]))
+	router.GET("/get-similar-code", adapt(s.v1SimilarCode, legacyCodeQuery("code"), respondGitURLs[api.CodeQuery]))
+	router.GET("/get-instructtype", adapt(s.v1InstructTypes, legacyCodeQuery("code"), func(c *gin.Context, _ *api.CodeQuery, resp api.InstructTypesResponse) {
+		c.JSON(http.StatusOK, resp.InstructTypes)
+	}))
+	router.GET("/models", v1["listModels"])
+
+	router.POST("/generate", s.legacyGenerate)
+	router.POST("/vote", adapt(s.v1Vote, readLegacyVote, func(c *gin.Context, _ *api.VoteRequest, _ api.VoteResponse) {
+		c.JSON(http.StatusOK, "OK")
+	}))
+	router.GET("/vote", adapt(s.v1Votes, func(c *gin.Context, path *api.PromptPath) error {
+		path.PromptID = c.Query("id")
+		return nil
+	}, respondJSON))
+
+	router.GET("/get-instruct", adapt(s.v1Instructs, func(c *gin.Context, query *api.InstructSetQuery) error {
+		query.Set = legacySet(c.Query("set"))
+		query.All = c.Query("all") == "true"
+		return nil
+	}, func(c *gin.Context, query *api.InstructSetQuery, resp api.InstructsResponse) {
+		// a single instruct was answered as it is
+		var result interface{} = resp.Instructs
+		if !query.All {
+			result = resp.Instructs[0]
+		}
+		c.JSON(http.StatusOK, gin.H{"result": result})
+	}))
+	router.POST("/add-instruct", adapt(s.v1AddInstruct, func(c *gin.Context, req *api.AddInstructRequest) error {
+		var requestBody struct {
+			Item string `json:"item"`
+			List string `json:"list"`
+		}
+		if err := readLegacyBody(c, &requestBody); err != nil {
+			return err
+		}
+
+		*req = api.AddInstructRequest{Set: legacySet(requestBody.List), Instruct: requestBody.Item}
+		return nil
+	}, func(c *gin.Context, req *api.AddInstructRequest, _ api.InstructsResponse) {
+		c.JSON(http.StatusOK, "added Item to list: "+req.Set)
+	}))
+	router.POST("/del-instruct", adapt(s.v1DeleteInstruct, func(c *gin.Context, req *api.DeleteInstructRequest) error {
+		var requestBody struct {
+			Item string `json:"item"`
+			Set  string `json:"set"`
+		}
+		if err := readLegacyBody(c, &requestBody); err != nil {
+			return err
+		}
+
+		*req = api.DeleteInstructRequest{Set: legacySet(requestBody.Set), Instruct: requestBody.Item}
+		return nil
+	}, func(c *gin.Context, _ *api.DeleteInstructRequest, _ struct{}) {
+		c.Status(http.StatusOK)
+	}))
+	router.GET("/get-all-sets", adapt(s.v1InstructSets, readNothing, func(c *gin.Context, _ *struct{}, resp api.InstructSetsResponse) {
+		c.JSON(http.StatusOK, resp.Sets)
+	}))
+
+	router.POST("/analyze/file", adapt(s.v1AnalyzeFile, readLegacyFile, respondJSON))
+	router.POST("/analyze/repo", s.legacyAnalyzeRepo)
+	router.GET("/analyze/:id", v1["getAnalysis"])
+
+	router.POST("/conversations/:promptID/messages", s.legacyPostMessage)
+	router.GET("/conversations/:promptID/messages", v1["listMessages"])
+	router.POST("/conversations/:promptID/messages/:messageID/vote", v1["voteMessage"])
+	router.GET("/suggestions/:promptID", v1["listSuggestions"])
+	router.GET("/suggestions/:promptID/:suggestionID", v1["getSuggestion"])
+}
+
+func respondGitURLs[Req any](c *gin.Context, _ *Req, resp api.SimilarResponse) {
+	c.JSON(http.StatusOK, resp.GitURLs)
+}
+
+// readLegacyBody decodes the JSON body of a deprecated route. It is validated
+// once converted into the request of the successor.
+func readLegacyBody(c *gin.Context, body interface{}) error {
+	if err := json.NewDecoder(c.Request.Body).Decode(body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return api.InvalidRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// legacyHealth answers with the state of every backing service, also if one
+// is unavailable.
+func (s *server) legacyHealth(c *gin.Context) {
+	health, err := s.v1Health(c, nil)
+
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		if details, ok := apiErr.Details.(api.HealthResponse); ok {
+			c.JSON(apiErr.Status, details)
+			return
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// legacyResponse answers with the best rated response if best is set and
+// with a random one otherwise.
+func (s *server) legacyResponse(c *gin.Context, query *api.CodeQuery) (interface{}, error) {
+	if c.Query("best") == "true" {
+		response, err := s.v1BestResponse(c, query)
+		return response, err
+	}
+	response, err := s.v1RandomResponse(c, query)
+	return response, err
+}
+
+// legacyGenerate reads the prompt from the body, in which the code is named
+// prompt, while the flags may also be passed as query parameters.
+func (s *server) legacyGenerate(c *gin.Context) {
+	var requestBody struct {
+		api.GenerateRequest
+		Prompt string `json:"prompt"`
+	}
+	if err := readLegacyBody(c, &requestBody); err != nil {
+		c.Error(err)
+		return
+	}
+
+	req := requestBody.GenerateRequest
+	if requestBody.Prompt != "" {
+		req.Code = requestBody.Prompt
+	}
+	req.Force = req.Force || c.Query("force") == "true"
+	req.RAG = req.RAG || c.Query("rag") == "true"
+	req.Structured = req.Structured || c.Query("structured") == "true"
+	req.Stream = req.Stream || c.Query("stream") == "true"
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		c.Error(api.InvalidRequest("%v", err))
+		return
+	}
+
+	if err := s.generate(c, req); err != nil {
+		legacyError(c, err)
+	}
+}
+
+// readLegacyVote reads the prompt ID from id. Older clients pass the
+// direction as upvote query parameter instead of the vote in the body.
+func readLegacyVote(c *gin.Context, req *api.VoteRequest) error {
+	var requestBody struct {
+		ID   string `json:"id"`
+		Vote string `json:"vote"`
+	}
+	if err := readLegacyBody(c, &requestBody); err != nil {
+		return err
+	}
+
+	req.PromptID = requestBody.ID
+	req.Vote = requestBody.Vote
+	if req.Vote == "" {
+		req.Vote = "down"
+		if c.Query("upvote") == "true" {
+			req.Vote = "up"
+		}
+	}
+	return nil
+}
+
+// legacyAnalysisQuery reads the options shared by both analysis routes from
+// the query or the form.
+func legacyAnalysisQuery(c *gin.Context) api.AnalyzeRepoQuery {
+	value := func(key string) string {
+		if v := c.Query(key); v != "" {
+			return v
+		}
+		return c.PostForm(key)
+	}
+
+	return api.AnalyzeRepoQuery{
+		Instruct:   value("instruct"),
+		Model:      value("model"),
+		Repository: value("repository"),
+	}
+}
+
+// readLegacyFile reads the file to analyze, which is either uploaded as the
+// multipart field file or sent as JSON like to the versioned API.
+func readLegacyFile(c *gin.Context, req *api.AnalyzeFileRequest) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*maxFileSize)
+
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return readLegacyBody(c, req)
+	}
+
+	header, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	if err != nil {
+		return api.InvalidRequest("file field not found in form")
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	query := legacyAnalysisQuery(c)
+	*req = api.AnalyzeFileRequest{
+		Path:       c.DefaultPostForm("path", header.Filename),
+		Content:    string(content),
+		Instruct:   query.Instruct,
+		Model:      query.Model,
+		Repository: query.Repository,
+	}
+	return nil
+}
+
+// legacyAnalyzeRepo starts the analysis of a tarball uploaded either as the
+// multipart field archive or as the request body.
+func (s *server) legacyAnalyzeRepo(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize)
+	query := legacyAnalysisQuery(c)
+
+	var archive io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("archive")
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(err)
+			return
+		}
+		if err != nil {
+			c.Error(api.InvalidRequest("archive field not found in form"))
+			return
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		defer f.Close()
+		archive = f
+	}
+
+	resp, err := s.startAnalysis(c, query, archive)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", "/analyze/"+resp.ID)
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// legacyPostMessage also streams the answer if stream is set in the query.
+func (s *server) legacyPostMessage(c *gin.Context) {
+	var req api.MessageRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	req.Stream = req.Stream || c.Query("stream") == "true"
+
+	if err := s.converse(c, req); err != nil {
+		legacyError(c, err)
+	}
+}
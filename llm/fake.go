@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// FakeDimensions is the length of the vectors returned by Fake.Embed.
+const FakeDimensions = 64
+
+// Fake is a deterministic provider that never leaves the process. The same
+// input always produces the same output, which makes it suitable for running
+// the generation pipeline offline.
+type Fake struct{}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return f.respond(req.Model, req.Prompt, req.JSON, req.OnToken)
+}
+
+func (f *Fake) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var conversation strings.Builder
+	for _, message := range req.Messages {
+		conversation.WriteString(message.Role)
+		conversation.WriteString(": ")
+		conversation.WriteString(message.Content)
+		conversation.WriteString("\n")
+	}
+
+	return f.respond(req.Model, conversation.String(), req.JSON, req.OnToken)
+}
+
+// Embed returns a normalized bag-of-words vector, so texts sharing words end
+// up close to each other.
+func (f *Fake) Embed(ctx context.Context, model string, input string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	vector := make([]float32, FakeDimensions)
+	for _, word := range strings.Fields(strings.ToLower(input)) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vector[h.Sum32()%FakeDimensions]++
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector, nil
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+
+	return vector, nil
+}
+
+// CountTokens counts with EstimateTokens, so budgets behave the same in every
+// run.
+func (f *Fake) CountTokens(ctx context.Context, model string, text string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return EstimateTokens(text), nil
+}
+
+// respond derives the answer from the input. JSON answers wrap the text in an
+// object with a single response field.
+func (f *Fake) respond(model string, input string, asJSON bool, onToken TokenHandler) (string, error) {
+	sum := sha256.Sum256([]byte(model + "\x00" + input))
+	response := fmt.Sprintf("fake response %016x from %s", binary.BigEndian.Uint64(sum[:8]), model)
+	if asJSON {
+		response = fmt.Sprintf(`{"response": %q}`, response)
+	}
+
+	if onToken != nil {
+		for i, word := range strings.Fields(response) {
+			if i > 0 {
+				word = " " + word
+			}
+			if err := onToken(word); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return response, nil
+}
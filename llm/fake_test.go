@@ -0,0 +1,237 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFakeGenerate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  GenerateRequest
+		// same is a request that must be answered alike, other one that must not
+		same  GenerateRequest
+		other GenerateRequest
+	}{
+		{
+			name:  "prompt",
+			req:   GenerateRequest{Model: "m", Prompt: "explain"},
+			same:  GenerateRequest{Model: "m", Prompt: "explain", Options: map[string]interface{}{"temperature": 0.5}},
+			other: GenerateRequest{Model: "m", Prompt: "refactor"},
+		},
+		{
+			name:  "model",
+			req:   GenerateRequest{Model: "a", Prompt: "explain"},
+			same:  GenerateRequest{Model: "a", Prompt: "explain"},
+			other: GenerateRequest{Model: "b", Prompt: "explain"},
+		},
+	}
+
+	f := NewFake()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Generate(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(got, "from "+tt.req.Model) {
+				t.Errorf("Generate() = %q, want it to name model %s", got, tt.req.Model)
+			}
+
+			same, err := f.Generate(context.Background(), tt.same)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same != got {
+				t.Errorf("Generate() = %q and %q for the same input", got, same)
+			}
+
+			other, err := f.Generate(context.Background(), tt.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == got {
+				t.Errorf("Generate() = %q for different inputs", got)
+			}
+		})
+	}
+}
+
+func TestFakeRespond(t *testing.T) {
+	tests := []struct {
+		name   string
+		json   bool
+		stream bool
+	}{
+		{name: "text"},
+		{name: "json", json: true},
+		{name: "stream", stream: true},
+		{name: "json stream", json: true, stream: true},
+	}
+
+	f := NewFake()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			req := ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "hello"}}, JSON: tt.json}
+			if tt.stream {
+				req.OnToken = func(token string) error {
+					tokens = append(tokens, token)
+					return nil
+				}
+			}
+
+			got, err := f.Chat(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.json {
+				var answer struct {
+					Response string `json:"response"`
+				}
+				if err := json.Unmarshal([]byte(got), &answer); err != nil || answer.Response == "" {
+					t.Errorf("Chat() = %q, want a JSON object with a response", got)
+				}
+			}
+			if tt.stream {
+				if len(tokens) < 2 || strings.Join(tokens, "") != got {
+					t.Errorf("streamed %q, want the tokens of %q", tokens, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	errAbort := errors.New("abort")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		call func(ctx context.Context, f *Fake) error
+		want error
+	}{
+		{
+			name: "generate canceled",
+			ctx:  canceled,
+			call: func(ctx context.Context, f *Fake) error {
+				_, err := f.Generate(ctx, GenerateRequest{Model: "m", Prompt: "p"})
+				return err
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "chat canceled",
+			ctx:  canceled,
+			call: func(ctx context.Context, f *Fake) error {
+				_, err := f.Chat(ctx, ChatRequest{Model: "m"})
+				return err
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "embed canceled",
+			ctx:  canceled,
+			call: func(ctx context.Context, f *Fake) error {
+				_, err := f.Embed(ctx, "m", "text")
+				return err
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "count canceled",
+			ctx:  canceled,
+			call: func(ctx context.Context, f *Fake) error {
+				_, err := f.CountTokens(ctx, "m", "text")
+				return err
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "aborted stream",
+			ctx:  context.Background(),
+			call: func(ctx context.Context, f *Fake) error {
+				_, err := f.Generate(ctx, GenerateRequest{Model: "m", Prompt: "p", OnToken: func(string) error {
+					return errAbort
+				}})
+				return err
+			},
+			want: errAbort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(tt.ctx, NewFake()); !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeEmbed(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		// closer is compared to b, it must be more similar to a
+		closer string
+	}{
+		{name: "shared words", a: "parse the config file", b: "render the page", closer: "read the config file"},
+		{name: "case", a: "Parse Config", b: "write output", closer: "parse config"},
+	}
+
+	f := NewFake()
+	embed := func(text string) []float32 {
+		vector, err := f.Embed(context.Background(), "m", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vector) != FakeDimensions {
+			t.Fatalf("Embed() returned %d dimensions, want %d", len(vector), FakeDimensions)
+		}
+		return vector
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := embed(tt.a)
+			if norm := dot(a, a); math.Abs(norm-1) > 1e-6 {
+				t.Errorf("Embed(%q) has norm %f, want 1", tt.a, norm)
+			}
+			if dot(a, embed(tt.closer)) <= dot(a, embed(tt.b)) {
+				t.Errorf("%q is not closer to %q than %q", tt.closer, tt.a, tt.b)
+			}
+		})
+	}
+
+	if vector := embed(""); dot(vector, vector) != 0 {
+		t.Errorf("Embed(\"\") = %v, want the zero vector", vector)
+	}
+}
+
+func TestFakeCountTokens(t *testing.T) {
+	for _, text := range []string{"", "func main() {}", "   indented\n\tcode"} {
+		got, err := NewFake().CountTokens(context.Background(), "m", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := EstimateTokens(text); got != want {
+			t.Errorf("CountTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
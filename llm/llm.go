@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message is a single turn of a chat conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// TokenHandler receives the tokens of a streamed generation in the order the
+// model produces them. Returning an error aborts the generation.
+type TokenHandler func(token string) error
+
+// GenerateRequest describes a single prompt completion. If OnToken is set the
+// provider streams the answer and calls it for every token. JSON constrains
+// the answer to a JSON object.
+type GenerateRequest struct {
+	Model   string
+	Prompt  string
+	Options map[string]interface{}
+	OnToken TokenHandler
+	JSON    bool
+}
+
+// ChatRequest describes a chat completion over a list of messages. If OnToken
+// is set the provider streams the answer and calls it for every token. JSON
+// constrains the answer to a JSON object.
+type ChatRequest struct {
+	Model    string
+	Messages []Message
+	Options  map[string]interface{}
+	OnToken  TokenHandler
+	JSON     bool
+}
+
+// LLMProvider is implemented by every backend able to serve the models used
+// by the modernizer.
+type LLMProvider interface {
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+	Chat(ctx context.Context, req ChatRequest) (string, error)
+	Embed(ctx context.Context, model string, input string) ([]float32, error)
+}
+
+// New creates the provider with the given name. Supported names are
+// "ollama", "openai" and "fake".
+func New(name string, baseURL string, apiKey string) (LLMProvider, error) {
+	switch name {
+	case "", "ollama":
+		return NewOllama(baseURL), nil
+	case "openai":
+		return NewOpenAI(baseURL, apiKey), nil
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider: %s", name)
+	}
+}
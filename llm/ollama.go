@@ -0,0 +1,306 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// Ollama talks to the native Ollama API.
+type Ollama struct {
+	BaseURL string
+	Client  *http.Client
+
+	// noTokenize is set once the server turned out to have no tokenize
+	// endpoint.
+	noTokenize atomic.Bool
+}
+
+func NewOllama(baseURL string) *Ollama {
+	return &Ollama{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  &http.Client{},
+	}
+}
+
+func (o *Ollama) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":  req.Model,
+		"prompt": req.Prompt,
+		"stream": req.OnToken != nil,
+	}
+	if req.Options != nil {
+		requestBody["options"] = req.Options
+	}
+	if req.JSON {
+		requestBody["format"] = "json"
+	}
+
+	resp, err := o.post(ctx, "/api/generate", requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	type chunk struct {
+		Response string `json:"response"`
+		Done     bool   `json:"done"`
+		Error    string `json:"error"`
+	}
+
+	if req.OnToken == nil {
+		var c chunk
+		if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+			return "", err
+		}
+		if c.Error != "" {
+			return "", errors.New(c.Error)
+		}
+		return c.Response, nil
+	}
+
+	return readNDJSON(resp.Body, req.OnToken, func(decoder *json.Decoder) (string, bool, error) {
+		var c chunk
+		if err := decoder.Decode(&c); err != nil {
+			return "", false, err
+		}
+		if c.Error != "" {
+			return "", false, errors.New(c.Error)
+		}
+		return c.Response, c.Done, nil
+	})
+}
+
+func (o *Ollama) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"stream":   req.OnToken != nil,
+	}
+	if req.Options != nil {
+		requestBody["options"] = req.Options
+	}
+	if req.JSON {
+		requestBody["format"] = "json"
+	}
+
+	resp, err := o.post(ctx, "/api/chat", requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	type chunk struct {
+		Message Message `json:"message"`
+		Done    bool    `json:"done"`
+		Error   string  `json:"error"`
+	}
+
+	if req.OnToken == nil {
+		var c chunk
+		if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+			return "", err
+		}
+		if c.Error != "" {
+			return "", errors.New(c.Error)
+		}
+		return c.Message.Content, nil
+	}
+
+	return readNDJSON(resp.Body, req.OnToken, func(decoder *json.Decoder) (string, bool, error) {
+		var c chunk
+		if err := decoder.Decode(&c); err != nil {
+			return "", false, err
+		}
+		if c.Error != "" {
+			return "", false, errors.New(c.Error)
+		}
+		return c.Message.Content, c.Done, nil
+	})
+}
+
+func (o *Ollama) Embed(ctx context.Context, model string, input string) ([]float32, error) {
+	resp, err := o.post(ctx, "/api/embeddings", map[string]interface{}{
+		"model":  model,
+		"prompt": input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var responseJSON struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return nil, err
+	}
+
+	return responseJSON.Embedding, nil
+}
+
+// Show reads the context length of model from the metadata Ollama keeps for
+// its architecture.
+func (o *Ollama) Show(ctx context.Context, model string) (ModelInfo, error) {
+	resp, err := o.post(ctx, "/api/show", map[string]interface{}{
+		"model": model,
+	})
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	var responseJSON struct {
+		ModelInfo map[string]interface{} `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return ModelInfo{}, err
+	}
+
+	var info ModelInfo
+	architecture, _ := responseJSON.ModelInfo["general.architecture"].(string)
+	if length, ok := responseJSON.ModelInfo[architecture+".context_length"].(float64); ok {
+		info.ContextLength = int(length)
+	}
+
+	return info, nil
+}
+
+// CountTokens uses the tokenize endpoint of Ollama versions that offer it.
+// Other versions return ErrTokenizeUnsupported, which is remembered, so that
+// callers estimate counts without asking again. Counting with a generation
+// instead would evaluate every prompt twice.
+func (o *Ollama) CountTokens(ctx context.Context, model string, text string) (int, error) {
+	if o.noTokenize.Load() {
+		return 0, ErrTokenizeUnsupported
+	}
+
+	tokens, err := o.tokenize(ctx, model, text)
+	if errors.Is(err, ErrTokenizeUnsupported) {
+		o.noTokenize.Store(true)
+	}
+
+	return tokens, err
+}
+
+// tokenize counts the tokens of text with the tokenize endpoint. Servers
+// without it return ErrTokenizeUnsupported.
+func (o *Ollama) tokenize(ctx context.Context, model string, text string) (int, error) {
+	resp, err := o.post(ctx, "/api/tokenize", map[string]interface{}{
+		"model": model,
+		"text":  text,
+	})
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && (statusErr.code == http.StatusNotFound || statusErr.code == http.StatusMethodNotAllowed) {
+		return 0, ErrTokenizeUnsupported
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var responseJSON struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return 0, err
+	}
+
+	return len(responseJSON.Tokens), nil
+}
+
+func (o *Ollama) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	return postJSON(ctx, o.Client, o.BaseURL+path, body, nil)
+}
+
+// readNDJSON consumes a stream of newline delimited JSON chunks, forwards the
+// tokens to onToken and returns the concatenated text.
+func readNDJSON(body io.Reader, onToken TokenHandler, next func(*json.Decoder) (string, bool, error)) (string, error) {
+	var response strings.Builder
+
+	decoder := json.NewDecoder(body)
+	for {
+		token, done, err := next(decoder)
+		if err == io.EOF {
+			return "", errors.New("stream ended before generation was done")
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if token != "" {
+			response.WriteString(token)
+			if err := onToken(token); err != nil {
+				return "", err
+			}
+		}
+
+		if done {
+			return response.String(), nil
+		}
+	}
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, header http.Header) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := &statusError{url: url, status: resp.Status, code: resp.StatusCode, message: strings.TrimSpace(string(message))}
+		return nil, errkind.Wrap(statusKind(resp.StatusCode), err)
+	}
+
+	return resp, nil
+}
+
+// statusError is returned for responses with a status other than 200 OK.
+type statusError struct {
+	url     string
+	status  string
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned %s: %s", e.url, e.status, e.message)
+}
+
+// statusKind classifies the status of an LLM server. Unknown paths and models
+// mean the server is not set up as configured, not that the caller asked for
+// something missing.
+func statusKind(code int) errkind.Kind {
+	kind := errkind.FromStatus(code)
+	if kind == errkind.NotFound {
+		return errkind.Unavailable
+	}
+	return kind
+}
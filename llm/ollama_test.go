@@ -0,0 +1,398 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"strconv"
+)
+
+// recorded is the request a test server received.
+type recorded struct {
+	method string
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+// answer starts a server answering every request with status and body, and
+// records the last request.
+func answer(t *testing.T, status int, body string) (*httptest.Server, *recorded) {
+	t.Helper()
+
+	last := &recorded{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request: %v", err)
+		}
+		*last = recorded{method: r.Method, path: r.URL.Path, header: r.Header.Clone()}
+		if err := json.Unmarshal(raw, &last.body); err != nil {
+			t.Errorf("request body is no JSON object: %v", err)
+		}
+
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, last
+}
+
+// collect returns a TokenHandler appending to tokens.
+func collect(tokens *[]string) TokenHandler {
+	return func(token string) error {
+		*tokens = append(*tokens, token)
+		return nil
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		header  http.Header
+		want    errkind.Kind
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK, body: `{}`, header: http.Header{"Authorization": {"Bearer key"}}},
+		{name: "unknown model", status: http.StatusNotFound, body: `{"error":"model not found"}`, want: errkind.Unavailable, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, body: "boom", want: errkind.Unavailable, wantErr: true},
+		{name: "overloaded", status: http.StatusTooManyRequests, body: "slow down", want: errkind.Unavailable, wantErr: true},
+		{name: "timeout", status: http.StatusGatewayTimeout, body: "too slow", want: errkind.Timeout, wantErr: true},
+		{name: "conflict", status: http.StatusConflict, body: "busy", want: errkind.Conflict, wantErr: true},
+		{name: "bad request", status: http.StatusBadRequest, body: "  bad  \n", want: errkind.Internal, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, tt.status, tt.body)
+
+			resp, err := postJSON(context.Background(), server.Client(), server.URL+"/path", map[string]string{"key": "value"}, tt.header)
+			if last.method != http.MethodPost || last.path != "/path" || last.body["key"] != "value" {
+				t.Errorf("server received %s %s %v", last.method, last.path, last.body)
+			}
+			if got := last.header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			for key := range tt.header {
+				if got := last.header.Get(key); got != tt.header.Get(key) {
+					t.Errorf("header %s = %q, want %q", key, got, tt.header.Get(key))
+				}
+			}
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return
+			}
+
+			var statusErr *statusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("got error %v, want a statusError", err)
+			}
+			if statusErr.code != tt.status || statusErr.message != strings.TrimSpace(tt.body) {
+				t.Errorf("statusError = %+v, want status %d and message %q", statusErr, tt.status, strings.TrimSpace(tt.body))
+			}
+			if !strings.Contains(err.Error(), server.URL+"/path") || !strings.Contains(err.Error(), http.StatusText(tt.status)) {
+				t.Errorf("error %q does not name the URL and the status", err)
+			}
+			if got := errkind.Of(err); got != tt.want {
+				t.Errorf("error kind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostJSONUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := postJSON(context.Background(), server.Client(), server.URL, struct{}{}, nil)
+	if got := errkind.Of(err); got != errkind.Unavailable {
+		t.Errorf("error %v has kind %v, want %v", err, got, errkind.Unavailable)
+	}
+}
+
+func TestOllamaGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      GenerateRequest
+		response string
+		want     string
+		// wantBody holds the fields the request body must have
+		wantBody   map[string]interface{}
+		wantTokens []string
+		wantErr    string
+	}{
+		{
+			name:     "text",
+			req:      GenerateRequest{Model: "m", Prompt: "p"},
+			response: `{"response":"answer","done":true}`,
+			want:     "answer",
+			wantBody: map[string]interface{}{"model": "m", "prompt": "p", "stream": false},
+		},
+		{
+			name:     "options and json",
+			req:      GenerateRequest{Model: "m", Prompt: "p", Options: map[string]interface{}{"num_ctx": 4096}, JSON: true},
+			response: `{"response":"{}","done":true}`,
+			want:     "{}",
+			wantBody: map[string]interface{}{"format": "json", "options": map[string]interface{}{"num_ctx": float64(4096)}},
+		},
+		{
+			name:     "error",
+			req:      GenerateRequest{Model: "m", Prompt: "p"},
+			response: `{"error":"out of memory"}`,
+			wantErr:  "out of memory",
+		},
+		{
+			name:       "stream",
+			req:        GenerateRequest{Model: "m", Prompt: "p"},
+			response:   `{"response":"an","done":false}` + "\n" + `{"response":"","done":false}` + "\n" + `{"response":"swer","done":true}` + "\n",
+			want:       "answer",
+			wantBody:   map[string]interface{}{"stream": true},
+			wantTokens: []string{"an", "swer"},
+		},
+		{
+			name:       "stream cut off",
+			req:        GenerateRequest{Model: "m", Prompt: "p"},
+			response:   `{"response":"an","done":false}` + "\n",
+			wantTokens: []string{"an"},
+			wantErr:    "stream ended before generation was done",
+		},
+		{
+			name:       "stream error",
+			req:        GenerateRequest{Model: "m", Prompt: "p"},
+			response:   `{"response":"an","done":false}` + "\n" + `{"error":"model unloaded"}` + "\n",
+			wantTokens: []string{"an"},
+			wantErr:    "model unloaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, http.StatusOK, tt.response)
+			o := NewOllama(server.URL + "/")
+
+			var tokens []string
+			if tt.wantTokens != nil {
+				tt.req.OnToken = collect(&tokens)
+			}
+
+			got, err := o.Generate(context.Background(), tt.req)
+			if last.path != "/api/generate" {
+				t.Errorf("requested %s, want /api/generate", last.path)
+			}
+			checkBody(t, last.body, tt.wantBody)
+			if !reflect.DeepEqual(tokens, tt.wantTokens) {
+				t.Errorf("streamed %q, want %q", tokens, tt.wantTokens)
+			}
+			checkResult(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestOllamaChat(t *testing.T) {
+	messages := []Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}}
+
+	tests := []struct {
+		name       string
+		stream     bool
+		response   string
+		want       string
+		wantTokens []string
+	}{
+		{
+			name:     "text",
+			response: `{"message":{"role":"assistant","content":"hello"},"done":true}`,
+			want:     "hello",
+		},
+		{
+			name:       "stream",
+			stream:     true,
+			response:   `{"message":{"role":"assistant","content":"hel"},"done":false}` + "\n" + `{"message":{"role":"assistant","content":"lo"},"done":true}` + "\n",
+			want:       "hello",
+			wantTokens: []string{"hel", "lo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, http.StatusOK, tt.response)
+
+			var tokens []string
+			req := ChatRequest{Model: "m", Messages: messages}
+			if tt.stream {
+				req.OnToken = collect(&tokens)
+			}
+
+			got, err := NewOllama(server.URL).Chat(context.Background(), req)
+			if last.path != "/api/chat" {
+				t.Errorf("requested %s, want /api/chat", last.path)
+			}
+			checkBody(t, last.body, map[string]interface{}{
+				"model":  "m",
+				"stream": tt.stream,
+				"messages": []interface{}{
+					map[string]interface{}{"role": "system", "content": "be brief"},
+					map[string]interface{}{"role": "user", "content": "hi"},
+				},
+			})
+			if !reflect.DeepEqual(tokens, tt.wantTokens) {
+				t.Errorf("streamed %q, want %q", tokens, tt.wantTokens)
+			}
+			checkResult(t, got, err, tt.want, "")
+		})
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	server, last := answer(t, http.StatusOK, `{"embedding":[0.5,-1]}`)
+
+	got, err := NewOllama(server.URL).Embed(context.Background(), "m", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.path != "/api/embeddings" {
+		t.Errorf("requested %s, want /api/embeddings", last.path)
+	}
+	checkBody(t, last.body, map[string]interface{}{"model": "m", "prompt": "text"})
+	if want := []float32{0.5, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Embed() = %v, want %v", got, want)
+	}
+}
+
+func TestOllamaShow(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     int
+	}{
+		{name: "context length", response: `{"model_info":{"general.architecture":"llama","llama.context_length":16384}}`, want: 16384},
+		{name: "other architecture", response: `{"model_info":{"general.architecture":"qwen2","llama.context_length":16384}}`},
+		{name: "no model info", response: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, http.StatusOK, tt.response)
+
+			got, err := NewOllama(server.URL).Show(context.Background(), "m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last.path != "/api/show" || last.body["model"] != "m" {
+				t.Errorf("requested %s with %v", last.path, last.body)
+			}
+			if got.ContextLength != tt.want {
+				t.Errorf("ContextLength = %d, want %d", got.ContextLength, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaCountTokens(t *testing.T) {
+	// reply holds the status and the body answering a request to a path
+	type reply struct {
+		status int
+		body   string
+	}
+	const tokenize = "/api/tokenize"
+
+	tests := []struct {
+		name    string
+		replies map[string]reply
+		want    int
+		wantErr string
+		// wantPaths lists the requests of the first and the second count
+		wantPaths []string
+	}{
+		{
+			name:      "tokenize",
+			replies:   map[string]reply{tokenize: {http.StatusOK, `{"tokens":[1,2,3]}`}},
+			want:      3,
+			wantPaths: []string{tokenize, tokenize},
+		},
+		{
+			name:      "unsupported",
+			replies:   map[string]reply{tokenize: {http.StatusNotFound, "404 page not found"}},
+			wantErr:   ErrTokenizeUnsupported.Error(),
+			wantPaths: []string{tokenize},
+		},
+		{
+			name: "tokenize fails",
+			replies: map[string]reply{
+				tokenize: {http.StatusInternalServerError, "out of memory"},
+			},
+			wantErr:   "out of memory",
+			wantPaths: []string{tokenize, tokenize},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+
+				reply, ok := tt.replies[r.URL.Path]
+				if !ok {
+					reply.status = http.StatusNotFound
+				}
+				w.WriteHeader(reply.status)
+				io.WriteString(w, reply.body)
+			}))
+			defer server.Close()
+
+			o := NewOllama(server.URL)
+			for i := 0; i < 2; i++ {
+				got, err := o.CountTokens(context.Background(), "m", "some text")
+				checkResult(t, strconv.Itoa(got), err, strconv.Itoa(tt.want), tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("requested %q, want %q", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+// checkBody fails unless body has every field of want.
+func checkBody(t *testing.T, body map[string]interface{}, want map[string]interface{}) {
+	t.Helper()
+
+	for key, value := range want {
+		if !reflect.DeepEqual(body[key], value) {
+			t.Errorf("request field %s = %#v, want %#v", key, body[key], value)
+		}
+	}
+}
+
+// checkResult fails unless got is want, or err contains wantErr if set.
+func checkResult(t *testing.T, got string, err error, want string, wantErr string) {
+	t.Helper()
+
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("got error %v, want %q", err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI talks to any server implementing the OpenAI /v1/chat/completions
+// API, e.g. llama.cpp or vLLM.
+type OpenAI struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewOpenAI(baseURL string, apiKey string) *OpenAI {
+	return &OpenAI{
+		BaseURL: strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1"),
+		APIKey:  apiKey,
+		Client:  &http.Client{},
+	}
+}
+
+// openAIOptions maps the Ollama style options used throughout the backend to
+// their OpenAI counterparts. Options without a counterpart such as num_ctx
+// are dropped since the context size is fixed by the server.
+var openAIOptions = map[string]string{
+	"temperature": "temperature",
+	"top_p":       "top_p",
+	"seed":        "seed",
+	"stop":        "stop",
+	"num_predict": "max_tokens",
+	"max_tokens":  "max_tokens",
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	return o.Chat(ctx, ChatRequest{
+		Model:    req.Model,
+		Messages: []Message{{Role: "user", Content: req.Prompt}},
+		Options:  req.Options,
+		OnToken:  req.OnToken,
+		JSON:     req.JSON,
+	})
+}
+
+func (o *OpenAI) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	requestBody := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+		"stream":   req.OnToken != nil,
+	}
+	for key, value := range req.Options {
+		if mapped, ok := openAIOptions[key]; ok {
+			requestBody[mapped] = value
+		}
+	}
+	if req.JSON {
+		requestBody["response_format"] = map[string]interface{}{"type": "json_object"}
+	}
+
+	resp, err := o.post(ctx, "/v1/chat/completions", requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if req.OnToken == nil {
+		var responseJSON struct {
+			Choices []struct {
+				Message Message `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+			return "", err
+		}
+		if len(responseJSON.Choices) == 0 {
+			return "", errors.New("invalid response format: no choices returned")
+		}
+		return responseJSON.Choices[0].Message.Content, nil
+	}
+
+	return readEventStream(resp.Body, req.OnToken)
+}
+
+func (o *OpenAI) Embed(ctx context.Context, model string, input string) ([]float32, error) {
+	resp, err := o.post(ctx, "/v1/embeddings", map[string]interface{}{
+		"model": model,
+		"input": input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var responseJSON struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return nil, err
+	}
+	if len(responseJSON.Data) == 0 {
+		return nil, errors.New("invalid response format: no embedding returned")
+	}
+
+	return responseJSON.Data[0].Embedding, nil
+}
+
+// CountTokens uses the /tokenize endpoint offered by llama.cpp and vLLM next
+// to the OpenAI API. Servers without it return ErrTokenizeUnsupported.
+func (o *OpenAI) CountTokens(ctx context.Context, model string, text string) (int, error) {
+	// llama.cpp reads content, vLLM reads model and prompt
+	resp, err := o.post(ctx, "/tokenize", map[string]interface{}{
+		"model":   model,
+		"prompt":  text,
+		"content": text,
+	})
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && (statusErr.code == http.StatusNotFound || statusErr.code == http.StatusMethodNotAllowed) {
+		return 0, ErrTokenizeUnsupported
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var responseJSON struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return 0, err
+	}
+
+	return len(responseJSON.Tokens), nil
+}
+
+func (o *OpenAI) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	header := http.Header{}
+	if o.APIKey != "" {
+		header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+
+	return postJSON(ctx, o.Client, o.BaseURL+path, body, header)
+}
+
+// readEventStream consumes the Server-Sent Events of a streamed chat
+// completion, forwards the deltas to onToken and returns the concatenated text.
+func readEventStream(body io.Reader, onToken TokenHandler) (string, error) {
+	var response strings.Builder
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return "", errors.New("stream ended before generation was done")
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return response.String(), nil
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta        Message `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", err
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			response.WriteString(choice.Delta.Content)
+			if err := onToken(choice.Delta.Content); err != nil {
+				return "", err
+			}
+		}
+	}
+}
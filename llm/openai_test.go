@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNewOpenAI(t *testing.T) {
+	for _, baseURL := range []string{"http://llm:8080", "http://llm:8080/", "http://llm:8080/v1", "http://llm:8080/v1/"} {
+		if got := NewOpenAI(baseURL, "").BaseURL; got != "http://llm:8080" {
+			t.Errorf("NewOpenAI(%q).BaseURL = %q, want http://llm:8080", baseURL, got)
+		}
+	}
+}
+
+func TestOpenAIChat(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        ChatRequest
+		apiKey     string
+		response   string
+		want       string
+		wantBody   map[string]interface{}
+		wantAbsent []string
+		wantTokens []string
+		wantErr    string
+	}{
+		{
+			name:     "text",
+			req:      ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}},
+			response: `{"choices":[{"message":{"role":"assistant","content":"hello"}}]}`,
+			want:     "hello",
+			wantBody: map[string]interface{}{
+				"model":    "m",
+				"stream":   false,
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "hi"}},
+			},
+		},
+		{
+			name: "options",
+			req: ChatRequest{Model: "m", Options: map[string]interface{}{
+				"temperature": 0.2,
+				"num_predict": 128,
+				"num_ctx":     4096,
+			}, JSON: true},
+			response: `{"choices":[{"message":{"content":"{}"}}]}`,
+			want:     "{}",
+			wantBody: map[string]interface{}{
+				"temperature":     0.2,
+				"max_tokens":      float64(128),
+				"response_format": map[string]interface{}{"type": "json_object"},
+			},
+			wantAbsent: []string{"num_predict", "num_ctx"},
+		},
+		{
+			name:     "no choices",
+			req:      ChatRequest{Model: "m"},
+			response: `{"choices":[]}`,
+			wantErr:  "no choices returned",
+		},
+		{
+			name: "stream",
+			req:  ChatRequest{Model: "m"},
+			response: ": keep-alive\n\n" +
+				`data: {"choices":[{"delta":{"role":"assistant"}}]}` + "\n\n" +
+				`data: {"choices":[{"delta":{"content":"hel"}}]}` + "\n\n" +
+				`data:{"choices":[{"delta":{"content":"lo"},"finish_reason":"stop"}]}` + "\n\n" +
+				"data: [DONE]\n\n",
+			want:       "hello",
+			wantBody:   map[string]interface{}{"stream": true},
+			wantTokens: []string{"hel", "lo"},
+		},
+		{
+			name:       "stream cut off",
+			req:        ChatRequest{Model: "m"},
+			response:   `data: {"choices":[{"delta":{"content":"hel"}}]}` + "\n\n",
+			wantTokens: []string{"hel"},
+			wantErr:    "stream ended before generation was done",
+		},
+		{
+			name:       "stream garbage",
+			req:        ChatRequest{Model: "m"},
+			response:   "data: {not json}\n\n",
+			wantTokens: []string{},
+			wantErr:    "invalid character",
+		},
+		{
+			name:     "api key",
+			req:      ChatRequest{Model: "m"},
+			apiKey:   "secret",
+			response: `{"choices":[{"message":{"content":"ok"}}]}`,
+			want:     "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, http.StatusOK, tt.response)
+			o := NewOpenAI(server.URL, tt.apiKey)
+
+			tokens := []string{}
+			if tt.wantTokens != nil {
+				tt.req.OnToken = collect(&tokens)
+			} else {
+				tt.wantTokens = []string{}
+			}
+
+			got, err := o.Chat(context.Background(), tt.req)
+			if last.path != "/v1/chat/completions" {
+				t.Errorf("requested %s, want /v1/chat/completions", last.path)
+			}
+			checkBody(t, last.body, tt.wantBody)
+			for _, key := range tt.wantAbsent {
+				if _, ok := last.body[key]; ok {
+					t.Errorf("request has field %s", key)
+				}
+			}
+			wantAuth := ""
+			if tt.apiKey != "" {
+				wantAuth = "Bearer " + tt.apiKey
+			}
+			if got := last.header.Get("Authorization"); got != wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, wantAuth)
+			}
+			if !reflect.DeepEqual(tokens, tt.wantTokens) {
+				t.Errorf("streamed %q, want %q", tokens, tt.wantTokens)
+			}
+			checkResult(t, got, err, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestOpenAIGenerate(t *testing.T) {
+	server, last := answer(t, http.StatusOK, `{"choices":[{"message":{"content":"answer"}}]}`)
+
+	got, err := NewOpenAI(server.URL, "").Generate(context.Background(), GenerateRequest{Model: "m", Prompt: "explain"})
+	checkResult(t, got, err, "answer", "")
+	checkBody(t, last.body, map[string]interface{}{
+		"model":    "m",
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": "explain"}},
+	})
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []float32
+		wantErr  bool
+	}{
+		{name: "embedding", response: `{"data":[{"embedding":[1,0.25]}]}`, want: []float32{1, 0.25}},
+		{name: "no data", response: `{"data":[]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, http.StatusOK, tt.response)
+
+			got, err := NewOpenAI(server.URL, "").Embed(context.Background(), "m", "text")
+			if last.path != "/v1/embeddings" {
+				t.Errorf("requested %s, want /v1/embeddings", last.path)
+			}
+			checkBody(t, last.body, map[string]interface{}{"model": "m", "input": "text"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Embed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAICountTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     int
+		wantErr  error
+	}{
+		{name: "llama.cpp", status: http.StatusOK, response: `{"tokens":[1,2,3]}`, want: 3},
+		{name: "vllm", status: http.StatusOK, response: `{"tokens":[1,2],"count":2}`, want: 2},
+		{name: "not found", status: http.StatusNotFound, response: "404 page not found", wantErr: ErrTokenizeUnsupported},
+		{name: "method not allowed", status: http.StatusMethodNotAllowed, wantErr: ErrTokenizeUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, last := answer(t, tt.status, tt.response)
+
+			got, err := NewOpenAI(server.URL, "").CountTokens(context.Background(), "m", "some text")
+			if last.path != "/tokenize" {
+				t.Errorf("requested %s, want /tokenize", last.path)
+			}
+			checkBody(t, last.body, map[string]interface{}{"model": "m", "prompt": "some text", "content": "some text"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CountTokens() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"unicode"
+)
+
+// ErrTokenizeUnsupported is returned by a Tokenizer whose server cannot
+// tokenize text.
+var ErrTokenizeUnsupported = errors.New("server does not support tokenization")
+
+// Tokenizer is implemented by providers able to count the tokens of a text
+// with the tokenizer of the model.
+type Tokenizer interface {
+	CountTokens(ctx context.Context, model string, text string) (int, error)
+}
+
+// ModelInfo holds the properties a provider reports for a model.
+type ModelInfo struct {
+	// ContextLength is the number of tokens the model was trained for, zero
+	// if unknown.
+	ContextLength int
+}
+
+// Inspector is implemented by providers able to report the properties of a
+// model.
+type Inspector interface {
+	Show(ctx context.Context, model string) (ModelInfo, error)
+}
+
+// EstimateTokens approximates the number of tokens of text for models whose
+// tokenizer is not available. It errs on the high side for source code:
+// ASCII letters and digits count one token per three characters, every other
+// character except single spaces counts as a token of its own.
+func EstimateTokens(text string) int {
+	tokens := 0
+	word := 0
+	spaces := 0
+
+	flush := func() {
+		tokens += (word + 2) / 3
+		word = 0
+		// a single space is merged into the following token
+		if spaces > 1 {
+			tokens += (spaces + 3) / 4
+		}
+		spaces = 0
+	}
+
+	for _, r := range text {
+		switch {
+		case r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			if spaces > 0 {
+				flush()
+			}
+			word++
+		case r == ' ':
+			if word > 0 {
+				flush()
+			}
+			spaces++
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+
+	return tokens
+}
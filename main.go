@@ -1,289 +1,379 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/admin"
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/auth"
+	"github.com/rwth-acis/modernizer/config"
+	"github.com/rwth-acis/modernizer/llm"
 	"github.com/rwth-acis/modernizer/ollama"
+	"github.com/rwth-acis/modernizer/ranking"
 	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/registry"
 	"github.com/rwth-acis/modernizer/weaviate"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
 )
 
-func main() {
-	err := weaviate.InitSchema()
-	if err != nil {
-		panic(err)
-	}
-
-	redis.InitRedis()
+// server bundles the dependencies of the HTTP handlers.
+type server struct {
+	store     weaviate.Store
+	redis     redis.Store
+	generator *ollama.Generator
+	models    *registry.Registry
+	tokens    *auth.Authenticator
+	resetter  *admin.Resetter
+	checker   *admin.Checker
+	transfer  *admin.Transfer
+	strategy  ranking.Strategy
+	proxies   []string // may set X-Forwarded-For, nil trusts none
+}
 
-	router := gin.New()
+func newServer(store weaviate.Store, rdb redis.Store, provider llm.LLMProvider, models *registry.Registry, instructs ollama.InstructSource) *server {
+	generator := &ollama.Generator{
+		Store:     store,
+		Provider:  provider,
+		Models:    models,
+		Instructs: instructs,
+	}
 
-	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{
-		SkipPaths: []string{"/weaviate/promptcount", "/weaviate"},
-	}))
-	router.Use(gin.Recovery())
+	return &server{
+		store:     store,
+		redis:     rdb,
+		generator: generator,
+		models:    models,
+		tokens:    auth.NewAuthenticator(nil),
+		strategy:  ranking.Wilson{Z: 1.96},
+		resetter: &admin.Resetter{
+			Store:       store,
+			Sets:        rdb,
+			Votes:       rdb,
+			SnapshotDir: "snapshots",
+		},
+		checker: &admin.Checker{
+			Store:     store,
+			Responses: generator,
+		},
+		transfer: &admin.Transfer{
+			Store: store,
+			Sets:  rdb,
+			Votes: rdb,
+		},
+	}
+}
 
-	router.GET("/weaviate/promptcount", func(c *gin.Context) {
-		searchQuery := c.Query("query")
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "backfill":
+			runBackfill(os.Args[2:])
 			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		default:
+			log.Fatalf("unknown command %q, expected serve, backfill, migrate, export or import", os.Args[1])
 		}
+	}
 
-		count, err := weaviate.RetrievePromptCount(decodedQuery)
+	serve(nil)
+}
+
+// openStore connects to the configured store.
+func openStore(cfg *config.Config) (weaviate.Store, error) {
+	switch cfg.Store {
+	case "weaviate":
+		client, err := weaviate.NewClient(cfg.Weaviate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, fmt.Errorf("could not connect to weaviate: %w", err)
 		}
+		return client, nil
+	case "memory":
+		return memory.New(nil), nil
+	default:
+		return nil, fmt.Errorf("unknown store: %s", cfg.Store)
+	}
+}
 
-		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(strconv.Itoa(count)))
-	})
+// openRedis connects to Redis and checks that it is reachable.
+func openRedis(ctx context.Context, cfg *config.Config) (*redis.Client, error) {
+	rdb, err := redis.NewClient(cfg.Redis)
+	if err != nil {
+		return nil, fmt.Errorf("could not create redis client: %w", err)
+	}
 
-	router.GET("/weaviate/retrieveresponse", func(c *gin.Context) {
+	err = rdb.Health(ctx)
+	if err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("redis is not reachable: %w", err)
+	}
 
-		//TODO: allow user annotation
+	return rdb, nil
+}
 
-		searchQuery := c.Query("query")
-		upvoteStr := c.Query("best")
-		best := upvoteStr == "true"
+// openModels returns the configured model registry.
+func openModels(cfg *config.Config) (*registry.Registry, error) {
+	if cfg.Models.Registry == "" {
+		return registry.Default(cfg.Models.Default, cfg.Models.SemanticMeaning), nil
+	}
 
-		// Decode the search query
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
-			return
-		}
+	return registry.Load(cfg.Models.Registry)
+}
 
-		log.Printf("Decoded Query: %s", decodedQuery)
-
-		var response interface{}
-
-		if best {
-			response, err = weaviate.RetrieveBestResponse(decodedQuery)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		} else {
-			response, err = weaviate.RetrieveRandomResponse(decodedQuery)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
+// loadConfig parses the command line of a subcommand and exits with a list of
+// all invalid values if the configuration cannot be used.
+func loadConfig(fs *flag.FlagSet, args []string) *config.Config {
+	return checkConfig(config.Load(fs, args))
+}
 
-		c.JSON(http.StatusOK, response)
-	})
+// loadStoreConfig is loadConfig for subcommands that only use the store.
+func loadStoreConfig(fs *flag.FlagSet, args []string) *config.Config {
+	return checkConfig(config.LoadStore(fs, args))
+}
 
-	router.GET("/weaviate/retrieveresponselist", func(c *gin.Context) {
-		searchQuery := c.Query("query")
-		instructType := c.Query("instructType")
+func checkConfig(cfg *config.Config, err error) *config.Config {
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
 
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
-			return
-		}
+	log.Printf("effective configuration:\n%s", cfg)
 
-		log.Printf("Decoded Query: %s", decodedQuery)
+	return cfg
+}
 
-		responseList, err := weaviate.ResponseList(decodedQuery, instructType)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+func serve(args []string) {
+	cfg := loadConfig(flag.NewFlagSet("serve", flag.ExitOnError), args)
 
-		c.JSON(http.StatusOK, responseList)
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	router.GET("/weaviate/responsebyid", func(c *gin.Context) {
-		searchQuery := c.Query("id")
+	store, err := openStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeLogged("store", store)
 
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
-			return
-		}
+	err = store.InitSchema(ctx)
+	if err != nil {
+		panic(err)
+	}
 
-		response, err := weaviate.RetrieveResponseByID(decodedQuery)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	rdb, err := openRedis(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeLogged("redis", rdb)
 
-		c.JSON(http.StatusOK, response)
-	})
+	rdb.InitRedis(ctx)
 
-	router.GET("/weaviate/propertiesbyid", func(c *gin.Context) {
-		id := c.Query("id")
+	provider, err := llm.New(cfg.LLM.Provider, cfg.LLM.URL, cfg.LLM.APIKey)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		response, err := weaviate.RetrieveProperties(id)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	models, err := openModels(cfg)
+	if err != nil {
+		log.Fatalf("could not load model registry: %v", err)
+	}
 
-		c.JSON(http.StatusOK, response)
-	})
+	s := newServer(store, rdb, provider, models, rdb)
+	tokens, err := auth.ParseTokens(cfg.APITokens)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.tokens = auth.NewAuthenticator(tokens)
+	s.proxies, err = config.ParseProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(s.proxies) == 0 {
+		log.Println("warning: TRUSTED_PROXIES is empty, anonymous voters behind a reverse proxy share the address of the proxy and therefore one vote")
+	}
+	s.resetter.SnapshotDir = cfg.SnapshotDir
+	s.checker.MaxRepairs = cfg.ConsistencyMaxRepairs
+
+	s.strategy, err = ranking.New(cfg.Ranking)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.generator.Strategy = s.strategy
+
+	queue := rdb.Queue(ollama.SemanticMeaningQueue)
+	queue.Policy = cfg.Queue.Policy
+	queue.Visibility = cfg.Queue.Visibility
+	s.generator.Queue = queue
+	s.generator.Cache = rdb.ResponseCache()
+	s.resetter.Cache = s.generator.Cache
+	s.generator.StartWorkers(cfg.Queue.Workers)
+
+	if cfg.ConsistencyInterval > 0 {
+		go s.checker.Run(ctx, cfg.ConsistencyInterval)
+	}
 
-	router.GET("/get-similar-meaning", func(c *gin.Context) {
-		searchQuery := c.Query("meaning")
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: s.router(),
+	}
 
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
-			return
-		}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
 
-		response, err := SemanticSimilarityByMeaning(decodedQuery)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("server stopped: %v", err)
 		}
+	case <-ctx.Done():
+	}
+	stop()
 
-		c.JSON(http.StatusOK, response)
-	})
+	log.Println("shutting down, draining in-flight requests")
 
-	router.GET("/get-similar-code", func(c *gin.Context) {
-		searchQuery := c.Query("code")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-		decodedQuery, err := url.QueryUnescape(searchQuery)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
-			return
-		}
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("could not drain in-flight requests: %v", err)
+	}
 
-		response, err := SemanticSimilarityByCode(decodedQuery)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	err = s.generator.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("could not finish pending semantic meaning jobs: %v", err)
+	}
+}
 
-		c.JSON(http.StatusOK, response)
-	})
+func (s *server) router() *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(s.proxies); err != nil {
+		log.Fatalf("invalid trusted proxies: %v", err)
+	}
+
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{
+		SkipPaths: []string{"/weaviate/promptcount", "/weaviate", "/health", api.Prefix + "/health"},
+	}))
+	router.Use(gin.Recovery())
+	router.Use(handleErrors)
+	router.Use(deprecated)
+
+	v1 := s.registerV1(router)
+	s.registerLegacy(router, v1)
 
-	router.GET("/get-instructtype", func(c *gin.Context) {
-		searchQuery := c.Query("code")
+	adminAPI := router.Group("/admin")
 
-		decodedQuery, err := url.QueryUnescape(searchQuery)
+	adminAPI.GET("/jobs", s.tokens.Require(auth.RoleViewer), func(c *gin.Context) {
+		queue := s.generator.Queue
+
+		stats, err := queue.Stats(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
+			c.Error(err)
 			return
 		}
 
-		response, err := weaviate.GetInstructTypes(decodedQuery)
+		failed, err := queue.DeadJobs(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 
-		c.JSON(http.StatusOK, response)
+		c.JSON(http.StatusOK, gin.H{"stats": stats, "failed": failed})
 	})
 
-	router.POST("/generate", func(c *gin.Context) {
-		var requestBody map[string]interface{}
-		if err := c.BindJSON(&requestBody); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	adminAPI.POST("/jobs/requeue", s.tokens.Require(auth.RoleOperator), func(c *gin.Context) {
+		var requestBody struct {
+			IDs []string `json:"ids"`
+		}
+		if err := c.ShouldBindJSON(&requestBody); err != nil && !errors.Is(err, io.EOF) {
+			c.Error(api.InvalidRequest("invalid request body: %v", err))
 			return
 		}
 
-		response, err := ollama.GenerateResponse(requestBody)
+		requeued, err := s.generator.Queue.Requeue(c.Request.Context(), requestBody.IDs...)
 		if err != nil {
+			c.Error(err)
 			return
 		}
 
-		c.JSON(http.StatusOK, response)
+		c.JSON(http.StatusOK, gin.H{"requeued": requeued})
 	})
-	router.POST("/vote", func(c *gin.Context) {
 
-		upvoteStr := c.Query("upvote")
-		upvote := upvoteStr == "true"
-
-		var requestBody map[string]interface{}
-		if err := c.BindJSON(&requestBody); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	adminAPI.GET("/cache", s.tokens.Require(auth.RoleViewer), func(c *gin.Context) {
+		if s.generator.Cache == nil {
+			c.Error(api.NotFound("response cache is disabled"))
 			return
 		}
 
-		log.Printf("%v\n", requestBody)
-
-		if upvote {
-			err := weaviate.UpdateRankPrompt(requestBody, true)
-			if err != nil {
-				log.Printf("%v", err)
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-		} else {
-			err := weaviate.UpdateRankPrompt(requestBody, false)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				log.Printf("%v", err)
-				return
-			}
+		stats, err := s.generator.Cache.Stats(c.Request.Context())
+		if err != nil {
+			c.Error(err)
+			return
 		}
 
-		c.JSON(http.StatusOK, "OK")
+		c.JSON(http.StatusOK, stats)
 	})
 
-	router.GET("/get-instruct", func(c *gin.Context) {
-		setName := c.Query("set")
-		getAll := c.Query("all") == "true"
+	adminAPI.POST("/reset", s.tokens.Require(auth.RoleAdmin), s.reset)
 
-		var result interface{}
-		var err error
-		if getAll {
-			result, err = redis.GetSet(setName)
-		} else {
-			result, err = redis.GetSetMember(setName)
-		}
+	adminAPI.GET("/export", s.tokens.Require(auth.RoleOperator), s.exportKnowledgeBase)
+	adminAPI.POST("/import", s.tokens.Require(auth.RoleAdmin), s.importKnowledgeBase)
 
+	adminAPI.GET("/consistency", s.tokens.Require(auth.RoleViewer), func(c *gin.Context) {
+		report, err := s.checker.Check(c.Request.Context(), false)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"result": result})
+		c.JSON(http.StatusOK, report)
 	})
 
-	router.POST("/add-instruct", redis.AddInstruct)
-	router.POST("/del-instruct", redis.DeleteInstruct)
-	router.GET("/get-all-sets", redis.GetAllSets)
-	router.GET("/delete-db", func(c *gin.Context) {
-		secretkey := c.Query("key")
-
-		if secretkey == os.Getenv("delete_key") {
-			ResetDB()
-			c.JSON(http.StatusOK, "OK")
-		} else {
-			c.JSON(http.StatusUnauthorized, "Unauthorized")
+	adminAPI.POST("/consistency/repair", s.tokens.Require(auth.RoleAdmin), func(c *gin.Context) {
+		report, err := s.checker.Check(c.Request.Context(), true)
+		if err != nil {
+			c.Error(err)
+			return
 		}
+
+		c.JSON(http.StatusOK, report)
 	})
 
-	err = router.Run(":8080")
-	if err != nil {
-		return
-	}
+	return router
 }
 
-func SemanticSimilarityByCode(code string) ([]string, error) {
-	PromptExists, exists := weaviate.RetrieveHasSemanticMeaning(code)
+func (s *server) SemanticSimilarityByCode(ctx context.Context, match weaviate.CodeMatch) ([]string, error) {
+	PromptExists, exists := s.store.RetrieveHasSemanticMeaning(ctx, match)
 	if !exists {
-		SemanticMeaning := ollama.SemanticMeaning("", code, false)
+		SemanticMeaning := s.generator.SemanticMeaning(ctx, "", match.Code, false)
 
-		similarCode, err := weaviate.GetSimilarSemanticMeaning(SemanticMeaning)
+		similarCode, err := s.store.GetSimilarSemanticMeaning(ctx, SemanticMeaning)
 		if err != nil {
 			return nil, err
 		}
 		return similarCode, err
 	} else {
-		similarCode, err := weaviate.GetSimilarSemanticMeaning(PromptExists)
+		similarCode, err := s.store.GetSimilarSemanticMeaning(ctx, PromptExists)
 		if err != nil {
 			log.Printf("error: %v", err)
 		}
@@ -292,20 +382,46 @@ func SemanticSimilarityByCode(code string) ([]string, error) {
 	}
 }
 
-func SemanticSimilarityByMeaning(meaning string) ([]string, error) {
-	similarCode, err := weaviate.GetSimilarSemanticMeaning(meaning)
+func (s *server) SemanticSimilarityByMeaning(ctx context.Context, meaning string) ([]string, error) {
+	similarCode, err := s.store.GetSimilarSemanticMeaning(ctx, meaning)
 	if err != nil {
 		return nil, err
 	}
 	return similarCode, err
 }
 
-func ResetDB() {
-	redis.DeleteAllSets()
-	redis.InitRedis()
-	weaviate.DeleteAllClasses()
-	err := weaviate.InitSchema()
+// streamEvents answers with Server-Sent Events. Every token run passes to
+// onToken is sent as a "token" event. The result of run is sent as "done"
+// event, or its error as "error" event with the body the error middleware
+// would answer with.
+func streamEvents(c *gin.Context, run func(onToken llm.TokenHandler) (interface{}, error)) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	result, err := run(func(token string) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+
+		c.SSEvent("token", gin.H{"token": token})
+		c.Writer.Flush()
+		return nil
+	})
 	if err != nil {
+		log.Printf("streaming %s failed: %v", c.FullPath(), err)
+		c.SSEvent("error", errorBody(c, err))
+		c.Writer.Flush()
 		return
 	}
+
+	c.SSEvent("done", result)
+	c.Writer.Flush()
+}
+
+func closeLogged(name string, closer io.Closer) {
+	if err := closer.Close(); err != nil {
+		log.Printf("error closing %s: %v", name, err)
+	}
 }
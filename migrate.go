@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// runMigrate implements the migrate command. migrate status lists the schema
+// migrations and whether they were applied, migrate up applies the pending
+// ones, which serve also does on startup.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("missing subcommand, expected migrate status or migrate up")
+	}
+	subcommand := args[0]
+	if subcommand != "status" && subcommand != "up" {
+		log.Fatalf("unknown subcommand %q, expected status or up", subcommand)
+	}
+
+	cfg := loadStoreConfig(flag.NewFlagSet("migrate "+subcommand, flag.ExitOnError), args[1:])
+	if cfg.Store != "weaviate" {
+		log.Fatalf("the %s store has no schema to migrate", cfg.Store)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := weaviate.NewClient(cfg.Weaviate)
+	if err != nil {
+		log.Fatalf("could not connect to weaviate: %v", err)
+	}
+	defer closeLogged("weaviate", client)
+
+	if subcommand == "up" {
+		err = client.InitSchema(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	status, err := client.SchemaStatus(ctx)
+	if err != nil {
+		log.Fatalf("could not read schema version: %v", err)
+	}
+
+	printSchemaStatus(status)
+}
+
+func printSchemaStatus(status weaviate.SchemaStatus) {
+	fmt.Printf("schema version %d of %d", status.Version, weaviate.LatestVersion())
+	if !status.MigratedAt.IsZero() {
+		fmt.Printf(", migrated at %s", status.MigratedAt.Local().Format(time.RFC3339))
+	}
+	fmt.Println()
+	if status.Holder != "" {
+		fmt.Printf("locked by %s until %s\n", status.Holder, status.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tDESCRIPTION")
+	for _, migration := range weaviate.Migrations {
+		state := "applied"
+		if migration.Version > status.Version {
+			state = "pending"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, state, migration.Description)
+	}
+	w.Flush()
+}
@@ -0,0 +1,390 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// AnalysisOptions controls an analysis of a file or a repository.
+type AnalysisOptions struct {
+	// Instruct is the question answered for every function, file and the
+	// repository. Without one the code is summarized.
+	Instruct   string
+	Model      string
+	Repository string
+}
+
+// SourceFile is a file to analyze.
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
+const (
+	defaultAnalysisInstruct = "Summarize what the code does and how it does it."
+	// minChunkSize is the smallest chunk in bytes a file is split into
+	// before giving up on fitting its chunks into the context.
+	minChunkSize = 64
+	// minPartTokens is the least a part may be cut to when combining parts.
+	minPartTokens = 32
+)
+
+// analysis holds the state shared by all steps of one analysis.
+type analysis struct {
+	g     *Generator
+	opts  AnalysisOptions
+	model registry.Model
+	// limit is the number of tokens available for a prompt.
+	limit int
+}
+
+func (g *Generator) newAnalysis(ctx context.Context, opts AnalysisOptions) (*analysis, error) {
+	model, err := g.Models.Get(opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Instruct == "" {
+		opts.Instruct = defaultAnalysisInstruct
+	}
+
+	return &analysis{g: g, opts: opts, model: model, limit: g.promptLimit(ctx, model)}, nil
+}
+
+// AnalyzeFile summarizes every function of file and then the whole file from
+// the summaries of its functions. The results are stored as a file analysis
+// with the function analyses as its parts. The file analysis is returned
+// with its parts even if the analysis failed.
+func (g *Generator) AnalyzeFile(ctx context.Context, opts AnalysisOptions, file SourceFile) (weaviate.Analysis, error) {
+	a, err := g.newAnalysis(ctx, opts)
+	if err != nil {
+		return weaviate.Analysis{}, err
+	}
+
+	id, _, err := a.file(ctx, file)
+	if id == "" {
+		return weaviate.Analysis{}, err
+	}
+
+	result, retrieveErr := g.Store.RetrieveAnalysis(ctx, id, 1)
+	if retrieveErr != nil {
+		return weaviate.Analysis{}, errors.Join(err, retrieveErr)
+	}
+
+	return result, err
+}
+
+// StartRepositoryAnalysis stores a pending repository analysis and analyzes
+// the files in the background. The summary of the repository is combined from
+// the summaries of its files. Files that fail are marked as failed and left
+// out of the summary. It returns the ID of the repository analysis.
+func (g *Generator) StartRepositoryAnalysis(ctx context.Context, opts AnalysisOptions, files []SourceFile) (string, error) {
+	a, err := g.newAnalysis(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", errkind.New(errkind.Invalid, "no files to analyze")
+	}
+
+	id, err := g.Store.CreateAnalysisObject(ctx, weaviate.AnalysisObject{
+		Level:          weaviate.AnalysisRepository,
+		Repository:     a.opts.Repository,
+		Instruct:       a.opts.Instruct,
+		Model:          a.model.Name,
+		AnalysisResult: weaviate.AnalysisResult{Status: weaviate.AnalysisPending},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	g.background(func(ctx context.Context) {
+		err := a.repository(ctx, id, files)
+		if err != nil {
+			log.Printf("analysis %s of repository %s failed: %v", id, a.opts.Repository, err)
+		}
+	})
+
+	return id, nil
+}
+
+func (a *analysis) repository(ctx context.Context, id string, files []SourceFile) error {
+	var summaries []string
+	for _, file := range files {
+		fileID, summary, err := a.file(ctx, file)
+		if fileID != "" {
+			if linkErr := a.g.Store.CreateAnalysisReferences(ctx, id, fileID); linkErr != nil {
+				return a.fail(ctx, id, linkErr)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return a.fail(ctx, id, err)
+			}
+			log.Printf("analysis of %s failed: %v", file.Path, err)
+			continue
+		}
+		summaries = append(summaries, fmt.Sprintf("File %s:\n%s", file.Path, summary))
+	}
+
+	if len(summaries) == 0 {
+		return a.fail(ctx, id, errors.New("the analysis of every file failed"))
+	}
+
+	summary, err := a.reduce(ctx, fmt.Sprintf("the repository %s", a.opts.Repository), summaries, nil)
+	if err != nil {
+		return a.fail(ctx, id, err)
+	}
+
+	return a.g.Store.SetAnalysisResult(ctx, id, weaviate.AnalysisResult{Status: weaviate.AnalysisDone, Summary: summary})
+}
+
+// file analyzes a file and returns the ID of its analysis, which is set as
+// soon as the analysis is stored, together with its summary.
+func (a *analysis) file(ctx context.Context, file SourceFile) (string, string, error) {
+	language, chunks, err := a.split(ctx, file.Path, file.Content)
+	if err != nil {
+		return "", "", err
+	}
+
+	id, err := a.g.Store.CreateAnalysisObject(ctx, weaviate.AnalysisObject{
+		Level:          weaviate.AnalysisFile,
+		Repository:     a.opts.Repository,
+		Path:           file.Path,
+		Instruct:       a.opts.Instruct,
+		Model:          a.model.Name,
+		AnalysisResult: weaviate.AnalysisResult{Status: weaviate.AnalysisPending},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// map every chunk to a summary
+	var summaries []string
+	for _, chunk := range chunks {
+		summary, err := a.chunk(ctx, id, file.Path, chunk)
+		if err != nil {
+			return id, "", a.fail(ctx, id, err)
+		}
+		summaries = append(summaries, chunkSummary(chunk, summary))
+	}
+
+	summary, err := a.reduce(ctx, fmt.Sprintf("the %s file %s", language, file.Path), summaries, nil)
+	if err != nil {
+		return id, "", a.fail(ctx, id, err)
+	}
+
+	err = a.g.Store.SetAnalysisResult(ctx, id, weaviate.AnalysisResult{Status: weaviate.AnalysisDone, Summary: summary})
+	if err != nil {
+		return id, "", err
+	}
+
+	return id, summary, nil
+}
+
+// chunk summarizes a chunk and stores the summary as a part of the file
+// analysis fileID.
+func (a *analysis) chunk(ctx context.Context, fileID string, path string, chunk codeanalysis.Chunk) (string, error) {
+	summary, err := a.g.ask(ctx, a.model, a.chunkPrompt(path, chunk), nil)
+	if err != nil {
+		return "", err
+	}
+
+	fn := chunk.Function
+	fn.Lines = chunk.Lines
+	id, err := a.g.Store.CreateAnalysisObject(ctx, weaviate.AnalysisObject{
+		Level:          weaviate.AnalysisFunction,
+		Repository:     a.opts.Repository,
+		Path:           path,
+		Function:       &fn,
+		Instruct:       a.opts.Instruct,
+		Model:          a.model.Name,
+		AnalysisResult: weaviate.AnalysisResult{Status: weaviate.AnalysisDone, Summary: summary},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return summary, a.g.Store.CreateAnalysisReferences(ctx, fileID, id)
+}
+
+// split divides a file into chunks whose prompts fit into the context. The
+// chunk size starts from the ratio of bytes to tokens of the whole file and
+// shrinks until every chunk fits.
+func (a *analysis) split(ctx context.Context, path string, code string) (codeanalysis.Language, []codeanalysis.Chunk, error) {
+	tokens, err := a.g.tokens(ctx, a.model, code)
+	if err != nil {
+		return "", nil, err
+	}
+	size := len(code)
+	if tokens > a.limit {
+		size = len(code) * a.limit / tokens
+	}
+
+	for {
+		language, chunks := codeanalysis.Split(path, code, size)
+		if len(chunks) == 0 {
+			return "", nil, fmt.Errorf("%s contains no code", path)
+		}
+
+		var overflow *ContextOverflowError
+		for _, chunk := range chunks {
+			tokens, err := a.g.tokens(ctx, a.model, a.chunkPrompt(path, chunk))
+			if err != nil {
+				return "", nil, err
+			}
+			if tokens > a.limit && (overflow == nil || tokens > overflow.Tokens) {
+				overflow = &ContextOverflowError{Model: a.model.Name, Tokens: tokens, Limit: a.limit}
+			}
+		}
+		if overflow == nil {
+			return language, chunks, nil
+		}
+
+		size = size * a.limit / overflow.Tokens * 9 / 10
+		if size < minChunkSize {
+			return "", nil, overflow
+		}
+	}
+}
+
+// chunkPrompt asks for the instruct of the analysis about a chunk of the file
+// path.
+func (a *analysis) chunkPrompt(path string, chunk codeanalysis.Chunk) string {
+	source := describeChunk(chunk)
+	if path != "" {
+		source += " from the file " + path
+	}
+	return fmt.Sprintf("%s\nThe code is %s:\n %s", a.opts.Instruct, source, chunk.Code)
+}
+
+// reduce combines the summaries of the parts of subject into one summary.
+// Parts that do not fit into the context of the model at once are combined
+// in groups first. The final summary is streamed to onToken if it is set.
+func (a *analysis) reduce(ctx context.Context, subject string, parts []string, onToken llm.TokenHandler) (string, error) {
+	instruct := fmt.Sprintf("%s\nThe following are the results for the parts of %s. Combine them into one answer for %s as a whole:\n", a.opts.Instruct, subject, subject)
+	instructTokens, err := a.g.tokens(ctx, a.model, instruct+" ")
+	if err != nil {
+		return "", err
+	}
+	// parts are separated by a blank line
+	const separator = 2
+	limit := a.limit - instructTokens
+	if limit/2-separator < minPartTokens {
+		return "", &ContextOverflowError{Model: a.model.Name, Tokens: instructTokens + 2*(minPartTokens+separator), Limit: a.limit}
+	}
+
+	for len(parts) > 1 {
+		// every group holds at least two parts, so each round shrinks
+		var groups [][]string
+		size := 0
+		for _, part := range parts {
+			part, tokens, err := a.g.fit(ctx, a.model, "", part, limit/2-separator)
+			if err != nil {
+				return "", err
+			}
+			if len(groups) == 0 || size+tokens+separator > limit {
+				groups = append(groups, nil)
+				size = 0
+			}
+			groups[len(groups)-1] = append(groups[len(groups)-1], part)
+			size += tokens + separator
+		}
+
+		var final llm.TokenHandler
+		if len(groups) == 1 {
+			final = onToken
+		}
+
+		combined := make([]string, 0, len(groups))
+		for _, group := range groups {
+			summary, err := a.g.ask(ctx, a.model, instruct+" "+strings.Join(group, "\n\n"), final)
+			if err != nil {
+				return "", err
+			}
+			combined = append(combined, summary)
+		}
+		if len(groups) == 1 {
+			return combined[0], nil
+		}
+		parts = combined
+	}
+
+	// a single part needs no combining
+	if onToken != nil {
+		if err := onToken(parts[0]); err != nil {
+			return "", err
+		}
+	}
+	return parts[0], nil
+}
+
+// completeChunked answers instruct for every chunk of code and combines the
+// answers, for code that does not fit into the context of model at once.
+func (g *Generator) completeChunked(ctx context.Context, model registry.Model, instruct string, code string, onToken llm.TokenHandler) (string, error) {
+	a, err := g.newAnalysis(ctx, AnalysisOptions{Instruct: instruct, Model: model.Name})
+	if err != nil {
+		return "", err
+	}
+
+	_, chunks, err := a.split(ctx, "", code)
+	if err != nil {
+		return "", err
+	}
+
+	var answers []string
+	for _, chunk := range chunks {
+		answer, err := g.ask(ctx, model, a.chunkPrompt("", chunk), nil)
+		if err != nil {
+			return "", err
+		}
+		answers = append(answers, chunkSummary(chunk, answer))
+	}
+
+	return a.reduce(ctx, "the code", answers, onToken)
+}
+
+// truncate shortens s to at most size bytes without splitting runes.
+func truncate(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+// fail marks the analysis id as failed and returns err.
+func (a *analysis) fail(ctx context.Context, id string, err error) error {
+	// the failure must be recorded even if the analysis was cancelled
+	setErr := a.g.Store.SetAnalysisResult(context.WithoutCancel(ctx), id, weaviate.AnalysisResult{Status: weaviate.AnalysisFailed, Error: err.Error()})
+	if setErr != nil {
+		log.Printf("could not mark analysis %s as failed: %v", id, setErr)
+	}
+
+	return err
+}
+
+// chunkSummary labels the summary of a chunk with its location for reduce.
+func chunkSummary(chunk codeanalysis.Chunk, summary string) string {
+	return fmt.Sprintf("%s (lines %d-%d):\n%s", describeChunk(chunk), chunk.Lines.Start, chunk.Lines.End, summary)
+}
+
+// describeChunk names the definition a chunk belongs to.
+func describeChunk(chunk codeanalysis.Chunk) string {
+	fn := chunk.Function
+	if fn.Name == "" {
+		return "code outside of any definition"
+	}
+	return fmt.Sprintf("the %s %s", fn.Kind, fn.Name)
+}
@@ -0,0 +1,161 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// BackfillOptions controls a Backfill run.
+type BackfillOptions struct {
+	// DryRun only reports the missing references without generating anything.
+	DryRun bool
+	// BatchSize is the number of prompts fetched per page.
+	BatchSize int
+	// Interval is the minimum time between two LLM calls, zero disables rate
+	// limiting.
+	Interval time.Duration
+	// Responses and SemanticMeanings select which references are repaired.
+	Responses        bool
+	SemanticMeanings bool
+	// Fingerprints stores the code fingerprint and the analyzed function of
+	// prompts created before they were recorded. This needs no LLM call and is not rate
+	// limited.
+	Fingerprints bool
+}
+
+// BackfillReport summarizes the progress of a Backfill run.
+type BackfillReport struct {
+	Scanned                int `json:"scanned"`
+	MissingResponses       int `json:"missingResponses"`
+	MissingSemanticMeaning int `json:"missingSemanticMeaning"`
+	MissingFingerprints    int `json:"missingFingerprints"`
+	Repaired               int `json:"repaired"`
+	Failed                 int `json:"failed"`
+}
+
+func (r BackfillReport) String() string {
+	return fmt.Sprintf("scanned %d prompts, %d without response, %d without semantic meaning, %d without fingerprint, %d repaired, %d failed",
+		r.Scanned, r.MissingResponses, r.MissingSemanticMeaning, r.MissingFingerprints, r.Repaired, r.Failed)
+}
+
+// Backfill scans all prompts and regenerates missing responses, semantic
+// meanings and fingerprints. progress is called after every page and for every failure.
+func (g *Generator) Backfill(ctx context.Context, opts BackfillOptions, progress func(report BackfillReport, err error)) (BackfillReport, error) {
+	var report BackfillReport
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	var limiter <-chan time.Time
+	if opts.Interval > 0 && !opts.DryRun {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	wait := func() error {
+		if limiter == nil {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-limiter:
+			return nil
+		}
+	}
+
+	after := ""
+	for {
+		prompts, err := g.Store.ListPrompts(ctx, after, opts.BatchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(prompts) == 0 {
+			return report, nil
+		}
+
+		for _, prompt := range prompts {
+			report.Scanned++
+
+			if opts.Fingerprints && !prompt.HasFingerprint {
+				report.MissingFingerprints++
+				if !opts.DryRun {
+					err := g.Store.SetFingerprintPrompt(ctx, prompt.ID, prompt.Code, prompt.GitURL)
+					report.record(err, progress, fmt.Errorf("fingerprint of prompt %s: %w", prompt.ID, err))
+				}
+			}
+
+			if opts.Responses && !prompt.HasResponse {
+				report.MissingResponses++
+				if !opts.DryRun {
+					if err := wait(); err != nil {
+						return report, err
+					}
+					err := g.backfillResponse(ctx, prompt)
+					report.record(err, progress, fmt.Errorf("response of prompt %s: %w", prompt.ID, err))
+				}
+			}
+
+			if opts.SemanticMeanings && !prompt.HasSemanticMeaning {
+				report.MissingSemanticMeaning++
+				if !opts.DryRun {
+					if err := wait(); err != nil {
+						return report, err
+					}
+					err := g.backfillSemanticMeaning(ctx, prompt)
+					report.record(err, progress, fmt.Errorf("semantic meaning of prompt %s: %w", prompt.ID, err))
+				}
+			}
+		}
+
+		after = prompts[len(prompts)-1].ID
+		if progress != nil {
+			progress(report, nil)
+		}
+	}
+}
+
+func (r *BackfillReport) record(err error, progress func(BackfillReport, error), wrapped error) {
+	if err == nil {
+		r.Repaired++
+		return
+	}
+
+	r.Failed++
+	if progress != nil {
+		progress(*r, wrapped)
+	}
+}
+
+func (g *Generator) backfillResponse(ctx context.Context, prompt weaviate.PromptSummary) error {
+	model, err := g.Models.Get(prompt.Model)
+	if err != nil {
+		return err
+	}
+
+	response, err := g.complete(ctx, model, prompt.Instruct, prompt.Code, nil)
+	if err != nil {
+		return err
+	}
+
+	return g.Store.ReplaceResponse(ctx, prompt.ID, response)
+}
+
+func (g *Generator) backfillSemanticMeaning(ctx context.Context, prompt weaviate.PromptSummary) error {
+	content, err := g.describe(ctx, prompt.Code)
+	if err != nil {
+		return err
+	}
+
+	return g.Store.SetSemanticMeaningPrompt(ctx, prompt.ID, content)
+}
+
+// RepairResponse regenerates the response of a prompt that lost it.
+func (g *Generator) RepairResponse(ctx context.Context, prompt weaviate.PromptSummary) error {
+	return g.backfillResponse(ctx, prompt)
+}
@@ -0,0 +1,213 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/registry"
+)
+
+// minContext is the smallest context requested from the model, so short
+// prompts do not cause the model to be reloaded for every context size.
+const minContext = 2048
+
+// ContextOverflowError is returned for prompts that do not fit into the
+// context of a model.
+type ContextOverflowError struct {
+	Model string `json:"model"`
+	// Tokens is the length of the prompt, Limit the number of tokens left for
+	// the prompt once the answer is reserved.
+	Tokens int `json:"tokens"`
+	Limit  int `json:"limit"`
+}
+
+// Over returns by how many tokens the prompt exceeds the limit.
+func (e *ContextOverflowError) Over() int {
+	return e.Tokens - e.Limit
+}
+
+func (e *ContextOverflowError) Error() string {
+	return fmt.Sprintf("prompt of %d tokens exceeds the limit of %d tokens of model %s by %d tokens", e.Tokens, e.Limit, e.Model, e.Over())
+}
+
+// complete asks model to answer instruct about code. Prompts that do not fit
+// into the context of the model are handled by its overflow policy.
+func (g *Generator) complete(ctx context.Context, model registry.Model, instruct string, code string, onToken llm.TokenHandler) (string, error) {
+	prompt := instruct + " " + code
+	tokens, err := g.tokens(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	limit := g.promptLimit(ctx, model)
+	if tokens <= limit {
+		return g.send(ctx, model, prompt, tokens, onToken)
+	}
+	overflow := &ContextOverflowError{Model: model.Name, Tokens: tokens, Limit: limit}
+
+	switch model.Overflow {
+	case registry.OverflowTruncate:
+		code, tokens, err = g.fit(ctx, model, instruct+" ", code, limit)
+		if err != nil {
+			return "", err
+		}
+		log.Printf("truncated code to %d bytes: %v", len(code), overflow)
+		return g.send(ctx, model, instruct+" "+code, tokens, onToken)
+	case registry.OverflowChunk:
+		log.Printf("answering in chunks: %v", overflow)
+		return g.completeChunked(ctx, model, instruct, code, onToken)
+	default:
+		return "", overflow
+	}
+}
+
+// ask sends prompt to model, rejecting it if it does not fit.
+func (g *Generator) ask(ctx context.Context, model registry.Model, prompt string, onToken llm.TokenHandler) (string, error) {
+	return g.request(ctx, model, llm.GenerateRequest{Prompt: prompt, OnToken: onToken})
+}
+
+// request sends req to model, rejecting its prompt if it does not fit. The
+// model and the options are filled in.
+func (g *Generator) request(ctx context.Context, model registry.Model, req llm.GenerateRequest) (string, error) {
+	tokens, err := g.tokens(ctx, model, req.Prompt)
+	if err != nil {
+		return "", err
+	}
+
+	limit := g.promptLimit(ctx, model)
+	if tokens > limit {
+		return "", &ContextOverflowError{Model: model.Name, Tokens: tokens, Limit: limit}
+	}
+
+	req.Model = model.Name
+	req.Options = g.options(ctx, model, tokens)
+
+	return g.Provider.Generate(ctx, req)
+}
+
+// send generates the answer to a prompt of the given number of tokens.
+func (g *Generator) send(ctx context.Context, model registry.Model, prompt string, tokens int, onToken llm.TokenHandler) (string, error) {
+	return g.Provider.Generate(ctx, llm.GenerateRequest{
+		Model:   model.Name,
+		Prompt:  prompt,
+		Options: g.options(ctx, model, tokens),
+		OnToken: onToken,
+	})
+}
+
+// options returns the options of model for a prompt of the given number of
+// tokens. The context requested from the model is just large enough for the
+// prompt and the reserved answer, and the answer is limited to the rest of it.
+func (g *Generator) options(ctx context.Context, model registry.Model, tokens int) map[string]interface{} {
+	contextSize := tokens + model.ResponseTokens
+	if contextSize < minContext {
+		contextSize = minContext
+	}
+	if window := g.window(ctx, model); contextSize > window {
+		contextSize = window
+	}
+
+	options := make(map[string]interface{}, len(model.Options)+2)
+	for key, value := range model.Options {
+		options[key] = value
+	}
+	options["num_ctx"] = contextSize
+	if _, ok := options["num_predict"]; !ok {
+		options["num_predict"] = contextSize - tokens
+	}
+
+	return options
+}
+
+// fit shortens text at its end until prefix followed by text fits into limit
+// tokens. It returns the shortened text with the number of tokens of both.
+func (g *Generator) fit(ctx context.Context, model registry.Model, prefix string, text string, limit int) (string, int, error) {
+	prefixTokens, err := g.tokens(ctx, model, prefix)
+	if err != nil {
+		return "", 0, err
+	}
+	if prefixTokens > limit {
+		return "", 0, &ContextOverflowError{Model: model.Name, Tokens: prefixTokens, Limit: limit}
+	}
+
+	for {
+		tokens, err := g.tokens(ctx, model, prefix+text)
+		if err != nil {
+			return "", 0, err
+		}
+		if tokens <= limit {
+			return text, tokens, nil
+		}
+
+		// shrink in proportion to the excess with a margin, which always
+		// drops at least one byte, and prefer to cut at a line break
+		size := len(text) * (limit - prefixTokens) / (tokens - prefixTokens) * 9 / 10
+		text = truncate(text, size)
+		if i := strings.LastIndexByte(text, '\n'); i > size/2 {
+			text = text[:i]
+		}
+	}
+}
+
+// promptLimit returns the number of tokens available for a prompt to model.
+func (g *Generator) promptLimit(ctx context.Context, model registry.Model) int {
+	limit := g.window(ctx, model) - model.ResponseTokens
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// window returns the context length of model, which is the configured one
+// unless the provider reports a shorter one.
+func (g *Generator) window(ctx context.Context, model registry.Model) int {
+	inspector, ok := g.Provider.(llm.Inspector)
+	if !ok {
+		return model.ContextLength
+	}
+	if window, ok := g.windows.Load(model.Name); ok {
+		return window.(int)
+	}
+
+	info, err := inspector.Show(ctx, model.Name)
+	if err != nil {
+		// not remembered, so the next prompt asks again
+		log.Printf("could not read the context length of model %s: %v", model.Name, err)
+		return model.ContextLength
+	}
+
+	window := model.ContextLength
+	if info.ContextLength > 0 && info.ContextLength < window {
+		log.Printf("model %s supports %d tokens of context, less than the configured %d", model.Name, info.ContextLength, window)
+		window = info.ContextLength
+	}
+	g.windows.Store(model.Name, window)
+
+	return window
+}
+
+// estimateMargin is added to estimated token counts in percent, so prompts the
+// estimate gets wrong by a little still fit into the context.
+const estimateMargin = 10
+
+// tokens counts the tokens of text with the tokenizer of the provider. If the
+// provider has none the count is estimated, with a margin on top, so budgets
+// are only approximate then.
+func (g *Generator) tokens(ctx context.Context, model registry.Model, text string) (int, error) {
+	tokenizer, ok := g.Provider.(llm.Tokenizer)
+	if ok && !g.estimateTokens.Load() {
+		tokens, err := tokenizer.CountTokens(ctx, model.Name, text)
+		if !errors.Is(err, llm.ErrTokenizeUnsupported) {
+			return tokens, err
+		}
+		log.Printf("%v, estimating tokens instead", err)
+		g.estimateTokens.Store(true)
+	}
+
+	tokens := llm.EstimateTokens(text)
+	return tokens + (tokens*estimateMargin+99)/100, nil
+}
@@ -0,0 +1,174 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
+)
+
+// recorder is a fake provider remembering every generation request.
+type recorder struct {
+	*llm.Fake
+
+	mu       sync.Mutex
+	requests []llm.GenerateRequest
+}
+
+func (r *recorder) Generate(ctx context.Context, req llm.GenerateRequest) (string, error) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+
+	return r.Fake.Generate(ctx, req)
+}
+
+// newBudgetGenerator returns a generator for a model with a context of 600
+// tokens, 100 of them reserved for the answer, and the given overflow policy.
+func newBudgetGenerator(t *testing.T, overflow string) (*Generator, registry.Model, *recorder) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "models.yaml")
+	config := fmt.Sprintf("default: m\nsemanticMeaning: m\nmodels:\n  - name: m\n    contextLength: 600\n    responseTokens: 100\n    overflow: %s\n", overflow)
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	models, err := registry.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := models.Get("m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &recorder{Fake: llm.NewFake()}
+	return &Generator{Store: memory.New(nil), Provider: provider, Models: models}, model, provider
+}
+
+func TestComplete(t *testing.T) {
+	const instruct = "Explain this:"
+	small := "func f() int {\n\treturn 1\n}\n"
+	var large strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&large, "func f%d() int {\n\treturn %d * 1234567\n}\n\n", i, i)
+	}
+
+	tests := []struct {
+		name     string
+		overflow string
+		code     string
+		// wantRequests is the number of generation requests, or the least
+		// number if the answer is combined from chunks. wantErr is set if the
+		// prompt is rejected.
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "fits", overflow: registry.OverflowReject, code: small, wantRequests: 1},
+		{name: "reject", overflow: registry.OverflowReject, code: large.String(), wantErr: true},
+		{name: "truncate", overflow: registry.OverflowTruncate, code: large.String(), wantRequests: 1},
+		{name: "chunk", overflow: registry.OverflowChunk, code: large.String(), wantRequests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g, model, provider := newBudgetGenerator(t, tt.overflow)
+
+			answer, err := g.complete(ctx, model, instruct, tt.code, nil)
+			if tt.wantErr {
+				var overflow *ContextOverflowError
+				if !errors.As(err, &overflow) {
+					t.Fatalf("got error %v, want a ContextOverflowError", err)
+				}
+				if overflow.Limit != 500 || overflow.Over() <= 0 {
+					t.Errorf("got %v, want a prompt over the limit of 500 tokens", overflow)
+				}
+				if len(provider.requests) != 0 {
+					t.Errorf("sent %d requests for a rejected prompt", len(provider.requests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answer == "" {
+				t.Error("got an empty answer")
+			}
+
+			if got := len(provider.requests); got < tt.wantRequests || got != tt.wantRequests && tt.overflow != registry.OverflowChunk {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+
+			for _, req := range provider.requests {
+				tokens, err := g.tokens(ctx, model, req.Prompt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tokens > 500 {
+					t.Errorf("sent a prompt of %d tokens, want at most 500", tokens)
+				}
+				if numCtx := req.Options["num_ctx"].(int); numCtx > 600 || numCtx-req.Options["num_predict"].(int) != tokens {
+					t.Errorf("options %v do not fit a prompt of %d tokens into 600", req.Options, tokens)
+				}
+			}
+
+			full := instruct + " " + tt.code
+			switch tt.overflow {
+			case registry.OverflowReject:
+				if got := provider.requests[0].Prompt; got != full {
+					t.Errorf("sent %q, want %q", got, full)
+				}
+			case registry.OverflowTruncate:
+				got := provider.requests[0].Prompt
+				if len(got) >= len(full) || !strings.HasPrefix(full, got) {
+					t.Errorf("sent %q, want the prompt cut short", got)
+				}
+			}
+		})
+	}
+}
+
+// noTokenizer is a provider whose server cannot tokenize, like older Ollama
+// versions.
+type noTokenizer struct {
+	*recorder
+	counts int
+}
+
+func (p *noTokenizer) CountTokens(ctx context.Context, model string, text string) (int, error) {
+	p.counts++
+	return 0, llm.ErrTokenizeUnsupported
+}
+
+func TestTokensWithoutTokenizer(t *testing.T) {
+	ctx := context.Background()
+	g, model, recorder := newBudgetGenerator(t, registry.OverflowTruncate)
+	provider := &noTokenizer{recorder: recorder}
+	g.Provider = provider
+
+	var large strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&large, "func f%d() int {\n\treturn %d\n}\n\n", i, i)
+	}
+	if _, err := g.complete(ctx, model, "Explain this:", large.String(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// truncating counts many times, but the server is asked once and every
+	// count is estimated afterwards without generating
+	if provider.counts != 1 {
+		t.Errorf("asked the server to count %d times, want once", provider.counts)
+	}
+	if len(recorder.requests) != 1 {
+		t.Errorf("sent %d generation requests, want 1", len(recorder.requests))
+	}
+}
@@ -0,0 +1,60 @@
+package ollama
+
+import (
+	"context"
+	"log"
+
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// cached returns the stored response for key. Entries whose prompt no longer
+// exists are dropped and count as a miss. Cache failures are logged and
+// treated as a miss, so generation still works without the cache.
+func (g *Generator) cached(ctx context.Context, key string, onToken llm.TokenHandler) (weaviate.ResponseData, bool, error) {
+	promptID, ok, err := g.Cache.Lookup(ctx, key)
+	if err != nil {
+		log.Printf("response cache lookup failed: %v", err)
+		return weaviate.ResponseData{}, false, nil
+	}
+
+	if ok {
+		properties, err := g.Store.RetrieveProperties(ctx, promptID)
+		if err == nil {
+			g.record(ctx, redis.CacheHit)
+
+			if onToken != nil {
+				err = onToken(properties.HasResponse)
+				if err != nil {
+					return weaviate.ResponseData{}, false, err
+				}
+			}
+
+			return weaviate.ResponseData{
+				Response: properties.HasResponse,
+				PromptID: promptID,
+				Instruct: properties.Instruct,
+				GitURL:   properties.GitURL,
+				Model:    properties.Model,
+				Cached:   true,
+				Examples: examplesByID(properties.Examples),
+			}, true, nil
+		}
+
+		log.Printf("dropping cached prompt %s: %v", promptID, err)
+		if err := g.Cache.Forget(ctx, key); err != nil {
+			log.Printf("could not drop cached prompt %s: %v", promptID, err)
+		}
+	}
+
+	g.record(ctx, redis.CacheMiss)
+
+	return weaviate.ResponseData{}, false, nil
+}
+
+func (g *Generator) record(ctx context.Context, event redis.CacheEvent) {
+	if err := g.Cache.Record(ctx, event); err != nil {
+		log.Printf("could not record cache %s: %v", event, err)
+	}
+}
@@ -0,0 +1,172 @@
+package ollama
+
+import (
+	"context"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// messageOverhead is the number of tokens a chat template adds around the
+// content of every message.
+const messageOverhead = 4
+
+// Converse answers a follow-up question about the response to promptID. The
+// conversation starts with the instruct and code of the prompt and its
+// response, followed by the earlier turns. Earlier turns that do not fit into
+// the context of the model are left out, oldest first. An empty modelName
+// selects the model of the prompt. The question and the answer are stored as
+// the next turns of the conversation and returned.
+func (g *Generator) Converse(ctx context.Context, promptID string, question string, modelName string, onToken llm.TokenHandler) ([]weaviate.Message, error) {
+	properties, err := g.Store.RetrieveProperties(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	if modelName == "" {
+		modelName = properties.Model
+	}
+	model, err := g.Models.Get(modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := g.Store.RetrieveConversation(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := []llm.Message{
+		{Role: weaviate.RoleUser, Content: properties.Instruct + " " + properties.Code},
+		{Role: weaviate.RoleAssistant, Content: properties.HasResponse},
+	}
+	messages, tokens, err := g.fitConversation(ctx, model, start, history, llm.Message{Role: weaviate.RoleUser, Content: question})
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := g.Provider.Chat(ctx, llm.ChatRequest{
+		Model:    model.Name,
+		Messages: messages,
+		Options:  g.options(ctx, model, tokens),
+		OnToken:  onToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g.storeTurns(ctx, promptID, history,
+		weaviate.MessageObject{PromptID: promptID, Role: weaviate.RoleUser, Content: question},
+		weaviate.MessageObject{PromptID: promptID, Role: weaviate.RoleAssistant, Content: answer, Model: model.Name},
+	)
+}
+
+// maxTurnAttempts bounds how often a question moves behind concurrent
+// follow-ups before storing it fails.
+const maxTurnAttempts = 5
+
+// storeTurns stores question and answer as the next turns of the conversation
+// about promptID, which had the turns history. The question takes the next
+// even position, so the position of its answer is never claimed by another
+// question. A concurrent follow-up stored first moves the turns behind it. If
+// the answer cannot be stored, the question is deleted again.
+func (g *Generator) storeTurns(ctx context.Context, promptID string, history []weaviate.Message, question weaviate.MessageObject, answer weaviate.MessageObject) ([]weaviate.Message, error) {
+	position := nextPosition(history)
+	for attempt := 1; ; attempt++ {
+		question.Position = position
+		questionID, err := g.Store.CreateMessageObject(ctx, question)
+		if errkind.Is(err, errkind.Conflict) && attempt < maxTurnAttempts {
+			history, err = g.Store.RetrieveConversation(ctx, promptID)
+			if err != nil {
+				return nil, err
+			}
+			// the turn taking the position may not be searchable yet
+			position = max(nextPosition(history), position+2)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		answer.Position = position + 1
+		answerID, err := g.Store.CreateMessageObject(ctx, answer)
+		if err != nil {
+			// without its answer the question would confuse every later turn
+			g.discard(ctx, weaviate.MessageClass, questionID)
+			return nil, err
+		}
+
+		return []weaviate.Message{
+			{ID: questionID, MessageObject: question},
+			{ID: answerID, MessageObject: answer},
+		}, nil
+	}
+}
+
+// nextPosition returns the position of the question following history, the
+// first even position behind its last turn.
+func nextPosition(history []weaviate.Message) int {
+	if len(history) == 0 {
+		return 0
+	}
+	position := history[len(history)-1].Position + 1
+
+	return position + position%2
+}
+
+// fitConversation puts the conversation together from start, as many of the
+// latest turns of history as fit into the context of model, and question. It
+// returns the messages with their number of tokens.
+func (g *Generator) fitConversation(ctx context.Context, model registry.Model, start []llm.Message, history []weaviate.Message, question llm.Message) ([]llm.Message, int, error) {
+	count := func(content string) (int, error) {
+		tokens, err := g.tokens(ctx, model, content)
+		return tokens + messageOverhead, err
+	}
+
+	tokens := 0
+	for _, message := range append(start[:len(start):len(start)], question) {
+		n, err := count(message.Content)
+		if err != nil {
+			return nil, 0, err
+		}
+		tokens += n
+	}
+
+	limit := g.promptLimit(ctx, model)
+	if tokens > limit {
+		return nil, 0, &ContextOverflowError{Model: model.Name, Tokens: tokens, Limit: limit}
+	}
+
+	// take the turns from the latest back as long as they fit
+	first := len(history)
+	for first > 0 {
+		n, err := count(history[first-1].Content)
+		if err != nil {
+			return nil, 0, err
+		}
+		if tokens+n > limit {
+			break
+		}
+		tokens += n
+		first--
+	}
+	// an answer without its question is confusing
+	if first < len(history) && history[first].Role == weaviate.RoleAssistant {
+		n, err := count(history[first].Content)
+		if err != nil {
+			return nil, 0, err
+		}
+		tokens -= n
+		first++
+	}
+
+	messages := append([]llm.Message(nil), start...)
+	for _, turn := range history[first:] {
+		messages = append(messages, llm.Message{Role: turn.Role, Content: turn.Content})
+	}
+	messages = append(messages, question)
+
+	return messages, tokens, nil
+}
@@ -0,0 +1,145 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// failingAnswers is a store which cannot store answers.
+type failingAnswers struct {
+	weaviate.Store
+}
+
+func (s failingAnswers) CreateMessageObject(ctx context.Context, message weaviate.MessageObject) (string, error) {
+	if message.Role == weaviate.RoleAssistant {
+		return "", errors.New("store unavailable")
+	}
+	return s.Store.CreateMessageObject(ctx, message)
+}
+
+func TestStoreTurns(t *testing.T) {
+	tests := []struct {
+		name string
+		// stored are the turns stored before, at positions from zero
+		stored []string
+		// stale leaves the stored turns out of the history, as if they
+		// were stored concurrently
+		stale        bool
+		failAnswer   bool
+		wantPosition int
+		wantErr      bool
+	}{
+		{name: "first turns", wantPosition: 0},
+		{name: "after an answer", stored: []string{weaviate.RoleUser, weaviate.RoleAssistant}, wantPosition: 2},
+		{name: "after a question without answer", stored: []string{weaviate.RoleUser}, wantPosition: 2},
+		{name: "concurrent follow-up", stored: []string{weaviate.RoleUser, weaviate.RoleAssistant}, stale: true, wantPosition: 2},
+		{name: "answer fails", stored: []string{weaviate.RoleUser, weaviate.RoleAssistant}, failAnswer: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			g, _, _ := newBudgetGenerator(t, registry.OverflowReject)
+			promptID, _, err := g.Store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: "func f() {}", Instruct: "Explain this:", Model: "m"}, "It does nothing.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for position, role := range tt.stored {
+				if _, err := g.Store.CreateMessageObject(ctx, weaviate.MessageObject{PromptID: promptID, Position: position, Role: role, Content: role}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			history, err := g.Store.RetrieveConversation(ctx, promptID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stale {
+				history = nil
+			}
+			if tt.failAnswer {
+				g.Store = failingAnswers{Store: g.Store}
+			}
+
+			turns, err := g.storeTurns(ctx, promptID, history,
+				weaviate.MessageObject{PromptID: promptID, Role: weaviate.RoleUser, Content: "Why?"},
+				weaviate.MessageObject{PromptID: promptID, Role: weaviate.RoleAssistant, Content: "Because."},
+			)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("storeTurns() succeeded")
+				}
+				conversation, err := g.Store.RetrieveConversation(ctx, promptID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(conversation) != len(tt.stored) {
+					t.Errorf("conversation after the failure = %+v, want the question discarded", conversation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(turns) != 2 || turns[0].Position != tt.wantPosition || turns[1].Position != tt.wantPosition+1 {
+				t.Fatalf("storeTurns() = %+v, want the question at %d and the answer behind it", turns, tt.wantPosition)
+			}
+			for _, turn := range turns {
+				if stored, err := g.Store.RetrieveMessage(ctx, turn.ID); err != nil || stored.MessageObject != turn.MessageObject {
+					t.Errorf("RetrieveMessage(%s) = %+v, %v, want %+v", turn.ID, stored, err, turn.MessageObject)
+				}
+			}
+		})
+	}
+}
+
+func TestConverseConcurrently(t *testing.T) {
+	ctx := context.Background()
+	g, _, _ := newBudgetGenerator(t, registry.OverflowReject)
+	promptID, _, err := g.Store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: "func f() {}", Instruct: "Explain this:", Model: "m"}, "It does nothing.")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const followUps = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, followUps)
+	for i := 0; i < followUps; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := g.Converse(ctx, promptID, fmt.Sprintf("Question %d?", i), "", nil)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conversation, err := g.Store.RetrieveConversation(ctx, promptID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversation) != 2*followUps {
+		t.Fatalf("conversation has %d turns, want %d", len(conversation), 2*followUps)
+	}
+	for i, turn := range conversation {
+		wantRole := weaviate.RoleUser
+		if i%2 == 1 {
+			wantRole = weaviate.RoleAssistant
+		}
+		if turn.Position != i || turn.Role != wantRole {
+			t.Errorf("turn %d = %s at position %d, want %s at %d", i, turn.Role, turn.Position, wantRole, i)
+		}
+	}
+}
@@ -1,20 +1,73 @@
 package ollama
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/fingerprint"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/ranking"
 	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/registry"
 	"github.com/rwth-acis/modernizer/weaviate"
-	"io"
 	"log"
-	"net/http"
-	"os"
+	"sync"
+	"sync/atomic"
 )
 
-func GenerateResponse(prompt map[string]interface{}) (weaviate.ResponseData, error) {
-	url := os.Getenv("OLLAMA_URL") + "/api/generate"
+// InstructSource picks a random instruct out of an instruct set.
+type InstructSource interface {
+	GetSetMember(ctx context.Context, setName string) (string, error)
+}
+
+// InstructFunc adapts a plain function to the InstructSource interface.
+type InstructFunc func(ctx context.Context, setName string) (string, error)
+
+func (f InstructFunc) GetSetMember(ctx context.Context, setName string) (string, error) {
+	return f(ctx, setName)
+}
+
+// Generator runs the generation pipeline: it asks the LLM for a response,
+// persists prompt and response and derives the semantic meaning of the code.
+type Generator struct {
+	Store     weaviate.Store
+	Provider  llm.LLMProvider
+	Models    *registry.Registry
+	Instructs InstructSource
+	// Queue durably schedules semantic meaning jobs. Without a queue the jobs
+	// run in background goroutines and are lost on restart.
+	Queue redis.JobQueue
+	// Cache answers prompts that were already generated for the same code,
+	// instruct and model. Without a cache every prompt is generated.
+	Cache redis.Cache
+	// Strategy rates the answers considered as examples for retrieval
+	// augmented generation, Wilson scores by default.
+	Strategy ranking.Strategy
+
+	pool    redis.Workers
+	workers workerGroup
+	// windows caches the context length of every model, estimateTokens is
+	// set once the provider turned out not to support tokenization.
+	windows        sync.Map
+	estimateTokens atomic.Bool
+}
+
+func (g *Generator) GenerateResponse(ctx context.Context, prompt map[string]interface{}) (weaviate.ResponseData, error) {
+	return g.generate(ctx, prompt, nil)
+}
+
+// GenerateResponseStream behaves like GenerateResponse but forwards every token
+// to onToken as soon as the model emits it. The complete response is persisted
+// once the stream has finished.
+func (g *Generator) GenerateResponseStream(ctx context.Context, prompt map[string]interface{}, onToken llm.TokenHandler) (weaviate.ResponseData, error) {
+	if onToken == nil {
+		return weaviate.ResponseData{}, errors.New("token handler must not be nil")
+	}
+
+	return g.generate(ctx, prompt, onToken)
+}
 
+func (g *Generator) generate(ctx context.Context, prompt map[string]interface{}, onToken llm.TokenHandler) (weaviate.ResponseData, error) {
 	set, ok := prompt["instructType"].(string)
 
 	log.Printf("instruction type: %s\n", set)
@@ -33,173 +86,164 @@ func GenerateResponse(prompt map[string]interface{}) (weaviate.ResponseData, err
 
 	instruct, ok := prompt["instruct"].(string)
 	if !ok {
-		instruct, _ = redis.GetSetMember(set)
+		instruct, _ = g.Instructs.GetSetMember(ctx, set)
 	}
 
 	log.Printf("Prompt: %s\n", instruct)
 
 	code, ok := prompt["prompt"].(string)
 	if !ok {
-		return weaviate.ResponseData{}, errors.New("prompt field is not a string")
+		return weaviate.ResponseData{}, errkind.New(errkind.Invalid, "prompt field is not a string")
 	}
 
 	log.Printf("Code: %s\n", code)
 
-	completePrompt := instruct + " " + code
-
-	var context int
-	if len(completePrompt) < 2048 {
-		context = 2048
-	} else {
-		context = len(completePrompt) * 2
+	modelName, _ := prompt["model"].(string)
+	model, err := g.Models.Get(modelName)
+	if err != nil {
+		return weaviate.ResponseData{}, err
 	}
 
-	requestBody := map[string]interface{}{
-		"model":  "codellama:13b-instruct",
-		"prompt": completePrompt,
-		"stream": false,
-		"options": map[string]interface{}{
-			"num_ctx": context,
-		},
-	}
+	force, _ := prompt["force"].(bool)
 
-	jsonData, err := json.Marshal(requestBody)
+	k, err := examplesOption(prompt)
 	if err != nil {
 		return weaviate.ResponseData{}, err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	structured, err := structuredOption(prompt, set, instruct)
 	if err != nil {
 		return weaviate.ResponseData{}, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return weaviate.ResponseData{}, err
+	key := fingerprint.Key(code, instruct, model.Name)
+	// cached answers were generated without the current examples and are
+	// free text
+	if g.Cache != nil && k == 0 && !structured {
+		if force {
+			g.record(ctx, redis.CacheForced)
+		} else {
+			responseData, ok, err := g.cached(ctx, key, onToken)
+			if err != nil {
+				return weaviate.ResponseData{}, err
+			}
+			if ok {
+				return responseData, nil
+			}
+		}
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
 
+	augmented := instruct
+	var examples []weaviate.Example
+	if k > 0 {
+		candidates, err := g.examples(ctx, code, k)
+		if err != nil {
+			log.Printf("generating without examples, could not retrieve them: %v", err)
 		}
-	}(resp.Body)
+		augmented, examples, err = g.withExamples(ctx, model, instruct, code, candidates)
+		if err != nil {
+			return weaviate.ResponseData{}, err
+		}
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	var response string
+	if structured {
+		response, err = g.completeStructured(ctx, model, augmented, code, onToken)
+	} else {
+		response, err = g.complete(ctx, model, augmented, code, onToken)
+	}
 	if err != nil {
 		return weaviate.ResponseData{}, err
 	}
 
-	var responseJSON map[string]interface{}
-	err = json.Unmarshal(body, &responseJSON)
-	if err != nil {
-		return weaviate.ResponseData{}, err
+	var suggestions []weaviate.SuggestionObject
+	if structured {
+		suggestions, err = parseSuggestions(response, code)
+		if err != nil {
+			return weaviate.ResponseData{}, err
+		}
 	}
 
-	response, ok := responseJSON["response"].(string)
 	log.Printf("Reponse: %s\n", response)
 
-	if !ok {
-		log.Println("Error: 'response' field is not a string array")
-		return weaviate.ResponseData{}, errors.New("invalid response format")
-	}
-
-	PromptID, err := weaviate.CreatePromptObject(instruct, set, code, "Prompt", gitURL)
+	PromptID, created, err := g.Store.CreatePromptWithResponse(ctx, weaviate.PromptObject{
+		Instruct:     instruct,
+		InstructType: set,
+		Code:         code,
+		GitURL:       gitURL,
+		Model:        model.Name,
+		Examples:     exampleIDs(examples),
+	}, response)
 	if err != nil {
 		return weaviate.ResponseData{}, err
 	}
 
-	log.Printf("PromptID: %s\n", PromptID)
-
-	ResponseID, err := weaviate.CreateResponseObject(response, "Response")
-	if err != nil {
-		return weaviate.ResponseData{}, err
+	if !created {
+		// the prompt was stored before and a reset may have removed its
+		// response since
+		_, err = g.Store.RetrieveProperties(ctx, PromptID)
+		if errors.Is(err, weaviate.ErrNoResponse) {
+			err = g.Store.ReplaceResponse(ctx, PromptID, response)
+		}
+		if err != nil {
+			return weaviate.ResponseData{}, err
+		}
 	}
 
-	err = weaviate.CreateResponseReferences(PromptID, ResponseID)
-	if err != nil {
-		panic(err)
-	}
+	log.Printf("PromptID: %s\n", PromptID)
 
 	responseData := weaviate.ResponseData{
 		Response: response,
 		PromptID: PromptID,
 		Instruct: instruct,
 		GitURL:   gitURL,
+		Model:    model.Name,
+		Examples: examples,
 	}
 
-	go SemanticMeaning(PromptID, code, true)
-
-	return responseData, nil
-}
-
-func SemanticMeaning(promptID string, code string, generateReference bool) string {
-	url := os.Getenv("OLLAMA_URL") + "/api/chat"
-
-	requestBody := map[string]interface{}{
-		"model":  "semantic-meaning",
-		"stream": false,
-		"messages": []map[string]string{
-			{"role": "user", "content": "def add(a, b):\n    return a + b"},
-			{"role": "assistant", "content": "add two numbers"},
-			{"role": "user", "content": "public class ArithmeticFunctions {\n    public static double divide(double a, double b) {\n        if (b == 0) {\n            throw new ArithmeticException(\"Cannot divide by zero\");\n        }\n        return a / b;\n    }\n}"},
-			{"role": "assistant", "content": "multiply two numbers"},
-			{"role": "user", "content": "#include <stdio.h>\n\nint fibonacci(int n) {\n    if (n <= 1)\n        return n;\n    else\n        return fibonacci(n - 1) + fibonacci(n - 2);\n}\n\nint main() {\n    int n, i;\n\n    printf(\"Enter the number of terms: \");\n    scanf(\"%d\", &n);\n\n    printf(\"Fibonacci Series: \");\n    for (i = 0; i < n; i++) {\n        printf(\"%d \", fibonacci(i));\n    }\n\n    return 0;\n}\n"},
-			{"role": "assistant", "content": "calculate the fibonacci sequence"},
-			{"role": "user", "content": "func proxy(c *gin.Context) {\n\tremote, err := url.Parse(os.Getenv(\"OLLAMA_URL\"))\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\n\tproxy := httputil.NewSingleHostReverseProxy(remote)\n\tproxy.Director = func(req *http.Request) {\n\t\treq.Header = c.Request.Header\n\t\treq.Host = remote.Host\n\t\treq.URL.Scheme = remote.Scheme\n\t\treq.URL.Host = remote.Host\n\t\treq.URL.Path = c.Param(\"proxyPath\")\n\t}\n\n\tproxy.ServeHTTP(c.Writer, c.Request)\n}"},
-			{"role": "assistant", "content": "reverse proxy"},
-			{"role": "user", "content": "func CreatePromptObject(instruct string, code string, class string, gitURL string) (string, error) {\n\tclient, err := loadClient()\n\tif err != nil {\n\t\treturn \"\", err\n\t}\n\n\tdataSchema := map[string]interface{}{\n\t\t\"instruct\": instruct,\n\t\t\"code\":     code,\n\t\t\"rank\":     1,\n\t\t\"gitURL\":   gitURL,\n\t}\n\n\tweaviateObject, err := client.Data().Creator().\n\t\tWithClassName(class).\n\t\tWithProperties(dataSchema).\n\t\tDo(context.Background())\n\tif err != nil {\n\t\treturn \"\", err\n\t}\n\n\treturn string(weaviateObject.Object.ID), nil\n}"},
-			{"role": "assistant", "content": "create a weaviate object"},
-			{"role": "user", "content": code},
-		},
+	if structured {
+		if created {
+			responseData.Suggestions, err = g.storeSuggestions(ctx, PromptID, suggestions)
+		} else {
+			// the same generation was stored before, together with its
+			// suggestions
+			responseData.Suggestions, err = g.Store.RetrieveSuggestions(ctx, PromptID)
+		}
+		if err != nil {
+			return weaviate.ResponseData{}, err
+		}
 	}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		log.Printf("could not marshal request body: %s\n", err)
+	if g.Cache != nil && !structured {
+		err = g.Cache.Remember(ctx, key, PromptID)
+		if err != nil {
+			log.Printf("could not cache prompt %s: %v", PromptID, err)
+		}
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		log.Printf("could not create request: %s\n", err)
+	if created {
+		g.enqueueSemanticMeaning(ctx, SemanticMeaningJob{PromptID: PromptID, Code: code})
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("could not send request: %s\n", err)
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
+	return responseData, nil
+}
 
+// discard deletes objects stored by a write that failed halfway. Objects that
+// cannot be deleted are left to the consistency check.
+func (g *Generator) discard(ctx context.Context, class string, ids ...string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, id := range ids {
+		err := g.Store.DeleteObject(ctx, class, id)
+		if err != nil && !errkind.Is(err, errkind.NotFound) {
+			log.Printf("could not discard %s %s: %v", class, id, err)
 		}
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("could not read body")
 	}
+}
 
-	var responseJSON map[string]interface{}
-	err = json.Unmarshal(body, &responseJSON)
+func (g *Generator) SemanticMeaning(ctx context.Context, promptID string, code string, generateReference bool) string {
+	content, err := g.describe(ctx, code)
 	if err != nil {
-		log.Printf("could not unmarshal response: %s\n", err)
-	}
-
-	message, ok := responseJSON["message"].(map[string]interface{})
-	if !ok {
-		log.Println("Error: 'message' field is not a map")
-		return ""
-	}
-
-	content, ok := message["content"].(string)
-	if !ok {
-		log.Println("Error: 'content' field is not a string")
+		log.Printf("could not generate semantic meaning: %s\n", err)
 		return ""
 	}
 
@@ -207,22 +251,43 @@ func SemanticMeaning(promptID string, code string, generateReference bool) strin
 
 	if !generateReference {
 		return content
-	} else {
-		semanticMeaningID, err := weaviate.CreateSemanticMeaningObject(content)
-		if err != nil {
-			log.Printf("creating semantic meaning object failed: %s\n", err)
-		}
-
-		err = weaviate.CreateReferencePromptToSemanticMeaning(promptID, semanticMeaningID)
-		if err != nil {
-			log.Printf("error creating semantic meaning reference: %s\n", err)
-		}
+	}
 
-		err = weaviate.CreateReferenceSemanticMeaningToPrompt(semanticMeaningID, promptID)
-		if err != nil {
-			log.Printf("error creating semantic meaning reference: %s\n", err)
-		}
+	err = g.Store.SetSemanticMeaningPrompt(ctx, promptID, content)
+	if err != nil {
+		log.Printf("error creating semantic meaning reference: %s\n", err)
 	}
 
 	return ""
 }
+
+// describe asks the semantic meaning model for a short description of code.
+func (g *Generator) describe(ctx context.Context, code string) (string, error) {
+	model, err := g.Models.Get(g.Models.SemanticMeaning)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := g.Provider.Chat(ctx, llm.ChatRequest{
+		Model:   model.Name,
+		Options: model.Options,
+		Messages: []llm.Message{
+			{Role: "user", Content: "def add(a, b):\n    return a + b"},
+			{Role: "assistant", Content: "add two numbers"},
+			{Role: "user", Content: "public class ArithmeticFunctions {\n    public static double divide(double a, double b) {\n        if (b == 0) {\n            throw new ArithmeticException(\"Cannot divide by zero\");\n        }\n        return a / b;\n    }\n}"},
+			{Role: "assistant", Content: "multiply two numbers"},
+			{Role: "user", Content: "#include <stdio.h>\n\nint fibonacci(int n) {\n    if (n <= 1)\n        return n;\n    else\n        return fibonacci(n - 1) + fibonacci(n - 2);\n}\n\nint main() {\n    int n, i;\n\n    printf(\"Enter the number of terms: \");\n    scanf(\"%d\", &n);\n\n    printf(\"Fibonacci Series: \");\n    for (i = 0; i < n; i++) {\n        printf(\"%d \", fibonacci(i));\n    }\n\n    return 0;\n}\n"},
+			{Role: "assistant", Content: "calculate the fibonacci sequence"},
+			{Role: "user", Content: "func proxy(c *gin.Context) {\n\tremote, err := url.Parse(os.Getenv(\"OLLAMA_URL\"))\n\tif err != nil {\n\t\tpanic(err)\n\t}\n\n\tproxy := httputil.NewSingleHostReverseProxy(remote)\n\tproxy.Director = func(req *http.Request) {\n\t\treq.Header = c.Request.Header\n\t\treq.Host = remote.Host\n\t\treq.URL.Scheme = remote.Scheme\n\t\treq.URL.Host = remote.Host\n\t\treq.URL.Path = c.Param(\"proxyPath\")\n\t}\n\n\tproxy.ServeHTTP(c.Writer, c.Request)\n}"},
+			{Role: "assistant", Content: "reverse proxy"},
+			{Role: "user", Content: "func CreatePromptObject(instruct string, code string, class string, gitURL string) (string, error) {\n\tclient, err := loadClient()\n\tif err != nil {\n\t\treturn \"\", err\n\t}\n\n\tdataSchema := map[string]interface{}{\n\t\t\"instruct\": instruct,\n\t\t\"code\":     code,\n\t\t\"rank\":     1,\n\t\t\"gitURL\":   gitURL,\n\t}\n\n\tweaviateObject, err := client.Data().Creator().\n\t\tWithClassName(class).\n\t\tWithProperties(dataSchema).\n\t\tDo(context.Background())\n\tif err != nil {\n\t\treturn \"\", err\n\t}\n\n\treturn string(weaviateObject.Object.ID), nil\n}"},
+			{Role: "assistant", Content: "create a weaviate object"},
+			{Role: "user", Content: code},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return content, nil
+}
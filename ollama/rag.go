@@ -0,0 +1,131 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ranking"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+const (
+	// DefaultExamples is the number of examples used when a prompt asks for
+	// retrieval augmented generation without giving a number.
+	DefaultExamples = 3
+	MaxExamples     = 10
+	// exampleCandidates is the number of similar prompts fetched per example,
+	// since most prompts have no votes and are skipped.
+	exampleCandidates = 5
+)
+
+// examplesOption reads whether prompt asks for retrieval augmented generation
+// and how many examples it wants. The number is zero if it does not.
+func examplesOption(prompt map[string]interface{}) (int, error) {
+	rag, _ := prompt["rag"].(bool)
+	if !rag {
+		return 0, nil
+	}
+
+	value, ok := prompt["ragExamples"]
+	if !ok {
+		return DefaultExamples, nil
+	}
+
+	// JSON numbers are decoded as float64
+	examples, ok := value.(float64)
+	if !ok || examples != float64(int(examples)) || examples < 1 || examples > MaxExamples {
+		return 0, errkind.New(errkind.Invalid, "ragExamples must be a whole number between 1 and %d", MaxExamples)
+	}
+
+	return int(examples), nil
+}
+
+// examples returns up to k answered prompts about code similar to code whose
+// answers got more upvotes than downvotes, best rated first.
+func (g *Generator) examples(ctx context.Context, code string, k int) ([]weaviate.Example, error) {
+	candidates, err := g.Store.SimilarPrompts(ctx, code, k*exampleCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy := g.Strategy
+	if strategy == nil {
+		strategy = ranking.Wilson{Z: 1.96}
+	}
+
+	var examples []weaviate.Example
+	for _, example := range candidates {
+		if example.Upvotes <= example.Downvotes {
+			continue
+		}
+		example.Score = strategy.Score(example.Upvotes, example.Downvotes)
+		examples = append(examples, example)
+	}
+
+	sort.SliceStable(examples, func(i, j int) bool {
+		return examples[i].Score > examples[j].Score
+	})
+	if len(examples) > k {
+		examples = examples[:k]
+	}
+
+	return examples, nil
+}
+
+// withExamples prepends the examples to instruct as few-shot context. Examples
+// that would push the prompt about code out of the context of model are left
+// out. It returns the new instruct together with the examples it contains.
+func (g *Generator) withExamples(ctx context.Context, model registry.Model, instruct string, code string, examples []weaviate.Example) (string, []weaviate.Example, error) {
+	const (
+		intro = "The following answers to questions about similar code were rated helpful by users:\n\n"
+		outro = "Answer the next question in the same way.\n"
+	)
+
+	limit := g.promptLimit(ctx, model)
+
+	var shots strings.Builder
+	var used []weaviate.Example
+	for _, example := range examples {
+		shot := fmt.Sprintf("Question: %s\nCode:\n%s\nAnswer:\n%s\n\n", example.Instruct, example.Code, example.Response)
+
+		tokens, err := g.tokens(ctx, model, intro+shots.String()+shot+outro+instruct+" "+code)
+		if err != nil {
+			return "", nil, err
+		}
+		// a shorter example further down may still fit
+		if tokens > limit {
+			continue
+		}
+
+		shots.WriteString(shot)
+		used = append(used, example)
+	}
+
+	if len(used) == 0 {
+		return instruct, nil, nil
+	}
+
+	return intro + shots.String() + outro + instruct, used, nil
+}
+
+// exampleIDs returns the prompt IDs of examples.
+func exampleIDs(examples []weaviate.Example) []string {
+	ids := make([]string, 0, len(examples))
+	for _, example := range examples {
+		ids = append(ids, example.PromptID)
+	}
+	return ids
+}
+
+// examplesByID lists examples known only by their prompt IDs.
+func examplesByID(ids []string) []weaviate.Example {
+	var examples []weaviate.Example
+	for _, id := range ids {
+		examples = append(examples, weaviate.Example{PromptID: id})
+	}
+	return examples
+}
@@ -0,0 +1,144 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/patch"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// ErrInvalidSuggestions is returned if the model does not answer a structured
+// prompt in the requested format. The model failed rather than the caller, so
+// it is unavailable.
+var ErrInvalidSuggestions = errkind.New(errkind.Unavailable, "model did not answer with valid suggestions")
+
+// structuredInstructType is the instruct set whose answers can be structured
+// as suggestions. Other instructs can be if they ask for a refactoring.
+const structuredInstructType = "modernisation"
+
+// suggestionsFormat describes the structured answer to the model.
+const suggestionsFormat = `Answer with a JSON object of the form
+{"suggestions": [{"title": "...", "rationale": "...", "severity": "low", "diff": "..."}]}
+Every suggestion is a single self-contained change. The title summarizes it in a few words, the rationale explains why it improves the code and the severity is low, medium or high. The diff is a unified diff against the code below with "--- a/code" and "+++ b/code" headers and hunks starting with "@@", each with three lines of unchanged context. Answer with an empty list if the code is fine as it is.
+The code is:`
+
+// structuredOption reads whether prompt asks for an answer structured as
+// suggestions. Only modernisation and refactoring instructs can be.
+func structuredOption(prompt map[string]interface{}, set string, instruct string) (bool, error) {
+	structured, _ := prompt["structured"].(bool)
+	if !structured {
+		return false, nil
+	}
+
+	if set != structuredInstructType && !strings.Contains(strings.ToLower(instruct), "refactor") {
+		return false, errkind.New(errkind.Invalid, "structured answers are only available for modernisation and refactoring instructs")
+	}
+
+	return true, nil
+}
+
+// completeStructured asks model for suggestions about code. Diffs depend on
+// all of the code, so prompts that do not fit are always rejected.
+func (g *Generator) completeStructured(ctx context.Context, model registry.Model, instruct string, code string, onToken llm.TokenHandler) (string, error) {
+	return g.request(ctx, model, llm.GenerateRequest{
+		Prompt:  instruct + "\n" + suggestionsFormat + "\n" + code,
+		OnToken: onToken,
+		JSON:    true,
+	})
+}
+
+// parseSuggestions validates a structured answer and checks every diff
+// against code. Suggestions whose diff does not apply are kept with the
+// reason, since their rationale may still help.
+func parseSuggestions(answer string, code string) ([]weaviate.SuggestionObject, error) {
+	var answerJSON struct {
+		Suggestions []struct {
+			Title     string `json:"title"`
+			Rationale string `json:"rationale"`
+			Severity  string `json:"severity"`
+			Diff      string `json:"diff"`
+		} `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(stripCodeFence(answer)), &answerJSON); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSuggestions, err)
+	}
+
+	suggestions := make([]weaviate.SuggestionObject, 0, len(answerJSON.Suggestions))
+	for i, s := range answerJSON.Suggestions {
+		suggestion := weaviate.SuggestionObject{
+			Position:  i,
+			Title:     strings.TrimSpace(s.Title),
+			Rationale: strings.TrimSpace(s.Rationale),
+			Severity:  strings.ToLower(strings.TrimSpace(s.Severity)),
+			Diff:      s.Diff,
+		}
+
+		switch {
+		case suggestion.Rationale == "":
+			return nil, fmt.Errorf("%w: suggestion %d has no rationale", ErrInvalidSuggestions, i+1)
+		case strings.TrimSpace(suggestion.Diff) == "":
+			return nil, fmt.Errorf("%w: suggestion %d has no diff", ErrInvalidSuggestions, i+1)
+		}
+		switch suggestion.Severity {
+		case weaviate.SeverityLow, weaviate.SeverityMedium, weaviate.SeverityHigh:
+		default:
+			return nil, fmt.Errorf("%w: suggestion %d has severity %q, expected low, medium or high", ErrInvalidSuggestions, i+1, s.Severity)
+		}
+
+		if _, err := patch.Apply(code, suggestion.Diff); err != nil {
+			suggestion.Problem = err.Error()
+		} else {
+			suggestion.Applies = true
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// storeSuggestions stores the suggestions answering promptID. If one cannot be
+// stored, the prompt is deleted together with its response and suggestions.
+func (g *Generator) storeSuggestions(ctx context.Context, promptID string, suggestions []weaviate.SuggestionObject) ([]weaviate.Suggestion, error) {
+	stored := make([]weaviate.Suggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		suggestion.PromptID = promptID
+
+		id, err := g.Store.CreateSuggestionObject(ctx, suggestion)
+		if err != nil {
+			// without its suggestions the prompt would be stored as answered
+			for _, s := range stored {
+				g.discard(ctx, weaviate.SuggestionClass, s.ID)
+			}
+			g.discard(ctx, weaviate.PromptClass, promptID)
+			g.discard(ctx, weaviate.ResponseClass, weaviate.ResponseUUID(promptID))
+			return nil, err
+		}
+		stored = append(stored, weaviate.Suggestion{ID: id, SuggestionObject: suggestion})
+	}
+
+	return stored, nil
+}
+
+// stripCodeFence removes a markdown code fence around text, which models add
+// out of habit even when asked for plain JSON.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	} else {
+		text = strings.TrimPrefix(text, "```")
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
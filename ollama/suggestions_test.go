@@ -0,0 +1,99 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+func TestParseSuggestions(t *testing.T) {
+	const code = "func f() {\n\tfmt.Println(\"a\")\n}\n"
+	const change = "@@ -1,3 +1,3 @@\n func f() {\n-\tfmt.Println(\"a\")\n+\tlog.Println(\"a\")\n }\n"
+	diff, err := json.Marshal(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		answer string
+		want   []weaviate.SuggestionObject
+		// wantErr is part of the error, which always wraps
+		// ErrInvalidSuggestions
+		wantErr string
+	}{
+		{
+			name:   "applying diff",
+			answer: `{"suggestions": [{"title": " Use log ", "rationale": "Logs carry a time.", "severity": "Medium", "diff": ` + string(diff) + `}]}`,
+			want: []weaviate.SuggestionObject{
+				{Title: "Use log", Rationale: "Logs carry a time.", Severity: weaviate.SeverityMedium, Diff: change, Applies: true},
+			},
+		},
+		{
+			name:   "code fence and mismatching context",
+			answer: "```json\n" + `{"suggestions": [{"title": "Rename", "rationale": "Clearer.", "severity": "low", "diff": "@@ -1 +1 @@\n-func g() {\n+func h() {\n"}]}` + "\n```",
+			want: []weaviate.SuggestionObject{
+				{Title: "Rename", Rationale: "Clearer.", Severity: weaviate.SeverityLow, Diff: "@@ -1 +1 @@\n-func g() {\n+func h() {\n", Problem: "hunk 1 does not apply at line 1"},
+			},
+		},
+		{
+			name:   "malformed diff",
+			answer: `{"suggestions": [{"title": "Remove", "rationale": "Unused.", "severity": "high", "diff": "remove the call"}]}`,
+			want: []weaviate.SuggestionObject{
+				{Title: "Remove", Rationale: "Unused.", Severity: weaviate.SeverityHigh, Diff: "remove the call", Problem: "diff contains no hunks"},
+			},
+		},
+		{
+			name:   "no suggestions",
+			answer: `{"suggestions": []}`,
+			want:   []weaviate.SuggestionObject{},
+		},
+		{
+			name:    "no JSON",
+			answer:  "The code is fine.",
+			wantErr: "invalid character",
+		},
+		{
+			name:    "no rationale",
+			answer:  `{"suggestions": [{"title": "Use log", "severity": "low", "diff": ` + string(diff) + `}]}`,
+			wantErr: "suggestion 1 has no rationale",
+		},
+		{
+			name:    "no diff",
+			answer:  `{"suggestions": [{"title": "Use log", "rationale": "Logs carry a time.", "severity": "low", "diff": " "}]}`,
+			wantErr: "suggestion 1 has no diff",
+		},
+		{
+			name:    "unknown severity",
+			answer:  `{"suggestions": [{"title": "Use log", "rationale": "Logs carry a time.", "severity": "critical", "diff": ` + string(diff) + `}]}`,
+			wantErr: `suggestion 1 has severity "critical"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSuggestions(tt.answer, code)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidSuggestions) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseSuggestions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseSuggestions() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if tt.want[i].Position = i; got[i] != tt.want[i] {
+					t.Errorf("suggestion %d = %+v, want %+v", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/rwth-acis/modernizer/redis"
+)
+
+// SemanticMeaningQueue is the name of the queue holding semantic meaning jobs.
+const SemanticMeaningQueue = "semantic-meaning"
+
+// SemanticMeaningJob identifies a prompt whose semantic meaning still has to
+// be generated and linked.
+type SemanticMeaningJob struct {
+	PromptID string `json:"promptID"`
+	Code     string `json:"code"`
+}
+
+// workerGroup tracks the background goroutines, such as semantic meaning
+// jobs when no queue is configured. The zero value is ready to use.
+type workerGroup struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (w *workerGroup) init() {
+	w.once.Do(func() {
+		w.ctx, w.cancel = context.WithCancel(context.Background())
+	})
+}
+
+// StartWorkers starts size workers processing the semantic meaning jobs of
+// Queue.
+func (g *Generator) StartWorkers(size int) {
+	if g.Queue == nil || g.pool != nil {
+		return
+	}
+
+	g.pool = g.Queue.Work(size, g.handleSemanticMeaning)
+}
+
+// handleSemanticMeaning describes the code of the prompt. A job may be
+// delivered again after it timed out, the meaning then replaces the first one.
+func (g *Generator) handleSemanticMeaning(ctx context.Context, job redis.Job) error {
+	var payload SemanticMeaningJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("malformed semantic meaning job: %w", err)
+	}
+
+	content, err := g.describe(ctx, payload.Code)
+	if err != nil {
+		return err
+	}
+
+	return g.Store.SetSemanticMeaningPrompt(ctx, payload.PromptID, content)
+}
+
+// enqueueSemanticMeaning schedules the job on the queue and falls back to a
+// background goroutine if the queue is unavailable.
+func (g *Generator) enqueueSemanticMeaning(ctx context.Context, job SemanticMeaningJob) {
+	if g.Queue != nil {
+		// the job must be enqueued even if the client has already gone away
+		_, err := g.Queue.Enqueue(context.WithoutCancel(ctx), job)
+		if err == nil {
+			return
+		}
+		log.Printf("could not enqueue semantic meaning job for prompt %s, running it in the background: %v", job.PromptID, err)
+	}
+
+	g.background(func(ctx context.Context) {
+		g.SemanticMeaning(ctx, job.PromptID, job.Code, true)
+	})
+}
+
+// background runs fn in a goroutine that Shutdown waits for. The context
+// passed to fn is cancelled if Shutdown gives up waiting.
+func (g *Generator) background(fn func(ctx context.Context)) {
+	w := &g.workers
+	w.init()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.ctx)
+	}()
+}
+
+// Shutdown stops the workers after their current job and waits for
+// background goroutines until ctx is done. Queued jobs that are interrupted
+// are handed back to the queue and picked up after the restart.
+func (g *Generator) Shutdown(ctx context.Context) error {
+	var err error
+	if g.pool != nil {
+		err = g.pool.Stop(ctx)
+	}
+
+	w := &g.workers
+	w.init()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		log.Println("cancelled background jobs on shutdown")
+		err = ctx.Err()
+	}
+
+	return err
+}
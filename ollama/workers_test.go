@@ -0,0 +1,45 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+func TestHandleSemanticMeaningTwice(t *testing.T) {
+	ctx := context.Background()
+	g, _, _ := newBudgetGenerator(t, registry.OverflowReject)
+
+	code := "func f() int {\n\treturn 1\n}\n"
+	promptID, _, err := g.Store.CreatePromptWithResponse(ctx, weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "m"}, "It returns one.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(SemanticMeaningJob{PromptID: promptID, Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a job delivered again after its visibility timeout runs twice
+	job := redis.Job{ID: "job", Payload: payload}
+	for i := 0; i < 2; i++ {
+		if err := g.handleSemanticMeaning(ctx, job); err != nil {
+			t.Fatalf("handleSemanticMeaning() run %d = %v", i+1, err)
+		}
+	}
+
+	meanings, err := g.Store.ListObjects(ctx, weaviate.SemanticMeaningClass, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meanings) != 1 || meanings[0].ID != weaviate.SemanticMeaningUUID(promptID) {
+		t.Errorf("semantic meanings = %+v, want the one of the prompt", meanings)
+	}
+	if _, ok := g.Store.RetrieveHasSemanticMeaning(ctx, weaviate.CodeMatch{Code: code}); !ok {
+		t.Error("RetrieveHasSemanticMeaning() found no meaning for the prompt")
+	}
+}
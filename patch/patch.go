@@ -0,0 +1,220 @@
+// Package patch parses unified diffs and applies them to a single text, such
+// as the code of a prompt.
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNoHunks is returned for diffs without any change.
+var ErrNoHunks = errors.New("diff contains no hunks")
+
+// Hunk is a contiguous change. Lines keep their prefix: a space for context,
+// a minus for removed and a plus for added lines. Starts count from one.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+// before returns the lines the hunk expects in the text.
+func (h Hunk) before() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// after returns the lines the hunk leaves in the text.
+func (h Hunk) after() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse reads the hunks of a unified diff of a single file. File headers and
+// other lines before the first hunk are skipped. The line counts in the hunk
+// headers have to match the lines of the hunks.
+func Parse(diff string) ([]Hunk, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	// the newline ending the last line does not start another one
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var hunks []Hunk
+	var current *Hunk
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		before, after := len(current.before()), len(current.after())
+		if before != current.OldLines || after != current.NewLines {
+			return fmt.Errorf("hunk %d has %d old and %d new lines, its header announces %d and %d", len(hunks)+1, before, after, current.OldLines, current.NewLines)
+		}
+		hunks = append(hunks, *current)
+		current = nil
+		return nil
+	}
+
+	for i, line := range lines {
+		if match := hunkHeader.FindStringSubmatch(line); match != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &Hunk{
+				OldStart: atoi(match[1]),
+				OldLines: count(match[2]),
+				NewStart: atoi(match[3]),
+				NewLines: count(match[4]),
+			}
+			continue
+		}
+
+		if current == nil {
+			// file headers and explanations before the first hunk
+			continue
+		}
+
+		complete := len(current.before()) == current.OldLines && len(current.after()) == current.NewLines
+		switch {
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case complete && (strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "diff ")):
+			return nil, fmt.Errorf("line %d: diff changes more than one file", i+1)
+		case line == "" && complete:
+			// blank lines between hunks
+		case line == "":
+			// editors and models drop the space of empty context lines
+			current.Lines = append(current.Lines, " ")
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			current.Lines = append(current.Lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: unexpected line %q in hunk", i+1, line)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	if len(hunks) == 0 {
+		return nil, ErrNoHunks
+	}
+
+	return hunks, nil
+}
+
+// maxOffset is how many lines away from the position its header gives a hunk
+// may apply. Further away, a match of short context is more likely to be a
+// different piece of code than the one the hunk was written for.
+const maxOffset = 20
+
+// Apply applies diff to text. Like patch, a hunk may apply up to maxOffset
+// lines away from the position its header gives, but its context and removed
+// lines have to match exactly.
+func Apply(text string, diff string) (string, error) {
+	hunks, err := Parse(diff)
+	if err != nil {
+		return "", err
+	}
+
+	trailingNewline := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	var result []string
+	// next is the first line of text not yet copied to result
+	next := 0
+	for i, hunk := range hunks {
+		old := hunk.before()
+
+		at, ok := locate(lines, old, position(hunk), next)
+		if !ok {
+			return "", fmt.Errorf("hunk %d does not apply at line %d", i+1, hunk.OldStart)
+		}
+
+		result = append(result, lines[next:at]...)
+		result = append(result, hunk.after()...)
+		next = at + len(old)
+	}
+	result = append(result, lines[next:]...)
+
+	patched := strings.Join(result, "\n")
+	if trailingNewline && len(result) > 0 {
+		patched += "\n"
+	}
+
+	return patched, nil
+}
+
+// position returns the index of the first line the hunk replaces. Hunks
+// without old lines insert after their start line.
+func position(hunk Hunk) int {
+	if hunk.OldLines == 0 {
+		return hunk.OldStart
+	}
+	return hunk.OldStart - 1
+}
+
+// locate finds old in lines at or after from, as close to want as possible
+// and at most maxOffset lines away.
+func locate(lines []string, old []string, want int, from int) (int, bool) {
+	matches := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for i, line := range old {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	// pure insertions have nothing to compare, so they go where they say
+	if len(old) == 0 {
+		return want, want >= from && want <= len(lines)
+	}
+
+	for offset := 0; offset <= maxOffset; offset++ {
+		if matches(want + offset) {
+			return want + offset, true
+		}
+		if offset > 0 && matches(want-offset) {
+			return want - offset, true
+		}
+	}
+
+	return 0, false
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// count reads the optional line count of a hunk header, which is one if
+// left out.
+func count(s string) int {
+	if s == "" {
+		return 1
+	}
+	return atoi(s)
+}
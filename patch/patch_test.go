@@ -0,0 +1,240 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// text has the lines a to j.
+const text = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		want    []Hunk
+		wantErr string
+	}{
+		{
+			name: "file headers",
+			diff: "diff --git a/code b/code\n--- a/code\n+++ b/code\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			want: []Hunk{{OldStart: 2, OldLines: 3, NewStart: 2, NewLines: 3, Lines: []string{" b", "-c", "+C", " d"}}},
+		},
+		{
+			name: "multiple hunks",
+			diff: "@@ -1,2 +1,2 @@\n-a\n+A\n b\n\n@@ -9 +9 @@\n-i\n+I\n",
+			want: []Hunk{
+				{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2, Lines: []string{"-a", "+A", " b"}},
+				{OldStart: 9, OldLines: 1, NewStart: 9, NewLines: 1, Lines: []string{"-i", "+I"}},
+			},
+		},
+		{
+			name: "empty context line without its space",
+			diff: "@@ -1,3 +1,3 @@\n a\n\n-c\n+C\n",
+			want: []Hunk{{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3, Lines: []string{" a", " ", "-c", "+C"}}},
+		},
+		{
+			name: "no newline at end of file",
+			diff: "@@ -10 +10 @@\n-j\n\\ No newline at end of file\n+J\n\\ No newline at end of file\n",
+			want: []Hunk{{OldStart: 10, OldLines: 1, NewStart: 10, NewLines: 1, Lines: []string{"-j", "+J"}}},
+		},
+		{
+			name: "windows line endings",
+			diff: "@@ -2 +2 @@\r\n-b\r\n+B\r\n",
+			want: []Hunk{{OldStart: 2, OldLines: 1, NewStart: 2, NewLines: 1, Lines: []string{"-b", "+B"}}},
+		},
+		{
+			name:    "no hunks",
+			diff:    "--- a/code\n+++ b/code\n",
+			wantErr: ErrNoHunks.Error(),
+		},
+		{
+			name:    "header without closing marker",
+			diff:    "@@ -2,1 +2,1\n-b\n+B\n",
+			wantErr: ErrNoHunks.Error(),
+		},
+		{
+			name:    "header without numbers",
+			diff:    "@@ -b +B @@\n-b\n+B\n",
+			wantErr: ErrNoHunks.Error(),
+		},
+		{
+			name:    "header announcing more lines",
+			diff:    "@@ -2,3 +2,3 @@\n b\n-c\n+C\n",
+			wantErr: "hunk 1 has 2 old and 2 new lines, its header announces 3 and 3",
+		},
+		{
+			name:    "header announcing fewer lines",
+			diff:    "@@ -2,1 +2,1 @@\n-b\n-c\n+B\n",
+			wantErr: "hunk 1 has 2 old and 1 new lines, its header announces 1 and 1",
+		},
+		{
+			name:    "unexpected line",
+			diff:    "@@ -2,2 +2,2 @@\n-b\nc\n",
+			wantErr: `line 3: unexpected line "c" in hunk`,
+		},
+		{
+			name:    "second file",
+			diff:    "--- a/code\n+++ b/code\n@@ -2 +2 @@\n-b\n+B\n--- a/other\n+++ b/other\n@@ -1 +1 @@\n-x\n+y\n",
+			wantErr: "line 6: diff changes more than one file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		diff    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "change",
+			text: text,
+			diff: "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			want: "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "multiple hunks",
+			text: text,
+			diff: "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n@@ -7,3 +7,4 @@\n g\n-h\n+H\n+H2\n i\n",
+			want: "a\nb\nC\nd\ne\nf\ng\nH\nH2\ni\nj\n",
+		},
+		{
+			name: "hunk away from its header",
+			text: text,
+			diff: "@@ -5,3 +5,3 @@\n b\n-c\n+C\n d\n",
+			want: "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "pure insertion",
+			text: text,
+			diff: "@@ -3,0 +4,2 @@\n+x\n+y\n",
+			want: "a\nb\nc\nx\ny\nd\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "insertion at the start",
+			text: text,
+			diff: "@@ -0,0 +1 @@\n+package main\n",
+			want: "package main\na\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "insertion into empty text",
+			text: "",
+			diff: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want: "a\nb",
+		},
+		{
+			name: "pure deletion",
+			text: text,
+			diff: "@@ -4,2 +3,0 @@\n-d\n-e\n",
+			want: "a\nb\nc\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "deletion with context",
+			text: text,
+			diff: "@@ -3,3 +3,2 @@\n c\n-d\n e\n",
+			want: "a\nb\nc\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name: "append at the end",
+			text: text,
+			diff: "@@ -9,2 +9,3 @@\n i\n j\n+k\n",
+			want: "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n",
+		},
+		{
+			name: "delete the last line",
+			text: text,
+			diff: "@@ -9,2 +9,1 @@\n i\n-j\n",
+			want: "a\nb\nc\nd\ne\nf\ng\nh\ni\n",
+		},
+		{
+			name: "end without newline",
+			text: "a\nb",
+			diff: "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want: "a\nB",
+		},
+		{
+			name: "hunk within the offset",
+			text: strings.Repeat("x\n", maxOffset) + text,
+			diff: "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			want: strings.Repeat("x\n", maxOffset) + "a\nb\nC\nd\ne\nf\ng\nh\ni\nj\n",
+		},
+		{
+			name:    "distant false match",
+			text:    strings.Repeat("x\n", maxOffset+1) + text,
+			diff:    "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			wantErr: "hunk 1 does not apply at line 2",
+		},
+		{
+			name:    "context mismatch",
+			text:    text,
+			diff:    "@@ -2,3 +2,3 @@\n b\n-x\n+X\n d\n",
+			wantErr: "hunk 1 does not apply at line 2",
+		},
+		{
+			name:    "second hunk before the first",
+			text:    text,
+			diff:    "@@ -7,1 +7,1 @@\n-g\n+G\n@@ -2,1 +2,1 @@\n-b\n+B\n",
+			wantErr: "hunk 2 does not apply at line 2",
+		},
+		{
+			name:    "hunk beyond the end",
+			text:    text,
+			diff:    "@@ -20,0 +21 @@\n+k\n",
+			wantErr: "hunk 1 does not apply at line 20",
+		},
+		{
+			name:    "malformed diff",
+			text:    text,
+			diff:    "just rename c",
+			wantErr: ErrNoHunks.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.text, tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Apply() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyNoHunks(t *testing.T) {
+	_, err := Apply(text, strings.Repeat("\n", 3))
+	if !errors.Is(err, ErrNoHunks) {
+		t.Errorf("Apply() error = %v, want %v", err, ErrNoHunks)
+	}
+}
@@ -0,0 +1,137 @@
+// Package ranking scores responses by their upvotes and downvotes.
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy turns the votes of a response into a score. Higher scores are
+// better.
+type Strategy interface {
+	Name() string
+	Score(upvotes int, downvotes int) float64
+}
+
+// New returns the strategy with the given name: wilson, bayesian or thompson.
+func New(name string) (Strategy, error) {
+	switch name {
+	case "wilson":
+		return Wilson{Z: 1.96}, nil
+	case "bayesian":
+		return Bayesian{PriorMean: 0.5, PriorWeight: 5}, nil
+	case "thompson":
+		return NewThompson(time.Now().UnixNano()), nil
+	default:
+		return nil, fmt.Errorf("unknown ranking strategy %q, expected wilson, bayesian or thompson", name)
+	}
+}
+
+// Wilson scores by the lower bound of the Wilson score interval of the
+// upvote ratio. Few votes give a wide interval and thus a low score, so a
+// response needs consistent approval to rank high. Without upvotes the lower
+// bound is zero however many downvotes there are, so these responses are
+// ordered by the upper bound instead, scaled by withoutUpvotes. Unrated
+// responses thus rank above downvoted ones and below approved ones.
+type Wilson struct {
+	// Z is the quantile of the confidence level, 1.96 for 95%.
+	Z float64
+}
+
+func (w Wilson) Name() string {
+	return "wilson"
+}
+
+// withoutUpvotes scales the score of responses without upvotes.
+const withoutUpvotes = 0.01
+
+func (w Wilson) Score(upvotes int, downvotes int) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return withoutUpvotes
+	}
+
+	z2 := w.Z * w.Z
+	if upvotes == 0 {
+		return withoutUpvotes * z2 / (n + z2)
+	}
+
+	p := float64(upvotes) / n
+
+	return (p + z2/(2*n) - w.Z*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// Bayesian scores by the upvote ratio after adding PriorWeight imaginary votes
+// with an upvote ratio of PriorMean. Responses without votes score PriorMean.
+type Bayesian struct {
+	PriorMean   float64
+	PriorWeight float64
+}
+
+func (b Bayesian) Name() string {
+	return "bayesian"
+}
+
+func (b Bayesian) Score(upvotes int, downvotes int) float64 {
+	return (b.PriorWeight*b.PriorMean + float64(upvotes)) / (b.PriorWeight + float64(upvotes+downvotes))
+}
+
+// Thompson scores by a sample of the Beta(upvotes+1, downvotes+1) posterior
+// of the upvote ratio. Responses with few votes have a wide posterior and are
+// therefore shown now and then, which collects votes for them.
+type Thompson struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewThompson creates a Thompson sampler with the given seed.
+func NewThompson(seed int64) *Thompson {
+	return &Thompson{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (t *Thompson) Name() string {
+	return "thompson"
+}
+
+func (t *Thompson) Score(upvotes int, downvotes int) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	x := t.gamma(float64(upvotes + 1))
+	y := t.gamma(float64(downvotes + 1))
+
+	return x / (x + y)
+}
+
+// gamma samples Gamma(shape, 1) for shape >= 1 with the method of Marsaglia
+// and Tsang.
+func (t *Thompson) gamma(shape float64) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+
+	for {
+		x := t.rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+
+		u := t.rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// LegacyVotes converts the rank of a prompt voted on before votes were counted
+// separately into votes. Every prompt started at rank one.
+func LegacyVotes(rank int) (upvotes int, downvotes int) {
+	if rank > 1 {
+		return rank - 1, 0
+	}
+	return 0, 1 - rank
+}
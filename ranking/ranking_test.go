@@ -0,0 +1,131 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+)
+
+// votes are the upvotes and downvotes of a response.
+type votes struct {
+	up, down int
+}
+
+func TestScore(t *testing.T) {
+	wilson := Wilson{Z: 1.96}
+	bayesian := Bayesian{PriorMean: 0.5, PriorWeight: 5}
+
+	tests := []struct {
+		strategy Strategy
+		votes    votes
+		want     float64
+	}{
+		{strategy: wilson, votes: votes{0, 0}, want: 0.01},
+		{strategy: wilson, votes: votes{1, 0}, want: 0.206543},
+		{strategy: wilson, votes: votes{10, 0}, want: 0.722460},
+		{strategy: wilson, votes: votes{5, 5}, want: 0.236590},
+		{strategy: wilson, votes: votes{0, 5}, want: 0.004345},
+		{strategy: bayesian, votes: votes{0, 0}, want: 0.5},
+		{strategy: bayesian, votes: votes{10, 0}, want: 12.5 / 15},
+		{strategy: bayesian, votes: votes{5, 5}, want: 0.5},
+		{strategy: bayesian, votes: votes{0, 5}, want: 0.25},
+	}
+
+	for _, tt := range tests {
+		got := tt.strategy.Score(tt.votes.up, tt.votes.down)
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s.Score(%d, %d) = %f, want %f", tt.strategy.Name(), tt.votes.up, tt.votes.down, got, tt.want)
+		}
+	}
+}
+
+func TestScoreOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		// ordered lists votes from the best to the worst score, ties lists
+		// votes scoring the same
+		ordered []votes
+		ties    []votes
+	}{
+		{
+			name:     "wilson",
+			strategy: Wilson{Z: 1.96},
+			// without upvotes fewer downvotes rank higher, unrated
+			// responses rank above clearly rejected ones
+			ordered: []votes{{100, 5}, {10, 0}, {50, 50}, {10, 10}, {1, 0}, {1, 1}, {0, 0}, {0, 1}, {1, 50}, {0, 100}},
+		},
+		{
+			name:     "bayesian",
+			strategy: Bayesian{PriorMean: 0.5, PriorWeight: 5},
+			ordered:  []votes{{100, 5}, {10, 0}, {1, 0}, {0, 0}, {0, 1}, {0, 10}, {0, 100}},
+			ties:     []votes{{0, 0}, {1, 1}, {50, 50}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 1; i < len(tt.ordered); i++ {
+				better, worse := tt.ordered[i-1], tt.ordered[i]
+				if a, b := tt.strategy.Score(better.up, better.down), tt.strategy.Score(worse.up, worse.down); a <= b {
+					t.Errorf("%v scores %f, not more than %f of %v", better, a, b, worse)
+				}
+			}
+
+			if len(tt.ties) == 0 {
+				return
+			}
+			want := tt.strategy.Score(tt.ties[0].up, tt.ties[0].down)
+			for _, v := range tt.ties[1:] {
+				if got := tt.strategy.Score(v.up, v.down); math.Abs(got-want) > 1e-9 {
+					t.Errorf("%v scores %f, want %f like %v", v, got, want, tt.ties[0])
+				}
+			}
+		})
+	}
+}
+
+func TestScoreRange(t *testing.T) {
+	strategies := []Strategy{Wilson{Z: 1.96}, Bayesian{PriorMean: 0.5, PriorWeight: 5}, NewThompson(1)}
+
+	for _, strategy := range strategies {
+		for _, v := range []votes{{0, 0}, {1, 0}, {0, 1}, {1000, 0}, {0, 1000}, {3, 7}} {
+			if got := strategy.Score(v.up, v.down); got < 0 || got > 1 || math.IsNaN(got) {
+				t.Errorf("%s.Score(%d, %d) = %f, want a score between 0 and 1", strategy.Name(), v.up, v.down, got)
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"wilson", "bayesian", "thompson"} {
+		strategy, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q): %v", name, err)
+		}
+		if strategy.Name() != name {
+			t.Errorf("New(%q).Name() = %q", name, strategy.Name())
+		}
+	}
+
+	if _, err := New("votes"); err == nil {
+		t.Error("New(\"votes\") succeeded, want an error")
+	}
+}
+
+func TestLegacyVotes(t *testing.T) {
+	tests := []struct {
+		rank int
+		want votes
+	}{
+		{rank: 1, want: votes{0, 0}},
+		{rank: 4, want: votes{3, 0}},
+		{rank: 0, want: votes{0, 1}},
+		{rank: -2, want: votes{0, 3}},
+	}
+
+	for _, tt := range tests {
+		if up, down := LegacyVotes(tt.rank); (votes{up, down}) != tt.want {
+			t.Errorf("LegacyVotes(%d) = %d, %d, want %d, %d", tt.rank, up, down, tt.want.up, tt.want.down)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheEvent is an outcome of a cache lookup counted in the statistics.
+type CacheEvent string
+
+const (
+	CacheHit    CacheEvent = "hits"
+	CacheMiss   CacheEvent = "misses"
+	CacheForced CacheEvent = "forced"
+)
+
+// CacheStats counts the outcomes of all cache lookups.
+type CacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Forced  int64   `json:"forced"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// ResponseCache maps cache keys to the prompt that already answered them.
+type ResponseCache struct {
+	client *Client
+}
+
+// ResponseCache returns the response cache stored in this Redis instance.
+func (r *Client) ResponseCache() *ResponseCache {
+	return &ResponseCache{client: r}
+}
+
+const cacheStatsKey = "cache:response:stats"
+
+func cacheKey(key string) string {
+	return "cache:response:" + key
+}
+
+// Lookup returns the ID of the prompt stored for key.
+func (c *ResponseCache) Lookup(ctx context.Context, key string) (promptID string, ok bool, err error) {
+	promptID, err = c.client.rdb.Get(ctx, cacheKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return promptID, true, nil
+}
+
+// Remember stores promptID as the answer for key.
+func (c *ResponseCache) Remember(ctx context.Context, key string, promptID string) error {
+	return c.client.rdb.Set(ctx, cacheKey(key), promptID, 0).Err()
+}
+
+// Forget removes key, for example because its prompt was deleted.
+func (c *ResponseCache) Forget(ctx context.Context, key string) error {
+	return c.client.rdb.Del(ctx, cacheKey(key)).Err()
+}
+
+// ForgetPrompts removes every key answered by one of promptIDs.
+func (c *ResponseCache) ForgetPrompts(ctx context.Context, promptIDs ...string) error {
+	if len(promptIDs) == 0 {
+		return nil
+	}
+
+	forget := make(map[string]bool, len(promptIDs))
+	for _, id := range promptIDs {
+		forget[id] = true
+	}
+
+	rdb := c.client.rdb
+	return c.client.scanKeys(ctx, cacheKey("*"), func(keys []string) error {
+		values, err := rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+
+		var stale []string
+		for i, value := range values {
+			// the statistics are a hash, MGET returns nil for them
+			if promptID, ok := value.(string); ok && forget[promptID] {
+				stale = append(stale, keys[i])
+			}
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+		return rdb.Del(ctx, stale...).Err()
+	})
+}
+
+// Clear removes all keys together with the statistics.
+func (c *ResponseCache) Clear(ctx context.Context) error {
+	return c.client.deleteMatching(ctx, cacheKey("*"))
+}
+
+// Record counts event in the statistics.
+func (c *ResponseCache) Record(ctx context.Context, event CacheEvent) error {
+	return c.client.rdb.HIncrBy(ctx, cacheStatsKey, string(event), 1).Err()
+}
+
+// Stats returns the counted outcomes.
+func (c *ResponseCache) Stats(ctx context.Context) (CacheStats, error) {
+	values, err := c.client.rdb.HGetAll(ctx, cacheStatsKey).Result()
+	if err != nil {
+		return CacheStats{}, err
+	}
+
+	var stats CacheStats
+	for event, target := range map[CacheEvent]*int64{CacheHit: &stats.Hits, CacheMiss: &stats.Misses, CacheForced: &stats.Forced} {
+		value, ok := values[string(event)]
+		if !ok {
+			continue
+		}
+		*target, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return CacheStats{}, err
+		}
+	}
+
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// ErrConfirmationNotFound is returned for unknown or expired confirmation
+// tokens.
+var ErrConfirmationNotFound = errkind.New(errkind.Conflict, "confirmation token not found or expired")
+
+// CreateConfirmation stores payload under a new random token for ttl. The
+// token has to be presented to ConsumeConfirmation to carry out a destructive
+// action that was previewed before.
+func (r *Client) CreateConfirmation(ctx context.Context, payload string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	err := r.rdb.Set(ctx, confirmationKey(token), payload, ttl).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConsumeConfirmation returns the payload of token and invalidates the token,
+// so every confirmation can be used only once.
+func (r *Client) ConsumeConfirmation(ctx context.Context, token string) (string, error) {
+	var get *redis.StringCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, confirmationKey(token))
+		pipe.Del(ctx, confirmationKey(token))
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return "", ErrConfirmationNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return get.Val(), nil
+}
+
+func confirmationKey(token string) string {
+	return "confirm:" + token
+}
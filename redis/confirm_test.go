@@ -0,0 +1,41 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfirmation(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestClient(t)
+
+	token, err := client.CreateConfirmation(ctx, "reset all", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.CreateConfirmation(ctx, "reset all", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Fatal("two confirmations share a token")
+	}
+
+	payload, err := client.ConsumeConfirmation(ctx, token)
+	if err != nil || payload != "reset all" {
+		t.Fatalf("ConsumeConfirmation() = %q, %v, want the payload", payload, err)
+	}
+	if _, err := client.ConsumeConfirmation(ctx, token); !errors.Is(err, ErrConfirmationNotFound) {
+		t.Errorf("second ConsumeConfirmation() = %v, want %v", err, ErrConfirmationNotFound)
+	}
+
+	server.FastForward(2 * time.Minute)
+	if _, err := client.ConsumeConfirmation(ctx, other); !errors.Is(err, ErrConfirmationNotFound) {
+		t.Errorf("ConsumeConfirmation() of an expired token = %v, want %v", err, ErrConfirmationNotFound)
+	}
+	if _, err := client.ConsumeConfirmation(ctx, "unknown"); !errors.Is(err, ErrConfirmationNotFound) {
+		t.Errorf("ConsumeConfirmation() of an unknown token = %v, want %v", err, ErrConfirmationNotFound)
+	}
+}
@@ -0,0 +1,72 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// unavailableReplies are the prefixes of error replies Redis answers with
+// while it cannot serve commands for the moment.
+var unavailableReplies = []string{"LOADING", "BUSY", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN"}
+
+// classify attaches a kind to the errors of go-redis. Connection failures are
+// unavailable, deadlines timeouts. redis.Nil and error replies to wrong
+// commands are returned as they are.
+func classify(err error) error {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var kindErr *errkind.Error
+	if errors.As(err, &kindErr) {
+		return err
+	}
+
+	var reply redis.Error
+	if errors.As(err, &reply) {
+		for _, prefix := range unavailableReplies {
+			if strings.HasPrefix(reply.Error(), prefix) {
+				return errkind.Wrap(errkind.Unavailable, err)
+			}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errkind.Wrap(errkind.Timeout, err)
+	}
+	return errkind.Wrap(errkind.Unavailable, err)
+}
+
+// errorHook classifies the errors of all commands, so callers of this
+// package can tell a broken connection apart from a failed command.
+type errorHook struct{}
+
+func (errorHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (errorHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	return classify(cmd.Err())
+}
+
+func (errorHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (errorHook) AfterProcessPipeline(_ context.Context, cmds []redis.Cmder) error {
+	var first error
+	for _, cmd := range cmds {
+		err := classify(cmd.Err())
+		cmd.SetErr(err)
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rwth-acis/modernizer/redis"
+)
+
+// Cache is an in-memory redis.Cache.
+type Cache struct {
+	mu      sync.Mutex
+	prompts map[string]string
+	stats   redis.CacheStats
+}
+
+var _ redis.Cache = (*Cache)(nil)
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{prompts: make(map[string]string)}
+}
+
+func (c *Cache) Lookup(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	promptID, ok := c.prompts[key]
+	return promptID, ok, nil
+}
+
+func (c *Cache) Remember(ctx context.Context, key string, promptID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prompts[key] = promptID
+
+	return nil
+}
+
+func (c *Cache) Forget(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.prompts, key)
+
+	return nil
+}
+
+func (c *Cache) ForgetPrompts(ctx context.Context, promptIDs ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	forget := make(map[string]bool, len(promptIDs))
+	for _, id := range promptIDs {
+		forget[id] = true
+	}
+	for key, promptID := range c.prompts {
+		if forget[promptID] {
+			delete(c.prompts, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) Clear(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prompts = make(map[string]string)
+	c.stats = redis.CacheStats{}
+
+	return nil
+}
+
+func (c *Cache) Record(ctx context.Context, event redis.CacheEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch event {
+	case redis.CacheHit:
+		c.stats.Hits++
+	case redis.CacheMiss:
+		c.stats.Misses++
+	case redis.CacheForced:
+		c.stats.Forced++
+	}
+
+	return nil
+}
+
+func (c *Cache) Stats(ctx context.Context) (redis.CacheStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,291 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/redis"
+)
+
+type confirmation struct {
+	payload string
+	// expires is zero for confirmations without a time to live
+	expires time.Time
+}
+
+// Store is an in-memory redis.Store. Votes, instruct sets and confirmations
+// behave as they do in Redis but are lost when the process exits.
+type Store struct {
+	mu sync.Mutex
+
+	// votes holds the vote of every client by prompt ID
+	votes   map[string]map[string]redis.Vote
+	tallies map[string]redis.VoteTally
+	sets    map[string]map[string]struct{}
+	// confirmations holds the pending confirmations by token
+	confirmations map[string]confirmation
+}
+
+var _ redis.Store = (*Store)(nil)
+
+// New creates an empty store.
+func New() *Store {
+	return &Store{
+		votes:         make(map[string]map[string]redis.Vote),
+		tallies:       make(map[string]redis.VoteTally),
+		sets:          make(map[string]map[string]struct{}),
+		confirmations: make(map[string]confirmation),
+	}
+}
+
+func (s *Store) Health(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) CastVote(ctx context.Context, promptID string, clientID string, vote redis.Vote, base int) (redis.VoteTally, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tally, ok := s.tallies[promptID]
+	if !ok {
+		tally.Base = base
+	}
+
+	votes := s.votes[promptID]
+	if votes == nil {
+		votes = make(map[string]redis.Vote)
+		s.votes[promptID] = votes
+	}
+
+	switch votes[clientID] {
+	case redis.Upvote:
+		tally.Up--
+	case redis.Downvote:
+		tally.Down--
+	}
+	switch vote {
+	case redis.Upvote:
+		tally.Up++
+	case redis.Downvote:
+		tally.Down++
+	}
+
+	if vote == redis.NoVote {
+		delete(votes, clientID)
+	} else {
+		votes[clientID] = vote
+	}
+	s.tallies[promptID] = tally
+
+	return tally, nil
+}
+
+func (s *Store) GetVote(ctx context.Context, promptID string, clientID string) (redis.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.votes[promptID][clientID], nil
+}
+
+func (s *Store) GetVoteTally(ctx context.Context, promptID string) (redis.VoteTally, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tally, ok := s.tallies[promptID]
+	return tally, ok, nil
+}
+
+func (s *Store) GetVotes(ctx context.Context, promptID string) (map[string]redis.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	votes := make(map[string]redis.Vote, len(s.votes[promptID]))
+	for clientID, vote := range s.votes[promptID] {
+		votes[clientID] = vote
+	}
+	return votes, nil
+}
+
+func (s *Store) RestoreVotes(ctx context.Context, promptID string, votes map[string]redis.Vote, tally redis.VoteTally) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored := make(map[string]redis.Vote, len(votes))
+	for clientID, vote := range votes {
+		if vote != redis.NoVote {
+			restored[clientID] = vote
+		}
+	}
+	s.votes[promptID] = restored
+	s.tallies[promptID] = tally
+
+	return nil
+}
+
+func (s *Store) DeleteVotes(ctx context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.votes, id)
+		delete(s.tallies, id)
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteAllVotes(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.votes = make(map[string]map[string]redis.Vote)
+	s.tallies = make(map[string]redis.VoteTally)
+
+	return nil
+}
+
+// InitRedis adds the instructs of redis.DefaultSets to their sets.
+func (s *Store) InitRedis(ctx context.Context) {
+	for set, instructs := range redis.DefaultSets {
+		for _, instruct := range instructs {
+			_ = s.AddSetMember(ctx, set, instruct)
+		}
+	}
+}
+
+func (s *Store) AddSetMember(ctx context.Context, set string, instruct string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := s.sets[set]
+	if members == nil {
+		members = make(map[string]struct{})
+		s.sets[set] = members
+	}
+	members[instruct] = struct{}{}
+
+	return nil
+}
+
+func (s *Store) RemoveSetMember(ctx context.Context, set string, instruct string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sets[set], instruct)
+	// like Redis, a set without members does not exist
+	if len(s.sets[set]) == 0 {
+		delete(s.sets, set)
+	}
+
+	return nil
+}
+
+func (s *Store) GetSet(ctx context.Context, setName string) ([]string, error) {
+	if setName == "" {
+		setName = "default"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.members(setName), nil
+}
+
+func (s *Store) GetSetMember(ctx context.Context, setName string) (string, error) {
+	if setName == "" {
+		setName = "default"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := s.members(setName)
+	if len(members) == 0 {
+		return "", errkind.New(errkind.NotFound, "instruct set %s is empty or does not exist", setName)
+	}
+
+	return members[mathrand.Intn(len(members))], nil
+}
+
+// members returns the sorted members of set, the caller must hold the lock.
+func (s *Store) members(set string) []string {
+	members := make([]string, 0, len(s.sets[set]))
+	for member := range s.sets[set] {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+
+	return members
+}
+
+func (s *Store) SetNames(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.sets))
+	for name := range s.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *Store) Sets(ctx context.Context) (map[string][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sets := make(map[string][]string, len(s.sets))
+	for name := range s.sets {
+		sets[name] = s.members(name)
+	}
+
+	return sets, nil
+}
+
+func (s *Store) DeleteAllSets(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sets = make(map[string]map[string]struct{})
+
+	return nil
+}
+
+func (s *Store) CreateConfirmation(ctx context.Context, payload string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := confirmation{payload: payload}
+	if ttl > 0 {
+		pending.expires = time.Now().Add(ttl)
+	}
+	s.confirmations[token] = pending
+
+	return token, nil
+}
+
+func (s *Store) ConsumeConfirmation(ctx context.Context, token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	confirmation, ok := s.confirmations[token]
+	delete(s.confirmations, token)
+	if !ok || (!confirmation.expires.IsZero() && time.Now().After(confirmation.expires)) {
+		return "", redis.ErrConfirmationNotFound
+	}
+
+	return confirmation.payload, nil
+}
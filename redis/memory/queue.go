@@ -0,0 +1,231 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/redis"
+)
+
+// Queue is an in-memory redis.JobQueue. Failed jobs are retried right away
+// until they run out of attempts, there is no backoff.
+type Queue struct {
+	// MaxAttempts is how often a job is tried before it is moved to the dead
+	// jobs.
+	MaxAttempts int
+
+	mu         sync.Mutex
+	ready      []redis.Job
+	dead       []redis.Job
+	processing int64
+	// wake is signalled whenever a job becomes ready
+	wake chan struct{}
+}
+
+var _ redis.JobQueue = (*Queue)(nil)
+
+// NewQueue creates an empty queue trying every job once.
+func NewQueue() *Queue {
+	return &Queue{MaxAttempts: 1, wake: make(chan struct{}, 1)}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, payload interface{}) (redis.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return redis.Job{}, err
+	}
+
+	job := redis.Job{
+		ID:        uuid.NewString(),
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}
+	q.push(job)
+
+	return job, nil
+}
+
+func (q *Queue) push(jobs ...redis.Job) {
+	q.mu.Lock()
+	q.ready = append(q.ready, jobs...)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take removes the oldest ready job.
+func (q *Queue) take() (redis.Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.ready) == 0 {
+		return redis.Job{}, false
+	}
+	job := q.ready[0]
+	q.ready = q.ready[1:]
+	q.processing++
+
+	return job, true
+}
+
+// done records the outcome of a job returned by take.
+func (q *Queue) done(job redis.Job, err error) {
+	q.mu.Lock()
+	q.processing--
+	if err == nil {
+		q.mu.Unlock()
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+	if job.Attempts < q.MaxAttempts {
+		q.mu.Unlock()
+		log.Printf("job %s failed (attempt %d of %d), retrying: %v", job.ID, job.Attempts, q.MaxAttempts, err)
+		q.push(job)
+		return
+	}
+
+	now := time.Now().UTC()
+	job.FailedAt = &now
+	q.dead = append([]redis.Job{job}, q.dead...)
+	q.mu.Unlock()
+	log.Printf("job %s failed %d times, moved to the dead jobs: %v", job.ID, job.Attempts, err)
+}
+
+// release hands a job returned by take back without counting an attempt.
+func (q *Queue) release(job redis.Job) {
+	q.mu.Lock()
+	q.processing--
+	q.mu.Unlock()
+
+	q.push(job)
+}
+
+func (q *Queue) DeadJobs(ctx context.Context) ([]redis.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]redis.Job(nil), q.dead...), nil
+}
+
+func (q *Queue) Requeue(ctx context.Context, ids ...string) (int, error) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	q.mu.Lock()
+	var requeued, kept []redis.Job
+	for _, job := range q.dead {
+		if len(ids) > 0 && !wanted[job.ID] {
+			kept = append(kept, job)
+			continue
+		}
+		job.Attempts = 0
+		job.LastError = ""
+		job.FailedAt = nil
+		requeued = append(requeued, job)
+	}
+	q.dead = kept
+	q.mu.Unlock()
+
+	if len(ids) > 0 && len(requeued) == 0 {
+		return 0, redis.ErrJobNotFound
+	}
+	q.push(requeued...)
+
+	return len(requeued), nil
+}
+
+func (q *Queue) Stats(ctx context.Context) (redis.QueueStats, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return redis.QueueStats{
+		Ready:      int64(len(q.ready)),
+		Processing: q.processing,
+		Dead:       int64(len(q.dead)),
+	}, nil
+}
+
+// workers are the running workers of a Queue.
+type workers struct {
+	// ctx is handed to the handlers and only cancelled if Stop times out
+	ctx    context.Context
+	cancel context.CancelFunc
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Work starts size workers handling jobs of q until Stop is called.
+func (q *Queue) Work(size int, handler redis.Handler) redis.Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &workers{ctx: ctx, cancel: cancel, quit: make(chan struct{})}
+
+	for i := 0; i < size; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			q.work(w, handler)
+		}()
+	}
+
+	return w
+}
+
+func (q *Queue) work(w *workers, handler redis.Handler) {
+	for {
+		select {
+		case <-w.quit:
+			return
+		default:
+		}
+
+		job, ok := q.take()
+		if !ok {
+			select {
+			case <-w.quit:
+				return
+			case <-q.wake:
+			}
+			continue
+		}
+
+		err := handler(w.ctx, job)
+		if w.ctx.Err() != nil {
+			q.release(job)
+			continue
+		}
+		q.done(job, err)
+	}
+}
+
+// Stop lets the workers finish their current job until ctx is done. Jobs
+// which are still running then are cancelled and handed back to the queue.
+func (w *workers) Stop(ctx context.Context) error {
+	close(w.quit)
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.cancel()
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
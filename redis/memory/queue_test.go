@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rwth-acis/modernizer/redis"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue()
+	q.MaxAttempts = 2
+
+	handled := make(chan string, 10)
+	workers := q.Work(2, func(ctx context.Context, job redis.Job) error {
+		handled <- string(job.Payload)
+		if string(job.Payload) == `"fail"` {
+			return errors.New("boom")
+		}
+		return nil
+	})
+
+	for _, payload := range []string{"ok", "fail"} {
+		if _, err := q.Enqueue(ctx, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ok once, fail until it runs out of attempts
+	got := map[string]int{}
+	for i := 0; i < 3; i++ {
+		select {
+		case payload := <-handled:
+			got[payload]++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handled %v, want three attempts", got)
+		}
+	}
+	if got[`"ok"`] != 1 || got[`"fail"`] != 2 {
+		t.Errorf("handled %v, want ok once and fail twice", got)
+	}
+
+	if err := workers.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	dead, err := q.DeadJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].LastError != "boom" || dead[0].FailedAt == nil {
+		t.Fatalf("dead jobs = %+v, want the failed job", dead)
+	}
+
+	if _, err := q.Requeue(ctx, "unknown"); !errors.Is(err, redis.ErrJobNotFound) {
+		t.Errorf("requeueing an unknown job returned %v, want %v", err, redis.ErrJobNotFound)
+	}
+	requeued, err := q.Requeue(ctx, dead[0].ID)
+	if err != nil || requeued != 1 {
+		t.Fatalf("Requeue() = %d, %v, want 1", requeued, err)
+	}
+
+	stats, err := q.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (redis.QueueStats{Ready: 1}) {
+		t.Errorf("stats = %+v, want the requeued job ready", stats)
+	}
+}
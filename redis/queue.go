@@ -0,0 +1,513 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// Job is a unit of work stored in a Queue.
+type Job struct {
+	ID        string          `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	FailedAt  *time.Time      `json:"failedAt,omitempty"`
+}
+
+// RetryPolicy controls how often and how late failed jobs are retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it doubles with every
+	// further attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	return delay
+}
+
+// QueueStats reports the number of jobs in every state.
+type QueueStats struct {
+	Ready      int64 `json:"ready"`
+	Delayed    int64 `json:"delayed"`
+	Processing int64 `json:"processing"`
+	Dead       int64 `json:"dead"`
+}
+
+// Queue is a reliable job queue. Jobs wait in a ready list, are moved to a
+// processing set while a worker handles them and are put back if the worker
+// does not finish within the visibility timeout. Failed jobs are retried with
+// exponential backoff and end up in a dead-letter list once they run out of
+// attempts.
+type Queue struct {
+	client *Client
+	name   string
+
+	Policy RetryPolicy
+	// Visibility is how long a job may be processed before it is handed to
+	// another worker.
+	Visibility time.Duration
+	// PollInterval is how long idle workers wait before looking for jobs again.
+	PollInterval time.Duration
+}
+
+// Queue returns the queue with the given name, using default settings.
+func (r *Client) Queue(name string) *Queue {
+	return &Queue{
+		client: r,
+		name:   name,
+		Policy: RetryPolicy{
+			MaxAttempts: 5,
+			Backoff:     10 * time.Second,
+			MaxBackoff:  10 * time.Minute,
+		},
+		Visibility:   5 * time.Minute,
+		PollInterval: time.Second,
+	}
+}
+
+func (q *Queue) key(state string) string {
+	return "queue:" + q.name + ":" + state
+}
+
+// Every delivery of a job to a worker gets its own token. The processing set
+// holds the tokens, the deliveries hash maps them to the job. A job whose
+// visibility timeout expired is delivered again under a new token, so the
+// worker that was too slow cannot acknowledge or fail the new delivery.
+
+// dequeueScript promotes due delayed jobs and timed out deliveries to the
+// ready list and then delivers the oldest ready job under the token ARGV[3].
+// A timed out delivery counts as a failed attempt with the error ARGV[5]; once
+// the job has ARGV[4] attempts, it is moved to the dead-letter list KEYS[5]
+// with the failure time ARGV[6]. The updated job is encoded by cjson, which
+// turns empty arrays in the payload into empty objects. Processing entries
+// without a delivery are jobs taken before deliveries had tokens.
+var dequeueScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, job in ipairs(due) do
+	redis.call('ZREM', KEYS[2], job)
+	redis.call('LPUSH', KEYS[1], job)
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, token in ipairs(expired) do
+	local raw = redis.call('HGET', KEYS[4], token) or token
+	redis.call('ZREM', KEYS[3], token)
+	redis.call('HDEL', KEYS[4], token)
+	local decoded, job = pcall(cjson.decode, raw)
+	if not decoded then
+		-- the delivery drops the malformed job
+		redis.call('LPUSH', KEYS[1], raw)
+	else
+		job.attempts = (job.attempts or 0) + 1
+		job.lastError = ARGV[5]
+		if job.attempts >= tonumber(ARGV[4]) then
+			job.failedAt = ARGV[6]
+			redis.call('LPUSH', KEYS[5], cjson.encode(job))
+		else
+			redis.call('LPUSH', KEYS[1], cjson.encode(job))
+		end
+	end
+end
+local job = redis.call('RPOP', KEYS[1])
+if job then
+	redis.call('ZADD', KEYS[3], ARGV[2], ARGV[3])
+	redis.call('HSET', KEYS[4], ARGV[3], job)
+end
+return job
+`)
+
+// settleScript ends the delivery ARGV[1] if it is still current. The job
+// ARGV[3] is then pushed to the list KEYS[3] with ARGV[2] LPUSH or RPUSH, or
+// added to the sorted set KEYS[3] with the score ARGV[2]. Without ARGV[2] the
+// job is dropped. It returns 0 if the delivery had timed out.
+var settleScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+if ARGV[2] == 'LPUSH' or ARGV[2] == 'RPUSH' then
+	redis.call(ARGV[2], KEYS[3], ARGV[3])
+elseif ARGV[2] then
+	redis.call('ZADD', KEYS[3], ARGV[2], ARGV[3])
+end
+return 1
+`)
+
+// errDeliveryExpired is returned for a delivery that timed out before it was
+// settled. The job was or will be delivered again.
+var errDeliveryExpired = errors.New("visibility timeout expired, the job is delivered again")
+
+// Enqueue marshals payload into a new job and appends it to the queue.
+func (q *Queue) Enqueue(ctx context.Context, payload interface{}) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := Job{
+		ID:        uuid.NewString(),
+		Payload:   data,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return Job{}, err
+	}
+
+	err = q.client.rdb.LPush(ctx, q.key("ready"), raw).Err()
+	if err != nil {
+		return Job{}, err
+	}
+
+	return job, nil
+}
+
+// delivery is a job handed to a worker.
+type delivery struct {
+	job   Job
+	token string
+}
+
+// dequeue returns the next job, or redis.Nil if the queue is empty.
+func (q *Queue) dequeue(ctx context.Context) (delivery, error) {
+	now := time.Now()
+	token := uuid.NewString()
+	result, err := dequeueScript.Run(ctx, q.client.rdb,
+		[]string{q.key("ready"), q.key("delayed"), q.key("processing"), q.key("deliveries"), q.key("dead")},
+		now.UnixMilli(), now.Add(q.Visibility).UnixMilli(), token,
+		q.Policy.MaxAttempts, "visibility timeout expired", now.UTC().Format(time.RFC3339Nano),
+	).Result()
+	if err != nil {
+		return delivery{}, err
+	}
+
+	raw, ok := result.(string)
+	if !ok {
+		return delivery{}, fmt.Errorf("unexpected dequeue result %T", result)
+	}
+
+	d := delivery{token: token}
+	if err := json.Unmarshal([]byte(raw), &d.job); err != nil {
+		// a job which cannot be decoded can never succeed
+		_ = q.settle(ctx, token, "", "", "")
+		return delivery{}, fmt.Errorf("dropping malformed job: %w", err)
+	}
+
+	return d, nil
+}
+
+// settle ends the delivery with the given token and hands job to target, see
+// settleScript.
+func (q *Queue) settle(ctx context.Context, token string, target string, how string, job string) error {
+	keys := []string{q.key("processing"), q.key("deliveries")}
+	args := []interface{}{token}
+	if target != "" {
+		keys = append(keys, target)
+		args = append(args, how, job)
+	}
+
+	settled, err := settleScript.Run(ctx, q.client.rdb, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if settled == 0 {
+		return errDeliveryExpired
+	}
+
+	return nil
+}
+
+func (q *Queue) ack(ctx context.Context, d delivery) error {
+	return q.settle(ctx, d.token, "", "", "")
+}
+
+// release hands a job that was interrupted by shutdown back to the queue
+// without counting it as an attempt.
+func (q *Queue) release(ctx context.Context, d delivery) error {
+	raw, err := json.Marshal(d.job)
+	if err != nil {
+		return err
+	}
+
+	return q.settle(ctx, d.token, q.key("ready"), "RPUSH", string(raw))
+}
+
+// fail records a failed attempt and either schedules a retry or moves the job
+// to the dead-letter list.
+func (q *Queue) fail(ctx context.Context, d delivery, cause error) error {
+	job := d.job
+	job.Attempts++
+	job.LastError = cause.Error()
+
+	dead := job.Attempts >= q.Policy.MaxAttempts
+	if dead {
+		now := time.Now().UTC()
+		job.FailedAt = &now
+	}
+
+	updated, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	if dead {
+		err = q.settle(ctx, d.token, q.key("dead"), "LPUSH", string(updated))
+	} else {
+		runAt := time.Now().Add(q.Policy.delay(job.Attempts))
+		err = q.settle(ctx, d.token, q.key("delayed"), strconv.FormatInt(runAt.UnixMilli(), 10), string(updated))
+	}
+	if err != nil {
+		return err
+	}
+
+	if dead {
+		log.Printf("job %s on queue %s failed %d times, moved to dead-letter list: %v", job.ID, q.name, job.Attempts, cause)
+	} else {
+		log.Printf("job %s on queue %s failed (attempt %d of %d), retrying: %v", job.ID, q.name, job.Attempts, q.Policy.MaxAttempts, cause)
+	}
+
+	return nil
+}
+
+// DeadJobs lists the jobs which ran out of attempts, most recent first.
+func (q *Queue) DeadJobs(ctx context.Context) ([]Job, error) {
+	raws, err := q.client.rdb.LRange(ctx, q.key("dead"), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(raws))
+	for _, raw := range raws {
+		var job Job
+		if err := json.Unmarshal([]byte(raw), &job); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// ErrJobNotFound is returned when a dead job to requeue does not exist.
+var ErrJobNotFound = errkind.New(errkind.NotFound, "job not found")
+
+// maxRequeueAttempts bounds how often Requeue starts over because the
+// dead-letter list changed while it was read.
+const maxRequeueAttempts = 5
+
+// Requeue moves the dead jobs with the given IDs back to the ready list with
+// a fresh attempt budget. Without IDs all dead jobs are requeued. It returns
+// the number of requeued jobs. The jobs are moved in one transaction, which
+// is retried if the dead-letter list changes in between.
+func (q *Queue) Requeue(ctx context.Context, ids ...string) (int, error) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	requeued := 0
+	requeue := func(tx *redis.Tx) error {
+		raws, err := tx.LRange(ctx, q.key("dead"), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+
+		var moved, updated []string
+		for _, raw := range raws {
+			var job Job
+			if err := json.Unmarshal([]byte(raw), &job); err != nil {
+				continue
+			}
+			if len(ids) > 0 && !wanted[job.ID] {
+				continue
+			}
+
+			job.Attempts = 0
+			job.LastError = ""
+			job.FailedAt = nil
+
+			data, err := json.Marshal(job)
+			if err != nil {
+				return err
+			}
+			moved = append(moved, raw)
+			updated = append(updated, string(data))
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i := range moved {
+				pipe.LRem(ctx, q.key("dead"), 1, moved[i])
+				pipe.LPush(ctx, q.key("ready"), updated[i])
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		requeued = len(moved)
+		return nil
+	}
+
+	for attempt := 0; attempt < maxRequeueAttempts; attempt++ {
+		err := q.client.rdb.Watch(ctx, requeue, q.key("dead"))
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if len(ids) > 0 && requeued == 0 {
+			return 0, ErrJobNotFound
+		}
+		return requeued, nil
+	}
+
+	return 0, errkind.New(errkind.Conflict, "dead-letter list of queue %s keeps changing, requeue again", q.name)
+}
+
+// Stats returns the number of jobs in every state.
+func (q *Queue) Stats(ctx context.Context) (QueueStats, error) {
+	pipe := q.client.rdb.Pipeline()
+	ready := pipe.LLen(ctx, q.key("ready"))
+	delayed := pipe.ZCard(ctx, q.key("delayed"))
+	processing := pipe.ZCard(ctx, q.key("processing"))
+	dead := pipe.LLen(ctx, q.key("dead"))
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return QueueStats{}, err
+	}
+
+	return QueueStats{
+		Ready:      ready.Val(),
+		Delayed:    delayed.Val(),
+		Processing: processing.Val(),
+		Dead:       dead.Val(),
+	}, nil
+}
+
+// Handler processes a single job. Returning an error counts as a failed
+// attempt.
+type Handler func(ctx context.Context, job Job) error
+
+// Pool is a set of workers consuming a Queue.
+type Pool struct {
+	// ctx is handed to the handlers and only cancelled if Stop times out
+	ctx    context.Context
+	cancel context.CancelFunc
+	// quit is closed by Stop, workers exit once their current job is done
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Work starts size workers handling jobs of q until Stop is called on the
+// returned Pool.
+func (q *Queue) Work(size int, handler Handler) Workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &Pool{ctx: ctx, cancel: cancel, quit: make(chan struct{})}
+
+	for i := 0; i < size; i++ {
+		pool.wg.Add(1)
+		go func(worker string) {
+			defer pool.wg.Done()
+			q.work(pool, worker, handler)
+		}(strconv.Itoa(i))
+	}
+
+	return pool
+}
+
+func (q *Queue) work(pool *Pool, worker string, handler Handler) {
+	for {
+		select {
+		case <-pool.quit:
+			return
+		default:
+		}
+
+		d, err := q.dequeue(pool.ctx)
+		if errors.Is(err, redis.Nil) {
+			pool.sleep(q.PollInterval)
+			continue
+		}
+		if err != nil {
+			log.Printf("worker %s on queue %s could not dequeue: %v", worker, q.name, err)
+			pool.sleep(q.PollInterval)
+			continue
+		}
+
+		err = handler(pool.ctx, d.job)
+
+		// the pool context may already be cancelled, bookkeeping must still
+		// reach Redis
+		bookkeeping, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		switch {
+		case err == nil:
+			err = q.ack(bookkeeping, d)
+		case pool.ctx.Err() != nil:
+			err = q.release(bookkeeping, d)
+		default:
+			err = q.fail(bookkeeping, d, err)
+		}
+		cancel()
+
+		if err != nil {
+			log.Printf("worker %s on queue %s could not update job %s: %v", worker, q.name, d.job.ID, err)
+		}
+	}
+}
+
+func (p *Pool) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-p.quit:
+	case <-timer.C:
+	}
+}
+
+// Stop lets the workers finish their current job until ctx is done. Jobs
+// which are still running then are cancelled and handed back to the queue.
+func (p *Pool) Stop(ctx context.Context) error {
+	close(p.quit)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
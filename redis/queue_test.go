@@ -0,0 +1,297 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func stats(t *testing.T, q *Queue) QueueStats {
+	t.Helper()
+	s, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestQueueRedelivery(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+	q.Visibility = time.Millisecond
+
+	job, err := q.Enqueue(ctx, "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.job.ID != job.ID {
+		t.Fatalf("dequeue() = job %s, want %s", first.job.ID, job.ID)
+	}
+
+	// the first worker is too slow, the job is delivered again
+	time.Sleep(5 * time.Millisecond)
+	q.Visibility = time.Minute
+	second, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.job.ID != job.ID || second.token == first.token {
+		t.Fatalf("redelivery = job %s with token %s, want job %s with a new token", second.job.ID, second.token, job.ID)
+	}
+
+	if err := q.ack(ctx, first); !errors.Is(err, errDeliveryExpired) {
+		t.Errorf("ack() of the expired delivery = %v, want errDeliveryExpired", err)
+	}
+	if err := q.fail(ctx, first, errors.New("slow")); !errors.Is(err, errDeliveryExpired) {
+		t.Errorf("fail() of the expired delivery = %v, want errDeliveryExpired", err)
+	}
+	if got := stats(t, q); got != (QueueStats{Processing: 1}) {
+		t.Errorf("Stats() after settling the expired delivery = %+v, want the redelivery processing", got)
+	}
+
+	if err := q.ack(ctx, second); err != nil {
+		t.Fatalf("ack() of the redelivery = %v", err)
+	}
+	if got := stats(t, q); got != (QueueStats{}) {
+		t.Errorf("Stats() after ack = %+v, want an empty queue", got)
+	}
+}
+
+func TestQueueTimeouts(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+	q.Policy.MaxAttempts = 2
+	q.Visibility = time.Millisecond
+
+	job, err := q.Enqueue(ctx, map[string]string{"code": "func f() {}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.dequeue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a timed out delivery counts as an attempt
+	time.Sleep(5 * time.Millisecond)
+	d, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.job.ID != job.ID || d.job.Attempts != 1 || d.job.LastError == "" {
+		t.Fatalf("redelivery = %+v, want job %s after 1 attempt", d.job, job.ID)
+	}
+
+	// the last attempt moves the job to the dead-letter list
+	time.Sleep(5 * time.Millisecond)
+	if _, err := q.dequeue(ctx); !errors.Is(err, redis.Nil) {
+		t.Fatalf("dequeue() after the last attempt = %v, want redis.Nil", err)
+	}
+	if got := stats(t, q); got != (QueueStats{Dead: 1}) {
+		t.Errorf("Stats() after the last attempt = %+v, want one dead job", got)
+	}
+	dead, err := q.DeadJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 || dead[0].FailedAt == nil || string(dead[0].Payload) != string(job.Payload) {
+		t.Fatalf("DeadJobs() = %+v, want job %s with its payload after 2 attempts", dead, job.ID)
+	}
+}
+
+func TestQueueLegacyProcessing(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+
+	// a job taken before deliveries had tokens and never settled
+	raw := `{"id":"legacy","payload":"1","attempts":0,"createdAt":"2024-01-01T00:00:00Z"}`
+	if err := client.rdb.ZAdd(ctx, q.key("processing"), &redis.Z{Score: 1, Member: raw}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.job.ID != "legacy" {
+		t.Fatalf("dequeue() = job %s, want the legacy job", d.job.ID)
+	}
+	if err := q.ack(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (QueueStats{}) {
+		t.Errorf("Stats() = %+v, want an empty queue", got)
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+	q.Policy = RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	job, err := q.Enqueue(ctx, "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.fail(ctx, d, errors.New("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (QueueStats{Delayed: 1}) {
+		t.Errorf("Stats() after the first failure = %+v, want one delayed job", got)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	d, err = q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.job.ID != job.ID || d.job.Attempts != 1 {
+		t.Fatalf("retry = job %s after %d attempts, want job %s after 1", d.job.ID, d.job.Attempts, job.ID)
+	}
+	if err := q.fail(ctx, d, errors.New("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (QueueStats{Dead: 1}) {
+		t.Errorf("Stats() after the last attempt = %+v, want one dead job", got)
+	}
+
+	dead, err := q.DeadJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].LastError != "second" || dead[0].FailedAt == nil {
+		t.Fatalf("DeadJobs() = %+v, want the job with its last error", dead)
+	}
+
+	if _, err := q.Requeue(ctx, "unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Requeue(unknown) = %v, want ErrJobNotFound", err)
+	}
+	if n, err := q.Requeue(ctx, job.ID); err != nil || n != 1 {
+		t.Fatalf("Requeue() = %d, %v, want 1", n, err)
+	}
+	d, err = q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.job.Attempts != 0 || d.job.LastError != "" {
+		t.Errorf("requeued job = %+v, want a fresh attempt budget", d.job)
+	}
+}
+
+func TestQueueRequeueAll(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+
+	dead := []string{
+		`{"id":"a","payload":"1","attempts":5,"lastError":"boom","createdAt":"2024-01-01T00:00:00Z","failedAt":"2024-01-01T00:01:00Z"}`,
+		`{"id":"b","payload":"2","attempts":5,"lastError":"boom","createdAt":"2024-01-01T00:00:00Z","failedAt":"2024-01-01T00:01:00Z"}`,
+		`malformed`,
+	}
+	for _, raw := range dead {
+		if err := client.rdb.LPush(ctx, q.key("dead"), raw).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := q.Requeue(ctx); err != nil || n != 2 {
+		t.Fatalf("Requeue() = %d, %v, want 2", n, err)
+	}
+	// a malformed job stays for inspection
+	if got := stats(t, q); got != (QueueStats{Ready: 2, Dead: 1}) {
+		t.Errorf("Stats() after Requeue() = %+v, want two ready jobs and the malformed one dead", got)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		d, err := q.dequeue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.job.Attempts != 0 || d.job.LastError != "" || d.job.FailedAt != nil {
+			t.Errorf("dequeue() = %+v, want a fresh attempt budget", d.job)
+		}
+		seen[d.job.ID] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Errorf("dequeued jobs %v, want a and b", seen)
+	}
+}
+
+func TestQueueRelease(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+
+	if _, err := q.Enqueue(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ctx, "second"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.release(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (QueueStats{Ready: 2}) {
+		t.Errorf("Stats() after release = %+v, want both jobs ready", got)
+	}
+
+	// a released job is delivered next without counting as an attempt
+	again, err := q.dequeue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.job.ID != d.job.ID || again.job.Attempts != 0 {
+		t.Errorf("dequeue() after release = %+v, want the released job %s", again.job, d.job.ID)
+	}
+}
+
+func TestQueueWork(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestClient(t)
+	q := client.Queue("test")
+	q.PollInterval = time.Millisecond
+
+	done := make(chan Job, 1)
+	workers := q.Work(2, func(ctx context.Context, job Job) error {
+		done <- job
+		return nil
+	})
+
+	job, err := q.Enqueue(ctx, "payload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-done:
+		if got.ID != job.ID || string(got.Payload) != `"payload"` {
+			t.Errorf("handled job = %+v, want %+v", got, job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job was not handled")
+	}
+
+	if err := workers.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (QueueStats{}) {
+		t.Errorf("Stats() after the job was handled = %+v, want an empty queue", got)
+	}
+}
@@ -2,28 +2,90 @@ package redis
 
 import (
 	"context"
-	"net/http"
-	"os"
+	"errors"
+	"fmt"
+	"net"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"github.com/rwth-acis/modernizer/errkind"
 )
 
-func loadClient() (rdb *redis.Client) {
-	rdb = redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_ADDR"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       0, // Use default DB.
+// Config holds the connection and pool settings of the Redis client.
+type Config struct {
+	Addr     string
+	Password string
+	DB       int
+
+	// PoolSize is the maximum number of socket connections, zero selects the
+	// go-redis default of ten connections per CPU.
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+func (cfg Config) Validate() error {
+	if cfg.Addr == "" {
+		return errors.New("redis address must not be empty")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return fmt.Errorf("invalid redis address %q: %w", cfg.Addr, err)
+	}
+	if cfg.DB < 0 {
+		return errors.New("redis db must not be negative")
+	}
+	if cfg.PoolSize < 0 || cfg.MinIdleConns < 0 {
+		return errors.New("redis pool sizes must not be negative")
+	}
+	if cfg.DialTimeout < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 {
+		return errors.New("redis timeouts must not be negative")
+	}
+
+	return nil
+}
+
+// Client wraps a long-lived Redis connection pool. It is safe for concurrent
+// use and should be created once and shared.
+type Client struct {
+	rdb *redis.Client
+}
+
+// NewClient validates cfg and creates the connection pool.
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	})
+	rdb.AddHook(errorHook{})
+
+	return &Client{rdb: rdb}, nil
+}
 
-	return rdb
+// Health reports an error if Redis does not answer a PING.
+func (r *Client) Health(ctx context.Context) error {
+	return r.rdb.Ping(ctx).Err()
 }
 
-func InitRedis() {
-	ctx := context.Background()
-	rdb := loadClient()
+// Close closes the connection pool.
+func (r *Client) Close() error {
+	return r.rdb.Close()
+}
 
-	members := []interface{}{
+// DefaultSets are the instruct sets InitRedis creates.
+var DefaultSets = map[string][]string{
+	"developer": {
 		"Explain me this:",
 		"How does the following code work?",
 		"Explain me step by step how this code works:",
@@ -35,11 +97,8 @@ func InitRedis() {
 		"Explain me how you would refactor this code to make it more maintainable:",
 		"Explain me how you would refactor this code to make it more testable:",
 		"Can you explain the design decisions behind this code?",
-	}
-
-	rdb.SAdd(ctx, "developer", members...)
-
-	members = []interface{}{
+	},
+	"security": {
 		"What security considerations should be taken into account when using this code?",
 		"Are there any security problems in this code:",
 		"What encryption algorithms are used to secure sensitive data?",
@@ -48,40 +107,28 @@ func InitRedis() {
 		"How would you ensure compliance with security standards in this code?",
 		"Does this code comply with GDPR?",
 		"What authentication and authorization mechanisms are used in this code?",
-	}
-
-	rdb.SAdd(ctx, "security", members...)
-
-	members = []interface{}{
+	},
+	"funny": {
 		"Explain me what this piece of code does like angry Linux Torvalds on Linux kernel code reviews:",
 		"Explain this code as if you were a wizard casting a spell.",
 		"Pretend you're a detective solving a mystery related to this code.",
 		"Explain this code as if you were a teacher explaining a concept to a student.",
 		"Describe this code using only emojis and internet slang.",
-	}
-
-	rdb.SAdd(ctx, "funny", members...)
-
-	members = []interface{}{
+	},
+	"architecture": {
 		"What is the overall architecture of this code?",
 		"What technologies and frameworks are used in this code?",
 		"How will this code handle scalability?",
 		"What design patterns or architectural patterns are used in this code?",
 		"What are the trade-offs of using this code?",
 		"What considerations have been made for future maintenance and updates?",
-	}
-
-	rdb.SAdd(ctx, "architecture", members...)
-
-	members = []interface{}{
+	},
+	"project-management": {
 		"What business impact does this code have?",
 		"What are the business requirements for this code?",
 		"How would you prioritize tasks and allocate workload among team members?",
-	}
-
-	rdb.SAdd(ctx, "project-management", members...)
-
-	members = []interface{}{
+	},
+	"modernisation": {
 		"What is the purpose of this function, and does it adhere to the Single Responsibility Principle (SRP)?",
 		"What dependencies does this function have, and can they be minimized or eliminated?",
 		"Does this function exhibit any code smells, such as long parameter lists or excessive branching?",
@@ -92,33 +139,24 @@ func InitRedis() {
 		"What design patterns or architectural principles can be applied to improve this function?",
 		"Can this function be optimized for concurrency or parallelism?",
 		"How can this function be modularized or decoupled to promote reusability and maintainability?",
-	}
-
-	rdb.SAdd(ctx, "modernisation", members...)
-
-	members = []interface{}{
+	},
+	"explanation": {
 		"Explain me this:",
 		"How does the following code work?",
 		"Explain me step by step how this code works:",
 		"Be concise and explain this code:",
 		"What does this code do?",
 		"What is the semantic meaning of this code?",
-	}
-
-	rdb.SAdd(ctx, "explanation", members...)
-
-	members = []interface{}{
+	},
+	"test-engineering": {
 		"What are the inputs required for this function, and what are their expected formats and constraints?",
 		"Are there any boundary conditions or edge cases that need to be tested for this function?",
 		"Are there any dependencies or external factors that may impact the behavior of this function during testing?",
 		"Can you describe any assumptions or preconditions that must be met for this function to behave as expected?",
 		"Are there any error handling mechanisms implemented within this function, and how do they handle unexpected inputs or exceptions?",
 		"Are there any side effects or unintended consequences of calling this function that need to be tested?",
-	}
-
-	rdb.SAdd(ctx, "test-engineering", members...)
-
-	members = []interface{}{
+	},
+	"file-based": {
 		"What part of this file needs to be modernized first?",
 		"What part of this file contains the most complexity and needs to dealt with?",
 		"How are dependencies managed within this file or module?",
@@ -129,11 +167,8 @@ func InitRedis() {
 		"Are there any specific coding standards or guidelines followed within this file to improve maintainability?",
 		"Are the inline comments enough to understand the complex parts of this file?",
 		"Can you provide insights into any technical debt backlog items related to this file and their prioritization?",
-	}
-
-	rdb.SAdd(ctx, "file-based", members...)
-
-	members = []interface{}{
+	},
+	"miscellaneous": {
 		"Can you identify redundant or duplicate code blocks within the codebase?",
 		"Can you analyze the codebase to determine the developer's preferred coding style or patterns?",
 		"Can you detect any intentional obfuscation or encryption techniques used within the codebase for security purposes?",
@@ -143,73 +178,37 @@ func InitRedis() {
 		"When taking Conway's law into account, what team structure can you derive from the codebase and which of those are anti-patterns or worsen the architecture of the software?",
 		"Can you identify any recurring anti-patterns or code smells within the codebase?",
 		"Give me the code performance of each function or class in the O-Notation.",
-	}
-
-	rdb.SAdd(ctx, "miscellaneous", members...)
+	},
 }
 
-func AddInstruct(c *gin.Context) {
-
-	rdb := loadClient()
-
-	var requestData struct {
-		Item string `json:"item"`
-		List string `json:"list,omitempty"`
-	}
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	listName := requestData.List
-	if listName == "" {
-		listName = "default"
-	}
-
-	ctx := context.Background()
-	if err := rdb.SAdd(ctx, listName, requestData.Item).Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// InitRedis adds the instructs of DefaultSets to their sets.
+func (r *Client) InitRedis(ctx context.Context) {
+	for set, instructs := range DefaultSets {
+		members := make([]interface{}, len(instructs))
+		for i, instruct := range instructs {
+			members[i] = instruct
+		}
+		r.rdb.SAdd(ctx, set, members...)
 	}
-
-	c.JSON(http.StatusOK, "added Item to list: "+listName)
 }
 
-func DeleteInstruct(c *gin.Context) {
-
-	rdb := loadClient()
-
-	var requestData struct {
-		Item string `json:"item"`
-		Set  string `json:"set,omitempty"`
-	}
-	if err := c.ShouldBindJSON(&requestData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	listName := requestData.Set
-	if listName == "" {
-		listName = "default"
-	}
-
-	ctx := context.Background()
-	if err := rdb.SRem(ctx, listName, requestData.Item).Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+// AddSetMember adds instruct to the instruct set.
+func (r *Client) AddSetMember(ctx context.Context, set string, instruct string) error {
+	return r.rdb.SAdd(ctx, set, instruct).Err()
+}
 
-	c.Status(http.StatusOK)
+// RemoveSetMember removes instruct from the instruct set.
+func (r *Client) RemoveSetMember(ctx context.Context, set string, instruct string) error {
+	return r.rdb.SRem(ctx, set, instruct).Err()
 }
 
-func GetSet(setName string) ([]string, error) {
-	rdb := loadClient()
+func (r *Client) GetSet(ctx context.Context, setName string) ([]string, error) {
+	rdb := r.rdb
 
 	if setName == "" {
 		setName = "default"
 	}
 
-	ctx := context.Background()
 	vals, err := rdb.SMembers(ctx, setName).Result()
 	if err != nil {
 		return nil, err
@@ -218,15 +217,17 @@ func GetSet(setName string) ([]string, error) {
 	return vals, nil
 }
 
-func GetSetMember(setName string) (string, error) {
-	rdb := loadClient()
+func (r *Client) GetSetMember(ctx context.Context, setName string) (string, error) {
+	rdb := r.rdb
 
 	if setName == "" {
 		setName = "default"
 	}
 
-	ctx := context.Background()
 	val, err := rdb.SRandMember(ctx, setName).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", errkind.New(errkind.NotFound, "instruct set %s is empty or does not exist", setName)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -234,26 +235,20 @@ func GetSetMember(setName string) (string, error) {
 	return val, nil
 }
 
-func GetAllSets(c *gin.Context) {
-	rdb := loadClient()
-
-	ctx := c.Request.Context()
-	keysCmd := rdb.Keys(ctx, "*") // Get all keys matching the pattern "*"
+// SetNames returns the names of all instruct sets.
+func (r *Client) SetNames(ctx context.Context) ([]string, error) {
+	rdb := r.rdb
 
-	keys, err := keysCmd.Result()
+	keys, err := rdb.Keys(ctx, "*").Result() // Get all keys matching the pattern "*"
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	var sets []string
 	for _, key := range keys {
-		typeCmd := rdb.Type(ctx, key) // Get the type of the key
-
-		keyType, err := typeCmd.Result()
+		keyType, err := rdb.Type(ctx, key).Result() // Get the type of the key
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
 
 		if keyType == "set" {
@@ -261,31 +256,106 @@ func GetAllSets(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, sets)
+	return sets, nil
+}
+
+// Sets returns every instruct set with its members.
+func (r *Client) Sets(ctx context.Context) (map[string][]string, error) {
+	rdb := r.rdb
 
-	return
+	keys, err := rdb.Keys(ctx, "*").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string][]string)
+	for _, key := range keys {
+		keyType, err := rdb.Type(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if keyType != "set" {
+			continue
+		}
+
+		members, err := rdb.SMembers(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		sets[key] = members
+	}
+
+	return sets, nil
 }
 
-func DeleteAllSets() {
-	rdb := loadClient()
+func (r *Client) DeleteAllSets(ctx context.Context) error {
+	rdb := r.rdb
 
-	keysCmd := rdb.Keys(context.Background(), "*") // Get all keys matching the pattern "*"
+	keysCmd := rdb.Keys(ctx, "*") // Get all keys matching the pattern "*"
 
 	keys, err := keysCmd.Result()
 	if err != nil {
-		return
+		return err
 	}
 
 	for _, key := range keys {
-		typeCmd := rdb.Type(context.Background(), key) // Get the type of the key
+		typeCmd := rdb.Type(ctx, key) // Get the type of the key
 
 		keyType, err := typeCmd.Result()
 		if err != nil {
-			return
+			return err
 		}
 
 		if keyType == "set" {
-			rdb.Del(context.Background(), key)
+			err = rdb.Del(ctx, key).Err()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// scanBatch is the number of keys scanned and deleted with one command.
+const scanBatch = 100
+
+// scanKeys calls fn with batches of the keys matching the glob pattern. It
+// scans the keys instead of blocking Redis with KEYS.
+func (r *Client) scanKeys(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	iter := r.rdb.Scan(ctx, 0, pattern, scanBatch).Iterator()
+
+	var batch []string
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) < scanBatch {
+			continue
+		}
+		if err := fn(batch); err != nil {
+			return err
 		}
+		batch = batch[:0]
 	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return fn(batch)
+}
+
+// deleteMatching deletes all keys matching one of the glob patterns.
+func (r *Client) deleteMatching(ctx context.Context, patterns ...string) error {
+	for _, pattern := range patterns {
+		err := r.scanKeys(ctx, pattern, func(keys []string) error {
+			return r.rdb.Del(ctx, keys...).Err()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,21 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestClient connects a Client to an in-process Redis server.
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *Client) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client, err := NewClient(Config{Addr: server.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return server, client
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+// Store covers the state the handlers keep in Redis: votes, instruct sets and
+// confirmation tokens. Client implements it against Redis, the memory package
+// provides an implementation without external services.
+type Store interface {
+	Health(ctx context.Context) error
+
+	CastVote(ctx context.Context, promptID string, clientID string, vote Vote, base int) (VoteTally, error)
+	GetVote(ctx context.Context, promptID string, clientID string) (Vote, error)
+	GetVoteTally(ctx context.Context, promptID string) (tally VoteTally, ok bool, err error)
+	GetVotes(ctx context.Context, promptID string) (map[string]Vote, error)
+	RestoreVotes(ctx context.Context, promptID string, votes map[string]Vote, tally VoteTally) error
+	DeleteVotes(ctx context.Context, ids ...string) error
+	DeleteAllVotes(ctx context.Context) error
+
+	InitRedis(ctx context.Context)
+	AddSetMember(ctx context.Context, set string, instruct string) error
+	RemoveSetMember(ctx context.Context, set string, instruct string) error
+	GetSet(ctx context.Context, setName string) ([]string, error)
+	GetSetMember(ctx context.Context, setName string) (string, error)
+	SetNames(ctx context.Context) ([]string, error)
+	Sets(ctx context.Context) (map[string][]string, error)
+	DeleteAllSets(ctx context.Context) error
+
+	CreateConfirmation(ctx context.Context, payload string, ttl time.Duration) (string, error)
+	ConsumeConfirmation(ctx context.Context, token string) (string, error)
+}
+
+// JobQueue schedules jobs for a pool of workers. Queue implements it.
+type JobQueue interface {
+	Enqueue(ctx context.Context, payload interface{}) (Job, error)
+	Work(size int, handler Handler) Workers
+	DeadJobs(ctx context.Context) ([]Job, error)
+	Requeue(ctx context.Context, ids ...string) (int, error)
+	Stats(ctx context.Context) (QueueStats, error)
+}
+
+// Workers are the running workers of a JobQueue.
+type Workers interface {
+	Stop(ctx context.Context) error
+}
+
+// Cache maps cache keys to the prompt that already answered them and counts
+// the outcomes of lookups. ResponseCache implements it.
+type Cache interface {
+	Lookup(ctx context.Context, key string) (promptID string, ok bool, err error)
+	Remember(ctx context.Context, key string, promptID string) error
+	Forget(ctx context.Context, key string) error
+	ForgetPrompts(ctx context.Context, promptIDs ...string) error
+	Clear(ctx context.Context) error
+	Record(ctx context.Context, event CacheEvent) error
+	Stats(ctx context.Context) (CacheStats, error)
+}
+
+var (
+	_ Store    = (*Client)(nil)
+	_ JobQueue = (*Queue)(nil)
+	_ Cache    = (*ResponseCache)(nil)
+)
@@ -0,0 +1,205 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// Vote is the opinion of one client about one prompt.
+type Vote int
+
+const (
+	Downvote Vote = -1
+	NoVote   Vote = 0
+	Upvote   Vote = 1
+)
+
+func (v Vote) String() string {
+	switch v {
+	case Upvote:
+		return "up"
+	case Downvote:
+		return "down"
+	default:
+		return "none"
+	}
+}
+
+// ParseVote accepts up, down and none.
+func ParseVote(s string) (Vote, error) {
+	switch s {
+	case "up":
+		return Upvote, nil
+	case "down":
+		return Downvote, nil
+	case "none":
+		return NoVote, nil
+	default:
+		return NoVote, errkind.New(errkind.Invalid, "invalid vote %q, expected up, down or none", s)
+	}
+}
+
+// VoteTally sums up the votes of a prompt. Base is the rank the prompt had
+// before votes were recorded per client.
+type VoteTally struct {
+	Base int `json:"-"`
+	Up   int `json:"upvotes"`
+	Down int `json:"downvotes"`
+}
+
+// Rank is the rank derived from the votes.
+func (t VoteTally) Rank() int {
+	return t.Base + t.Up - t.Down
+}
+
+func votesKey(promptID string) string {
+	return "votes:" + promptID
+}
+
+func tallyKey(promptID string) string {
+	return "tally:" + promptID
+}
+
+// castVoteScript replaces the vote of a client and updates the tally in one
+// step, so concurrent votes never lose an update.
+var castVoteScript = redis.NewScript(`
+local old = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local new = tonumber(ARGV[2])
+
+redis.call('HSETNX', KEYS[2], 'base', ARGV[3])
+
+if new == 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+else
+	redis.call('HSET', KEYS[1], ARGV[1], new)
+end
+
+if old == 1 then
+	redis.call('HINCRBY', KEYS[2], 'up', -1)
+elseif old == -1 then
+	redis.call('HINCRBY', KEYS[2], 'down', -1)
+end
+
+if new == 1 then
+	redis.call('HINCRBY', KEYS[2], 'up', 1)
+elseif new == -1 then
+	redis.call('HINCRBY', KEYS[2], 'down', 1)
+end
+
+local tally = redis.call('HMGET', KEYS[2], 'base', 'up', 'down')
+return {tonumber(tally[1]), tonumber(tally[2] or '0'), tonumber(tally[3] or '0')}
+`)
+
+// CastVote records the vote of clientID for promptID, replacing an earlier
+// vote of the same client, and returns the new tally. base seeds the tally of
+// a prompt that has none yet.
+func (r *Client) CastVote(ctx context.Context, promptID string, clientID string, vote Vote, base int) (VoteTally, error) {
+	result, err := castVoteScript.Run(ctx, r.rdb,
+		[]string{votesKey(promptID), tallyKey(promptID)},
+		clientID, int(vote), base,
+	).Int64Slice()
+	if err != nil {
+		return VoteTally{}, err
+	}
+
+	return VoteTally{Base: int(result[0]), Up: int(result[1]), Down: int(result[2])}, nil
+}
+
+// GetVote returns the vote of clientID for promptID.
+func (r *Client) GetVote(ctx context.Context, promptID string, clientID string) (Vote, error) {
+	vote, err := r.rdb.HGet(ctx, votesKey(promptID), clientID).Int()
+	if errors.Is(err, redis.Nil) {
+		return NoVote, nil
+	}
+	if err != nil {
+		return NoVote, err
+	}
+
+	return Vote(vote), nil
+}
+
+// GetVoteTally returns the tally of promptID. ok is false if nobody has voted
+// for the prompt yet.
+func (r *Client) GetVoteTally(ctx context.Context, promptID string) (tally VoteTally, ok bool, err error) {
+	values, err := r.rdb.HGetAll(ctx, tallyKey(promptID)).Result()
+	if err != nil {
+		return VoteTally{}, false, err
+	}
+	if len(values) == 0 {
+		return VoteTally{}, false, nil
+	}
+
+	for field, target := range map[string]*int{"base": &tally.Base, "up": &tally.Up, "down": &tally.Down} {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		*target, err = strconv.Atoi(value)
+		if err != nil {
+			return VoteTally{}, false, fmt.Errorf("invalid %s in vote tally of %s: %w", field, promptID, err)
+		}
+	}
+
+	return tally, true, nil
+}
+
+// GetVotes returns the vote of every client who voted for promptID.
+func (r *Client) GetVotes(ctx context.Context, promptID string) (map[string]Vote, error) {
+	values, err := r.rdb.HGetAll(ctx, votesKey(promptID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[string]Vote, len(values))
+	for clientID, value := range values {
+		vote, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vote of %s for %s: %w", clientID, promptID, err)
+		}
+		votes[clientID] = Vote(vote)
+	}
+
+	return votes, nil
+}
+
+// RestoreVotes replaces the votes and the tally of promptID, for example with
+// the ones of an export.
+func (r *Client) RestoreVotes(ctx context.Context, promptID string, votes map[string]Vote, tally VoteTally) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, votesKey(promptID), tallyKey(promptID))
+		for clientID, vote := range votes {
+			if vote != NoVote {
+				pipe.HSet(ctx, votesKey(promptID), clientID, int(vote))
+			}
+		}
+		pipe.HSet(ctx, tallyKey(promptID), "base", tally.Base, "up", tally.Up, "down", tally.Down)
+		return nil
+	})
+	return err
+}
+
+// DeleteVotes deletes the votes and the tallies of the given prompts or
+// messages.
+func (r *Client) DeleteVotes(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		keys = append(keys, votesKey(id), tallyKey(id))
+	}
+
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+// DeleteAllVotes deletes the votes and the tallies of all prompts and
+// messages.
+func (r *Client) DeleteAllVotes(ctx context.Context) error {
+	return r.deleteMatching(ctx, votesKey("*"), tallyKey("*"))
+}
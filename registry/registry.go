@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"gopkg.in/yaml.v3"
+)
+
+// Overflow policies decide what happens to a prompt that does not fit into
+// the context of a model.
+const (
+	// OverflowReject fails the request with the number of tokens it is over
+	// budget.
+	OverflowReject = "reject"
+	// OverflowTruncate cuts the code at the end until the prompt fits.
+	OverflowTruncate = "truncate"
+	// OverflowChunk answers the prompt for chunks of the code and combines
+	// the answers.
+	OverflowChunk = "chunk"
+)
+
+// DefaultResponseTokens is the part of the context kept free for the answer
+// if a model does not configure it.
+const DefaultResponseTokens = 1024
+
+// Model describes a model the backend is allowed to use.
+type Model struct {
+	Name string `json:"name" yaml:"name"`
+	// ContextLength is the context window in tokens. It is capped by the
+	// context length the LLM server reports for the model.
+	ContextLength int `json:"contextLength" yaml:"contextLength"`
+	// ResponseTokens is the part of the context reserved for the answer.
+	ResponseTokens int `json:"responseTokens,omitempty" yaml:"responseTokens,omitempty"`
+	// Overflow is the policy for prompts exceeding the context, reject by
+	// default.
+	Overflow string                 `json:"overflow,omitempty" yaml:"overflow,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// Registry holds the allowed models together with the models used when a
+// request does not name one.
+type Registry struct {
+	Default         string  `json:"default" yaml:"default"`
+	SemanticMeaning string  `json:"semanticMeaning" yaml:"semanticMeaning"`
+	Models          []Model `json:"models" yaml:"models"`
+
+	byName map[string]Model
+}
+
+// Default returns the registry used when no registry file is configured. It
+// allows exactly the generation and the semantic meaning model.
+func Default(generation string, semantic string) *Registry {
+	r := &Registry{
+		Default:         generation,
+		SemanticMeaning: semantic,
+		Models:          []Model{{Name: generation, ContextLength: 16384}},
+	}
+	if semantic != generation {
+		r.Models = append(r.Models, Model{Name: semantic, ContextLength: 4096})
+	}
+
+	if err := r.index(); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// Load reads a registry from a JSON or YAML file, depending on its extension.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Registry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &r)
+	case ".json":
+		err = json.Unmarshal(data, &r)
+	default:
+		return nil, fmt.Errorf("unsupported model registry format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse model registry %s: %w", path, err)
+	}
+
+	if err := r.index(); err != nil {
+		return nil, fmt.Errorf("invalid model registry %s: %w", path, err)
+	}
+
+	return &r, nil
+}
+
+func (r *Registry) index() error {
+	if len(r.Models) == 0 {
+		return errors.New("no models configured")
+	}
+
+	r.byName = make(map[string]Model, len(r.Models))
+	for i := range r.Models {
+		model := &r.Models[i]
+		if model.Name == "" {
+			return errors.New("model without name")
+		}
+		if model.ContextLength <= 0 {
+			return fmt.Errorf("model %s: contextLength must be positive", model.Name)
+		}
+
+		if model.ResponseTokens == 0 {
+			model.ResponseTokens = DefaultResponseTokens
+		}
+		if model.ResponseTokens < 0 || model.ResponseTokens >= model.ContextLength {
+			return fmt.Errorf("model %s: responseTokens must be positive and less than contextLength", model.Name)
+		}
+
+		switch model.Overflow {
+		case "":
+			model.Overflow = OverflowReject
+		case OverflowReject, OverflowTruncate, OverflowChunk:
+		default:
+			return fmt.Errorf("model %s: unknown overflow policy %q, expected %s, %s or %s", model.Name, model.Overflow, OverflowReject, OverflowTruncate, OverflowChunk)
+		}
+
+		if _, exists := r.byName[model.Name]; exists {
+			return fmt.Errorf("model %s is listed twice", model.Name)
+		}
+		r.byName[model.Name] = *model
+	}
+
+	if r.Default == "" {
+		r.Default = r.Models[0].Name
+	}
+	if _, ok := r.byName[r.Default]; !ok {
+		return fmt.Errorf("default model %s is not listed", r.Default)
+	}
+
+	if r.SemanticMeaning == "" {
+		r.SemanticMeaning = r.Default
+	}
+	if _, ok := r.byName[r.SemanticMeaning]; !ok {
+		return fmt.Errorf("semantic meaning model %s is not listed", r.SemanticMeaning)
+	}
+
+	return nil
+}
+
+// Get returns the model with the given name. An empty name selects the default
+// model, unknown names are rejected.
+func (r *Registry) Get(name string) (Model, error) {
+	if name == "" {
+		name = r.Default
+	}
+
+	model, ok := r.byName[name]
+	if !ok {
+		return Model{}, errkind.New(errkind.Invalid, "model %s is not allowed, choose one of: %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	return model, nil
+}
+
+// Names returns the sorted names of all allowed models.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "yaml",
+			file:    "models.yaml",
+			content: "default: b\nmodels:\n  - name: a\n    contextLength: 8192\n    overflow: chunk\n  - name: b\n    contextLength: 4096\n    responseTokens: 512\n",
+		},
+		{
+			name:    "json",
+			file:    "models.json",
+			content: `{"default": "b", "models": [{"name": "a", "contextLength": 8192, "overflow": "chunk"}, {"name": "b", "contextLength": 4096, "responseTokens": 512}]}`,
+		},
+		{name: "format", file: "models.toml", content: "", wantErr: "unsupported model registry format"},
+		{name: "malformed", file: "models.json", content: "{", wantErr: "could not parse model registry"},
+		{name: "no models", file: "models.yaml", content: "default: a\n", wantErr: "no models configured"},
+		{name: "no name", file: "models.yaml", content: "models:\n  - contextLength: 1\n", wantErr: "model without name"},
+		{name: "no context", file: "models.yaml", content: "models:\n  - name: a\n", wantErr: "model a: contextLength must be positive"},
+		{name: "response too large", file: "models.yaml", content: "models:\n  - name: a\n    contextLength: 512\n", wantErr: "model a: responseTokens must be positive and less than contextLength"},
+		{name: "overflow", file: "models.yaml", content: "models:\n  - name: a\n    contextLength: 4096\n    overflow: drop\n", wantErr: `unknown overflow policy "drop"`},
+		{name: "duplicate", file: "models.yaml", content: "models:\n  - name: a\n    contextLength: 4096\n  - name: a\n    contextLength: 4096\n", wantErr: "model a is listed twice"},
+		{name: "unknown default", file: "models.yaml", content: "default: c\nmodels:\n  - name: a\n    contextLength: 4096\n", wantErr: "default model c is not listed"},
+		{name: "unknown semantic meaning model", file: "models.yaml", content: "semanticMeaning: c\nmodels:\n  - name: a\n    contextLength: 4096\n", wantErr: "semantic meaning model c is not listed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the semantic meaning model falls back to the default model,
+			// unset fields to their defaults
+			if r.Default != "b" || r.SemanticMeaning != "b" {
+				t.Errorf("Default, SemanticMeaning = %s, %s, want b, b", r.Default, r.SemanticMeaning)
+			}
+			a, err := r.Get("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.ResponseTokens != DefaultResponseTokens || a.Overflow != OverflowChunk {
+				t.Errorf("Get(a) = %+v, want the default response tokens and the chunk policy", a)
+			}
+			b, err := r.Get("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Name != "b" || b.ResponseTokens != 512 || b.Overflow != OverflowReject {
+				t.Errorf("Get(\"\") = %+v, want model b rejecting overflows", b)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	r := Default("llama3", "nomic")
+
+	if got := strings.Join(r.Names(), ","); got != "llama3,nomic" {
+		t.Errorf("Names() = %s, want llama3,nomic", got)
+	}
+	if model, err := r.Get(""); err != nil || model.Name != "llama3" {
+		t.Errorf("Get(\"\") = %+v, %v, want the default model", model, err)
+	}
+
+	_, err := r.Get("gpt")
+	if !errkind.Is(err, errkind.Invalid) {
+		t.Fatalf("Get(gpt) = %v, want an invalid request", err)
+	}
+	if want := "model gpt is not allowed, choose one of: llama3, nomic"; err.Error() != want {
+		t.Errorf("Get(gpt) error = %q, want %q", err, want)
+	}
+
+	if names := Default("llama3", "llama3").Names(); len(names) != 1 {
+		t.Errorf("Default() with one model for both uses = %v, want it listed once", names)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/admin"
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/auth"
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+// confirmationTTL is how long a reset can be confirmed after its preview.
+const confirmationTTL = 5 * time.Minute
+
+// pendingReset is stored with a confirmation token, so the token only
+// confirms the exact reset it was issued for and only for the same caller.
+type pendingReset struct {
+	Request admin.ResetRequest `json:"request"`
+	Token   string             `json:"token"`
+}
+
+// reset deletes data in two steps. A request without a confirmation token
+// returns a preview of what would be deleted together with a token. Repeating
+// the request with that token in the confirm field carries out the reset,
+// after a snapshot of the affected data has been written.
+func (s *server) reset(c *gin.Context) {
+	var requestBody struct {
+		admin.ResetRequest
+		Confirm string `json:"confirm"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		c.Error(api.InvalidRequest("invalid request body: %v", err))
+		return
+	}
+
+	req := requestBody.ResetRequest
+	if err := req.Validate(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	token, _ := auth.FromContext(c)
+	pending, err := json.Marshal(pendingReset{Request: req, Token: token.Name})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if requestBody.Confirm == "" {
+		plan, err := s.resetter.Plan(ctx, req)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		confirm, err := s.redis.CreateConfirmation(ctx, string(pending), confirmationTTL)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"plan":      plan,
+			"confirm":   confirm,
+			"expiresAt": time.Now().Add(confirmationTTL).UTC(),
+		})
+		return
+	}
+
+	confirmed, err := s.redis.ConsumeConfirmation(ctx, requestBody.Confirm)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if confirmed != string(pending) {
+		c.Error(errkind.New(errkind.Conflict, "confirmation token was issued for a different reset"))
+		return
+	}
+
+	log.Printf("token %s started %s reset", token.Name, req.Scope)
+
+	// a client that disconnects must not leave the reset half done
+	result, err := s.resetter.Reset(context.WithoutCancel(ctx), req)
+	if err != nil {
+		log.Printf("%s reset by %s failed: %v", req.Scope, token.Name, err)
+		e := apiError(err)
+		e.Details = gin.H{"result": result}
+		c.Error(e)
+		return
+	}
+
+	log.Printf("token %s finished %s reset: %v", token.Name, req.Scope, result.Objects)
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,725 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/auth"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/redis"
+	redismemory "github.com/rwth-acis/modernizer/redis/memory"
+	"github.com/rwth-acis/modernizer/registry"
+	"github.com/rwth-acis/modernizer/weaviate"
+	"github.com/rwth-acis/modernizer/weaviate/memory"
+)
+
+const testModel = "fake-model"
+
+// testTokens are the API tokens of two voters and an admin.
+var testTokens = map[string]string{
+	"alice": "alice-secret",
+	"bob":   "bob-secret",
+	"root":  "root-secret",
+}
+
+// newTestServer serves the router of a server built from the in-memory stores
+// and the fake LLM provider.
+func newTestServer(t *testing.T, configure ...func(*server)) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sets := redismemory.New()
+	sets.InitRedis(context.Background())
+
+	s := newServer(memory.New(nil), sets, llm.NewFake(), registry.Default(testModel, testModel), sets)
+	tokens, err := auth.ParseTokens("alice:viewer:" + testTokens["alice"] + ",bob:viewer:" + testTokens["bob"] + ",root:admin:" + testTokens["root"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tokens = auth.NewAuthenticator(tokens)
+	s.generator.Queue = redismemory.NewQueue()
+	s.generator.Cache = redismemory.NewCache()
+	s.generator.StartWorkers(1)
+	for _, f := range configure {
+		f(s)
+	}
+
+	server := httptest.NewServer(s.router())
+	t.Cleanup(func() {
+		server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.generator.Shutdown(ctx); err != nil {
+			t.Errorf("shutting down the generator: %v", err)
+		}
+	})
+
+	return server
+}
+
+// request is a call of a route. Body is encoded as JSON unless it is a
+// string, Token is sent as bearer token.
+type request struct {
+	method string
+	path   string
+	body   interface{}
+	token  string
+	// forwardedFor is sent as X-Forwarded-For
+	forwardedFor string
+}
+
+// do sends req and fails unless the response has status want. The body is
+// decoded into out unless out is nil.
+func do(t *testing.T, server *httptest.Server, req request, want int, out interface{}) {
+	t.Helper()
+
+	var body io.Reader
+	switch b := req.body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	httpReq, err := http.NewRequest(req.method, server.URL+req.path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	if req.forwardedFor != "" {
+		httpReq.Header.Set("X-Forwarded-For", req.forwardedFor)
+	}
+
+	resp, err := server.Client().Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != want {
+		t.Fatalf("%s %s returned %d, want %d: %s", req.method, req.path, resp.StatusCode, want, raw)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			t.Fatalf("%s %s returned %q: %v", req.method, req.path, raw, err)
+		}
+	}
+}
+
+// generate answers a prompt about code and returns the response.
+func generate(t *testing.T, server *httptest.Server, code string) weaviate.ResponseData {
+	t.Helper()
+
+	var response weaviate.ResponseData
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/generate", body: api.GenerateRequest{Code: code, InstructType: "developer"}}, http.StatusOK, &response)
+	if response.PromptID == "" || response.Response == "" {
+		t.Fatalf("generate returned %+v, want a prompt and a response", response)
+	}
+
+	return response
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       interface{}
+		want       int
+		wantModel  string
+		wantErrors string
+	}{
+		{
+			name:      "v1",
+			path:      api.Prefix + "/generate",
+			body:      api.GenerateRequest{Code: "func add(a, b int) int { return a + b }", InstructType: "developer"},
+			want:      http.StatusOK,
+			wantModel: testModel,
+		},
+		{
+			name:      "v1 instruct",
+			path:      api.Prefix + "/generate",
+			body:      api.GenerateRequest{Code: "func sub(a, b int) int { return a - b }", Instruct: "Explain this:", Model: testModel},
+			want:      http.StatusOK,
+			wantModel: testModel,
+		},
+		{
+			name:      "legacy",
+			path:      "/generate",
+			body:      map[string]string{"prompt": "func mul(a, b int) int { return a * b }", "instructType": "security"},
+			want:      http.StatusOK,
+			wantModel: testModel,
+		},
+		{
+			name:       "v1 without code",
+			path:       api.Prefix + "/generate",
+			body:       api.GenerateRequest{InstructType: "developer"},
+			want:       http.StatusBadRequest,
+			wantErrors: string(api.CodeInvalidRequest),
+		},
+		{
+			name: "legacy without code",
+			path: "/generate",
+			body: map[string]string{"instructType": "developer"},
+			want: http.StatusBadRequest,
+		},
+		{
+			name:       "v1 malformed",
+			path:       api.Prefix + "/generate",
+			body:       "{",
+			want:       http.StatusBadRequest,
+			wantErrors: string(api.CodeInvalidRequest),
+		},
+		{
+			name:       "v1 unknown model",
+			path:       api.Prefix + "/generate",
+			body:       api.GenerateRequest{Code: "x := 1", InstructType: "developer", Model: "other"},
+			want:       http.StatusBadRequest,
+			wantErrors: string(api.CodeInvalidRequest),
+		},
+	}
+
+	server := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErrors != "" {
+				var body api.ErrorResponse
+				do(t, server, request{method: http.MethodPost, path: tt.path, body: tt.body}, tt.want, &body)
+				if body.Error == nil || string(body.Error.Code) != tt.wantErrors {
+					t.Errorf("error = %+v, want code %s", body.Error, tt.wantErrors)
+				}
+				return
+			}
+			if tt.want != http.StatusOK {
+				do(t, server, request{method: http.MethodPost, path: tt.path, body: tt.body}, tt.want, nil)
+				return
+			}
+
+			var response weaviate.ResponseData
+			do(t, server, request{method: http.MethodPost, path: tt.path, body: tt.body}, tt.want, &response)
+			if response.PromptID == "" || response.Response == "" || response.Instruct == "" {
+				t.Errorf("response = %+v, want a prompt, a response and an instruct", response)
+			}
+			if response.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", response.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestGenerateOverflow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.yaml")
+	config := "models:\n  - name: " + testModel + "\n    contextLength: 600\n    responseTokens: 100\n    overflow: reject\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	models, err := registry.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, func(s *server) {
+		s.models = models
+		s.generator.Models = models
+	})
+	code := strings.Repeat("func f() int {\n\treturn 1\n}\n", 100)
+
+	var v1 struct {
+		Error struct {
+			Code    api.ErrorCode `json:"code"`
+			Details struct {
+				Limit int `json:"limit"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/generate", body: api.GenerateRequest{Code: code, Instruct: "Explain this:"}}, http.StatusRequestEntityTooLarge, &v1)
+	if v1.Error.Code != api.CodeContextOverflow || v1.Error.Details.Limit != 500 {
+		t.Errorf("v1 error = %+v, want a context overflow over the limit of 500 tokens", v1.Error)
+	}
+
+	// the deprecated route keeps the token counts next to the error
+	var legacy struct {
+		Error  string        `json:"error"`
+		Code   api.ErrorCode `json:"code"`
+		Tokens int           `json:"tokens"`
+		Limit  int           `json:"limit"`
+	}
+	do(t, server, request{method: http.MethodPost, path: "/generate", body: map[string]string{"prompt": code, "instruct": "Explain this:"}}, http.StatusRequestEntityTooLarge, &legacy)
+	if legacy.Error == "" || legacy.Code != api.CodeContextOverflow || legacy.Limit != 500 || legacy.Tokens <= legacy.Limit {
+		t.Errorf("legacy error = %+v, want the token counts over the limit of 500", legacy)
+	}
+}
+
+func TestGenerateCached(t *testing.T) {
+	server := newTestServer(t)
+	req := api.GenerateRequest{Code: "func id(x int) int { return x }", Instruct: "Explain this:"}
+
+	var answered, cached, forced weaviate.ResponseData
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/generate", body: req}, http.StatusOK, &answered)
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/generate", body: req}, http.StatusOK, &cached)
+	if !cached.Cached || cached.PromptID != answered.PromptID {
+		t.Errorf("second answer = %+v, want the cached answer %s", cached, answered.PromptID)
+	}
+
+	req.Force = true
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/generate", body: req}, http.StatusOK, &forced)
+	if forced.Cached {
+		t.Errorf("forced answer %+v came from the cache", forced)
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := server.Client().Post(server.URL+api.Prefix+"/generate", "application/json",
+		strings.NewReader(`{"code":"func neg(x int) int { return -x }","instructType":"developer","stream":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	events := map[string]int{}
+	var done weaviate.ResponseData
+	var event string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			events[event]++
+		case strings.HasPrefix(line, "data:") && event == "done":
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &done); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if events["token"] == 0 || events["done"] != 1 || events["error"] != 0 {
+		t.Errorf("received events %v, want tokens and one done event", events)
+	}
+	if done.PromptID == "" {
+		t.Errorf("done event %+v has no prompt", done)
+	}
+}
+
+func TestVotes(t *testing.T) {
+	server := newTestServer(t)
+	promptID := generate(t, server, "func max(a, b int) int { if a > b { return a }; return b }").PromptID
+
+	// the rank of a new prompt starts at one, every vote moves it by one
+	// legacy steps answer "OK" like the deprecated route always did
+	steps := []struct {
+		name   string
+		req    request
+		legacy bool
+		want   api.VoteResponse
+	}{
+		{
+			name: "upvote",
+			req:  request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "up"}, token: testTokens["alice"]},
+			want: api.VoteResponse{Vote: "up", Upvotes: 1, Rank: 2},
+		},
+		{
+			name: "upvote again",
+			req:  request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "up"}, token: testTokens["alice"]},
+			want: api.VoteResponse{Vote: "up", Upvotes: 1, Rank: 2},
+		},
+		{
+			name: "other voter",
+			req:  request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "down"}, token: testTokens["bob"]},
+			want: api.VoteResponse{Vote: "down", Upvotes: 1, Downvotes: 1, Rank: 1},
+		},
+		{
+			name: "read",
+			req:  request{method: http.MethodGet, path: api.Prefix + "/votes/" + promptID, token: testTokens["alice"]},
+			want: api.VoteResponse{Vote: "up", Upvotes: 1, Downvotes: 1, Rank: 1},
+		},
+		{
+			name: "change vote",
+			req:  request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "down"}, token: testTokens["alice"]},
+			want: api.VoteResponse{Vote: "down", Downvotes: 2, Rank: -1},
+		},
+		{
+			name: "withdraw",
+			req:  request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "none"}, token: testTokens["bob"]},
+			want: api.VoteResponse{Vote: "none", Downvotes: 1},
+		},
+		{
+			name:   "legacy upvote",
+			req:    request{method: http.MethodPost, path: "/vote?upvote=true", body: map[string]string{"id": promptID}},
+			legacy: true,
+		},
+		{
+			name: "legacy read",
+			req:  request{method: http.MethodGet, path: "/vote?id=" + promptID},
+			want: api.VoteResponse{Vote: "up", Upvotes: 1, Downvotes: 1, Rank: 1},
+		},
+		{
+			name: "anonymous read",
+			req:  request{method: http.MethodGet, path: api.Prefix + "/votes/" + promptID, token: testTokens["bob"]},
+			want: api.VoteResponse{Vote: "none", Upvotes: 1, Downvotes: 1, Rank: 1},
+		},
+	}
+
+	for _, step := range steps {
+		if step.legacy {
+			var ok string
+			do(t, server, step.req, http.StatusOK, &ok)
+			if ok != "OK" {
+				t.Errorf("%s: got %q, want OK", step.name, ok)
+			}
+			continue
+		}
+
+		var got api.VoteResponse
+		do(t, server, step.req, http.StatusOK, &got)
+
+		step.want.PromptID = promptID
+		if got != step.want {
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+
+	var properties weaviate.PromptProperties
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/" + promptID}, http.StatusOK, &properties)
+	if properties.Upvotes != 1 || properties.Downvotes != 1 {
+		t.Errorf("stored votes are %d up and %d down, want 1 and 1", properties.Upvotes, properties.Downvotes)
+	}
+}
+
+func TestVotesForwardedFor(t *testing.T) {
+	// votes through the deprecated route, read back with the address of the
+	// last voter
+	vote := func(t *testing.T, server *httptest.Server, promptID string, upvote bool, forwardedFor string) api.VoteResponse {
+		t.Helper()
+		path := fmt.Sprintf("/vote?upvote=%v", upvote)
+		do(t, server, request{method: http.MethodPost, path: path, body: map[string]string{"id": promptID}, forwardedFor: forwardedFor}, http.StatusOK, nil)
+
+		var got api.VoteResponse
+		do(t, server, request{method: http.MethodGet, path: api.Prefix + "/votes/" + promptID, forwardedFor: forwardedFor}, http.StatusOK, &got)
+		return got
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		server := newTestServer(t)
+		promptID := generate(t, server, "func min(a, b int) int { if a < b { return a }; return b }").PromptID
+
+		vote(t, server, promptID, true, "203.0.113.1")
+		// a forged header does not make a new voter, the vote is replaced
+		got := vote(t, server, promptID, false, "203.0.113.2")
+		if got.Upvotes != 0 || got.Downvotes != 1 {
+			t.Errorf("votes are %d up and %d down, want 0 and 1", got.Upvotes, got.Downvotes)
+		}
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		server := newTestServer(t, func(s *server) { s.proxies = []string{"127.0.0.1", "::1"} })
+		promptID := generate(t, server, "func min(a, b int) int { if a < b { return a }; return b }").PromptID
+
+		vote(t, server, promptID, true, "203.0.113.1")
+		got := vote(t, server, promptID, false, "203.0.113.2")
+		if got.Upvotes != 1 || got.Downvotes != 1 {
+			t.Errorf("votes are %d up and %d down, want 1 and 1", got.Upvotes, got.Downvotes)
+		}
+	})
+}
+
+func TestVotesTrustedProxyRange(t *testing.T) {
+	server := newTestServer(t, func(s *server) { s.proxies = []string{"127.0.0.0/8", "::1/128"} })
+	promptID := generate(t, server, "func abs(x int) int { if x < 0 { return -x }; return x }").PromptID
+
+	vote := func(forwardedFor string, value string) api.VoteResponse {
+		t.Helper()
+		var got api.VoteResponse
+		do(t, server, request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: value}, forwardedFor: forwardedFor}, http.StatusOK, &got)
+		return got
+	}
+
+	// two users behind the ingress vote once each
+	vote("198.51.100.7", "up")
+	got := vote("198.51.100.8", "up")
+	if got.Upvotes != 2 || got.Downvotes != 0 {
+		t.Errorf("votes are %d up and %d down, want 2 and 0", got.Upvotes, got.Downvotes)
+	}
+
+	// the first user changes the vote instead of voting again
+	got = vote("198.51.100.7", "down")
+	if got.Upvotes != 1 || got.Downvotes != 1 {
+		t.Errorf("votes are %d up and %d down, want 1 and 1", got.Upvotes, got.Downvotes)
+	}
+}
+
+func TestVoteErrors(t *testing.T) {
+	server := newTestServer(t)
+	promptID := generate(t, server, "func min(a, b int) int { if a < b { return a }; return b }").PromptID
+
+	tests := []struct {
+		name string
+		req  request
+		want int
+	}{
+		{name: "unknown prompt", req: request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: "00000000-0000-0000-0000-000000000000", Vote: "up"}}, want: http.StatusNotFound},
+		{name: "invalid vote", req: request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "sideways"}}, want: http.StatusBadRequest},
+		{name: "no prompt", req: request{method: http.MethodPost, path: api.Prefix + "/votes", body: map[string]string{"vote": "up"}}, want: http.StatusBadRequest},
+		{name: "read unknown prompt", req: request{method: http.MethodGet, path: api.Prefix + "/votes/00000000-0000-0000-0000-000000000000"}, want: http.StatusNotFound},
+		{name: "legacy unknown prompt", req: request{method: http.MethodPost, path: "/vote", body: map[string]string{"id": "00000000-0000-0000-0000-000000000000", "vote": "up"}}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			do(t, server, tt.req, tt.want, nil)
+		})
+	}
+}
+
+func TestAPIv1Prompts(t *testing.T) {
+	server := newTestServer(t)
+	code := "func square(x int) int { return x * x }"
+	generated := generate(t, server, code)
+	query := "?code=" + url.QueryEscape(code)
+
+	var properties weaviate.PromptProperties
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/" + generated.PromptID}, http.StatusOK, &properties)
+	if properties.Code != code || properties.Model != testModel {
+		t.Errorf("prompt = %+v, want the generated prompt", properties)
+	}
+
+	var response api.PromptResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/" + generated.PromptID + "/response"}, http.StatusOK, &response)
+	if response.Response != generated.Response {
+		t.Errorf("response = %q, want %q", response.Response, generated.Response)
+	}
+
+	var count api.PromptCountResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/count" + query}, http.StatusOK, &count)
+	if count.Count != 1 {
+		t.Errorf("count = %d, want 1", count.Count)
+	}
+
+	var list api.ResponseListResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/responses" + query + "&instructType=developer"}, http.StatusOK, &list)
+	if len(list.Responses) != 1 || list.Responses[0] != generated.PromptID {
+		t.Errorf("responses = %q, want prompt %s", list.Responses, generated.PromptID)
+	}
+
+	var best weaviate.ResponseData
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/responses/best" + query}, http.StatusOK, &best)
+	if best.PromptID != generated.PromptID {
+		t.Errorf("best response = %+v, want prompt %s", best, generated.PromptID)
+	}
+
+	var types api.InstructTypesResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/instruct-types" + query}, http.StatusOK, &types)
+	if len(types.InstructTypes) != 1 || types.InstructTypes[0] != "developer" {
+		t.Errorf("instruct types = %q, want developer", types.InstructTypes)
+	}
+
+	var missing api.ErrorResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/00000000-0000-0000-0000-000000000000"}, http.StatusNotFound, &missing)
+	if missing.Error == nil || missing.Error.Code != api.CodeNotFound {
+		t.Errorf("error = %+v, want code %s", missing.Error, api.CodeNotFound)
+	}
+
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/count"}, http.StatusBadRequest, nil)
+}
+
+func TestLegacyPromptCount(t *testing.T) {
+	server := newTestServer(t)
+	code := "func square(x int) int { return x * x }"
+	generate(t, server, code)
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "function name", query: "?query=square", want: 1},
+		{name: "other function", query: "?query=cube"},
+		{name: "code", query: "?query=" + url.QueryEscape(code), want: 1},
+		{name: "function parameter", query: "?function=square", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int
+			do(t, server, request{method: http.MethodGet, path: "/weaviate/promptcount" + tt.query}, http.StatusOK, &count)
+			if count != tt.want {
+				t.Errorf("count = %d, want %d", count, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIv1Service(t *testing.T) {
+	server := newTestServer(t)
+
+	var health api.HealthResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/health"}, http.StatusOK, &health)
+	if health != (api.HealthResponse{Weaviate: "ok", Redis: "ok"}) {
+		t.Errorf("health = %+v, want both services ok", health)
+	}
+
+	var models registry.Registry
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/models"}, http.StatusOK, &models)
+	if models.Default != testModel {
+		t.Errorf("default model = %q, want %q", models.Default, testModel)
+	}
+}
+
+func TestAPIv1InstructSets(t *testing.T) {
+	server := newTestServer(t)
+
+	var sets api.InstructSetsResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/instruct-sets"}, http.StatusOK, &sets)
+	if len(sets.Sets) != len(redis.DefaultSets) {
+		t.Errorf("sets = %q, want the default sets", sets.Sets)
+	}
+
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/instruct-sets/custom/instructs", body: map[string]string{"instruct": "Document this:"}}, http.StatusCreated, nil)
+
+	var instructs api.InstructsResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/instruct-sets/custom?all=true"}, http.StatusOK, &instructs)
+	if len(instructs.Instructs) != 1 || instructs.Instructs[0] != "Document this:" {
+		t.Errorf("instructs = %q, want the added instruct", instructs.Instructs)
+	}
+
+	var random api.InstructsResponse
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/instruct-sets/custom"}, http.StatusOK, &random)
+	if len(random.Instructs) != 1 || random.Instructs[0] != "Document this:" {
+		t.Errorf("random instruct = %q, want the added instruct", random.Instructs)
+	}
+
+	do(t, server, request{method: http.MethodDelete, path: api.Prefix + "/instruct-sets/custom/instructs?instruct=" + url.QueryEscape("Document this:")}, http.StatusNoContent, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/instruct-sets/custom"}, http.StatusNotFound, nil)
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/instruct-sets/custom/instructs", body: map[string]string{}}, http.StatusBadRequest, nil)
+}
+
+func TestAPIv1Conversations(t *testing.T) {
+	server := newTestServer(t)
+	promptID := generate(t, server, "func half(x int) int { return x / 2 }").PromptID
+	path := api.Prefix + "/conversations/" + promptID + "/messages"
+
+	var conversation api.MessagesResponse
+	do(t, server, request{method: http.MethodPost, path: path, body: map[string]string{"content": "Why integer division?"}}, http.StatusOK, &conversation)
+	if len(conversation.Messages) != 2 || conversation.Messages[0].Role != weaviate.RoleUser || conversation.Messages[1].Role != weaviate.RoleAssistant {
+		t.Fatalf("conversation = %+v, want the question and the answer", conversation.Messages)
+	}
+
+	var listed api.MessagesResponse
+	do(t, server, request{method: http.MethodGet, path: path}, http.StatusOK, &listed)
+	if len(listed.Messages) != 2 {
+		t.Errorf("listed %d messages, want 2", len(listed.Messages))
+	}
+
+	var vote api.VoteResponse
+	answer := conversation.Messages[1].ID
+	do(t, server, request{method: http.MethodPost, path: path + "/" + answer + "/vote", body: map[string]string{"vote": "up"}}, http.StatusOK, &vote)
+	if vote.MessageID != answer || vote.Upvotes != 1 {
+		t.Errorf("vote = %+v, want one upvote on %s", vote, answer)
+	}
+
+	question := conversation.Messages[0].ID
+	do(t, server, request{method: http.MethodPost, path: path + "/" + question + "/vote", body: map[string]string{"vote": "up"}}, http.StatusBadRequest, nil)
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/conversations/00000000-0000-0000-0000-000000000000/messages", body: map[string]string{"content": "Why?"}}, http.StatusNotFound, nil)
+}
+
+func TestResetResponses(t *testing.T) {
+	server := newTestServer(t, func(s *server) {
+		s.resetter.SnapshotDir = t.TempDir()
+	})
+	code := "func twice(x int) int { return 2 * x }"
+	generation := request{method: http.MethodPost, path: api.Prefix + "/generate", body: api.GenerateRequest{Code: code, Instruct: "Explain this:"}}
+	var generated weaviate.ResponseData
+	do(t, server, generation, http.StatusOK, &generated)
+	promptID := generated.PromptID
+
+	var planned struct {
+		Confirm string `json:"confirm"`
+	}
+	reset := request{method: http.MethodPost, path: "/admin/reset", body: map[string]string{"scope": "responses"}, token: testTokens["root"]}
+	do(t, server, reset, http.StatusAccepted, &planned)
+	reset.body = map[string]string{"scope": "responses", "confirm": planned.Confirm}
+	do(t, server, reset, http.StatusOK, nil)
+
+	// the prompt is left without response
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "up"}, token: testTokens["alice"]}, http.StatusNotFound, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/votes/" + promptID, token: testTokens["alice"]}, http.StatusNotFound, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/prompts/" + promptID + "/response"}, http.StatusNotFound, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/responses/best?code=" + url.QueryEscape(code)}, http.StatusNotFound, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/responses/random?code=" + url.QueryEscape(code)}, http.StatusNotFound, nil)
+
+	// generating the same response answers the stored prompt again
+	var regenerated weaviate.ResponseData
+	do(t, server, generation, http.StatusOK, &regenerated)
+	if regenerated.PromptID != promptID {
+		t.Fatalf("generate returned prompt %s, want the stored prompt %s", regenerated.PromptID, promptID)
+	}
+	do(t, server, request{method: http.MethodPost, path: api.Prefix + "/votes", body: api.VoteRequest{PromptID: promptID, Vote: "up"}, token: testTokens["alice"]}, http.StatusOK, nil)
+	do(t, server, request{method: http.MethodGet, path: api.Prefix + "/responses/best?code=" + url.QueryEscape(code)}, http.StatusOK, nil)
+}
+
+func TestAdminErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		req        request
+		wantStatus int
+		wantCode   api.ErrorCode
+	}{
+		{name: "no token", req: request{method: http.MethodPost, path: "/admin/reset", body: map[string]string{"scope": "all"}}, wantStatus: http.StatusUnauthorized, wantCode: api.CodeUnauthorized},
+		{name: "lower role", req: request{method: http.MethodPost, path: "/admin/reset", body: map[string]string{"scope": "all"}, token: testTokens["alice"]}, wantStatus: http.StatusForbidden, wantCode: api.CodeForbidden},
+		{name: "malformed body", req: request{method: http.MethodPost, path: "/admin/reset", body: "{", token: testTokens["root"]}, wantStatus: http.StatusBadRequest, wantCode: api.CodeInvalidRequest},
+		{name: "unknown scope", req: request{method: http.MethodPost, path: "/admin/reset", body: map[string]string{"scope": "votes"}, token: testTokens["root"]}, wantStatus: http.StatusBadRequest, wantCode: api.CodeInvalidRequest},
+		{name: "unknown confirmation", req: request{method: http.MethodPost, path: "/admin/reset", body: map[string]string{"scope": "all", "confirm": "x"}, token: testTokens["root"]}, wantStatus: http.StatusConflict, wantCode: api.CodeConflict},
+		{name: "unknown job", req: request{method: http.MethodPost, path: "/admin/jobs/requeue", body: map[string][]string{"ids": {"x"}}, token: testTokens["root"]}, wantStatus: http.StatusNotFound, wantCode: api.CodeNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Error string        `json:"error"`
+				Code  api.ErrorCode `json:"code"`
+			}
+			do(t, server, tt.req, tt.wantStatus, &body)
+			if body.Code != tt.wantCode || body.Error == "" {
+				t.Errorf("body = %+v, want code %s with a message", body, tt.wantCode)
+			}
+		})
+	}
+}
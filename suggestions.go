@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rwth-acis/modernizer/api"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/patch"
+)
+
+// suggestion reads a suggestion of the answer to promptID and applies it to
+// the code of the prompt.
+func (s *server) suggestion(ctx context.Context, promptID string, suggestionID string) (api.SuggestionResponse, error) {
+	suggestion, err := s.store.RetrieveSuggestion(ctx, suggestionID)
+	if err != nil {
+		return api.SuggestionResponse{}, err
+	}
+	if suggestion.PromptID != promptID {
+		return api.SuggestionResponse{}, errkind.New(errkind.NotFound, "suggestion not found in the answer to %s", promptID)
+	}
+
+	properties, err := s.store.RetrieveProperties(ctx, promptID)
+	if err != nil {
+		return api.SuggestionResponse{}, err
+	}
+
+	response := api.SuggestionResponse{Suggestion: suggestion}
+	if suggestion.Applies {
+		response.Patched, err = patch.Apply(properties.Code, suggestion.Diff)
+		if err != nil {
+			return api.SuggestionResponse{}, err
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ranking"
+	"github.com/rwth-acis/modernizer/redis"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// clientID identifies the voter by the API token of the request or, for
+// anonymous requests, by the client address. Anonymous clients behind the same
+// address share one vote. X-Forwarded-For is only honored if it was set by
+// one of the trusted proxies, so clients cannot vote again by forging it.
+func (s *server) clientID(c *gin.Context) string {
+	if token, ok := s.tokens.Identify(c); ok {
+		return "token:" + token.Name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// promptVotes converts a tally into the votes stored on the prompt. The rank a
+// prompt had before votes were recorded per client counts as votes, too.
+func promptVotes(tally redis.VoteTally) weaviate.Votes {
+	upvotes, downvotes := ranking.LegacyVotes(tally.Base)
+
+	return weaviate.Votes{
+		Rank:      tally.Rank(),
+		Upvotes:   tally.Up + upvotes,
+		Downvotes: tally.Down + downvotes,
+	}
+}
+
+// castVote records the vote of client for a prompt and stores the votes and
+// the rank derived from all votes on the prompt.
+func (s *server) castVote(ctx context.Context, promptID string, client string, vote redis.Vote) (weaviate.Votes, error) {
+	// the current rank seeds the tally of prompts voted on before votes were
+	// recorded per client, and fails for unknown prompts
+	properties, err := s.store.RetrieveProperties(ctx, promptID)
+	if err != nil {
+		return weaviate.Votes{}, err
+	}
+
+	tally, err := s.redis.CastVote(ctx, promptID, client, vote, properties.Rank)
+	if err != nil {
+		return weaviate.Votes{}, err
+	}
+
+	return s.storeVotes(ctx, promptID, tally, promptVotes, s.store.SetVotesPrompt)
+}
+
+// currentVotes returns the vote of client for a prompt together with the
+// votes of all clients.
+func (s *server) currentVotes(ctx context.Context, promptID string, client string) (redis.Vote, weaviate.Votes, error) {
+	vote, err := s.redis.GetVote(ctx, promptID, client)
+	if err != nil {
+		return redis.NoVote, weaviate.Votes{}, err
+	}
+
+	tally, ok, err := s.redis.GetVoteTally(ctx, promptID)
+	if err != nil {
+		return redis.NoVote, weaviate.Votes{}, err
+	}
+	if !ok {
+		properties, err := s.store.RetrieveProperties(ctx, promptID)
+		if err != nil {
+			return redis.NoVote, weaviate.Votes{}, err
+		}
+		tally.Base = properties.Rank
+	}
+
+	return vote, promptVotes(tally), nil
+}
+
+// messageVotes converts a tally into the votes stored on a turn of a
+// conversation.
+func messageVotes(tally redis.VoteTally) weaviate.Votes {
+	return weaviate.Votes{
+		Rank:      tally.Rank(),
+		Upvotes:   tally.Up,
+		Downvotes: tally.Down,
+	}
+}
+
+// castMessageVote records the vote of client for a turn of a conversation and
+// stores the votes on the turn.
+func (s *server) castMessageVote(ctx context.Context, messageID string, client string, vote redis.Vote) (weaviate.Votes, error) {
+	tally, err := s.redis.CastVote(ctx, messageID, client, vote, 0)
+	if err != nil {
+		return weaviate.Votes{}, err
+	}
+
+	return s.storeVotes(ctx, messageID, tally, messageVotes, s.store.SetVotesMessage)
+}
+
+// storeVotes stores the votes derived from the tally of id with set. The vote
+// itself is recorded already, so a conflict only means the stored rank may lag
+// behind until the next vote; voting again is safe.
+func (s *server) storeVotes(ctx context.Context, id string, tally redis.VoteTally, votes func(redis.VoteTally) weaviate.Votes, set func(context.Context, string, weaviate.Votes) error) (weaviate.Votes, error) {
+	// a concurrent vote may have written its older rank after ours, so write
+	// again until the stored rank matches the latest tally
+	for attempt := 0; attempt < 3; attempt++ {
+		err := set(ctx, id, votes(tally))
+		if err != nil {
+			return weaviate.Votes{}, err
+		}
+
+		latest, _, err := s.redis.GetVoteTally(ctx, id)
+		if err != nil {
+			return weaviate.Votes{}, err
+		}
+		if latest.Rank() == tally.Rank() {
+			return votes(tally), nil
+		}
+		tally = latest
+	}
+
+	return weaviate.Votes{}, errkind.New(errkind.Conflict, "votes on %s keep changing, vote again to store the latest rank", id)
+}
@@ -0,0 +1,273 @@
+package weaviate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/fault"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// AnalysisClass holds the summaries of repositories, files and functions.
+const AnalysisClass = "Analysis"
+
+// AnalysisLevel is the level of an analysis within the hierarchy repository,
+// file and function.
+type AnalysisLevel string
+
+const (
+	AnalysisRepository AnalysisLevel = "repository"
+	AnalysisFile       AnalysisLevel = "file"
+	AnalysisFunction   AnalysisLevel = "function"
+)
+
+// AnalysisStatus tells whether the summary of an analysis is available.
+type AnalysisStatus string
+
+const (
+	AnalysisPending AnalysisStatus = "pending"
+	AnalysisDone    AnalysisStatus = "done"
+	AnalysisFailed  AnalysisStatus = "failed"
+)
+
+// AnalysisObject holds the properties of an analysis.
+type AnalysisObject struct {
+	Level      AnalysisLevel `json:"level"`
+	Repository string        `json:"repository,omitempty"`
+	Path       string        `json:"path,omitempty"`
+	// Function is the analyzed definition, or the code between definitions
+	// if its name is empty. Only function level analyses have one.
+	Function *codeanalysis.Function `json:"function,omitempty"`
+	Instruct string                 `json:"instruct"`
+	Model    string                 `json:"model"`
+	AnalysisResult
+}
+
+// AnalysisResult is the outcome of an analysis.
+type AnalysisResult struct {
+	Status  AnalysisStatus `json:"status"`
+	Summary string         `json:"summary,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Analysis is a stored analysis together with the analyses of its parts.
+type Analysis struct {
+	ID string `json:"id"`
+	AnalysisObject
+	// PartIDs lists all parts, Parts holds those that were retrieved.
+	PartIDs []string   `json:"partIDs,omitempty"`
+	Parts   []Analysis `json:"parts,omitempty"`
+}
+
+// analysisProperties describes the Analysis class apart from its references
+// to itself, which can only be added once the class exists.
+var analysisProperties = []*models.Property{
+	skippedProperty("level", "text", "Whether the analysis covers a repository, a file or a function", models.PropertyTokenizationField),
+	skippedProperty("repository", "text", "The analyzed repository", models.PropertyTokenizationField),
+	skippedProperty("path", "text", "The path of the analyzed file within the repository", models.PropertyTokenizationField),
+	skippedProperty("language", "text", "The language of the analyzed code", models.PropertyTokenizationField),
+	skippedProperty("functionName", "text", "The name of the analyzed function or class", models.PropertyTokenizationField),
+	skippedProperty("functionKind", "text", "Whether the analyzed code is a function, method, class or type", models.PropertyTokenizationField),
+	skippedProperty("signature", "text", "The header of the analyzed definition", ""),
+	skippedProperty("startLine", "int", "The first analyzed line of the file", ""),
+	skippedProperty("endLine", "int", "The last analyzed line of the file", ""),
+	skippedProperty("instruct", "text", "The question answered by the analysis", ""),
+	skippedProperty("model", "text", "The model which generated the summary", models.PropertyTokenizationField),
+	{
+		DataType:    []string{"text"},
+		Description: "The summary generated by the LLM",
+		Name:        "summary",
+	},
+	skippedProperty("status", "text", "Whether the summary is pending, done or failed", models.PropertyTokenizationField),
+	skippedProperty("error", "text", "Why the analysis failed", ""),
+}
+
+// analysisClass describes the Analysis class apart from the references
+// between analyses, see analysisReferences.
+var analysisClass = &models.Class{
+	Class:       AnalysisClass,
+	Description: "This class contains the summaries of repositories, files and functions",
+	Vectorizer:  "text2vec-transformers",
+	Properties:  analysisProperties,
+}
+
+// analysisReferences link an analysis to its parts and back.
+var analysisReferences = []*models.Property{
+	skippedProperty("hasPart", AnalysisClass, "The analyses of the parts", ""),
+	skippedProperty("partOf", AnalysisClass, "The analysis of the whole", ""),
+}
+
+func (c *Client) CreateAnalysisObject(ctx context.Context, analysis AnalysisObject) (string, error) {
+	client := c.client
+
+	dataSchema := map[string]interface{}{
+		"level":      analysis.Level,
+		"repository": analysis.Repository,
+		"path":       analysis.Path,
+		"instruct":   analysis.Instruct,
+		"model":      analysis.Model,
+		"summary":    analysis.Summary,
+		"status":     analysis.Status,
+		"error":      analysis.Error,
+	}
+	if fn := analysis.Function; fn != nil {
+		dataSchema["language"] = fn.Language
+		dataSchema["functionName"] = fn.Name
+		dataSchema["functionKind"] = fn.Kind
+		dataSchema["signature"] = fn.Signature
+		dataSchema["startLine"] = fn.Lines.Start
+		dataSchema["endLine"] = fn.Lines.End
+	}
+
+	weaviateObject, err := client.Data().Creator().
+		WithClassName(AnalysisClass).
+		WithProperties(dataSchema).
+		Do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return string(weaviateObject.Object.ID), nil
+}
+
+// SetAnalysisResult stores the outcome of the analysis id.
+func (c *Client) SetAnalysisResult(ctx context.Context, id string, result AnalysisResult) error {
+	client := c.client
+
+	return client.Data().Updater().
+		WithMerge().
+		WithID(id).
+		WithClassName(AnalysisClass).
+		WithProperties(map[string]interface{}{
+			"status":  result.Status,
+			"summary": result.Summary,
+			"error":   result.Error,
+		}).
+		Do(ctx)
+}
+
+// CreateAnalysisReferences links the analysis of a part with the analysis of
+// the whole in both directions.
+func (c *Client) CreateAnalysisReferences(ctx context.Context, parentID string, partID string) error {
+	client := c.client
+
+	err := client.Data().ReferenceCreator().
+		WithClassName(AnalysisClass).
+		WithID(parentID).
+		WithReferenceProperty("hasPart").
+		WithReference(client.Data().ReferencePayloadBuilder().
+			WithClassName(AnalysisClass).
+			WithID(partID).
+			Payload()).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.Data().ReferenceCreator().
+		WithClassName(AnalysisClass).
+		WithID(partID).
+		WithReferenceProperty("partOf").
+		WithReference(client.Data().ReferencePayloadBuilder().
+			WithClassName(AnalysisClass).
+			WithID(parentID).
+			Payload()).
+		Do(ctx)
+}
+
+// RetrieveAnalysis returns the analysis id with its parts down to depth
+// levels below it. Parts that were deleted are left out.
+func (c *Client) RetrieveAnalysis(ctx context.Context, id string, depth int) (Analysis, error) {
+	analysis, ok, err := c.retrieveAnalysis(ctx, id, depth)
+	if err != nil {
+		return Analysis{}, err
+	}
+	if !ok {
+		return Analysis{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	return analysis, nil
+}
+
+func (c *Client) retrieveAnalysis(ctx context.Context, id string, depth int) (Analysis, bool, error) {
+	client := c.client
+
+	objects, err := client.Data().ObjectsGetter().
+		WithID(id).
+		WithClassName(AnalysisClass).
+		Do(ctx)
+	if err != nil {
+		var clientErr *fault.WeaviateClientError
+		if errors.As(err, &clientErr) && clientErr.StatusCode == http.StatusNotFound {
+			return Analysis{}, false, nil
+		}
+		return Analysis{}, false, err
+	}
+	if len(objects) == 0 {
+		return Analysis{}, false, nil
+	}
+
+	properties, _ := objects[0].Properties.(map[string]interface{})
+	analysis, err := ParseAnalysis(Object{Class: AnalysisClass, ID: id, Properties: properties})
+	if err != nil {
+		return Analysis{}, false, err
+	}
+
+	if depth <= 0 {
+		return analysis, true, nil
+	}
+
+	for _, partID := range analysis.PartIDs {
+		part, ok, err := c.retrieveAnalysis(ctx, partID, depth-1)
+		if err != nil {
+			return Analysis{}, false, err
+		}
+		if ok {
+			analysis.Parts = append(analysis.Parts, part)
+		}
+	}
+
+	return analysis, true, nil
+}
+
+// ParseAnalysis reads an analysis from the raw properties of an object.
+func ParseAnalysis(object Object) (Analysis, error) {
+	propertiesJSON, err := json.Marshal(object.Properties)
+	if err != nil {
+		return Analysis{}, err
+	}
+
+	var temp struct {
+		AnalysisObject
+		Language     codeanalysis.Language `json:"language"`
+		FunctionName string                `json:"functionName"`
+		FunctionKind codeanalysis.Kind     `json:"functionKind"`
+		Signature    string                `json:"signature"`
+		StartLine    int                   `json:"startLine"`
+		EndLine      int                   `json:"endLine"`
+	}
+	if err := json.Unmarshal(propertiesJSON, &temp); err != nil {
+		return Analysis{}, err
+	}
+
+	analysis := Analysis{
+		ID:             object.ID,
+		AnalysisObject: temp.AnalysisObject,
+		PartIDs:        object.ReferencedIDs("hasPart"),
+	}
+	if temp.Level == AnalysisFunction {
+		analysis.Function = &codeanalysis.Function{
+			Language:  temp.Language,
+			Kind:      temp.FunctionKind,
+			Name:      temp.FunctionName,
+			Signature: temp.Signature,
+			Lines:     codeanalysis.Span{Start: temp.StartLine, End: temp.EndLine},
+		}
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,106 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate"
+)
+
+// Config holds the connection settings of the Weaviate client.
+type Config struct {
+	Host   string
+	Scheme string
+	APIKey string
+
+	// Timeout bounds every single request to Weaviate.
+	Timeout time.Duration
+	// StartupTimeout is how long NewClient waits for Weaviate to become ready.
+	StartupTimeout time.Duration
+	// MaxIdleConns is the number of keep-alive connections held open.
+	MaxIdleConns int
+}
+
+func (cfg Config) Validate() error {
+	if cfg.Host == "" {
+		return errors.New("weaviate host must not be empty")
+	}
+	if cfg.Scheme != "http" && cfg.Scheme != "https" {
+		return fmt.Errorf("weaviate scheme must be http or https, got %q", cfg.Scheme)
+	}
+	if cfg.Timeout < 0 || cfg.StartupTimeout < 0 {
+		return errors.New("weaviate timeouts must not be negative")
+	}
+	if cfg.MaxIdleConns < 0 {
+		return errors.New("weaviate max idle connections must not be negative")
+	}
+
+	return nil
+}
+
+// Client is the Store backed by a Weaviate instance. It is safe for concurrent
+// use and should be created once and shared.
+type Client struct {
+	client     *weaviate.Client
+	httpClient *http.Client
+}
+
+var _ Store = (*Client)(nil)
+
+// NewClient validates cfg and connects to Weaviate.
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConns
+	}
+
+	httpClient := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}
+
+	headers := map[string]string{}
+	if cfg.APIKey != "" {
+		headers["authorization"] = "Bearer " + cfg.APIKey
+	}
+
+	client, err := weaviate.NewClient(weaviate.Config{
+		Host:             cfg.Host,
+		Scheme:           cfg.Scheme,
+		ConnectionClient: httpClient,
+		Headers:          headers,
+		StartupTimeout:   cfg.StartupTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{client: client, httpClient: httpClient}, nil
+}
+
+// Health reports an error if Weaviate is not ready to serve requests.
+func (c *Client) Health(ctx context.Context) error {
+	ready, err := c.client.Misc().ReadyChecker().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return errors.New("weaviate is not ready")
+	}
+
+	return nil
+}
+
+// Close releases the idle connections held by the client.
+func (c *Client) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
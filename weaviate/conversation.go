@@ -0,0 +1,225 @@
+package weaviate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// MessageClass holds the turns of the follow-up conversations about prompts.
+const MessageClass = "Message"
+
+// Roles of the turns of a conversation, as used by the chat API.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// MessageObject holds the properties of a turn of a conversation. Position
+// orders the turns of a conversation, starting at zero. Questions take even
+// positions and their answers the odd position behind them.
+type MessageObject struct {
+	PromptID string `json:"promptID"`
+	Position int    `json:"position"`
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	Model    string `json:"model,omitempty"`
+}
+
+// Message is a stored turn together with its votes.
+type Message struct {
+	ID string `json:"id"`
+	MessageObject
+	Rank      int `json:"rank"`
+	Upvotes   int `json:"upvotes"`
+	Downvotes int `json:"downvotes"`
+}
+
+// maxConversationLength caps the number of turns retrieved for a prompt.
+const maxConversationLength = 1000
+
+var messageProperties = []*models.Property{
+	skippedProperty("promptID", "text", "The ID of the prompt the conversation is about", models.PropertyTokenizationField),
+	skippedProperty("ofPrompt", PromptClass, "The prompt the conversation is about", ""),
+	skippedProperty("position", "int", "The position of the turn within the conversation", ""),
+	skippedProperty("role", "text", "Whether the user or the model wrote the turn", models.PropertyTokenizationField),
+	{
+		DataType:    []string{"text"},
+		Description: "The text of the turn",
+		Name:        "content",
+	},
+	skippedProperty("model", "text", "The model which generated the answer", models.PropertyTokenizationField),
+	skippedProperty("rank", "int", "The rank derived from the votes of the turn", ""),
+	upvotesProperty,
+	downvotesProperty,
+}
+
+// messageClass describes the Message class.
+var messageClass = &models.Class{
+	Class:       MessageClass,
+	Description: "This class contains the turns of follow-up conversations about prompts",
+	Vectorizer:  "text2vec-transformers",
+	Properties:  messageProperties,
+}
+
+// CreateMessageObject stores a turn under the ID derived from its position. It
+// fails with a conflict if the position is taken.
+func (c *Client) CreateMessageObject(ctx context.Context, message MessageObject) (string, error) {
+	client := c.client
+
+	dataSchema := map[string]interface{}{
+		"promptID":  message.PromptID,
+		"ofPrompt":  []interface{}{Beacon(PromptClass, message.PromptID)},
+		"position":  message.Position,
+		"role":      message.Role,
+		"content":   message.Content,
+		"model":     message.Model,
+		"rank":      0,
+		"upvotes":   0,
+		"downvotes": 0,
+	}
+
+	weaviateObject, err := client.Data().Creator().
+		WithClassName(MessageClass).
+		WithID(MessageUUID(message.PromptID, message.Position)).
+		WithProperties(dataSchema).
+		Do(ctx)
+	if errkind.Is(err, errkind.Conflict) {
+		return "", errkind.New(errkind.Conflict, "position %d of the conversation about prompt %s is taken", message.Position, message.PromptID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(weaviateObject.Object.ID), nil
+}
+
+// SetVotesMessage overwrites the votes and the rank of a turn.
+func (c *Client) SetVotesMessage(ctx context.Context, id string, votes Votes) error {
+	client := c.client
+
+	return client.Data().Updater().
+		WithMerge().
+		WithID(id).
+		WithClassName(MessageClass).
+		WithProperties(map[string]interface{}{
+			"rank":      votes.Rank,
+			"upvotes":   votes.Upvotes,
+			"downvotes": votes.Downvotes,
+		}).
+		Do(ctx)
+}
+
+func (c *Client) RetrieveMessage(ctx context.Context, id string) (Message, error) {
+	client := c.client
+
+	objects, err := client.Data().ObjectsGetter().
+		WithID(id).
+		WithClassName(MessageClass).
+		Do(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(objects) == 0 {
+		return Message{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	properties, _ := objects[0].Properties.(map[string]interface{})
+	return ParseMessage(Object{Class: MessageClass, ID: id, Properties: properties})
+}
+
+// RetrieveConversation returns the turns of the conversation about promptID
+// in order.
+func (c *Client) RetrieveConversation(ctx context.Context, promptID string) ([]Message, error) {
+	client := c.client
+
+	fields := []graphql.Field{
+		{Name: "_additional", Fields: []graphql.Field{
+			{Name: "id"},
+		}},
+		{Name: "promptID"},
+		{Name: "position"},
+		{Name: "role"},
+		{Name: "content"},
+		{Name: "model"},
+		{Name: "rank"},
+		{Name: "upvotes"},
+		{Name: "downvotes"},
+	}
+
+	where := filters.Where().
+		WithPath([]string{"promptID"}).
+		WithOperator(filters.Equal).
+		WithValueText(promptID)
+
+	positionAsc := graphql.Sort{
+		Path: []string{"position"}, Order: graphql.Asc,
+	}
+
+	result, err := client.GraphQL().Get().
+		WithClassName(MessageClass).
+		WithFields(fields...).
+		WithWhere(where).
+		WithSort(positionAsc).
+		WithLimit(maxConversationLength).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	getMessage, ok := result.Data["Get"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
+	}
+
+	messageData, ok := getMessage[MessageClass].([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Message' field not found")
+	}
+
+	messages := make([]Message, 0, len(messageData))
+	for _, message := range messageData {
+		messageMap, ok := message.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected response format: message data is not a map")
+		}
+
+		id, err := ExtractID(messageMap)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := ParseMessage(Object{Class: MessageClass, ID: id, Properties: messageMap})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, parsed)
+	}
+
+	return messages, nil
+}
+
+// ParseMessage reads a turn from the raw properties of an object.
+func ParseMessage(object Object) (Message, error) {
+	propertiesJSON, err := json.Marshal(object.Properties)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var message Message
+	if err := json.Unmarshal(propertiesJSON, &message); err != nil {
+		return Message{}, err
+	}
+	message.ID = object.ID
+
+	return message, nil
+}
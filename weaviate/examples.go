@@ -0,0 +1,120 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+)
+
+// Example is an answered prompt about similar code, used as few-shot context
+// when generating the answer to a new prompt.
+type Example struct {
+	PromptID  string `json:"promptID"`
+	Instruct  string `json:"instruct,omitempty"`
+	Code      string `json:"-"`
+	Response  string `json:"-"`
+	Upvotes   int    `json:"upvotes,omitempty"`
+	Downvotes int    `json:"downvotes,omitempty"`
+	// Certainty is the similarity of the code of the example to the code of
+	// the new prompt, Score the rating of its answer.
+	Certainty float64 `json:"certainty,omitempty"`
+	Score     float64 `json:"score,omitempty"`
+}
+
+// SimilarPrompts returns up to limit answered prompts whose code is most
+// similar to code, by descending certainty.
+func (c *Client) SimilarPrompts(ctx context.Context, code string, limit int) ([]Example, error) {
+	client := c.client
+
+	fields := []graphql.Field{
+		{Name: "_additional", Fields: []graphql.Field{
+			{Name: "id"},
+			{Name: "certainty"},
+		}},
+		{Name: "code"},
+		{Name: "instruct"},
+		{Name: "rank"},
+		{Name: "upvotes"},
+		{Name: "downvotes"},
+		{Name: "hasResponse", Fields: []graphql.Field{
+			{Name: "... on Response", Fields: []graphql.Field{
+				{Name: "response"},
+			}},
+		}},
+	}
+
+	withNearText := client.GraphQL().NearTextArgBuilder().
+		WithConcepts([]string{code}).
+		WithCertainty(0.8)
+
+	answered := filters.Where().
+		WithPath([]string{"hasResponse"}).
+		WithOperator(filters.GreaterThan).
+		WithValueInt(0)
+
+	result, err := client.GraphQL().Get().
+		WithClassName("Prompt").
+		WithFields(fields...).
+		WithNearText(withNearText).
+		WithWhere(answered).
+		WithLimit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	getPrompt, ok := result.Data["Get"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
+	}
+
+	promptData, ok := getPrompt["Prompt"].([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Prompt' field not found")
+	}
+
+	examples := make([]Example, 0, len(promptData))
+	for _, prompt := range promptData {
+		promptMap, ok := prompt.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected response format: prompt data is not a map")
+		}
+
+		id, err := ExtractID(promptMap)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := ExtractResponse(promptMap)
+		if err != nil {
+			return nil, err
+		}
+
+		votes, err := ExtractVotes(promptMap)
+		if err != nil {
+			return nil, err
+		}
+
+		example := Example{
+			PromptID:  id,
+			Response:  response,
+			Upvotes:   votes.Upvotes,
+			Downvotes: votes.Downvotes,
+		}
+		example.Code, _ = promptMap["code"].(string)
+		example.Instruct, _ = promptMap["instruct"].(string)
+		if additional, ok := promptMap["_additional"].(map[string]interface{}); ok {
+			example.Certainty, _ = additional["certainty"].(float64)
+		}
+
+		examples = append(examples, example)
+	}
+
+	return examples, nil
+}
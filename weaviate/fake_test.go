@@ -0,0 +1,238 @@
+package weaviate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// fakeWeaviate serves the REST endpoints the client uses from memory, so the
+// code paths that need more than the memory store can be tested.
+type fakeWeaviate struct {
+	mu      sync.Mutex
+	classes map[string]*models.Class
+	objects map[string]map[string]*models.Object
+
+	// failBatch rejects the objects of this class in batches, the others are
+	// stored like Weaviate does when a batch fails in part.
+	failBatch string
+	// beforeBatch runs before a batch is stored, as a concurrent write would.
+	beforeBatch func()
+}
+
+func newFakeWeaviate(t *testing.T) (*fakeWeaviate, *Client) {
+	t.Helper()
+
+	fake := &fakeWeaviate{
+		classes: map[string]*models.Class{},
+		objects: map[string]map[string]*models.Object{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	client, err := NewClient(Config{Host: u.Host, Scheme: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return fake, client
+}
+
+func (f *fakeWeaviate) object(class string, id string) *models.Object {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.objects[class][id]
+}
+
+func (f *fakeWeaviate) put(object *models.Object) {
+	if f.objects[object.Class] == nil {
+		f.objects[object.Class] = map[string]*models.Object{}
+	}
+	f.objects[object.Class][string(object.ID)] = object
+}
+
+func (f *fakeWeaviate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1"), "/"), "/")
+	switch {
+	case path[0] == "meta":
+		writeJSON(w, http.StatusOK, map[string]string{"version": "1.24.1"})
+	case path[0] == "schema":
+		f.serveSchema(w, r, path[1:])
+	case path[0] == "batch" && r.Method == http.MethodPost:
+		f.serveBatch(w, r)
+	case path[0] == "objects" && len(path) == 1:
+		f.serveObjects(w, r)
+	case path[0] == "objects" && len(path) == 3:
+		f.serveObject(w, r, path[1], path[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWeaviate) serveSchema(w http.ResponseWriter, r *http.Request, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == http.MethodPost:
+		var class models.Class
+		if err := json.NewDecoder(r.Body).Decode(&class); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if f.classes[class.Class] != nil {
+			writeError(w, http.StatusUnprocessableEntity, "class name "+class.Class+" already exists")
+			return
+		}
+		f.classes[class.Class] = &class
+		writeJSON(w, http.StatusOK, class)
+	case len(path) == 1 && r.Method == http.MethodGet:
+		class := f.classes[path[0]]
+		if class == nil {
+			writeError(w, http.StatusNotFound, "class not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, class)
+	case len(path) == 2 && path[1] == "properties" && r.Method == http.MethodPost:
+		class := f.classes[path[0]]
+		if class == nil {
+			writeError(w, http.StatusNotFound, "class not found")
+			return
+		}
+		var property models.Property
+		if err := json.NewDecoder(r.Body).Decode(&property); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, existing := range class.Properties {
+			if existing.Name == property.Name {
+				writeError(w, http.StatusUnprocessableEntity, "property "+property.Name+" already exists")
+				return
+			}
+		}
+		class.Properties = append(class.Properties, &property)
+		writeJSON(w, http.StatusOK, property)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWeaviate) serveBatch(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Objects []*models.Object `json:"objects"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.beforeBatch != nil {
+		f.beforeBatch()
+	}
+
+	results := make([]models.ObjectsGetResponse, 0, len(body.Objects))
+	for _, object := range body.Objects {
+		result := models.ObjectsGetResponse{Object: *object, Result: &models.ObjectsGetResponseAO2Result{}}
+		if object.Class == f.failBatch {
+			result.Result.Errors = &models.ErrorResponse{Error: []*models.ErrorResponseErrorItems0{{Message: "rejected"}}}
+		} else {
+			f.put(object)
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (f *fakeWeaviate) serveObjects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		objects := f.objects[query.Get("class")]
+		ids := make([]string, 0, len(objects))
+		for id := range objects {
+			if id > query.Get("after") {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(ids) {
+			ids = ids[:limit]
+		}
+
+		list := models.ObjectsListResponse{Objects: []*models.Object{}}
+		for _, id := range ids {
+			list.Objects = append(list.Objects, objects[id])
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		var object models.Object
+		if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if f.objects[object.Class][string(object.ID)] != nil {
+			writeError(w, http.StatusUnprocessableEntity, "id '"+string(object.ID)+"' already exists")
+			return
+		}
+		f.put(&object)
+		writeJSON(w, http.StatusOK, object)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWeaviate) serveObject(w http.ResponseWriter, r *http.Request, class string, id string) {
+	object := f.objects[class][id]
+	if object == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, object)
+	case http.MethodDelete:
+		delete(f.objects[class], id)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch:
+		var update models.Object
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		properties, _ := object.Properties.(map[string]interface{})
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+		updated, _ := update.Properties.(map[string]interface{})
+		for name, value := range updated {
+			properties[name] = value
+		}
+		object.Properties = properties
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, models.ErrorResponse{Error: []*models.ErrorResponseErrorItems0{{Message: message}}})
+}
@@ -0,0 +1,131 @@
+package weaviate
+
+import (
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/fingerprint"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+)
+
+// MatchMode selects how stored code is compared with the code of a request.
+type MatchMode string
+
+const (
+	// MatchExact only matches identical code.
+	MatchExact MatchMode = "exact"
+	// MatchNormalized matches code that only differs in whitespace, line
+	// endings and comments.
+	MatchNormalized MatchMode = "normalized"
+	// MatchFuzzy matches near duplicates by the similarity of their MinHash
+	// signatures.
+	MatchFuzzy MatchMode = "fuzzy"
+)
+
+// ParseMatchMode accepts exact, normalized and fuzzy. The empty string selects
+// normalized matching.
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch MatchMode(s) {
+	case "":
+		return MatchNormalized, nil
+	case MatchExact, MatchNormalized, MatchFuzzy:
+		return MatchMode(s), nil
+	default:
+		return "", errkind.New(errkind.Invalid, "invalid match mode %q, expected exact, normalized or fuzzy", s)
+	}
+}
+
+// CodeMatch selects the prompts whose code matches Code in the given mode.
+// Function and Repository additionally restrict the match to prompts about
+// the function of that name in that repository. If only Function is set, the
+// code is not compared at all.
+type CodeMatch struct {
+	Code       string
+	Mode       MatchMode
+	Function   string
+	Repository string
+}
+
+// Fingerprint holds the values derived from the code of a prompt that are
+// used for matching.
+type Fingerprint struct {
+	CodeHash   string
+	Signature  fingerprint.Signature
+	Function   codeanalysis.Function
+	Repository string
+}
+
+// NewFingerprint computes the fingerprint of code, which was taken from the
+// blob gitURL points to.
+func NewFingerprint(code string, gitURL string) Fingerprint {
+	repository, file := codeanalysis.ParseGitURL(gitURL)
+	// code without a definition still has a language
+	function, _ := codeanalysis.Analyze(file, code)
+
+	return Fingerprint{
+		CodeHash:   fingerprint.CodeHash(code),
+		Signature:  fingerprint.MinHash(code),
+		Function:   function,
+		Repository: repository,
+	}
+}
+
+// Matches reports whether a prompt with the given code and fingerprint is
+// selected by m.
+func (m CodeMatch) Matches(code string, fp Fingerprint) bool {
+	if m.Function != "" && fp.Function.Name != m.Function {
+		return false
+	}
+	if m.Repository != "" && fp.Repository != m.Repository {
+		return false
+	}
+	if m.Code == "" && m.Function != "" {
+		return true
+	}
+
+	switch m.Mode {
+	case MatchExact:
+		return code == m.Code
+	case MatchFuzzy:
+		return fingerprint.Similarity(fingerprint.MinHash(m.Code), fp.Signature) >= fingerprint.FuzzyThreshold
+	default:
+		return fp.CodeHash == fingerprint.CodeHash(m.Code)
+	}
+}
+
+// where narrows a query down to the candidates for m. Exact and fuzzy
+// candidates still have to be checked with Matches.
+func (m CodeMatch) where() *filters.WhereBuilder {
+	var operands []*filters.WhereBuilder
+	equal := func(property string, value string) {
+		operands = append(operands, filters.Where().
+			WithPath([]string{property}).
+			WithOperator(filters.Equal).
+			WithValueText(value))
+	}
+
+	if m.Function != "" {
+		equal("functionName", m.Function)
+	}
+	if m.Repository != "" {
+		equal("repository", m.Repository)
+	}
+
+	bands := fingerprint.MinHash(m.Code).Bands()
+	switch {
+	case m.Code == "" && m.Function != "":
+	case m.Mode == MatchFuzzy && len(bands) > 0:
+		operands = append(operands, filters.Where().
+			WithPath([]string{"minhashBands"}).
+			WithOperator(filters.ContainsAny).
+			WithValueText(bands...))
+	default:
+		equal("codeHash", fingerprint.CodeHash(m.Code))
+	}
+
+	if len(operands) == 1 {
+		return operands[0]
+	}
+	return filters.Where().
+		WithOperator(filters.And).
+		WithOperands(operands)
+}
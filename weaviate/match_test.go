@@ -0,0 +1,96 @@
+package weaviate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/fingerprint"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+const (
+	halfCode    = "def half(n):\n    return n // 2  # rounded down\n"
+	quarterCode = "def half(n):\n    return n // 4\n"
+)
+
+func TestMatches(t *testing.T) {
+	stored := halfCode
+	fp := NewFingerprint(stored, "https://github.com/owner/repo/blob/main/calc.py#L1-L2")
+
+	tests := []struct {
+		name  string
+		match CodeMatch
+		want  bool
+	}{
+		{name: "exact", match: CodeMatch{Code: stored, Mode: MatchExact}, want: true},
+		{name: "exact without comment", match: CodeMatch{Code: "def half(n):\n    return n // 2\n", Mode: MatchExact}},
+		{name: "normalized without comment", match: CodeMatch{Code: "def half(n):\n    return n // 2\n", Mode: MatchNormalized}, want: true},
+		{name: "normalized after floor division", match: CodeMatch{Code: quarterCode, Mode: MatchNormalized}},
+		{name: "fuzzy", match: CodeMatch{Code: "def half(n):\n    return n // 2\n", Mode: MatchFuzzy}, want: true},
+		{name: "function", match: CodeMatch{Function: "half"}, want: true},
+		{name: "other function", match: CodeMatch{Function: "double"}},
+		{name: "repository", match: CodeMatch{Code: stored, Repository: "https://github.com/owner/repo"}, want: true},
+		{name: "other repository", match: CodeMatch{Code: stored, Repository: "https://github.com/owner/other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.Matches(stored, fp); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhere(t *testing.T) {
+	bands := fingerprint.MinHash(halfCode).Bands()
+	text := func(s string) *string { return &s }
+
+	tests := []struct {
+		name  string
+		match CodeMatch
+		want  *models.WhereFilter
+	}{
+		{
+			name:  "normalized",
+			match: CodeMatch{Code: halfCode, Mode: MatchNormalized},
+			want:  &models.WhereFilter{Operator: "Equal", Path: []string{"codeHash"}, ValueText: text(fingerprint.CodeHash(halfCode))},
+		},
+		{
+			name:  "exact narrows by hash",
+			match: CodeMatch{Code: halfCode, Mode: MatchExact},
+			want:  &models.WhereFilter{Operator: "Equal", Path: []string{"codeHash"}, ValueText: text(fingerprint.CodeHash(halfCode))},
+		},
+		{
+			name:  "fuzzy looks up the bands",
+			match: CodeMatch{Code: halfCode, Mode: MatchFuzzy},
+			want:  &models.WhereFilter{Operator: "ContainsAny", Path: []string{"minhashBands"}, ValueTextArray: bands},
+		},
+		{
+			name:  "function only",
+			match: CodeMatch{Function: "half"},
+			want:  &models.WhereFilter{Operator: "Equal", Path: []string{"functionName"}, ValueText: text("half")},
+		},
+		{
+			name:  "function in repository",
+			match: CodeMatch{Code: halfCode, Function: "half", Repository: "repo"},
+			want: &models.WhereFilter{Operator: "And", Operands: []*models.WhereFilter{
+				{Operator: "Equal", Path: []string{"functionName"}, ValueText: text("half")},
+				{Operator: "Equal", Path: []string{"repository"}, ValueText: text("repo")},
+				{Operator: "Equal", Path: []string{"codeHash"}, ValueText: text(fingerprint.CodeHash(halfCode))},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.match.where().Build(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("where() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if other := (CodeMatch{Code: quarterCode, Mode: MatchNormalized}).where().Build(); *other.ValueText == fingerprint.CodeHash(halfCode) {
+		t.Errorf("code differing after // looks up the same hash")
+	}
+}
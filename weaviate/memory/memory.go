@@ -0,0 +1,1185 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/llm"
+	"github.com/rwth-acis/modernizer/ranking"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+// Embedder turns text into a vector used for similarity search.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbedderFunc adapts a plain function to the Embedder interface.
+type EmbedderFunc func(ctx context.Context, text string) ([]float32, error)
+
+func (f EmbedderFunc) Embed(ctx context.Context, text string) ([]float32, error) {
+	return f(ctx, text)
+}
+
+// ProviderEmbedder embeds texts with the given model of an LLM provider.
+func ProviderEmbedder(provider llm.LLMProvider, model string) Embedder {
+	return EmbedderFunc(func(ctx context.Context, text string) ([]float32, error) {
+		return provider.Embed(ctx, model, text)
+	})
+}
+
+// minCertainty mirrors the certainty used for nearText searches in Weaviate.
+const minCertainty = 0.8
+
+type prompt struct {
+	id         string
+	properties weaviate.PromptObject
+	rank       int
+	upvotes    int
+	downvotes  int
+	created    int
+	// fingerprint is derived from the code when the prompt is created
+	fingerprint weaviate.Fingerprint
+	// vector embeds the code, which is the only vectorized property
+	vector []float32
+
+	responseID        string
+	semanticMeaningID string
+}
+
+type analysis struct {
+	weaviate.AnalysisObject
+	partIDs  []string
+	parentID string
+}
+
+type semanticMeaning struct {
+	meaning   string
+	vector    []float32
+	promptIDs []string
+}
+
+// Store is an in-memory weaviate.Store. Similarity search computes the cosine
+// similarity between vectors produced by the configured Embedder.
+type Store struct {
+	mu       sync.RWMutex
+	embedder Embedder
+	rng      *rand.Rand
+
+	prompts   map[string]*prompt
+	responses map[string]string
+	meanings  map[string]*semanticMeaning
+	analyses  map[string]*analysis
+	messages  map[string]*weaviate.Message
+	// suggestions are never modified, so they are stored by value
+	suggestions map[string]weaviate.Suggestion
+	created     int
+	// createdAt holds the creation time of every object by ID
+	createdAt map[string]time.Time
+}
+
+var _ weaviate.Store = (*Store)(nil)
+
+// New creates an empty store. A nil embedder falls back to the deterministic
+// bag-of-words vectors of the fake LLM provider.
+func New(embedder Embedder) *Store {
+	if embedder == nil {
+		embedder = ProviderEmbedder(llm.NewFake(), "")
+	}
+
+	s := &Store{
+		embedder: embedder,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	s.reset()
+
+	return s
+}
+
+func (s *Store) reset() {
+	s.prompts = make(map[string]*prompt)
+	s.responses = make(map[string]string)
+	s.meanings = make(map[string]*semanticMeaning)
+	s.analyses = make(map[string]*analysis)
+	s.messages = make(map[string]*weaviate.Message)
+	s.suggestions = make(map[string]weaviate.Suggestion)
+	s.createdAt = make(map[string]time.Time)
+}
+
+func (s *Store) InitSchema(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) DeleteAllClasses(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+}
+
+func (s *Store) Health(ctx context.Context) error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) CreatePromptWithResponse(ctx context.Context, properties weaviate.PromptObject, response string) (string, bool, error) {
+	vector, err := s.embedder.Embed(ctx, properties.Code)
+	if err != nil {
+		return "", false, err
+	}
+	properties.Examples = append([]string(nil), properties.Examples...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := weaviate.PromptUUID(properties, response)
+	if _, ok := s.prompts[id]; ok {
+		return id, false, nil
+	}
+
+	responseID := weaviate.ResponseUUID(id)
+	now := time.Now().UTC()
+
+	s.responses[responseID] = response
+	s.createdAt[responseID] = now
+
+	s.created++
+	s.prompts[id] = &prompt{
+		id:          id,
+		properties:  properties,
+		rank:        1,
+		created:     s.created,
+		fingerprint: weaviate.NewFingerprint(properties.Code, properties.GitURL),
+		vector:      vector,
+		responseID:  responseID,
+	}
+	s.createdAt[id] = now
+
+	return id, true, nil
+}
+
+func (s *Store) ReplaceResponse(ctx context.Context, promptID string, response string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prompts[promptID]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", promptID)
+	}
+
+	responseID := weaviate.ResponseUUID(promptID)
+	if _, ok := s.responses[responseID]; !ok {
+		s.createdAt[responseID] = time.Now().UTC()
+	}
+	s.responses[responseID] = response
+	p.responseID = responseID
+
+	return nil
+}
+
+func (s *Store) ClearResponsesPrompt(ctx context.Context, promptID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prompts[promptID]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", promptID)
+	}
+	p.responseID = ""
+
+	return nil
+}
+
+func (s *Store) SetSemanticMeaningPrompt(ctx context.Context, promptID string, meaning string) error {
+	vector, err := s.embedder.Embed(ctx, meaning)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prompts[promptID]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", promptID)
+	}
+
+	id := weaviate.SemanticMeaningUUID(promptID)
+	if _, ok := s.meanings[id]; !ok {
+		s.createdAt[id] = time.Now().UTC()
+	}
+	s.meanings[id] = &semanticMeaning{meaning: meaning, vector: vector, promptIDs: []string{promptID}}
+	p.semanticMeaningID = id
+
+	return nil
+}
+
+func (s *Store) SetVotesPrompt(ctx context.Context, id string, votes weaviate.Votes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prompts[id]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	p.rank = votes.Rank
+	p.upvotes = votes.Upvotes
+	p.downvotes = votes.Downvotes
+
+	return nil
+}
+
+func (s *Store) SetFingerprintPrompt(ctx context.Context, id string, code string, gitURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.prompts[id]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	p.fingerprint = weaviate.NewFingerprint(code, gitURL)
+
+	return nil
+}
+
+func (s *Store) RetrieveProperties(ctx context.Context, id string) (weaviate.PromptProperties, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.prompts[id]
+	if !ok {
+		return weaviate.PromptProperties{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	response, ok := s.response(p)
+	if !ok {
+		return weaviate.PromptProperties{}, weaviate.ErrNoResponse
+	}
+
+	var function *codeanalysis.Function
+	if p.fingerprint.Function.Language != "" {
+		fn := p.fingerprint.Function
+		function = &fn
+	}
+
+	return weaviate.PromptProperties{
+		Code:        p.properties.Code,
+		HasResponse: response,
+		Instruct:    p.properties.Instruct,
+		Rank:        p.rank,
+		Upvotes:     p.upvotes,
+		Downvotes:   p.downvotes,
+		GitURL:      p.properties.GitURL,
+		Model:       p.properties.Model,
+		Function:    function,
+		Repository:  p.fingerprint.Repository,
+		Examples:    append([]string(nil), p.properties.Examples...),
+	}, nil
+}
+
+func (s *Store) RetrievePromptCount(ctx context.Context, match weaviate.CodeMatch, model string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.match(match, "", model)), nil
+}
+
+func (s *Store) RetrieveResponseByID(ctx context.Context, id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.prompts[id]
+	if !ok {
+		return "", errkind.New(errkind.NotFound, "unexpected response format: 'Prompt' field not found or empty list")
+	}
+	response, ok := s.response(p)
+	if !ok {
+		return "", weaviate.ErrNoResponse
+	}
+
+	return response, nil
+}
+
+func (s *Store) ResponseList(ctx context.Context, match weaviate.CodeMatch, instructType string, model string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prompts := s.match(match, instructType, model)
+	if len(prompts) == 0 {
+		return nil, errkind.New(errkind.NotFound, "no prompt found")
+	}
+
+	var RankIDs []string
+	for _, p := range prompts {
+		RankIDs = append(RankIDs, p.id)
+	}
+
+	return RankIDs, nil
+}
+
+func (s *Store) RetrieveBestResponse(ctx context.Context, match weaviate.CodeMatch, model string, strategy ranking.Strategy) (weaviate.ResponseData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prompts := s.answered(s.match(match, "", model))
+	if len(prompts) == 0 {
+		return weaviate.ResponseData{}, errkind.New(errkind.NotFound, "no prompt found")
+	}
+
+	var highestScore float64
+	var highest []*prompt
+	for _, p := range prompts {
+		upvotes, downvotes := p.upvotes, p.downvotes
+		if upvotes == 0 && downvotes == 0 {
+			upvotes, downvotes = ranking.LegacyVotes(p.rank)
+		}
+
+		score := strategy.Score(upvotes, downvotes)
+		if len(highest) == 0 || score > highestScore {
+			highestScore = score
+			highest = []*prompt{p}
+		} else if score == highestScore {
+			highest = append(highest, p)
+		}
+	}
+
+	selected := highest[s.rng.Intn(len(highest))]
+
+	responseData, err := s.responseData(selected)
+	if err != nil {
+		return weaviate.ResponseData{}, err
+	}
+
+	responseData.Upvotes, responseData.Downvotes = selected.upvotes, selected.downvotes
+	if responseData.Upvotes == 0 && responseData.Downvotes == 0 {
+		responseData.Upvotes, responseData.Downvotes = ranking.LegacyVotes(selected.rank)
+	}
+	responseData.Score = &highestScore
+	responseData.Strategy = strategy.Name()
+
+	return responseData, nil
+}
+
+func (s *Store) RetrieveRandomResponse(ctx context.Context, match weaviate.CodeMatch, model string) (weaviate.ResponseData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prompts := s.answered(s.match(match, "", model))
+	if len(prompts) == 0 {
+		return weaviate.ResponseData{}, errkind.New(errkind.NotFound, "no prompt found")
+	}
+
+	return s.responseData(prompts[s.rng.Intn(len(prompts))])
+}
+
+func (s *Store) RetrieveHasSemanticMeaning(ctx context.Context, match weaviate.CodeMatch) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.match(match, "", "") {
+		m, ok := s.meanings[p.semanticMeaningID]
+		if ok && m.meaning != "" {
+			return m.meaning, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *Store) GetInstructTypes(ctx context.Context, match weaviate.CodeMatch, model string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	explanationSet := make(map[string]struct{})
+	var explanationStrings []string
+	for _, p := range s.match(match, "", model) {
+		if _, seen := explanationSet[p.properties.InstructType]; seen {
+			continue
+		}
+		explanationSet[p.properties.InstructType] = struct{}{}
+		explanationStrings = append(explanationStrings, p.properties.InstructType)
+	}
+
+	return explanationStrings, nil
+}
+
+func (s *Store) ListPrompts(ctx context.Context, after string, limit int) ([]weaviate.PromptSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prompts := make([]*prompt, 0, len(s.prompts))
+	for _, p := range s.prompts {
+		prompts = append(prompts, p)
+	}
+
+	// like Weaviate's cursor API the prompts are ordered by ID
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].id < prompts[j].id
+	})
+
+	var summaries []weaviate.PromptSummary
+	for _, p := range prompts {
+		if p.id <= after {
+			continue
+		}
+		if len(summaries) == limit {
+			break
+		}
+
+		// references to deleted objects count as missing, like in Weaviate
+		_, hasResponse := s.responses[p.responseID]
+		_, hasSemanticMeaning := s.meanings[p.semanticMeaningID]
+		summaries = append(summaries, weaviate.PromptSummary{
+			ID:                 p.id,
+			PromptObject:       p.properties,
+			HasResponse:        hasResponse,
+			HasSemanticMeaning: hasSemanticMeaning,
+			HasFingerprint:     p.fingerprint.Function.Language != "",
+		})
+	}
+
+	return summaries, nil
+}
+
+func (s *Store) GetSimilarSemanticMeaning(ctx context.Context, meaning string) ([]string, error) {
+	vector, err := s.embedder.Embed(ctx, meaning)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type candidate struct {
+		certainty float64
+		gitURL    string
+	}
+
+	var candidates []candidate
+	for _, m := range s.meanings {
+		certainty := (1 + cosine(vector, m.vector)) / 2
+		if certainty < minCertainty || len(m.promptIDs) == 0 {
+			continue
+		}
+
+		p, ok := s.prompts[m.promptIDs[0]]
+		if !ok {
+			continue
+		}
+
+		candidates = append(candidates, candidate{certainty: certainty, gitURL: p.properties.GitURL})
+	}
+
+	if len(candidates) == 0 {
+		return nil, errkind.New(errkind.NotFound, "unexpected response format: 'Prompt' field not found or empty list")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].certainty > candidates[j].certainty
+	})
+
+	gitURLs := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		gitURLs = append(gitURLs, c.gitURL)
+	}
+
+	return gitURLs, nil
+}
+
+func (s *Store) SimilarPrompts(ctx context.Context, code string, limit int) ([]weaviate.Example, error) {
+	vector, err := s.embedder.Embed(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var examples []weaviate.Example
+	for _, p := range s.prompts {
+		response, ok := s.responses[p.responseID]
+		if !ok {
+			continue
+		}
+
+		certainty := (1 + cosine(vector, p.vector)) / 2
+		if certainty < minCertainty {
+			continue
+		}
+
+		examples = append(examples, weaviate.Example{
+			PromptID:  p.id,
+			Instruct:  p.properties.Instruct,
+			Code:      p.properties.Code,
+			Response:  response,
+			Upvotes:   p.upvotes,
+			Downvotes: p.downvotes,
+			Certainty: certainty,
+		})
+	}
+
+	sort.Slice(examples, func(i, j int) bool {
+		if examples[i].Certainty != examples[j].Certainty {
+			return examples[i].Certainty > examples[j].Certainty
+		}
+		return examples[i].PromptID < examples[j].PromptID
+	})
+	if len(examples) > limit {
+		examples = examples[:limit]
+	}
+
+	return examples, nil
+}
+
+func (s *Store) CreateMessageObject(ctx context.Context, message weaviate.MessageObject) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prompts[message.PromptID]; !ok {
+		return "", errkind.New(errkind.NotFound, "no object found with ID: %s", message.PromptID)
+	}
+
+	id := weaviate.MessageUUID(message.PromptID, message.Position)
+	if _, ok := s.messages[id]; ok {
+		return "", errkind.New(errkind.Conflict, "position %d of the conversation about prompt %s is taken", message.Position, message.PromptID)
+	}
+	s.messages[id] = &weaviate.Message{ID: id, MessageObject: message}
+	s.createdAt[id] = time.Now().UTC()
+
+	return id, nil
+}
+
+func (s *Store) SetVotesMessage(ctx context.Context, id string, votes weaviate.Votes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	m.Rank = votes.Rank
+	m.Upvotes = votes.Upvotes
+	m.Downvotes = votes.Downvotes
+
+	return nil
+}
+
+func (s *Store) RetrieveMessage(ctx context.Context, id string) (weaviate.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return weaviate.Message{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	return *m, nil
+}
+
+func (s *Store) RetrieveConversation(ctx context.Context, promptID string) ([]weaviate.Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var messages []weaviate.Message
+	for _, m := range s.messages {
+		if m.PromptID == promptID {
+			messages = append(messages, *m)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].Position != messages[j].Position {
+			return messages[i].Position < messages[j].Position
+		}
+		return s.createdAt[messages[i].ID].Before(s.createdAt[messages[j].ID])
+	})
+
+	return messages, nil
+}
+
+func (s *Store) CreateSuggestionObject(ctx context.Context, suggestion weaviate.SuggestionObject) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.prompts[suggestion.PromptID]; !ok {
+		return "", errkind.New(errkind.NotFound, "no object found with ID: %s", suggestion.PromptID)
+	}
+
+	id := uuid.NewString()
+	s.suggestions[id] = weaviate.Suggestion{ID: id, SuggestionObject: suggestion}
+	s.createdAt[id] = time.Now().UTC()
+
+	return id, nil
+}
+
+func (s *Store) RetrieveSuggestion(ctx context.Context, id string) (weaviate.Suggestion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	suggestion, ok := s.suggestions[id]
+	if !ok {
+		return weaviate.Suggestion{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	return suggestion, nil
+}
+
+func (s *Store) RetrieveSuggestions(ctx context.Context, promptID string) ([]weaviate.Suggestion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var suggestions []weaviate.Suggestion
+	for _, suggestion := range s.suggestions {
+		if suggestion.PromptID == promptID {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].Position < suggestions[j].Position
+	})
+
+	return suggestions, nil
+}
+
+func (s *Store) CreateAnalysisObject(ctx context.Context, object weaviate.AnalysisObject) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if object.Function != nil {
+		fn := *object.Function
+		object.Function = &fn
+	}
+
+	id := uuid.NewString()
+	s.analyses[id] = &analysis{AnalysisObject: object}
+	s.createdAt[id] = time.Now().UTC()
+
+	return id, nil
+}
+
+func (s *Store) SetAnalysisResult(ctx context.Context, id string, result weaviate.AnalysisResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.analyses[id]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+	a.AnalysisResult = result
+
+	return nil
+}
+
+func (s *Store) CreateAnalysisReferences(ctx context.Context, parentID string, partID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, ok := s.analyses[parentID]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", parentID)
+	}
+	part, ok := s.analyses[partID]
+	if !ok {
+		return errkind.New(errkind.NotFound, "no object found with ID: %s", partID)
+	}
+
+	parent.partIDs = append(parent.partIDs, partID)
+	part.parentID = parentID
+
+	return nil
+}
+
+func (s *Store) RetrieveAnalysis(ctx context.Context, id string, depth int) (weaviate.Analysis, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.analysis(id, depth)
+}
+
+func (s *Store) analysis(id string, depth int) (weaviate.Analysis, error) {
+	a, ok := s.analyses[id]
+	if !ok {
+		return weaviate.Analysis{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	result := weaviate.Analysis{
+		ID:             id,
+		AnalysisObject: a.AnalysisObject,
+		PartIDs:        append([]string(nil), a.partIDs...),
+	}
+	if a.Function != nil {
+		fn := *a.Function
+		result.Function = &fn
+	}
+
+	if depth <= 0 {
+		return result, nil
+	}
+
+	for _, partID := range a.partIDs {
+		// like in Weaviate, references to deleted parts are left out
+		if _, ok := s.analyses[partID]; !ok {
+			continue
+		}
+		part, err := s.analysis(partID, depth-1)
+		if err != nil {
+			return weaviate.Analysis{}, err
+		}
+		result.Parts = append(result.Parts, part)
+	}
+
+	return result, nil
+}
+
+func (s *Store) ListObjects(ctx context.Context, class string, after string, limit int) ([]weaviate.Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listObjects(class, after, limit)
+}
+
+func (s *Store) ListObjectsWithVectors(ctx context.Context, class string, after string, limit int) ([]weaviate.Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects, err := s.listObjects(class, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the vectorized classes have vectors
+	for i := range objects {
+		switch class {
+		case weaviate.PromptClass:
+			objects[i].Vector = append([]float32(nil), s.prompts[objects[i].ID].vector...)
+		case weaviate.SemanticMeaningClass:
+			objects[i].Vector = append([]float32(nil), s.meanings[objects[i].ID].vector...)
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *Store) listObjects(class string, after string, limit int) ([]weaviate.Object, error) {
+	var objects []weaviate.Object
+	switch class {
+	case weaviate.PromptClass:
+		for _, p := range s.prompts {
+			properties := map[string]interface{}{
+				"instruct":     p.properties.Instruct,
+				"instructType": p.properties.InstructType,
+				"code":         p.properties.Code,
+				"gitURL":       p.properties.GitURL,
+				"model":        p.properties.Model,
+				"rank":         p.rank,
+				"upvotes":      p.upvotes,
+				"downvotes":    p.downvotes,
+			}
+			if len(p.properties.Examples) > 0 {
+				examples := make([]interface{}, 0, len(p.properties.Examples))
+				for _, example := range p.properties.Examples {
+					examples = append(examples, example)
+				}
+				properties["examples"] = examples
+			}
+			if p.responseID != "" {
+				properties["hasResponse"] = []interface{}{weaviate.Beacon(weaviate.ResponseClass, p.responseID)}
+			}
+			if p.semanticMeaningID != "" {
+				properties["hasSemanticMeaning"] = []interface{}{weaviate.Beacon(weaviate.SemanticMeaningClass, p.semanticMeaningID)}
+			}
+			objects = append(objects, s.object(class, p.id, properties))
+		}
+	case weaviate.ResponseClass:
+		for id, response := range s.responses {
+			objects = append(objects, s.object(class, id, map[string]interface{}{"response": response}))
+		}
+	case weaviate.SemanticMeaningClass:
+		for id, m := range s.meanings {
+			properties := map[string]interface{}{"semanticMeaning": m.meaning}
+			if len(m.promptIDs) > 0 {
+				refs := make([]interface{}, 0, len(m.promptIDs))
+				for _, promptID := range m.promptIDs {
+					refs = append(refs, weaviate.Beacon(weaviate.PromptClass, promptID))
+				}
+				properties["hasPrompt"] = refs
+			}
+			objects = append(objects, s.object(class, id, properties))
+		}
+	case weaviate.AnalysisClass:
+		for id, a := range s.analyses {
+			properties := map[string]interface{}{
+				"level":      a.Level,
+				"repository": a.Repository,
+				"path":       a.Path,
+				"instruct":   a.Instruct,
+				"model":      a.Model,
+				"summary":    a.Summary,
+				"status":     a.Status,
+				"error":      a.Error,
+			}
+			if fn := a.Function; fn != nil {
+				properties["language"] = fn.Language
+				properties["functionName"] = fn.Name
+				properties["functionKind"] = fn.Kind
+				properties["signature"] = fn.Signature
+				properties["startLine"] = fn.Lines.Start
+				properties["endLine"] = fn.Lines.End
+			}
+			if len(a.partIDs) > 0 {
+				refs := make([]interface{}, 0, len(a.partIDs))
+				for _, partID := range a.partIDs {
+					refs = append(refs, weaviate.Beacon(weaviate.AnalysisClass, partID))
+				}
+				properties["hasPart"] = refs
+			}
+			if a.parentID != "" {
+				properties["partOf"] = []interface{}{weaviate.Beacon(weaviate.AnalysisClass, a.parentID)}
+			}
+			objects = append(objects, s.object(class, id, properties))
+		}
+	case weaviate.MessageClass:
+		for id, m := range s.messages {
+			objects = append(objects, s.object(class, id, map[string]interface{}{
+				"promptID":  m.PromptID,
+				"ofPrompt":  []interface{}{weaviate.Beacon(weaviate.PromptClass, m.PromptID)},
+				"position":  m.Position,
+				"role":      m.Role,
+				"content":   m.Content,
+				"model":     m.Model,
+				"rank":      m.Rank,
+				"upvotes":   m.Upvotes,
+				"downvotes": m.Downvotes,
+			}))
+		}
+	case weaviate.SuggestionClass:
+		for id, suggestion := range s.suggestions {
+			objects = append(objects, s.object(class, id, map[string]interface{}{
+				"promptID":  suggestion.PromptID,
+				"ofPrompt":  []interface{}{weaviate.Beacon(weaviate.PromptClass, suggestion.PromptID)},
+				"position":  suggestion.Position,
+				"title":     suggestion.Title,
+				"rationale": suggestion.Rationale,
+				"severity":  suggestion.Severity,
+				"diff":      suggestion.Diff,
+				"applies":   suggestion.Applies,
+				"problem":   suggestion.Problem,
+			}))
+		}
+	default:
+		return nil, fmt.Errorf("unknown class: %s", class)
+	}
+
+	// like Weaviate's cursor API the objects are ordered by ID
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ID < objects[j].ID
+	})
+
+	start := sort.Search(len(objects), func(i int) bool {
+		return objects[i].ID > after
+	})
+	objects = objects[start:]
+	if len(objects) > limit {
+		objects = objects[:limit]
+	}
+
+	return objects, nil
+}
+
+// ImportObjects stores objects under their IDs, replacing objects with the
+// same ID. Prompts and semantic meanings without a vector are embedded.
+func (s *Store) ImportObjects(ctx context.Context, objects []weaviate.Object) error {
+	vectors := make([][]float32, len(objects))
+	for i, object := range objects {
+		vectors[i] = object.Vector
+		if len(vectors[i]) > 0 {
+			continue
+		}
+
+		var text string
+		switch object.Class {
+		case weaviate.PromptClass:
+			text, _ = object.Properties["code"].(string)
+		case weaviate.SemanticMeaningClass:
+			text, _ = object.Properties["semanticMeaning"].(string)
+		default:
+			continue
+		}
+
+		var err error
+		vectors[i], err = s.embedder.Embed(ctx, text)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, object := range objects {
+		err := s.importObject(object, vectors[i])
+		if err != nil {
+			return fmt.Errorf("could not import %s %s: %w", object.Class, object.ID, err)
+		}
+
+		createdAt := object.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now().UTC()
+		}
+		s.createdAt[object.ID] = createdAt
+	}
+
+	return nil
+}
+
+func (s *Store) importObject(object weaviate.Object, vector []float32) error {
+	id := object.ID
+	properties := object.Properties
+	text := func(name string) string {
+		value, _ := properties[name].(string)
+		return value
+	}
+	first := func(ids []string) string {
+		if len(ids) == 0 {
+			return ""
+		}
+		return ids[0]
+	}
+
+	switch object.Class {
+	case weaviate.PromptClass:
+		p := &prompt{
+			id: id,
+			properties: weaviate.PromptObject{
+				Instruct:     text("instruct"),
+				InstructType: text("instructType"),
+				Code:         text("code"),
+				GitURL:       text("gitURL"),
+				Model:        text("model"),
+			},
+			rank:              number(properties["rank"]),
+			upvotes:           number(properties["upvotes"]),
+			downvotes:         number(properties["downvotes"]),
+			vector:            vector,
+			responseID:        first(object.ReferencedIDs("hasResponse")),
+			semanticMeaningID: first(object.ReferencedIDs("hasSemanticMeaning")),
+		}
+		examples, _ := properties["examples"].([]interface{})
+		for _, example := range examples {
+			if example, ok := example.(string); ok {
+				p.properties.Examples = append(p.properties.Examples, example)
+			}
+		}
+		p.fingerprint = weaviate.NewFingerprint(p.properties.Code, p.properties.GitURL)
+
+		if existing, ok := s.prompts[id]; ok {
+			p.created = existing.created
+		} else {
+			s.created++
+			p.created = s.created
+		}
+		s.prompts[id] = p
+	case weaviate.ResponseClass:
+		s.responses[id] = text("response")
+	case weaviate.SemanticMeaningClass:
+		s.meanings[id] = &semanticMeaning{
+			meaning:   text("semanticMeaning"),
+			vector:    vector,
+			promptIDs: object.ReferencedIDs("hasPrompt"),
+		}
+	case weaviate.AnalysisClass:
+		a, err := weaviate.ParseAnalysis(object)
+		if err != nil {
+			return err
+		}
+		s.analyses[id] = &analysis{
+			AnalysisObject: a.AnalysisObject,
+			partIDs:        a.PartIDs,
+			parentID:       first(object.ReferencedIDs("partOf")),
+		}
+	case weaviate.MessageClass:
+		m, err := weaviate.ParseMessage(object)
+		if err != nil {
+			return err
+		}
+		s.messages[id] = &m
+	case weaviate.SuggestionClass:
+		suggestion, err := weaviate.ParseSuggestion(object)
+		if err != nil {
+			return err
+		}
+		s.suggestions[id] = suggestion
+	default:
+		return fmt.Errorf("unknown class: %s", object.Class)
+	}
+
+	return nil
+}
+
+// number reads an integer property, which is a float64 once it went through
+// JSON.
+func number(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+func (s *Store) object(class string, id string, properties map[string]interface{}) weaviate.Object {
+	return weaviate.Object{
+		Class:      class,
+		ID:         id,
+		Properties: properties,
+		CreatedAt:  s.createdAt[id],
+	}
+}
+
+func (s *Store) DeleteObject(ctx context.Context, class string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch class {
+	case weaviate.PromptClass:
+		if _, ok := s.prompts[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.prompts, id)
+	case weaviate.ResponseClass:
+		if _, ok := s.responses[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.responses, id)
+	case weaviate.SemanticMeaningClass:
+		if _, ok := s.meanings[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.meanings, id)
+	case weaviate.AnalysisClass:
+		if _, ok := s.analyses[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.analyses, id)
+	case weaviate.MessageClass:
+		if _, ok := s.messages[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.messages, id)
+	case weaviate.SuggestionClass:
+		if _, ok := s.suggestions[id]; !ok {
+			return errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+		}
+		delete(s.suggestions, id)
+	default:
+		return fmt.Errorf("unknown class: %s", class)
+	}
+	delete(s.createdAt, id)
+
+	return nil
+}
+
+func (s *Store) responseData(p *prompt) (weaviate.ResponseData, error) {
+	response, ok := s.response(p)
+	if !ok {
+		return weaviate.ResponseData{}, weaviate.ErrNoResponse
+	}
+
+	return weaviate.ResponseData{
+		Response: response,
+		PromptID: p.id,
+		Instruct: p.properties.Instruct,
+		GitURL:   p.properties.GitURL,
+		Model:    p.properties.Model,
+	}, nil
+}
+
+// response returns the response of p. Like in Weaviate, a reference to a
+// deleted response counts as missing.
+func (s *Store) response(p *prompt) (string, bool) {
+	response, ok := s.responses[p.responseID]
+	return response, ok
+}
+
+// answered keeps the prompts which have a response.
+func (s *Store) answered(prompts []*prompt) []*prompt {
+	var kept []*prompt
+	for _, p := range prompts {
+		if _, ok := s.response(p); ok {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// sorted returns all prompts by descending rank, oldest first on ties.
+func (s *Store) sorted() []*prompt {
+	prompts := make([]*prompt, 0, len(s.prompts))
+	for _, p := range s.prompts {
+		prompts = append(prompts, p)
+	}
+
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].rank != prompts[j].rank {
+			return prompts[i].rank > prompts[j].rank
+		}
+		return prompts[i].created < prompts[j].created
+	})
+
+	return prompts
+}
+
+// match returns the prompts whose code matches, sorted like sorted. An empty
+// instructType or model matches every prompt.
+func (s *Store) match(match weaviate.CodeMatch, instructType string, model string) []*prompt {
+	var prompts []*prompt
+	for _, p := range s.sorted() {
+		if !match.Matches(p.properties.Code, p.fingerprint) {
+			continue
+		}
+		if instructType != "" && p.properties.InstructType != instructType {
+			continue
+		}
+		if model != "" && p.properties.Model != model {
+			continue
+		}
+		prompts = append(prompts, p)
+	}
+
+	return prompts
+}
+
+func cosine(a []float32, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
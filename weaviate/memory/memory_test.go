@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ranking"
+	"github.com/rwth-acis/modernizer/weaviate"
+)
+
+const code = "func one() int {\n\treturn 1\n}\n"
+
+func create(t *testing.T, s *Store, prompt weaviate.PromptObject, response string) string {
+	t.Helper()
+	id, _, err := s.CreatePromptWithResponse(context.Background(), prompt, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestCreatePromptWithResponse(t *testing.T) {
+	ctx := context.Background()
+	s := New(nil)
+	prompt := weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "m"}
+
+	id, created, err := s.CreatePromptWithResponse(ctx, prompt, "It returns one.")
+	if err != nil || !created {
+		t.Fatalf("CreatePromptWithResponse() = %s, %t, %v, want a new prompt", id, created, err)
+	}
+	again, created, err := s.CreatePromptWithResponse(ctx, prompt, "It returns one.")
+	if err != nil || created || again != id {
+		t.Fatalf("CreatePromptWithResponse() of the same prompt = %s, %t, %v, want the existing prompt %s", again, created, err, id)
+	}
+
+	properties, err := s.RetrieveProperties(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if properties.HasResponse != "It returns one." || properties.Rank != 1 || properties.Function == nil || properties.Function.Name != "one" {
+		t.Errorf("RetrieveProperties() = %+v, want the response, rank 1 and the function", properties)
+	}
+
+	if err := s.ReplaceResponse(ctx, id, "One."); err != nil {
+		t.Fatal(err)
+	}
+	if response, err := s.RetrieveResponseByID(ctx, id); err != nil || response != "One." {
+		t.Errorf("RetrieveResponseByID() after ReplaceResponse = %q, %v, want the new response", response, err)
+	}
+
+	if err := s.ClearResponsesPrompt(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RetrieveProperties(ctx, id); err == nil {
+		t.Error("RetrieveProperties() of a prompt without response succeeded")
+	}
+
+	for name, err := range map[string]error{
+		"RetrieveProperties": func() error { _, err := s.RetrieveProperties(ctx, "missing"); return err }(),
+		"ReplaceResponse":    s.ReplaceResponse(ctx, "missing", "x"),
+		"SetVotesPrompt":     s.SetVotesPrompt(ctx, "missing", weaviate.Votes{}),
+	} {
+		if !errkind.Is(err, errkind.NotFound) {
+			t.Errorf("%s() of a missing prompt = %v, want not found", name, err)
+		}
+	}
+}
+
+func TestRetrieveBestResponse(t *testing.T) {
+	ctx := context.Background()
+	s := New(nil)
+	wilson, err := ranking.New("wilson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	liked := create(t, s, weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "a"}, "liked")
+	disliked := create(t, s, weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "a"}, "disliked")
+	other := create(t, s, weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "b"}, "other model")
+	for id, votes := range map[string]weaviate.Votes{
+		liked:    {Rank: 9, Upvotes: 10, Downvotes: 1},
+		disliked: {Rank: -4, Upvotes: 1, Downvotes: 5},
+		other:    {Rank: 20, Upvotes: 20},
+	} {
+		if err := s.SetVotesPrompt(ctx, id, votes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	best, err := s.RetrieveBestResponse(ctx, weaviate.CodeMatch{Code: code}, "a", wilson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.PromptID != liked || best.Upvotes != 10 || best.Strategy != "wilson" || best.Score == nil {
+		t.Errorf("RetrieveBestResponse() = %+v, want the liked response of model a with its score", best)
+	}
+
+	if count, err := s.RetrievePromptCount(ctx, weaviate.CodeMatch{Code: code}, ""); err != nil || count != 3 {
+		t.Errorf("RetrievePromptCount() = %d, %v, want 3", count, err)
+	}
+	if count, err := s.RetrievePromptCount(ctx, weaviate.CodeMatch{Function: "one"}, "b"); err != nil || count != 1 {
+		t.Errorf("RetrievePromptCount() by function and model = %d, %v, want 1", count, err)
+	}
+
+	// prompts are listed by descending rank
+	ids, err := s.ResponseList(ctx, weaviate.CodeMatch{Code: code}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != other || ids[2] != disliked {
+		t.Errorf("ResponseList() = %v, want %s first and %s last", ids, other, disliked)
+	}
+
+	_, err = s.RetrieveBestResponse(ctx, weaviate.CodeMatch{Code: "func two() {}"}, "", wilson)
+	if !errkind.Is(err, errkind.NotFound) {
+		t.Errorf("RetrieveBestResponse() of unknown code = %v, want not found", err)
+	}
+}
+
+func TestSetSemanticMeaningPrompt(t *testing.T) {
+	ctx := context.Background()
+	s := New(nil)
+	id := create(t, s, weaviate.PromptObject{Code: code, Instruct: "Explain this:", GitURL: "https://example.com/one.git"}, "It returns one.")
+
+	if err := s.SetSemanticMeaningPrompt(ctx, id, "returns a constant"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSemanticMeaningPrompt(ctx, id, "returns the number one"); err != nil {
+		t.Fatal(err)
+	}
+
+	meanings, err := s.ListObjects(ctx, weaviate.SemanticMeaningClass, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meanings) != 1 || meanings[0].Properties["semanticMeaning"] != "returns the number one" {
+		t.Fatalf("semantic meanings = %+v, want only the replacing one", meanings)
+	}
+	if meaning, ok := s.RetrieveHasSemanticMeaning(ctx, weaviate.CodeMatch{Code: code}); !ok || meaning != "returns the number one" {
+		t.Errorf("RetrieveHasSemanticMeaning() = %q, %t, want the replacing meaning", meaning, ok)
+	}
+
+	urls, err := s.GetSimilarSemanticMeaning(ctx, "returns the number one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 || urls[0] != "https://example.com/one.git" {
+		t.Errorf("GetSimilarSemanticMeaning() = %v, want the repository of the prompt", urls)
+	}
+
+	if err := s.SetSemanticMeaningPrompt(ctx, "missing", "anything"); !errkind.Is(err, errkind.NotFound) {
+		t.Errorf("SetSemanticMeaningPrompt() of a missing prompt = %v, want not found", err)
+	}
+}
+
+func TestDeleteObject(t *testing.T) {
+	ctx := context.Background()
+	s := New(nil)
+	id := create(t, s, weaviate.PromptObject{Code: code, Instruct: "Explain this:"}, "It returns one.")
+	if err := s.SetSemanticMeaningPrompt(ctx, id, "returns a constant"); err != nil {
+		t.Fatal(err)
+	}
+
+	// references to deleted objects count as missing
+	if err := s.DeleteObject(ctx, weaviate.ResponseClass, weaviate.ResponseUUID(id)); err != nil {
+		t.Fatal(err)
+	}
+	summaries, err := s.ListPrompts(ctx, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].HasResponse || !summaries[0].HasSemanticMeaning || !summaries[0].HasFingerprint {
+		t.Errorf("ListPrompts() = %+v, want a prompt without response", summaries)
+	}
+
+	if err := s.DeleteObject(ctx, weaviate.ResponseClass, weaviate.ResponseUUID(id)); !errkind.Is(err, errkind.NotFound) {
+		t.Errorf("DeleteObject() of a deleted response = %v, want not found", err)
+	}
+	if err := s.DeleteObject(ctx, "Unknown", id); err == nil {
+		t.Error("DeleteObject() of an unknown class succeeded")
+	}
+}
+
+func TestRetrieveAnalysis(t *testing.T) {
+	ctx := context.Background()
+	s := New(nil)
+
+	repository, err := s.CreateAnalysisObject(ctx, weaviate.AnalysisObject{Level: weaviate.AnalysisRepository, Repository: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, path := range []string{"a.go", "b.go"} {
+		file, err := s.CreateAnalysisObject(ctx, weaviate.AnalysisObject{Level: weaviate.AnalysisFile, Repository: "r", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateAnalysisReferences(ctx, repository, file); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	shallow, err := s.RetrieveAnalysis(ctx, repository, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shallow.PartIDs) != 2 || shallow.Parts != nil {
+		t.Errorf("RetrieveAnalysis() with depth 0 = %+v, want the IDs of the parts only", shallow)
+	}
+
+	// like in Weaviate, deleted parts are left out
+	if err := s.DeleteObject(ctx, weaviate.AnalysisClass, files[0]); err != nil {
+		t.Fatal(err)
+	}
+	deep, err := s.RetrieveAnalysis(ctx, repository, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deep.Parts) != 1 || deep.Parts[0].Path != "b.go" {
+		t.Errorf("RetrieveAnalysis() with depth 1 = %+v, want the remaining file", deep.Parts)
+	}
+}
+
+func TestImportObjects(t *testing.T) {
+	ctx := context.Background()
+	classes := []string{
+		weaviate.PromptClass, weaviate.ResponseClass, weaviate.SemanticMeaningClass,
+		weaviate.AnalysisClass, weaviate.MessageClass, weaviate.SuggestionClass,
+	}
+
+	source := New(nil)
+	id := create(t, source, weaviate.PromptObject{Code: code, Instruct: "Explain this:", Model: "m", Examples: []string{"example"}}, "It returns one.")
+	if err := source.SetVotesPrompt(ctx, id, weaviate.Votes{Rank: 3, Upvotes: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetSemanticMeaningPrompt(ctx, id, "returns a constant"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateMessageObject(ctx, weaviate.MessageObject{PromptID: id, Position: 1, Role: "user", Content: "Why?"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateSuggestionObject(ctx, weaviate.SuggestionObject{PromptID: id, Title: "Inline", Severity: weaviate.SeverityLow}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.CreateAnalysisObject(ctx, weaviate.AnalysisObject{Level: weaviate.AnalysisFile, Path: "one.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// objects go through JSON like in an export
+	listings := make(map[string]string)
+	var objects []weaviate.Object
+	for _, class := range classes {
+		listed, err := source.ListObjectsWithVectors(ctx, class, "", 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(listed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listings[class] = string(data)
+
+		var decoded []weaviate.Object
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, decoded...)
+	}
+
+	target := New(nil)
+	if err := target.ImportObjects(ctx, objects); err != nil {
+		t.Fatal(err)
+	}
+	// importing twice replaces the objects
+	if err := target.ImportObjects(ctx, objects); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, class := range classes {
+		listed, err := target.ListObjectsWithVectors(ctx, class, "", 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(listed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != listings[class] {
+			t.Errorf("imported %s objects =\n%s\nwant\n%s", class, data, listings[class])
+		}
+	}
+
+	err := target.ImportObjects(ctx, []weaviate.Object{{Class: "Unknown", ID: id}})
+	if err == nil {
+		t.Error("ImportObjects() of an unknown class succeeded")
+	}
+}
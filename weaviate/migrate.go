@@ -0,0 +1,444 @@
+package weaviate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// Migration is one step from the previous version of the schema to Version.
+// Deployments set up before the schema was versioned have no version marker,
+// so every migration has to tolerate finding its change applied already.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, c *Client) error
+}
+
+// Migrations lists every step of the schema in the order they are applied.
+// New steps are appended, applied ones are never changed.
+var Migrations = []Migration{
+	{1, "create Response class", addClass(responseClass)},
+	{2, "create SemanticMeaning class", addClass(semanticMeaningClass)},
+	{3, "create Prompt class", addClass(promptClass)},
+	{4, "add hasPrompt to SemanticMeaning", addProperties(SemanticMeaningClass, hasPromptProperty)},
+	{5, "add model and votes to Prompt", addProperties(PromptClass, modelProperty, upvotesProperty, downvotesProperty)},
+	{6, "add examples to Prompt", addProperties(PromptClass, examplesProperty)},
+	{7, "add fingerprint to Prompt", addProperties(PromptClass, fingerprintSchema...)},
+	{8, "create Analysis class", addClass(analysisClass)},
+	{9, "add references between analyses", addProperties(AnalysisClass, analysisReferences...)},
+	{10, "create Message class", addClass(messageClass)},
+	{11, "create Suggestion class", addClass(suggestionClass)},
+	{12, "fingerprint prompts stored without one", reindex(PromptClass, fingerprintPrompt)},
+	{13, "add the creation time of imported objects to every class", addCreatedAt},
+}
+
+// LatestVersion is the version of the schema once all migrations ran.
+func LatestVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// MigrationClass holds the schema version and the lock taken while migrating.
+// It is not part of Classes, so it survives DeleteAllClasses, which only
+// resets the version.
+const MigrationClass = "SchemaMigration"
+
+var migrationClass = &models.Class{
+	Class:       MigrationClass,
+	Description: "This class contains the version of the schema and the lock held while migrating it",
+	Vectorizer:  "none",
+	Properties: []*models.Property{
+		{DataType: []string{"int"}, Name: "version", Description: "The version of the last applied migration"},
+		{DataType: []string{"date"}, Name: "migratedAt", Description: "When the last migration was applied"},
+		{DataType: []string{"text"}, Name: "holder", Description: "The process holding the lock"},
+		{DataType: []string{"date"}, Name: "expiresAt", Description: "When the lock is released if its holder stops"},
+	},
+}
+
+// The version marker and the lock are objects with fixed IDs.
+var (
+	versionID = uuid.NewSHA1(namespace, []byte(MigrationClass+"\x00version")).String()
+	lockID    = uuid.NewSHA1(namespace, []byte(MigrationClass+"\x00lock")).String()
+)
+
+// schemaLockTTL is how long a lock outlives a holder that stopped without
+// releasing it. The holder renews it every schemaLockRenewal while it
+// migrates, so long migrations keep it.
+const (
+	schemaLockTTL     = 5 * time.Minute
+	schemaLockRenewal = time.Minute
+)
+
+// SchemaStatus is the applied version of the schema. Holder names the process
+// migrating it, if any.
+type SchemaStatus struct {
+	Version    int
+	MigratedAt time.Time
+	Holder     string
+	ExpiresAt  time.Time
+}
+
+// Pending returns the migrations that have not been applied yet.
+func (s SchemaStatus) Pending() []Migration {
+	for i, migration := range Migrations {
+		if migration.Version > s.Version {
+			return Migrations[i:]
+		}
+	}
+	return nil
+}
+
+// InitSchema applies all pending migrations. Concurrent processes wait for
+// each other, so every migration runs once.
+func (c *Client) InitSchema(ctx context.Context) error {
+	err := addClass(migrationClass)(ctx, c)
+	// another process may have created the class since it was checked
+	if err != nil && !errkind.Is(err, errkind.Conflict) {
+		return err
+	}
+
+	ctx, unlock, err := c.lockSchema(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	status, err := c.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := status.Pending()
+	if len(pending) == 0 {
+		log.Printf("schema is at version %d\n", status.Version)
+		return nil
+	}
+
+	for _, migration := range pending {
+		err = migration.Up(ctx, c)
+		if cause := context.Cause(ctx); err != nil && cause != nil {
+			err = cause
+		}
+		if err != nil {
+			return fmt.Errorf("schema migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		err = c.setSchemaVersion(ctx, migration.Version)
+		if err != nil {
+			return err
+		}
+		log.Printf("applied schema migration %d: %s\n", migration.Version, migration.Description)
+	}
+
+	return nil
+}
+
+// SchemaStatus returns the applied version of the schema. A schema created
+// before it was versioned is at version 0.
+func (c *Client) SchemaStatus(ctx context.Context) (SchemaStatus, error) {
+	var status SchemaStatus
+
+	exists, err := c.client.Schema().ClassExistenceChecker().WithClassName(MigrationClass).Do(ctx)
+	if err != nil || !exists {
+		return status, err
+	}
+
+	version, ok, err := c.migrationObject(ctx, versionID)
+	if err != nil {
+		return status, err
+	}
+	if ok {
+		v, _ := version["version"].(float64)
+		status.Version = int(v)
+		status.MigratedAt = timeProperty(version["migratedAt"])
+	}
+
+	lock, ok, err := c.migrationObject(ctx, lockID)
+	if err != nil {
+		return status, err
+	}
+	if ok {
+		status.Holder, _ = lock["holder"].(string)
+		status.ExpiresAt = timeProperty(lock["expiresAt"])
+	}
+
+	return status, nil
+}
+
+// resetSchemaVersion forgets the applied migrations after the classes were
+// dropped, so the next InitSchema creates them again.
+func (c *Client) resetSchemaVersion(ctx context.Context) error {
+	err := c.DeleteObject(ctx, MigrationClass, versionID)
+	if errkind.Is(err, errkind.NotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) setSchemaVersion(ctx context.Context, version int) error {
+	// the batch creates the marker or overwrites it
+	results, err := c.client.Batch().ObjectsBatcher().
+		WithObjects(&models.Object{
+			Class: MigrationClass,
+			ID:    strfmt.UUID(versionID),
+			Properties: map[string]interface{}{
+				"version":    version,
+				"migratedAt": time.Now().UTC().Format(time.RFC3339),
+			},
+		}).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	return batchError(results)
+}
+
+// lockSchema waits until no other process migrates the schema and takes the
+// lock. A lock whose holder stopped is taken over once it expired. Until
+// unlock is called the lock is renewed in the background; the returned context
+// is cancelled if the lock is lost nevertheless.
+func (c *Client) lockSchema(ctx context.Context) (context.Context, func(), error) {
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), uuid.NewString()[:8])
+
+	err := c.acquireSchemaLock(ctx, holder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(schemaLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := c.renewSchemaLock(lockCtx, holder)
+			if err != nil {
+				cancel(fmt.Errorf("lost schema lock: %w", err))
+				return
+			}
+		}
+	}()
+
+	unlock := func() {
+		close(done)
+		<-stopped
+		cancel(nil)
+
+		err := c.deleteSchemaLock(context.WithoutCancel(ctx), holder, false)
+		if err != nil {
+			log.Printf("could not release schema lock: %v", err)
+		}
+	}
+
+	return lockCtx, unlock, nil
+}
+
+func (c *Client) acquireSchemaLock(ctx context.Context, holder string) error {
+	waiting := false
+	for {
+		_, err := c.client.Data().Creator().
+			WithClassName(MigrationClass).
+			WithID(lockID).
+			WithProperties(map[string]interface{}{
+				"holder":    holder,
+				"expiresAt": time.Now().Add(schemaLockTTL).UTC().Format(time.RFC3339),
+			}).
+			Do(ctx)
+		if err != nil && !errkind.Is(err, errkind.Conflict) {
+			return fmt.Errorf("could not lock schema: %w", err)
+		}
+
+		status, statusErr := c.SchemaStatus(ctx)
+		if statusErr != nil {
+			return statusErr
+		}
+		// a process taking over an expired lock at the same time may have
+		// deleted ours right after it was created
+		if err == nil && status.Holder == holder {
+			return nil
+		}
+		if status.Holder == "" {
+			// released in the meantime
+			continue
+		}
+
+		if time.Now().After(status.ExpiresAt) {
+			log.Printf("taking over schema lock of %s, it expired at %s\n", status.Holder, status.ExpiresAt.Format(time.RFC3339))
+			err = c.deleteSchemaLock(ctx, status.Holder, true)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !waiting {
+			log.Printf("waiting for %s to migrate the schema\n", status.Holder)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// renewSchemaLock extends the lock of holder. It fails if another process
+// holds the lock by now.
+func (c *Client) renewSchemaLock(ctx context.Context, holder string) error {
+	status, err := c.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Holder != holder {
+		return errkind.New(errkind.Conflict, "schema lock is held by %q", status.Holder)
+	}
+
+	return c.client.Data().Updater().
+		WithMerge().
+		WithID(lockID).
+		WithClassName(MigrationClass).
+		WithProperties(map[string]interface{}{
+			"expiresAt": time.Now().Add(schemaLockTTL).UTC().Format(time.RFC3339),
+		}).
+		Do(ctx)
+}
+
+// deleteSchemaLock deletes the lock if holder still holds it and, if expired
+// is set, has not renewed it since.
+func (c *Client) deleteSchemaLock(ctx context.Context, holder string, expired bool) error {
+	status, err := c.SchemaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Holder != holder || (expired && !time.Now().After(status.ExpiresAt)) {
+		return nil
+	}
+
+	err = c.DeleteObject(ctx, MigrationClass, lockID)
+	if errkind.Is(err, errkind.NotFound) {
+		return nil
+	}
+	return err
+}
+
+// migrationObject returns the properties of the version marker or the lock.
+func (c *Client) migrationObject(ctx context.Context, id string) (map[string]interface{}, bool, error) {
+	objects, err := c.client.Data().ObjectsGetter().
+		WithClassName(MigrationClass).
+		WithID(id).
+		Do(ctx)
+	if errkind.Is(err, errkind.NotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(objects) == 0 {
+		return nil, false, nil
+	}
+
+	properties, _ := objects[0].Properties.(map[string]interface{})
+	return properties, true, nil
+}
+
+// addClass creates class unless it exists.
+func addClass(class *models.Class) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		exists, err := c.client.Schema().ClassExistenceChecker().WithClassName(class.Class).Do(ctx)
+		if err != nil || exists {
+			return err
+		}
+
+		err = c.client.Schema().ClassCreator().WithClass(class).Do(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("created %s class\n", class.Class)
+
+		return nil
+	}
+}
+
+// addProperties adds the properties className lacks.
+func addProperties(className string, properties ...*models.Property) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		for _, prop := range properties {
+			err := ensureProperty(ctx, c.client, className, prop)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addCreatedAt adds the property keeping the creation time of imported
+// objects to every class.
+func addCreatedAt(ctx context.Context, c *Client) error {
+	for _, class := range Classes {
+		prop := skippedProperty(createdAtProperty, "date", "When the object was created before it was imported", "")
+		err := addProperties(class, prop)(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindex calls fn for every object of className, typically to fill in a
+// property added by an earlier migration.
+func reindex(className string, fn func(context.Context, *Client, Object) error) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		updated := 0
+		err := EachObject(ctx, c, className, 100, func(object Object) error {
+			updated++
+			return fn(ctx, c, object)
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("reindexed %d %s objects\n", updated, className)
+
+		return nil
+	}
+}
+
+// fingerprintPrompt stores the fingerprint of a prompt created before
+// prompts were fingerprinted.
+func fingerprintPrompt(ctx context.Context, c *Client, prompt Object) error {
+	if hash, _ := prompt.Properties["codeHash"].(string); hash != "" {
+		return nil
+	}
+
+	code, _ := prompt.Properties["code"].(string)
+	gitURL, _ := prompt.Properties["gitURL"].(string)
+
+	return c.SetFingerprintPrompt(ctx, prompt.ID, code, gitURL)
+}
+
+func timeProperty(value interface{}) time.Time {
+	s, _ := value.(string)
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
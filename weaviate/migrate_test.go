@@ -0,0 +1,107 @@
+package weaviate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rwth-acis/modernizer/errkind"
+)
+
+func TestInitSchemaTwice(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeWeaviate(t)
+
+	if err := client.InitSchema(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.SchemaStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != LatestVersion() || status.Holder != "" {
+		t.Fatalf("SchemaStatus() = %+v, want version %d and no lock", status, LatestVersion())
+	}
+	schema := fake.schema(t)
+
+	if err := client.InitSchema(ctx); err != nil {
+		t.Fatalf("second InitSchema() = %v", err)
+	}
+	if again := fake.schema(t); again != schema {
+		t.Errorf("second InitSchema() changed the schema:\n%s\nwant\n%s", again, schema)
+	}
+
+	// every migration tolerates a change applied before its version was
+	// recorded
+	if err := client.resetSchemaVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.InitSchema(ctx); err != nil {
+		t.Fatalf("InitSchema() after resetting the version = %v", err)
+	}
+	if again := fake.schema(t); again != schema {
+		t.Errorf("reapplied migrations changed the schema:\n%s\nwant\n%s", again, schema)
+	}
+}
+
+func TestSchemaLock(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeWeaviate(t)
+	if err := addClass(migrationClass)(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.acquireSchemaLock(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := client.acquireSchemaLock(waitCtx, "second"); err == nil {
+		t.Fatal("acquireSchemaLock() succeeded while the lock was held")
+	}
+	if err := client.renewSchemaLock(ctx, "second"); !errkind.Is(err, errkind.Conflict) {
+		t.Errorf("renewSchemaLock() by another holder = %v, want a conflict", err)
+	}
+	if err := client.renewSchemaLock(ctx, "first"); err != nil {
+		t.Errorf("renewSchemaLock() by the holder = %v", err)
+	}
+
+	// an expired lock is taken over
+	fake.mu.Lock()
+	lock := fake.objects[MigrationClass][lockID].Properties.(map[string]interface{})
+	lock["expiresAt"] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	fake.mu.Unlock()
+	if err := client.acquireSchemaLock(ctx, "second"); err != nil {
+		t.Fatalf("acquireSchemaLock() of an expired lock = %v", err)
+	}
+	if err := client.renewSchemaLock(ctx, "first"); !errkind.Is(err, errkind.Conflict) {
+		t.Errorf("renewSchemaLock() after the takeover = %v, want a conflict", err)
+	}
+
+	// releasing a lock that was taken over keeps the new one
+	if err := client.deleteSchemaLock(ctx, "first", false); err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.SchemaStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Holder != "second" {
+		t.Errorf("lock holder = %q, want second", status.Holder)
+	}
+}
+
+// schema returns the classes of the fake as JSON for comparison.
+func (f *fakeWeaviate) schema(t *testing.T) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.MarshalIndent(f.classes, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,311 @@
+package weaviate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// Names of the classes created by InitSchema.
+const (
+	PromptClass          = "Prompt"
+	ResponseClass        = "Response"
+	SemanticMeaningClass = "SemanticMeaning"
+)
+
+// Classes lists every class of the schema.
+var Classes = []string{PromptClass, ResponseClass, SemanticMeaningClass, AnalysisClass, MessageClass, SuggestionClass}
+
+// createdAtProperty holds the creation time of an imported object, which
+// replaces the time Weaviate stored it at.
+const createdAtProperty = "createdAt"
+
+// Object is a stored object of any class with its raw properties. References
+// are kept as lists of beacons, exactly as Weaviate returns them. Vector is
+// only set if it was asked for.
+type Object struct {
+	Class      string                 `json:"class"`
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	Vector     []float32              `json:"vector,omitempty"`
+}
+
+// ReferencedIDs returns the IDs of the objects property references.
+func (o Object) ReferencedIDs(property string) []string {
+	refs, ok := o.Properties[property].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	for _, ref := range refs {
+		refMap, ok := ref.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		beacon, ok := refMap["beacon"].(string)
+		if !ok {
+			continue
+		}
+		ids = append(ids, beacon[strings.LastIndex(beacon, "/")+1:])
+	}
+
+	return ids
+}
+
+// Beacon returns the reference to the object id of class.
+func Beacon(class string, id string) map[string]interface{} {
+	return map[string]interface{}{
+		"beacon": "weaviate://localhost/" + class + "/" + id,
+	}
+}
+
+// namespace is the UUID namespace of the IDs derived from content.
+var namespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/rwth-acis/modernizer"))
+
+// PromptUUID derives the ID of a prompt from its content and the response
+// generated for it, so storing the same generation twice yields the same ID.
+func PromptUUID(prompt PromptObject, response string) string {
+	parts := []string{PromptClass, prompt.Model, prompt.InstructType, prompt.Instruct, prompt.GitURL, prompt.Code}
+	parts = append(parts, prompt.Examples...)
+	parts = append(parts, response)
+
+	return uuid.NewSHA1(namespace, []byte(strings.Join(parts, "\x00"))).String()
+}
+
+// ResponseUUID derives the ID of the response of a prompt. A prompt has one
+// response, which is replaced when it is regenerated.
+func ResponseUUID(promptID string) string {
+	return uuid.NewSHA1(namespace, []byte(ResponseClass+"\x00"+promptID)).String()
+}
+
+// SemanticMeaningUUID derives the ID of the semantic meaning of a prompt. A
+// prompt has one semantic meaning, which is replaced when it is described
+// again.
+func SemanticMeaningUUID(promptID string) string {
+	return uuid.NewSHA1(namespace, []byte(SemanticMeaningClass+"\x00"+promptID)).String()
+}
+
+// MessageUUID derives the ID of the turn at position in the conversation about
+// a prompt, so two turns cannot take the same position.
+func MessageUUID(promptID string, position int) string {
+	return uuid.NewSHA1(namespace, []byte(MessageClass+"\x00"+promptID+"\x00"+strconv.Itoa(position))).String()
+}
+
+// batchError joins the errors of the objects a batch failed to store.
+func batchError(results []models.ObjectsGetResponse) error {
+	var errs []error
+	for _, result := range results {
+		if result.Result == nil || result.Result.Errors == nil {
+			continue
+		}
+		for _, item := range result.Result.Errors.Error {
+			errs = append(errs, errors.New(result.Class+" "+string(result.ID)+": "+item.Message))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// discard deletes an object that was stored as part of a failed write. The
+// object may not exist, the consistency check removes it if deleting fails.
+func (c *Client) discard(ctx context.Context, class string, id string) {
+	err := c.DeleteObject(ctx, class, id)
+	if err != nil && !errkind.Is(err, errkind.NotFound) {
+		log.Printf("could not discard %s %s: %v", class, id, err)
+	}
+}
+
+// discardBatch deletes the objects a failed batch reports as stored. A
+// rejected object that exists nevertheless was written by a concurrent
+// identical request, which owns the stored objects as well, so they are kept.
+// Without results, or if that cannot be checked, the consistency check
+// removes what is left.
+func (c *Client) discardBatch(ctx context.Context, results []models.ObjectsGetResponse) {
+	ctx = context.WithoutCancel(ctx)
+
+	var stored []models.ObjectsGetResponse
+	for _, result := range results {
+		if result.Result == nil || result.Result.Errors == nil {
+			stored = append(stored, result)
+			continue
+		}
+
+		exists, err := c.client.Data().Checker().
+			WithClassName(result.Class).
+			WithID(string(result.ID)).
+			Do(ctx)
+		if err != nil || exists {
+			return
+		}
+	}
+
+	for _, result := range stored {
+		c.discard(ctx, result.Class, string(result.ID))
+	}
+}
+
+// ListObjects pages through all objects of class ordered by ID, starting
+// after the given ID.
+func (c *Client) ListObjects(ctx context.Context, class string, after string, limit int) ([]Object, error) {
+	return c.listObjects(ctx, class, after, limit, false)
+}
+
+// ListObjectsWithVectors behaves like ListObjects but includes the vector of
+// every object.
+func (c *Client) ListObjectsWithVectors(ctx context.Context, class string, after string, limit int) ([]Object, error) {
+	return c.listObjects(ctx, class, after, limit, true)
+}
+
+func (c *Client) listObjects(ctx context.Context, class string, after string, limit int, vectors bool) ([]Object, error) {
+	client := c.client
+
+	getter := client.Data().ObjectsGetter().
+		WithClassName(class).
+		WithLimit(limit)
+	if after != "" {
+		getter = getter.WithAfter(after)
+	}
+	if vectors {
+		getter = getter.WithVector()
+	}
+
+	result, err := getter.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]Object, 0, len(result))
+	for _, object := range result {
+		properties, _ := object.Properties.(map[string]interface{})
+		createdAt := time.UnixMilli(object.CreationTimeUnix).UTC()
+		// imported objects keep the creation time they were exported with
+		if imported := timeProperty(properties[createdAtProperty]); !imported.IsZero() {
+			createdAt = imported.UTC()
+		}
+		delete(properties, createdAtProperty)
+
+		objects = append(objects, Object{
+			Class:      object.Class,
+			ID:         string(object.ID),
+			Properties: properties,
+			CreatedAt:  createdAt,
+			Vector:     object.Vector,
+		})
+	}
+
+	return objects, nil
+}
+
+// ImportObjects stores objects under their IDs, replacing objects with the
+// same ID, so importing twice is harmless. Objects without a vector are
+// vectorized by Weaviate. References are stored as they are, the objects they
+// point to may follow in a later call. Weaviate sets the creation time of
+// every object it stores, so the exported one is kept in a property.
+func (c *Client) ImportObjects(ctx context.Context, objects []Object) error {
+	client := c.client
+
+	batch := make([]*models.Object, 0, len(objects))
+	for _, object := range objects {
+		batch = append(batch, &models.Object{
+			Class:      object.Class,
+			ID:         strfmt.UUID(object.ID),
+			Properties: importProperties(object),
+			Vector:     object.Vector,
+		})
+	}
+
+	results, err := client.Batch().ObjectsBatcher().
+		WithObjects(batch...).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+
+	return batchError(results)
+}
+
+// importProperties prepares the properties of an exported object for storing.
+// References keep their beacon only, prompts exported without a fingerprint
+// get one.
+func importProperties(object Object) map[string]interface{} {
+	properties := make(map[string]interface{}, len(object.Properties))
+	for name, value := range object.Properties {
+		refs, ok := value.([]interface{})
+		if !ok {
+			properties[name] = value
+			continue
+		}
+
+		beacons := make([]interface{}, 0, len(refs))
+		for _, ref := range refs {
+			refMap, ok := ref.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if beacon, ok := refMap["beacon"]; ok {
+				beacons = append(beacons, map[string]interface{}{"beacon": beacon})
+			}
+		}
+		if len(beacons) == len(refs) && len(refs) > 0 {
+			properties[name] = beacons
+		} else {
+			properties[name] = value
+		}
+	}
+
+	if !object.CreatedAt.IsZero() {
+		properties[createdAtProperty] = object.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if hash, _ := properties["codeHash"].(string); object.Class == PromptClass && hash == "" {
+		code, _ := properties["code"].(string)
+		gitURL, _ := properties["gitURL"].(string)
+		for name, value := range fingerprintProperties(NewFingerprint(code, gitURL)) {
+			properties[name] = value
+		}
+	}
+
+	return properties
+}
+
+func (c *Client) DeleteObject(ctx context.Context, class string, id string) error {
+	client := c.client
+
+	return client.Data().Deleter().
+		WithClassName(class).
+		WithID(id).
+		Do(ctx)
+}
+
+// EachObject calls fn for every object of class, fetching pageSize objects at
+// a time. It stops at the first error returned by fn.
+func EachObject(ctx context.Context, store Store, class string, pageSize int, fn func(Object) error) error {
+	after := ""
+	for {
+		objects, err := store.ListObjects(ctx, class, after, pageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+
+		if len(objects) < pageSize {
+			return nil
+		}
+		after = objects[len(objects)-1].ID
+	}
+}
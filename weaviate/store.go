@@ -0,0 +1,57 @@
+package weaviate
+
+import (
+	"context"
+
+	"github.com/rwth-acis/modernizer/ranking"
+)
+
+// Store covers all persistence the backend needs for prompts, responses and
+// semantic meanings. Client implements it against Weaviate, the memory
+// package provides an implementation without external services.
+type Store interface {
+	InitSchema(ctx context.Context) error
+	DeleteAllClasses(ctx context.Context)
+	Health(ctx context.Context) error
+	Close() error
+
+	CreatePromptWithResponse(ctx context.Context, prompt PromptObject, response string) (id string, created bool, err error)
+	ReplaceResponse(ctx context.Context, promptID string, response string) error
+	ClearResponsesPrompt(ctx context.Context, promptID string) error
+	SetSemanticMeaningPrompt(ctx context.Context, promptID string, meaning string) error
+
+	SetVotesPrompt(ctx context.Context, id string, votes Votes) error
+	SetFingerprintPrompt(ctx context.Context, id string, code string, gitURL string) error
+
+	RetrieveProperties(ctx context.Context, id string) (PromptProperties, error)
+	RetrievePromptCount(ctx context.Context, match CodeMatch, model string) (int, error)
+	RetrieveResponseByID(ctx context.Context, id string) (string, error)
+	ResponseList(ctx context.Context, match CodeMatch, instructType string, model string) ([]string, error)
+	RetrieveBestResponse(ctx context.Context, match CodeMatch, model string, strategy ranking.Strategy) (ResponseData, error)
+	RetrieveRandomResponse(ctx context.Context, match CodeMatch, model string) (ResponseData, error)
+	RetrieveHasSemanticMeaning(ctx context.Context, match CodeMatch) (string, bool)
+	GetInstructTypes(ctx context.Context, match CodeMatch, model string) ([]string, error)
+	ListPrompts(ctx context.Context, after string, limit int) ([]PromptSummary, error)
+
+	GetSimilarSemanticMeaning(ctx context.Context, meaning string) ([]string, error)
+	SimilarPrompts(ctx context.Context, code string, limit int) ([]Example, error)
+
+	CreateAnalysisObject(ctx context.Context, analysis AnalysisObject) (string, error)
+	SetAnalysisResult(ctx context.Context, id string, result AnalysisResult) error
+	CreateAnalysisReferences(ctx context.Context, parentID string, partID string) error
+	RetrieveAnalysis(ctx context.Context, id string, depth int) (Analysis, error)
+
+	CreateMessageObject(ctx context.Context, message MessageObject) (string, error)
+	SetVotesMessage(ctx context.Context, id string, votes Votes) error
+	RetrieveMessage(ctx context.Context, id string) (Message, error)
+	RetrieveConversation(ctx context.Context, promptID string) ([]Message, error)
+
+	CreateSuggestionObject(ctx context.Context, suggestion SuggestionObject) (string, error)
+	RetrieveSuggestion(ctx context.Context, id string) (Suggestion, error)
+	RetrieveSuggestions(ctx context.Context, promptID string) ([]Suggestion, error)
+
+	ListObjects(ctx context.Context, class string, after string, limit int) ([]Object, error)
+	ListObjectsWithVectors(ctx context.Context, class string, after string, limit int) ([]Object, error)
+	ImportObjects(ctx context.Context, objects []Object) error
+	DeleteObject(ctx context.Context, class string, id string) error
+}
@@ -0,0 +1,203 @@
+package weaviate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
+	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+// SuggestionClass holds the single suggestions of structured answers.
+const SuggestionClass = "Suggestion"
+
+// Severities of a suggestion, from nice to have to must fix.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// SuggestionObject holds the properties of a suggestion. Diff is a unified
+// diff against the code of the prompt. Applies is set if it applies cleanly,
+// otherwise Problem tells why it does not.
+type SuggestionObject struct {
+	PromptID  string `json:"promptID"`
+	Position  int    `json:"position"`
+	Title     string `json:"title"`
+	Rationale string `json:"rationale"`
+	Severity  string `json:"severity"`
+	Diff      string `json:"diff"`
+	Applies   bool   `json:"applies"`
+	Problem   string `json:"problem,omitempty"`
+}
+
+// Suggestion is a stored suggestion.
+type Suggestion struct {
+	ID string `json:"id"`
+	SuggestionObject
+}
+
+// maxSuggestions caps the number of suggestions retrieved for a prompt.
+const maxSuggestions = 100
+
+var suggestionProperties = []*models.Property{
+	skippedProperty("promptID", "text", "The ID of the prompt the suggestion answers", models.PropertyTokenizationField),
+	skippedProperty("ofPrompt", PromptClass, "The prompt the suggestion answers", ""),
+	skippedProperty("position", "int", "The position of the suggestion within the answer", ""),
+	skippedProperty("title", "text", "A short summary of the suggestion", ""),
+	{
+		DataType:    []string{"text"},
+		Description: "Why the change is suggested",
+		Name:        "rationale",
+	},
+	skippedProperty("severity", "text", "How important the change is: low, medium or high", models.PropertyTokenizationField),
+	skippedProperty("diff", "text", "The change as unified diff against the code of the prompt", models.PropertyTokenizationField),
+	skippedProperty("applies", "boolean", "Whether the diff applies cleanly to the code of the prompt", ""),
+	skippedProperty("problem", "text", "Why the diff does not apply", ""),
+}
+
+// suggestionClass describes the Suggestion class.
+var suggestionClass = &models.Class{
+	Class:       SuggestionClass,
+	Description: "This class contains the suggestions of structured answers to prompts",
+	Vectorizer:  "text2vec-transformers",
+	Properties:  suggestionProperties,
+}
+
+func (c *Client) CreateSuggestionObject(ctx context.Context, suggestion SuggestionObject) (string, error) {
+	client := c.client
+
+	dataSchema := map[string]interface{}{
+		"promptID":  suggestion.PromptID,
+		"ofPrompt":  []interface{}{Beacon(PromptClass, suggestion.PromptID)},
+		"position":  suggestion.Position,
+		"title":     suggestion.Title,
+		"rationale": suggestion.Rationale,
+		"severity":  suggestion.Severity,
+		"diff":      suggestion.Diff,
+		"applies":   suggestion.Applies,
+		"problem":   suggestion.Problem,
+	}
+
+	weaviateObject, err := client.Data().Creator().
+		WithClassName(SuggestionClass).
+		WithProperties(dataSchema).
+		Do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return string(weaviateObject.Object.ID), nil
+}
+
+func (c *Client) RetrieveSuggestion(ctx context.Context, id string) (Suggestion, error) {
+	client := c.client
+
+	objects, err := client.Data().ObjectsGetter().
+		WithID(id).
+		WithClassName(SuggestionClass).
+		Do(ctx)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	if len(objects) == 0 {
+		return Suggestion{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
+	}
+
+	properties, _ := objects[0].Properties.(map[string]interface{})
+	return ParseSuggestion(Object{Class: SuggestionClass, ID: id, Properties: properties})
+}
+
+// RetrieveSuggestions returns the suggestions answering promptID in order.
+func (c *Client) RetrieveSuggestions(ctx context.Context, promptID string) ([]Suggestion, error) {
+	client := c.client
+
+	fields := []graphql.Field{
+		{Name: "_additional", Fields: []graphql.Field{
+			{Name: "id"},
+		}},
+		{Name: "promptID"},
+		{Name: "position"},
+		{Name: "title"},
+		{Name: "rationale"},
+		{Name: "severity"},
+		{Name: "diff"},
+		{Name: "applies"},
+		{Name: "problem"},
+	}
+
+	where := filters.Where().
+		WithPath([]string{"promptID"}).
+		WithOperator(filters.Equal).
+		WithValueText(promptID)
+
+	positionAsc := graphql.Sort{
+		Path: []string{"position"}, Order: graphql.Asc,
+	}
+
+	result, err := client.GraphQL().Get().
+		WithClassName(SuggestionClass).
+		WithFields(fields...).
+		WithWhere(where).
+		WithSort(positionAsc).
+		WithLimit(maxSuggestions).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	getSuggestion, ok := result.Data["Get"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
+	}
+
+	suggestionData, ok := getSuggestion[SuggestionClass].([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Suggestion' field not found")
+	}
+
+	suggestions := make([]Suggestion, 0, len(suggestionData))
+	for _, suggestion := range suggestionData {
+		suggestionMap, ok := suggestion.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected response format: suggestion data is not a map")
+		}
+
+		id, err := ExtractID(suggestionMap)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := ParseSuggestion(Object{Class: SuggestionClass, ID: id, Properties: suggestionMap})
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, parsed)
+	}
+
+	return suggestions, nil
+}
+
+// ParseSuggestion reads a suggestion from the raw properties of an object.
+func ParseSuggestion(object Object) (Suggestion, error) {
+	propertiesJSON, err := json.Marshal(object.Properties)
+	if err != nil {
+		return Suggestion{}, err
+	}
+
+	var suggestion Suggestion
+	if err := json.Unmarshal(propertiesJSON, &suggestion); err != nil {
+		return Suggestion{}, err
+	}
+	suggestion.ID = object.ID
+
+	return suggestion, nil
+}
@@ -2,13 +2,12 @@ package weaviate
 
 import (
 	"context"
-	"errors"
 	"log"
-	"os"
 	"strings"
 
+	"github.com/go-openapi/strfmt"
+	"github.com/rwth-acis/modernizer/codeanalysis"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate"
-	"github.com/weaviate/weaviate-go-client/v4/weaviate/auth"
 	"github.com/weaviate/weaviate/entities/models"
 )
 
@@ -27,6 +26,47 @@ type ResponseData struct {
 	PromptID string `json:"promptID"`
 	Instruct string `json:"instruct"`
 	GitURL   string `json:"gitURL"`
+	Model    string `json:"model,omitempty"`
+
+	Upvotes   int      `json:"upvotes,omitempty"`
+	Downvotes int      `json:"downvotes,omitempty"`
+	Score     *float64 `json:"score,omitempty"`
+	Strategy  string   `json:"strategy,omitempty"`
+
+	// Cached is set if the response was answered from the response cache.
+	Cached bool `json:"cached,omitempty"`
+	// Examples lists the prompts whose answers were given to the model as
+	// few-shot context.
+	Examples []Example `json:"examples,omitempty"`
+	// Suggestions holds the parsed suggestions of a structured answer.
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+// PromptObject holds the properties of a newly generated prompt.
+type PromptObject struct {
+	Instruct     string
+	InstructType string
+	Code         string
+	GitURL       string
+	Model        string
+	// Examples holds the IDs of the prompts used as few-shot context.
+	Examples []string
+}
+
+// PromptSummary describes a stored prompt and which of its references exist.
+type PromptSummary struct {
+	ID string
+	PromptObject
+	HasResponse        bool
+	HasSemanticMeaning bool
+	HasFingerprint     bool
+}
+
+// Votes holds the votes of a prompt together with the rank derived from them.
+type Votes struct {
+	Rank      int
+	Upvotes   int
+	Downvotes int
 }
 
 type PromptProperties struct {
@@ -34,266 +74,273 @@ type PromptProperties struct {
 	HasResponse string `json:"hasResponse"`
 	Instruct    string `json:"instruct"`
 	Rank        int    `json:"rank"`
+	Upvotes     int    `json:"upvotes"`
+	Downvotes   int    `json:"downvotes"`
 	GitURL      string `json:"gitURL"`
-}
-
-func InitSchema() error {
+	Model       string `json:"model"`
 
-	client, err := loadClient()
-	if err != nil {
-		return err
-	}
+	// Function describes the definition in Code, it is missing for prompts
+	// created before code was analyzed.
+	Function   *codeanalysis.Function `json:"function,omitempty"`
+	Repository string                 `json:"repository,omitempty"`
+	Examples   []string               `json:"examples,omitempty"`
+}
 
-	exists, err := client.Schema().ClassExistenceChecker().WithClassName("Response").Do(context.Background())
-	if err != nil {
-		return err
-	}
+// responseClass holds the responses of the prompts.
+var responseClass = &models.Class{
+	Class:       ResponseClass,
+	Description: "This class contains the responses to prompts",
+	Vectorizer:  "text2vec-transformers",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{},
+	},
+	Properties: []*models.Property{
+		{
+			DataType:    []string{"text"},
+			Description: "The generated response by the LLM",
+			Name:        "response",
+		},
+	},
+}
 
-	if !exists {
-		classObj := &models.Class{
-			Class:       "Response",
-			Description: "This class contains the responses to prompts",
-			Vectorizer:  "text2vec-transformers",
-			ModuleConfig: map[string]interface{}{
-				"text2vec-transformers": map[string]interface{}{},
-			},
-			Properties: []*models.Property{
-				{
-					DataType:    []string{"text"},
-					Description: "The generated response by the LLM",
-					Name:        "response",
-				},
-			},
-		}
+// semanticMeaningClass holds the descriptions of the code of the prompts. Its
+// reference back to the prompts is added once the Prompt class exists.
+var semanticMeaningClass = &models.Class{
+	Class:       SemanticMeaningClass,
+	Description: "This class contains the semantic Meaning of the code",
+	Vectorizer:  "text2vec-transformers",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{},
+	},
+	Properties: []*models.Property{
+		{
+			DataType:    []string{"text"},
+			Description: "The generated response by the LLM",
+			Name:        "semanticMeaning",
+		},
+	},
+}
 
-		err = client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
-		if err != nil {
-			return err
-		}
-		log.Println("created Response class")
-	} else {
-		log.Println("Response class already exists")
-	}
+// promptClass holds the prompts as first created. Later properties are added
+// by the migrations following it.
+var promptClass = &models.Class{
+	Class:       PromptClass,
+	Description: "This class holds information regarding the prompt, code and count of queries regarding ones codebase",
+	Vectorizer:  "text2vec-transformers",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{},
+	},
+	Properties: []*models.Property{
+		skippedProperty("instruct", "text", "The specific instruct or question prepended to the code", ""),
+		skippedProperty("instructType", "text", "instruct type", ""),
+		{
+			DataType:    []string{"text"},
+			Description: "The code which is targeted in the prompt",
+			Name:        "code",
+		},
+		skippedProperty("hasResponse", ResponseClass, "", ""),
+		skippedProperty("hasSemanticMeaning", SemanticMeaningClass, "", ""),
+		skippedProperty("rank", "int", "The relative rank for this response against other ones regarding the same code", ""),
+		skippedProperty("gitURL", "text", "A link to the git blob containing the code with the line and character position of the prompt", ""),
+	},
+}
 
-	exists, err = client.Schema().ClassExistenceChecker().WithClassName("SemanticMeaning").Do(context.Background())
-	if err != nil {
-		return err
-	}
+// hasPromptProperty refers from a semantic meaning to its prompts.
+var hasPromptProperty = skippedProperty("hasPrompt", PromptClass, "", "")
+
+// modelProperty records which model generated the response of a prompt.
+var modelProperty = &models.Property{
+	DataType:    []string{"text"},
+	Description: "The model which generated the response",
+	Name:        "model",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{
+			"skip": true,
+		},
+	},
+}
 
-	if !exists {
-		classObj := &models.Class{
-			Class:       "SemanticMeaning",
-			Description: "This class contains the semantic Meaning of the code",
-			Vectorizer:  "text2vec-transformers",
-			ModuleConfig: map[string]interface{}{
-				"text2vec-transformers": map[string]interface{}{},
-			},
-			Properties: []*models.Property{
-				{
-					DataType:    []string{"text"},
-					Description: "The generated response by the LLM",
-					Name:        "semanticMeaning",
-				},
-			},
-		}
-		err = client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
-		if err != nil {
-			return err
-		}
-		log.Println("created semanticMeaning class")
-	} else {
-		log.Println("semanticMeaning class already exists")
-	}
+// upvotesProperty and downvotesProperty count the votes of a prompt.
+var upvotesProperty = &models.Property{
+	DataType:    []string{"int"},
+	Description: "The number of upvotes of the response",
+	Name:        "upvotes",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{
+			"skip": true,
+		},
+	},
+}
 
-	exists, err = client.Schema().ClassExistenceChecker().WithClassName("Prompt").Do(context.Background())
-	if err != nil {
-		return err
-	}
+var downvotesProperty = &models.Property{
+	DataType:    []string{"int"},
+	Description: "The number of downvotes of the response",
+	Name:        "downvotes",
+	ModuleConfig: map[string]interface{}{
+		"text2vec-transformers": map[string]interface{}{
+			"skip": true,
+		},
+	},
+}
 
-	if !exists {
-		classObj := &models.Class{
-			Class:       "Prompt",
-			Description: "This class holds information regarding the prompt, code and count of queries regarding ones codebase",
-			Vectorizer:  "text2vec-transformers",
-			ModuleConfig: map[string]interface{}{
-				"text2vec-transformers": map[string]interface{}{},
-			},
-			Properties: []*models.Property{
-				{
-					DataType:    []string{"text"},
-					Description: "The specific instruct or question prepended to the code",
-					Name:        "instruct",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-				{
-					DataType:    []string{"text"},
-					Description: "instruct type",
-					Name:        "instructType",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-				{
-					DataType:    []string{"text"},
-					Description: "The code which is targeted in the prompt",
-					Name:        "code",
-				},
-				{
-					DataType: []string{"Response"},
-					Name:     "hasResponse",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-				{
-					DataType: []string{"SemanticMeaning"},
-					Name:     "hasSemanticMeaning",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-				{
-					DataType:    []string{"int"},
-					Description: "The relative rank for this response against other ones regarding the same code",
-					Name:        "rank",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-				{
-					DataType:    []string{"text"},
-					Description: "A link to the git blob containing the code with the line and character position of the prompt",
-					Name:        "gitURL",
-					ModuleConfig: map[string]interface{}{
-						"text2vec-transformers": map[string]interface{}{
-							"skip": true,
-						},
-					},
-				},
-			},
-		}
+// examplesProperty records the prompts used as few-shot context.
+var examplesProperty = skippedProperty("examples", "text[]", "The IDs of the prompts whose answers were given to the model as examples", models.PropertyTokenizationField)
+
+// fingerprintSchema holds the properties of a prompt derived from its code
+// and its gitURL, see Fingerprint.
+var fingerprintSchema = []*models.Property{
+	skippedProperty("codeHash", "text", "The hash of the normalized code", models.PropertyTokenizationField),
+	skippedProperty("minhash", "int[]", "The MinHash signature of the code", ""),
+	skippedProperty("minhashBands", "text[]", "The bands of the MinHash signature used to find near duplicate code", models.PropertyTokenizationField),
+	skippedProperty("language", "text", "The detected language of the code", models.PropertyTokenizationField),
+	skippedProperty("functionName", "text", "The name of the function or class the code defines", models.PropertyTokenizationField),
+	skippedProperty("functionKind", "text", "Whether the code defines a function, method, class or type", models.PropertyTokenizationField),
+	skippedProperty("signature", "text", "The header of the definition", ""),
+	skippedProperty("bodyStart", "int", "The first line of the body of the definition", ""),
+	skippedProperty("bodyEnd", "int", "The last line of the body of the definition", ""),
+	skippedProperty("repository", "text", "The repository the code was taken from", models.PropertyTokenizationField),
+}
 
-		err = client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
-		if err != nil {
-			return err
-		}
-		log.Println("created Prompt class")
-
-		prop := &models.Property{
-			DataType: []string{"Prompt"},
-			Name:     "hasPrompt",
-			ModuleConfig: map[string]interface{}{
-				"text2vec-transformers": map[string]interface{}{
-					"skip": true,
-				},
+// skippedProperty describes a property that is not vectorized.
+func skippedProperty(name string, dataType string, description string, tokenization string) *models.Property {
+	return &models.Property{
+		DataType:     []string{dataType},
+		Description:  description,
+		Name:         name,
+		Tokenization: tokenization,
+		ModuleConfig: map[string]interface{}{
+			"text2vec-transformers": map[string]interface{}{
+				"skip": true,
 			},
-		}
-		err = client.Schema().PropertyCreator().WithClassName("SemanticMeaning").WithProperty(prop).Do(context.Background())
-		if err != nil {
-
-			log.Printf("Error creating property: %v\n", err)
-			return err
-		}
-	} else {
-		log.Println("Prompt class already exists")
+		},
 	}
-
-	return nil
 }
 
-func createClass(className, description, vectorizer string, properties []*models.Property) error {
+// fingerprintProperties returns the properties storing fp.
+func fingerprintProperties(fp Fingerprint) map[string]interface{} {
+	return map[string]interface{}{
+		"codeHash":     fp.CodeHash,
+		"minhash":      fp.Signature,
+		"minhashBands": fp.Signature.Bands(),
+		"language":     fp.Function.Language,
+		"functionName": fp.Function.Name,
+		"functionKind": fp.Function.Kind,
+		"signature":    fp.Function.Signature,
+		"bodyStart":    fp.Function.Body.Start,
+		"bodyEnd":      fp.Function.Body.End,
+		"repository":   fp.Repository,
+	}
+}
 
-	client, err := loadClient()
+// ensureProperty adds prop to an existing class unless it is already present.
+func ensureProperty(ctx context.Context, client *weaviate.Client, className string, prop *models.Property) error {
+	class, err := client.Schema().ClassGetter().WithClassName(className).Do(ctx)
 	if err != nil {
 		return err
 	}
 
-	classObj := &models.Class{
-		Class:       className,
-		Description: description,
-		Vectorizer:  vectorizer,
-		Properties:  properties,
+	for _, existing := range class.Properties {
+		if existing.Name == prop.Name {
+			return nil
+		}
 	}
 
-	// Create or update the class in Weaviate
-	err = client.Schema().ClassCreator().WithClass(classObj).Do(context.Background())
+	err = client.Schema().PropertyCreator().WithClassName(className).WithProperty(prop).Do(ctx)
 	if err != nil {
 		return err
 	}
+	log.Printf("added property %s to %s class\n", prop.Name, className)
 
 	return nil
 }
 
-func CreatePromptObject(instruct string, instructType string, code string, class string, gitURL string) (string, error) {
-	client, err := loadClient()
-	if err != nil {
-		return "", err
-	}
-
+// promptProperties returns the properties of a new prompt.
+func promptProperties(prompt PromptObject) map[string]interface{} {
 	dataSchema := map[string]interface{}{
-		"instruct":     instruct,
-		"code":         code,
+		"instruct":     prompt.Instruct,
+		"code":         prompt.Code,
 		"rank":         1,
-		"gitURL":       gitURL,
-		"instructType": instructType,
+		"gitURL":       prompt.GitURL,
+		"instructType": prompt.InstructType,
+		"model":        prompt.Model,
+		"upvotes":      0,
+		"downvotes":    0,
 	}
-
-	weaviateObject, err := client.Data().Creator().
-		WithClassName(class).
-		WithProperties(dataSchema).
-		Do(context.Background())
-	if err != nil {
-		return "", err
+	if len(prompt.Examples) > 0 {
+		dataSchema["examples"] = prompt.Examples
+	}
+	for name, value := range fingerprintProperties(NewFingerprint(prompt.Code, prompt.GitURL)) {
+		dataSchema[name] = value
 	}
 
-	return string(weaviateObject.Object.ID), nil
+	return dataSchema
 }
 
-func UpdateRankPrompt(prompt map[string]interface{}, upvote bool) error {
-	id, ok := prompt["id"].(string)
-	if !ok {
-		return errors.New("ID not found in request body")
+// CreatePromptWithResponse stores a prompt together with its response in one
+// batch. Their IDs are derived from the content, so a retried write finds the
+// prompt of the first one instead of creating a duplicate; created is false
+// then. If only part of the batch is stored, that part is deleted again.
+func (c *Client) CreatePromptWithResponse(ctx context.Context, prompt PromptObject, response string) (string, bool, error) {
+	client := c.client
+
+	promptID := PromptUUID(prompt, response)
+	responseID := ResponseUUID(promptID)
+
+	// writing the prompt again would reset its votes
+	exists, err := client.Data().Checker().
+		WithClassName(PromptClass).
+		WithID(promptID).
+		Do(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if exists {
+		return promptID, false, nil
 	}
 
-	log.Printf("%v\n", id)
+	properties := promptProperties(prompt)
+	properties["hasResponse"] = []interface{}{Beacon(ResponseClass, responseID)}
 
-	client, err := loadClient()
-	if err != nil {
-		return err
+	results, err := client.Batch().ObjectsBatcher().
+		WithObjects(
+			&models.Object{
+				Class:      ResponseClass,
+				ID:         strfmt.UUID(responseID),
+				Properties: map[string]interface{}{"response": response},
+			},
+			&models.Object{
+				Class:      PromptClass,
+				ID:         strfmt.UUID(promptID),
+				Properties: properties,
+			},
+		).
+		Do(ctx)
+	if err == nil {
+		err = batchError(results)
 	}
-
-	promptProperties, err := RetrieveProperties(id)
 	if err != nil {
-		return err
+		c.discardBatch(ctx, results)
+		return "", false, err
 	}
 
-	var rank int
-	if upvote {
-		rank = promptProperties.Rank + 1
-	} else {
-		rank = promptProperties.Rank - 1
-	}
+	return promptID, true, nil
+}
+
+// SetVotesPrompt overwrites the votes and the rank of a prompt. They are
+// derived from the recorded votes, so writing them is idempotent.
+func (c *Client) SetVotesPrompt(ctx context.Context, id string, votes Votes) error {
+	client := c.client
 
-	err = client.Data().Updater().
+	err := client.Data().Updater().
 		WithMerge().
 		WithID(id).
 		WithClassName("Prompt").
 		WithProperties(map[string]interface{}{
-			"rank": rank,
+			"rank":      votes.Rank,
+			"upvotes":   votes.Upvotes,
+			"downvotes": votes.Downvotes,
 		}).
-		Do(context.Background())
+		Do(ctx)
 	if err != nil {
 		return err
 	}
@@ -301,148 +348,127 @@ func UpdateRankPrompt(prompt map[string]interface{}, upvote bool) error {
 	return nil
 }
 
-func CreateResponseObject(response string, class string) (string, error) {
-	client, err := loadClient()
-	if err != nil {
-		return "", err
-	}
+// SetFingerprintPrompt stores the fingerprint of code and gitURL on a prompt
+// created before fingerprints were recorded.
+func (c *Client) SetFingerprintPrompt(ctx context.Context, id string, code string, gitURL string) error {
+	client := c.client
 
-	dataSchema := map[string]interface{}{
-		strings.ToLower(class): response,
-	}
-
-	weaviateObject, err := client.Data().Creator().
-		WithClassName(class).
-		WithProperties(dataSchema).
-		Do(context.Background())
-
-	if err != nil {
-		return "", err
-	}
-
-	return string(weaviateObject.Object.ID), nil
+	return client.Data().Updater().
+		WithMerge().
+		WithID(id).
+		WithClassName("Prompt").
+		WithProperties(fingerprintProperties(NewFingerprint(code, gitURL))).
+		Do(ctx)
 }
 
-func CreateSemanticMeaningObject(meaning string) (string, error) {
-	client, err := loadClient()
-	if err != nil {
-		return "", err
-	}
+// ReplaceResponse stores a new response of a prompt and makes it the only one
+// the prompt references. The new response is deleted again if the prompt
+// cannot be updated.
+func (c *Client) ReplaceResponse(ctx context.Context, promptID string, response string) error {
+	client := c.client
 
-	dataSchema := map[string]interface{}{
-		"semanticMeaning": meaning,
-	}
+	responseID := ResponseUUID(promptID)
 
-	weaviateObject, err := client.Data().Creator().
-		WithClassName("semanticMeaning").
-		WithProperties(dataSchema).
-		Do(context.Background())
-
-	if err != nil {
-		return "", err
+	// the batch overwrites an earlier response with the same ID
+	results, err := client.Batch().ObjectsBatcher().
+		WithObjects(&models.Object{
+			Class:      ResponseClass,
+			ID:         strfmt.UUID(responseID),
+			Properties: map[string]interface{}{"response": response},
+		}).
+		Do(ctx)
+	if err == nil {
+		err = batchError(results)
 	}
-
-	return string(weaviateObject.Object.ID), nil
-}
-
-func CreateObject(vector []float32, body string, class string) error {
-	client, err := loadClient()
 	if err != nil {
 		return err
 	}
 
-	dataSchema := map[string]interface{}{
-		strings.ToLower(class): body,
-	}
-
-	_, err = client.Data().Creator().
-		WithClassName(class).
-		WithProperties(dataSchema).
-		WithVector(vector).
-		Do(context.Background())
-
+	err = client.Data().ReferenceReplacer().
+		WithClassName(PromptClass).
+		WithID(promptID).
+		WithReferenceProperty("hasResponse").
+		WithReferences(&models.MultipleRef{
+			client.Data().ReferencePayloadBuilder().
+				WithClassName(ResponseClass).
+				WithID(responseID).
+				Payload(),
+		}).
+		Do(ctx)
 	if err != nil {
+		c.discard(context.WithoutCancel(ctx), ResponseClass, responseID)
 		return err
 	}
 
 	return nil
 }
 
-func loadClient() (*weaviate.Client, error) {
-	cfg := weaviate.Config{
-		Host:       os.Getenv("WEAVIATE_HOST"),
-		Scheme:     os.Getenv("WEAVIATE_SCHEME"),
-		AuthConfig: auth.ApiKey{Value: os.Getenv("WEAVIATE_KEY")},
-	}
-
-	client, err := weaviate.NewClient(cfg)
-	if err != nil {
-		return nil, err
-	}
-	return client, nil
-}
-
-func CreateResponseReferences(PromptID string, ResponseID string) error {
-	client, err := loadClient()
-	if err != nil {
-		return err
-	}
+// ClearResponsesPrompt removes the references of a prompt to its responses
+// after they were deleted, so the prompt counts as unanswered.
+func (c *Client) ClearResponsesPrompt(ctx context.Context, promptID string) error {
+	client := c.client
 
-	err = client.Data().ReferenceCreator().
-		WithClassName("Prompt").
-		WithID(PromptID).
+	return client.Data().ReferenceReplacer().
+		WithClassName(PromptClass).
+		WithID(promptID).
 		WithReferenceProperty("hasResponse").
-		WithReference(client.Data().ReferencePayloadBuilder().
-			WithClassName("Response").
-			WithID(ResponseID).
-			Payload()).
-		Do(context.Background())
-	if err != nil {
-		return err
-	}
-
-	return nil
+		WithReferences(&models.MultipleRef{}).
+		Do(ctx)
 }
 
-func CreateReferencePromptToSemanticMeaning(PromptID string, semanticMeaningID string) error {
-	client, err := loadClient()
+// SetSemanticMeaningPrompt stores the semantic meaning of a prompt and links
+// both. The ID of the meaning is derived from the prompt, so describing the
+// prompt again, for example by a job delivered twice, replaces the meaning
+// instead of linking a second one.
+func (c *Client) SetSemanticMeaningPrompt(ctx context.Context, promptID string, meaning string) error {
+	client := c.client
+
+	meaningID := SemanticMeaningUUID(promptID)
+
+	// the batch overwrites an earlier meaning with the same ID
+	results, err := client.Batch().ObjectsBatcher().
+		WithObjects(&models.Object{
+			Class: SemanticMeaningClass,
+			ID:    strfmt.UUID(meaningID),
+			Properties: map[string]interface{}{
+				"semanticMeaning": meaning,
+				"hasPrompt":       []interface{}{Beacon(PromptClass, promptID)},
+			},
+		}).
+		Do(ctx)
+	if err == nil {
+		err = batchError(results)
+	}
 	if err != nil {
 		return err
 	}
 
-	err = client.Data().ReferenceReplacer().
-		WithClassName("Prompt").
-		WithID(PromptID).
+	return client.Data().ReferenceReplacer().
+		WithClassName(PromptClass).
+		WithID(promptID).
 		WithReferenceProperty("hasSemanticMeaning").
 		WithReferences(&models.MultipleRef{
 			client.Data().ReferencePayloadBuilder().
-				WithClassName("SemanticMeaning").
-				WithID(semanticMeaningID).
+				WithClassName(SemanticMeaningClass).
+				WithID(meaningID).
 				Payload(),
 		}).
-		Do(context.Background())
-	if err != nil {
-		return err
-	}
-
-	return nil
+		Do(ctx)
 }
 
-func CreateReferenceSemanticMeaningToPrompt(semanticMeaningID string, PromptID string) error {
-	client, err := loadClient()
-	if err != nil {
-		return err
+func (c *Client) CreateObject(ctx context.Context, vector []float32, body string, class string) error {
+	client := c.client
+
+	dataSchema := map[string]interface{}{
+		strings.ToLower(class): body,
 	}
 
-	err = client.Data().ReferenceCreator().
-		WithClassName("SemanticMeaning").
-		WithID(semanticMeaningID).
-		WithReferenceProperty("hasPrompt").
-		WithReference(client.Data().ReferencePayloadBuilder().
-			WithClassName("Prompt").
-			WithID(PromptID).
-			Payload()).
-		Do(context.Background())
+	_, err := client.Data().Creator().
+		WithClassName(class).
+		WithProperties(dataSchema).
+		WithVector(vector).
+		Do(ctx)
+
 	if err != nil {
 		return err
 	}
@@ -450,17 +476,18 @@ func CreateReferenceSemanticMeaningToPrompt(semanticMeaningID string, PromptID s
 	return nil
 }
 
-func DeleteAllClasses() {
-	client, err := loadClient()
-	if err != nil {
-		log.Printf("Error loading client: %v\n", err)
-		return
-	}
-
-	classes := []string{"Prompt", "Response", "SemanticMeaning"}
+func (c *Client) DeleteAllClasses(ctx context.Context) {
+	client := c.client
 
-	for _, ch := range classes {
-		err = client.Schema().ClassDeleter().WithClassName(ch).Do(context.Background())
+	for _, ch := range Classes {
+		err := client.Schema().ClassDeleter().WithClassName(ch).Do(ctx)
+		if err != nil {
+			log.Printf("Error deleting class %s: %v\n", ch, err)
+		}
+	}
 
+	err := c.resetSchemaVersion(ctx)
+	if err != nil {
+		log.Printf("Error resetting schema version: %v\n", err)
 	}
 }
@@ -4,33 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"math/rand"
 	"strings"
 	"time"
 
+	"github.com/rwth-acis/modernizer/codeanalysis"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/rwth-acis/modernizer/ranking"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/filters"
 	"github.com/weaviate/weaviate-go-client/v4/weaviate/graphql"
 	"github.com/weaviate/weaviate/entities/models"
 )
 
-func RetrieveProperties(id string) (PromptProperties, error) {
-	client, err := loadClient()
-	if err != nil {
-		return PromptProperties{}, err
-	}
+// ErrNoResponse is returned for a prompt whose response was removed, for
+// example by a reset of the responses.
+var ErrNoResponse = errkind.New(errkind.NotFound, "prompt has no response")
+
+func (c *Client) RetrieveProperties(ctx context.Context, id string) (PromptProperties, error) {
+	client := c.client
 
 	objects, err := client.Data().ObjectsGetter().
 		WithID(id).
 		WithClassName("Prompt").
-		Do(context.Background())
+		Do(ctx)
 	if err != nil {
 		return PromptProperties{}, err
 	}
 
 	if len(objects) == 0 {
-		return PromptProperties{}, fmt.Errorf("no object found with ID: %s", id)
+		return PromptProperties{}, errkind.New(errkind.NotFound, "no object found with ID: %s", id)
 	}
 
 	propertiesJSON, err := json.Marshal(objects[0].Properties)
@@ -43,7 +46,19 @@ func RetrieveProperties(id string) (PromptProperties, error) {
 		HasResponse []map[string]interface{} `json:"hasResponse"`
 		Instruct    string                   `json:"instruct"`
 		Rank        int                      `json:"rank"`
+		Upvotes     int                      `json:"upvotes"`
+		Downvotes   int                      `json:"downvotes"`
 		GitURL      string                   `json:"gitURL"`
+		Model       string                   `json:"model"`
+
+		Language     codeanalysis.Language `json:"language"`
+		FunctionName string                `json:"functionName"`
+		FunctionKind codeanalysis.Kind     `json:"functionKind"`
+		Signature    string                `json:"signature"`
+		BodyStart    int                   `json:"bodyStart"`
+		BodyEnd      int                   `json:"bodyEnd"`
+		Repository   string                `json:"repository"`
+		Examples     []string              `json:"examples"`
 	}
 
 	if err := json.Unmarshal(propertiesJSON, &temp); err != nil {
@@ -58,11 +73,15 @@ func RetrieveProperties(id string) (PromptProperties, error) {
 	objects, err = client.Data().ObjectsGetter().
 		WithID(responseID).
 		WithClassName("Response").
-		Do(context.Background())
+		Do(ctx)
 	if err != nil {
 		return PromptProperties{}, err
 	}
 
+	if len(objects) == 0 {
+		return PromptProperties{}, errkind.New(errkind.NotFound, "no object found with ID: %s", responseID)
+	}
+
 	propertiesJSON, err = json.Marshal(objects[0].Properties)
 	if err != nil {
 		return PromptProperties{}, err
@@ -83,7 +102,21 @@ func RetrieveProperties(id string) (PromptProperties, error) {
 		HasResponse: responseText,
 		Instruct:    temp.Instruct,
 		Rank:        temp.Rank,
+		Upvotes:     temp.Upvotes,
+		Downvotes:   temp.Downvotes,
 		GitURL:      temp.GitURL,
+		Model:       temp.Model,
+		Repository:  temp.Repository,
+		Examples:    temp.Examples,
+	}
+	if temp.Language != "" {
+		promptProperties.Function = &codeanalysis.Function{
+			Language:  temp.Language,
+			Kind:      temp.FunctionKind,
+			Name:      temp.FunctionName,
+			Signature: temp.Signature,
+			Body:      codeanalysis.Span{Start: temp.BodyStart, End: temp.BodyEnd},
+		}
 	}
 
 	return promptProperties, nil
@@ -97,30 +130,36 @@ func extractUUIDFromHasResponse(hasResponse []map[string]interface{}) (string, e
 			return uuid, nil
 		}
 	}
-	return "", fmt.Errorf("no UUID found in hasResponse field")
+	return "", ErrNoResponse
 }
 
-func RetrievePromptCount(code string) (int, error) {
-	client, err := loadClient()
-	if err != nil {
-		return 0, err
+// RetrievePromptCount counts the prompts matching match. Only normalized
+// matches are counted by their hash alone. Exact and fuzzy matches and prompts
+// of one model have to be compared one by one, so at most matchLimit of them
+// are counted.
+func (c *Client) RetrievePromptCount(ctx context.Context, match CodeMatch, model string) (int, error) {
+	if (match.Mode != MatchNormalized && match.Code != "") || model != "" {
+		prompts, err := c.matchingPrompts(ctx, match, "", model)
+		if err != nil {
+			return 0, err
+		}
+
+		return len(prompts), nil
 	}
 
-	count := graphql.Field{
-		Name: "code", Fields: []graphql.Field{
+	client := c.client
+
+	where := match.where()
+
+	meta := graphql.Field{
+		Name: "meta", Fields: []graphql.Field{
 			{Name: "count"},
 		},
 	}
 
-	where := filters.Where().
-		WithPath([]string{"code"}).
-		WithOperator(filters.Like).
-		WithValueText(code)
-
-	ctx := context.Background()
 	result, err := client.GraphQL().Aggregate().
 		WithClassName("Prompt").
-		WithFields(count).
+		WithFields(meta).
 		WithWhere(where).
 		Do(ctx)
 	if err != nil {
@@ -131,44 +170,36 @@ func RetrievePromptCount(code string) (int, error) {
 		return 0, errors.New(result.Errors[0].Message)
 	}
 
-	getPrompt, ok := result.Data["Aggregate"].(map[string]interface{})
+	aggregate, ok := result.Data["Aggregate"].(map[string]interface{})
 	if !ok {
 		return 0, errors.New("unexpected response format: 'Aggregate' field not found or not a map")
 	}
 
-	promptData, ok := getPrompt["Prompt"].([]interface{})
+	promptData, ok := aggregate["Prompt"].([]interface{})
 	if !ok || len(promptData) == 0 {
 		return 0, errors.New("unexpected response format: 'Prompt' field not found or not a list")
 	}
 
-	prompt := promptData[0].(map[string]interface{})
+	prompt, ok := promptData[0].(map[string]interface{})
 	if !ok {
 		return 0, errors.New("unexpected response format: prompt data is not a map")
 	}
 
-	codeMap, ok := prompt["code"].(map[string]interface{})
+	metaMap, ok := prompt["meta"].(map[string]interface{})
 	if !ok {
-		return 0, errors.New("code field not found in prompt data or not a map")
+		return 0, errors.New("meta field not found in prompt data or not a map")
 	}
 
-	countValue, ok := codeMap["count"]
-	if !ok {
-		return 0, errors.New("count not found in code map")
-	}
-
-	countFloat, ok := countValue.(float64)
+	count, ok := metaMap["count"].(float64)
 	if !ok {
 		return 0, errors.New("count is not a number")
 	}
 
-	return int(countFloat), nil
+	return int(count), nil
 }
 
-func RetrieveResponseByID(id string) (interface{}, error) {
-	client, err := loadClient()
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) RetrieveResponseByID(ctx context.Context, id string) (string, error) {
+	client := c.client
 
 	fields := []graphql.Field{
 		{Name: "hasResponse", Fields: []graphql.Field{
@@ -187,7 +218,6 @@ func RetrieveResponseByID(id string) (interface{}, error) {
 
 	withNearObject.WithID(id)
 
-	ctx := context.Background()
 	result, err := client.GraphQL().Get().
 		WithClassName("Prompt").
 		WithFields(fields...).
@@ -195,46 +225,31 @@ func RetrieveResponseByID(id string) (interface{}, error) {
 		WithNearObject(withNearObject).
 		Do(ctx)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	response, err := ExtractResponseFromGraphQL(result)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	return response, nil
 }
 
-func ResponseList(code string, instructtype string) ([]string, error) {
+func (c *Client) ResponseList(ctx context.Context, match CodeMatch, instructtype string, model string) ([]string, error) {
 
-	responses, err := RetrieveResponsesRankDesc(code, instructtype)
+	promptData, err := c.RetrieveResponsesRankDesc(ctx, match, instructtype, model)
 	if err != nil {
 		return nil, err
 	}
 
-	getPrompt, ok := responses.Data["Get"].(map[string]interface{})
-	if !ok {
-		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
-	}
-
-	promptData, ok := getPrompt["Prompt"].([]interface{})
-	if !ok {
-		return nil, errors.New("unexpected response format: 'Prompt' field not found")
-	}
-
 	if len(promptData) == 0 {
-		return nil, errors.New("no prompt found")
+		return nil, errkind.New(errkind.NotFound, "no prompt found")
 	}
 
 	var RankIDs []string
 
-	for _, prompt := range promptData {
-		promptMap, ok := prompt.(map[string]interface{})
-		if !ok {
-			return nil, errors.New("unexpected response format: prompt data is not a map")
-		}
-
+	for _, promptMap := range promptData {
 		id, err := ExtractID(promptMap)
 		if err != nil {
 			return nil, err
@@ -246,59 +261,45 @@ func ResponseList(code string, instructtype string) ([]string, error) {
 	return RankIDs, nil
 }
 
-func RetrieveBestResponse(code string) (ResponseData, error) {
+// RetrieveBestResponse returns the response to code with the highest score
+// according to strategy. Ties are broken at random.
+func (c *Client) RetrieveBestResponse(ctx context.Context, match CodeMatch, model string, strategy ranking.Strategy) (ResponseData, error) {
 
-	responses, err := RetrieveResponsesRankDesc(code, "")
+	promptData, err := c.RetrieveResponsesRankDesc(ctx, match, "", model)
 	if err != nil {
 		return ResponseData{}, err
 	}
 
-	getPrompt, ok := responses.Data["Get"].(map[string]interface{})
-	if !ok {
-		return ResponseData{}, errors.New("unexpected response format: 'Get' field not found or not a map")
-	}
-
-	promptData, ok := getPrompt["Prompt"].([]interface{})
-	if !ok || len(promptData) == 0 {
-		return ResponseData{}, errors.New("unexpected response format: 'Prompt' field not found or empty list")
+	promptData = answered(promptData)
+	if len(promptData) == 0 {
+		return ResponseData{}, errkind.New(errkind.NotFound, "no prompt found")
 	}
 
-	var highestRank int
-	var highestRankPrompts []map[string]interface{}
+	var highestScore float64
+	var highestScorePrompts []map[string]interface{}
 
-	for _, prompt := range promptData {
-		promptMap, ok := prompt.(map[string]interface{})
-		if !ok {
-			return ResponseData{}, errors.New("unexpected response format: prompt data is not a map")
-		}
-
-		rankInterface, ok := promptMap["rank"]
-		log.Printf("%v", rankInterface)
-		if !ok {
-			return ResponseData{}, errors.New("rank field not found in prompt data")
-		}
-
-		rank, ok := rankInterface.(float64)
-		if !ok {
-			return ResponseData{}, errors.New("rank field is not a number")
+	for _, promptMap := range promptData {
+		votes, err := ExtractVotes(promptMap)
+		if err != nil {
+			return ResponseData{}, err
 		}
 
-		rankInt := int(rank)
+		score := strategy.Score(votes.Upvotes, votes.Downvotes)
 
-		if rankInt > highestRank {
-			highestRank = rankInt
-			highestRankPrompts = []map[string]interface{}{promptMap}
-		} else if rankInt == highestRank {
-			highestRankPrompts = append(highestRankPrompts, promptMap)
+		if len(highestScorePrompts) == 0 || score > highestScore {
+			highestScore = score
+			highestScorePrompts = []map[string]interface{}{promptMap}
+		} else if score == highestScore {
+			highestScorePrompts = append(highestScorePrompts, promptMap)
 		}
 	}
 
-	if len(highestRankPrompts) > 0 {
+	if len(highestScorePrompts) > 0 {
 
 		source := rand.NewSource(time.Now().UnixNano())
 		rng := rand.New(source)
-		randomIndex := rng.Intn(len(highestRankPrompts))
-		selectedPrompt := highestRankPrompts[randomIndex]
+		randomIndex := rng.Intn(len(highestScorePrompts))
+		selectedPrompt := highestScorePrompts[randomIndex]
 
 		response, err := ExtractResponse(selectedPrompt)
 		if err != nil {
@@ -315,45 +316,45 @@ func RetrieveBestResponse(code string) (ResponseData, error) {
 			return ResponseData{}, err
 		}
 
+		votes, err := ExtractVotes(selectedPrompt)
+		if err != nil {
+			return ResponseData{}, err
+		}
+
 		responseData := ResponseData{
-			PromptID: id,
-			Response: response,
-			Instruct: instruct,
+			PromptID:  id,
+			Response:  response,
+			Instruct:  instruct,
+			Model:     ExtractModel(selectedPrompt),
+			Upvotes:   votes.Upvotes,
+			Downvotes: votes.Downvotes,
+			Score:     &highestScore,
+			Strategy:  strategy.Name(),
 		}
 
 		return responseData, nil
 	}
 
-	return ResponseData{}, errors.New("no prompt found")
+	return ResponseData{}, errkind.New(errkind.NotFound, "no prompt found")
 
 }
 
-func RetrieveRandomResponse(code string) (ResponseData, error) {
+func (c *Client) RetrieveRandomResponse(ctx context.Context, match CodeMatch, model string) (ResponseData, error) {
 
-	responses, err := RetrieveResponsesRankDesc(code, "")
+	promptData, err := c.RetrieveResponsesRankDesc(ctx, match, "", model)
 	if err != nil {
 		return ResponseData{}, err
 	}
 
-	getPrompt, ok := responses.Data["Get"].(map[string]interface{})
-	if !ok {
-		return ResponseData{}, errors.New("unexpected response format: 'Get' field not found or not a map")
-	}
-
-	promptData, ok := getPrompt["Prompt"].([]interface{})
-	if !ok || len(promptData) == 0 {
-		return ResponseData{}, errors.New("unexpected response format: 'Prompt' field not found or empty list")
+	promptData = answered(promptData)
+	if len(promptData) == 0 {
+		return ResponseData{}, errkind.New(errkind.NotFound, "no prompt found")
 	}
 
 	source := rand.NewSource(time.Now().UnixNano())
 	rng := rand.New(source)
 	randomIndex := rng.Intn(len(promptData))
-	selectedPrompt := promptData[randomIndex]
-
-	selectedPromptMap, ok := selectedPrompt.(map[string]interface{})
-	if !ok {
-		return ResponseData{}, errors.New("unexpected response format: selected prompt data is not a map")
-	}
+	selectedPromptMap := promptData[randomIndex]
 
 	response, err := ExtractResponse(selectedPromptMap)
 	if err != nil {
@@ -374,64 +375,157 @@ func RetrieveRandomResponse(code string) (ResponseData, error) {
 		PromptID: id,
 		Response: response,
 		Instruct: instruct,
+		Model:    ExtractModel(selectedPromptMap),
 	}
 
 	return responseData, nil
 }
 
-func RetrieveResponsesRankDesc(code string, instructType string) (*models.GraphQLResponse, error) {
-
-	client, err := loadClient()
-	if err != nil {
-		return nil, err
-	}
-
-	fields := []graphql.Field{
-		{Name: "hasResponse", Fields: []graphql.Field{
+// RetrieveResponsesRankDesc returns the prompts matching match, instructType
+// and model together with their responses, by descending rank.
+func (c *Client) RetrieveResponsesRankDesc(ctx context.Context, match CodeMatch, instructType string, model string) ([]map[string]interface{}, error) {
+	return c.matchingPrompts(ctx, match, instructType, model,
+		graphql.Field{Name: "hasResponse", Fields: []graphql.Field{
 			{Name: "... on Response", Fields: []graphql.Field{
 				{Name: "response"},
 			}},
 		}},
-		{Name: "_additional", Fields: []graphql.Field{
-			{Name: "id"},
-		}},
-		{Name: "rank"},
-		{Name: "instruct"},
-	}
+		graphql.Field{Name: "rank"},
+		graphql.Field{Name: "upvotes"},
+		graphql.Field{Name: "downvotes"},
+		graphql.Field{Name: "instruct"},
+	)
+}
 
-	if instructType == "" {
-		instructType = "*" // wildcard to match all instruct types
+// answered keeps the prompts which have a response. A reset of the responses
+// leaves prompts without one.
+func answered(promptData []map[string]interface{}) []map[string]interface{} {
+	kept := promptData[:0:0]
+	for _, promptMap := range promptData {
+		if _, err := ExtractResponse(promptMap); !errors.Is(err, ErrNoResponse) {
+			kept = append(kept, promptMap)
+		}
 	}
+	return kept
+}
 
-	where := filters.Where().
-		WithOperator(filters.And).
-		WithOperands([]*filters.WhereBuilder{
-			filters.Where().
-				WithPath([]string{"code"}).
-				WithOperator(filters.Like).
-				WithValueText(code),
-			filters.Where().
-				WithPath([]string{"instructType"}).
-				WithOperator(filters.Like).
-				WithValueText(instructType),
-		})
+// matchLimit caps the number of prompts considered for one piece of code.
+const matchLimit = 1000
+
+// matchingPrompts returns the given fields of the prompts matching match,
+// instructType and model by descending rank. An empty instructType or model
+// matches every prompt.
+func (c *Client) matchingPrompts(ctx context.Context, match CodeMatch, instructType string, model string, fields ...graphql.Field) ([]map[string]interface{}, error) {
+	client := c.client
+
+	fields = append(fields,
+		graphql.Field{Name: "_additional", Fields: []graphql.Field{
+			{Name: "id"},
+		}},
+		graphql.Field{Name: "code"},
+		graphql.Field{Name: "codeHash"},
+		graphql.Field{Name: "minhash"},
+		graphql.Field{Name: "language"},
+		graphql.Field{Name: "functionName"},
+		graphql.Field{Name: "repository"},
+		graphql.Field{Name: "instructType"},
+		graphql.Field{Name: "model"},
+	)
+
+	where := match.where()
+	if instructType != "" {
+		where = filters.Where().
+			WithOperator(filters.And).
+			WithOperands([]*filters.WhereBuilder{
+				where,
+				filters.Where().
+					WithPath([]string{"instructType"}).
+					WithOperator(filters.Equal).
+					WithValueText(instructType),
+			})
+	}
+	where = withModelFilter(where, model)
 
 	rankDesc := graphql.Sort{
 		Path: []string{"rank"}, Order: graphql.Desc,
 	}
 
-	ctx := context.Background()
 	result, err := client.GraphQL().Get().
 		WithClassName("Prompt").
 		WithFields(fields...).
 		WithWhere(where).
 		WithSort(rankDesc).
+		WithLimit(matchLimit).
 		Do(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	getPrompt, ok := result.Data["Get"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
+	}
+
+	promptData, ok := getPrompt["Prompt"].([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Prompt' field not found")
+	}
+
+	var prompts []map[string]interface{}
+	for _, prompt := range promptData {
+		promptMap, ok := prompt.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected response format: prompt data is not a map")
+		}
+
+		if selectsPrompt(promptMap, match, instructType, model) {
+			prompts = append(prompts, promptMap)
+		}
+	}
+
+	return prompts, nil
+}
+
+// selectsPrompt reports whether a prompt fetched as candidate matches match,
+// instructType and model. instructType and model are tokenized into words, so
+// Equal also selects values that only share their words, such as llama3 and
+// llama3:8b.
+func selectsPrompt(promptMap map[string]interface{}, match CodeMatch, instructType string, model string) bool {
+	if t, _ := promptMap["instructType"].(string); instructType != "" && t != instructType {
+		return false
+	}
+	if model != "" && ExtractModel(promptMap) != model {
+		return false
+	}
+
+	code, _ := promptMap["code"].(string)
+	return match.Matches(code, ExtractFingerprint(promptMap))
+}
+
+// ExtractFingerprint reads the fields of a prompt needed by CodeMatch.
+func ExtractFingerprint(selectedPrompt map[string]interface{}) Fingerprint {
+	var fp Fingerprint
+	fp.CodeHash, _ = selectedPrompt["codeHash"].(string)
+	fp.Repository, _ = selectedPrompt["repository"].(string)
+	fp.Function.Name, _ = selectedPrompt["functionName"].(string)
+	language, _ := selectedPrompt["language"].(string)
+	fp.Function.Language = codeanalysis.Language(language)
+
+	values, _ := selectedPrompt["minhash"].([]interface{})
+	for _, value := range values {
+		v, ok := value.(float64)
+		if !ok {
+			fp.Signature = nil
+			return fp
+		}
+		fp.Signature = append(fp.Signature, int64(v))
+	}
+
+	return fp
 }
 
 func ExtractID(selectedPrompt map[string]interface{}) (string, error) {
@@ -461,7 +555,7 @@ func ExtractID(selectedPrompt map[string]interface{}) (string, error) {
 func ExtractResponse(selectedPromptMap map[string]interface{}) (string, error) {
 	hasResponse, ok := selectedPromptMap["hasResponse"].([]interface{})
 	if !ok || len(hasResponse) == 0 {
-		return "", errors.New("hasResponse field not found in prompt data or empty list")
+		return "", ErrNoResponse
 	}
 
 	firstResponseMap, ok := hasResponse[0].(map[string]interface{})
@@ -491,6 +585,52 @@ func ExtractInstruct(selectedPrompt map[string]interface{}) (string, error) {
 	return instruct, nil
 }
 
+// ExtractModel returns the model stored on a prompt. Prompts created before
+// models were recorded yield an empty string.
+func ExtractModel(selectedPrompt map[string]interface{}) string {
+	model, _ := selectedPrompt["model"].(string)
+	return model
+}
+
+// ExtractVotes reads the votes of a prompt. Prompts voted on before votes
+// were counted separately only carry a rank, which is converted into votes.
+func ExtractVotes(selectedPrompt map[string]interface{}) (Votes, error) {
+	rank, ok := selectedPrompt["rank"].(float64)
+	if !ok {
+		return Votes{}, errors.New("rank field not found in prompt data or not a number")
+	}
+
+	// the properties are missing on prompts created before they existed
+	upvotes, _ := selectedPrompt["upvotes"].(float64)
+	downvotes, _ := selectedPrompt["downvotes"].(float64)
+
+	votes := Votes{Rank: int(rank), Upvotes: int(upvotes), Downvotes: int(downvotes)}
+	if votes.Upvotes == 0 && votes.Downvotes == 0 {
+		votes.Upvotes, votes.Downvotes = ranking.LegacyVotes(votes.Rank)
+	}
+
+	return votes, nil
+}
+
+// withModelFilter narrows where down to the candidates generated by model,
+// which still have to be checked with selectsPrompt. An empty model leaves the
+// filter unchanged.
+func withModelFilter(where *filters.WhereBuilder, model string) *filters.WhereBuilder {
+	if model == "" {
+		return where
+	}
+
+	return filters.Where().
+		WithOperator(filters.And).
+		WithOperands([]*filters.WhereBuilder{
+			where,
+			filters.Where().
+				WithPath([]string{"model"}).
+				WithOperator(filters.Equal).
+				WithValueText(model),
+		})
+}
+
 func ExtractResponseFromGraphQL(query *models.GraphQLResponse) (string, error) {
 	getPrompt, ok := query.Data["Get"].(map[string]interface{})
 	if !ok {
@@ -499,7 +639,7 @@ func ExtractResponseFromGraphQL(query *models.GraphQLResponse) (string, error) {
 
 	promptData, ok := getPrompt["Prompt"].([]interface{})
 	if !ok || len(promptData) == 0 {
-		return "", errors.New("unexpected response format: 'Prompt' field not found or empty list")
+		return "", errkind.New(errkind.NotFound, "unexpected response format: 'Prompt' field not found or empty list")
 	}
 	selectedPrompt := promptData[0].(map[string]interface{})
 	if !ok {
@@ -514,65 +654,133 @@ func ExtractResponseFromGraphQL(query *models.GraphQLResponse) (string, error) {
 
 }
 
-func RetrieveHasSemanticMeaning(code string) (string, bool) {
-	client, err := loadClient()
-	if err != nil {
-		log.Printf("Error loading client: %v", err)
-	}
-
-	fields := []graphql.Field{
-		{Name: "hasSemanticMeaning", Fields: []graphql.Field{
+func (c *Client) RetrieveHasSemanticMeaning(ctx context.Context, match CodeMatch) (string, bool) {
+	promptData, err := c.matchingPrompts(ctx, match, "", "",
+		graphql.Field{Name: "hasSemanticMeaning", Fields: []graphql.Field{
 			{Name: "... on SemanticMeaning", Fields: []graphql.Field{
 				{Name: "semanticMeaning"},
 			}},
 		}},
+	)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return "", false
+	}
+
+	for _, selectedPrompt := range promptData {
+		// prompts whose semantic meaning job has not finished yet have no
+		// reference
+		hasSemanticMeaning, _ := selectedPrompt["hasSemanticMeaning"].([]interface{})
+		if len(hasSemanticMeaning) == 0 {
+			continue
+		}
+
+		firstSemanticMeaningMap, _ := hasSemanticMeaning[0].(map[string]interface{})
+
+		semanticMeaning, ok := firstSemanticMeaningMap["semanticMeaning"].(string)
+		if ok && semanticMeaning != "" {
+			return semanticMeaning, true
+		}
+	}
+
+	return "", false
+}
+
+// ListPrompts returns up to limit prompts following the prompt with ID after,
+// in the stable order of Weaviate's cursor API. An empty after starts at the
+// beginning.
+func (c *Client) ListPrompts(ctx context.Context, after string, limit int) ([]PromptSummary, error) {
+	client := c.client
+
+	reference := func(name string, class string) graphql.Field {
+		return graphql.Field{Name: name, Fields: []graphql.Field{
+			{Name: "... on " + class, Fields: []graphql.Field{
+				{Name: "_additional", Fields: []graphql.Field{
+					{Name: "id"},
+				}},
+			}},
+		}}
+	}
+
+	fields := []graphql.Field{
 		{Name: "_additional", Fields: []graphql.Field{
 			{Name: "id"},
 		}},
+		{Name: "code"},
+		{Name: "instruct"},
+		{Name: "instructType"},
+		{Name: "gitURL"},
+		{Name: "model"},
+		{Name: "language"},
+		reference("hasResponse", "Response"),
+		reference("hasSemanticMeaning", "SemanticMeaning"),
 	}
 
-	where := filters.Where().
-		WithPath([]string{"code"}).
-		WithOperator(filters.Equal).
-		WithValueText(code)
-
-	ctx := context.Background()
-	result, err := client.GraphQL().Get().
+	query := client.GraphQL().Get().
 		WithClassName("Prompt").
 		WithFields(fields...).
-		WithLimit(1).
-		WithWhere(where).
-		Do(ctx)
+		WithLimit(limit)
+	if after != "" {
+		query = query.WithAfter(after)
+	}
+
+	result, err := query.Do(ctx)
 	if err != nil {
-		log.Printf("error: %v", err)
+		return nil, err
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
 	}
 
 	getPrompt, ok := result.Data["Get"].(map[string]interface{})
-	if !ok || len(getPrompt) == 0 {
-		return "", false
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Get' field not found or not a map")
 	}
 
 	promptData, ok := getPrompt["Prompt"].([]interface{})
-	if !ok || len(promptData) == 0 {
-		return "", false
+	if !ok {
+		return nil, errors.New("unexpected response format: 'Prompt' field not found")
 	}
 
-	selectedPrompt := promptData[0].(map[string]interface{})
+	prompts := make([]PromptSummary, 0, len(promptData))
+	for _, prompt := range promptData {
+		promptMap, ok := prompt.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("unexpected response format: prompt data is not a map")
+		}
 
-	hasSemanticMeaning, _ := selectedPrompt["hasSemanticMeaning"].([]interface{})
+		id, err := ExtractID(promptMap)
+		if err != nil {
+			return nil, err
+		}
 
-	firstSemanticMeaningMap, _ := hasSemanticMeaning[0].(map[string]interface{})
+		hasResponse, _ := promptMap["hasResponse"].([]interface{})
+		hasSemanticMeaning, _ := promptMap["hasSemanticMeaning"].([]interface{})
 
-	semanticMeaning, _ := firstSemanticMeaningMap["semanticMeaning"].(string)
+		summary := PromptSummary{
+			ID:                 id,
+			HasResponse:        len(hasResponse) > 0,
+			HasSemanticMeaning: len(hasSemanticMeaning) > 0,
+		}
+		// prompts fingerprinted before their code was analyzed have no
+		// language yet
+		language, _ := promptMap["language"].(string)
+		summary.HasFingerprint = language != ""
+		summary.Code, _ = promptMap["code"].(string)
+		summary.Instruct, _ = promptMap["instruct"].(string)
+		summary.InstructType, _ = promptMap["instructType"].(string)
+		summary.GitURL, _ = promptMap["gitURL"].(string)
+		summary.Model, _ = promptMap["model"].(string)
 
-	return semanticMeaning, true
+		prompts = append(prompts, summary)
+	}
+
+	return prompts, nil
 }
 
-func GetSimilarSemanticMeaning(meaning string) ([]string, error) {
-	client, err := loadClient()
-	if err != nil {
-		log.Printf("Error loading client: %v", err)
-	}
+func (c *Client) GetSimilarSemanticMeaning(ctx context.Context, meaning string) ([]string, error) {
+	client := c.client
 
 	fields := []graphql.Field{
 		{Name: "hasPrompt", Fields: []graphql.Field{
@@ -593,7 +801,10 @@ func GetSimilarSemanticMeaning(meaning string) ([]string, error) {
 		WithClassName("SemanticMeaning").
 		WithFields(fields...).
 		WithNearText(withNearText).
-		Do(context.Background())
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	getMap, ok := result.Data["Get"].(map[string]interface{})
 	if !ok {
@@ -602,7 +813,7 @@ func GetSimilarSemanticMeaning(meaning string) ([]string, error) {
 
 	SemanticMeaningData, ok := getMap["SemanticMeaning"].([]interface{})
 	if !ok || len(SemanticMeaningData) == 0 {
-		return nil, errors.New("unexpected response format: 'Prompt' field not found or empty list")
+		return nil, errkind.New(errkind.NotFound, "unexpected response format: 'Prompt' field not found or empty list")
 	}
 
 	var gitURLs []string
@@ -634,63 +845,33 @@ func GetSimilarSemanticMeaning(meaning string) ([]string, error) {
 	return gitURLs, nil
 }
 
-func GetInstructTypes(code string) ([]string, error) {
-	client, err := loadClient()
-	if err != nil {
-		log.Printf("Error loading client: %v", err)
-	}
-
-	fields := []graphql.Field{
-		{Name: "instructType"},
-	}
-
-	where := filters.Where().
-		WithPath([]string{"code"}).
-		WithOperator(filters.Like).
-		WithValueText(code)
-
-	result, err := client.GraphQL().Get().
-		WithClassName("Prompt").
-		WithFields(fields...).
-		WithWhere(where).
-		Do(context.Background())
+func (c *Client) GetInstructTypes(ctx context.Context, match CodeMatch, model string) ([]string, error) {
+	promptData, err := c.matchingPrompts(ctx, match, "", model,
+		graphql.Field{Name: "instructType"},
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	uniqueExplanationStrings := ExtractExplanationStrings(result)
+	uniqueExplanationStrings := ExtractExplanationStrings(promptData)
 
 	log.Printf("uniqueExplanationStrings: %v", uniqueExplanationStrings)
 	return uniqueExplanationStrings, nil
 }
 
-func ExtractExplanationStrings(result *models.GraphQLResponse) []string {
+func ExtractExplanationStrings(promptList []map[string]interface{}) []string {
 	var explanationStrings []string
 
-	getMap, ok := result.Data["Get"].(map[string]interface{})
-	if !ok {
-		return explanationStrings
-	}
-
-	promptList, ok := getMap["Prompt"].([]interface{})
-	if !ok {
-		return explanationStrings
-	}
-
 	explanationSet := make(map[string]struct{})
-	for _, prompt := range promptList {
-		promptMap, ok := prompt.(map[string]interface{})
+	for _, promptMap := range promptList {
+		explanation, ok := promptMap["instructType"].(string)
 		if !ok {
 			continue
 		}
-		explanation, ok := promptMap["instructType"].(string)
-		if !ok {
+		if _, seen := explanationSet[explanation]; seen {
 			continue
 		}
 		explanationSet[explanation] = struct{}{}
-	}
-
-	for explanation := range explanationSet {
 		explanationStrings = append(explanationStrings, explanation)
 	}
 
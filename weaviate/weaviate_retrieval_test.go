@@ -0,0 +1,43 @@
+package weaviate
+
+import (
+	"testing"
+
+	"github.com/rwth-acis/modernizer/fingerprint"
+)
+
+func TestSelectsPrompt(t *testing.T) {
+	prompt := map[string]interface{}{
+		"code":         halfCode,
+		"codeHash":     fingerprint.CodeHash(halfCode),
+		"instructType": "developer",
+		"model":        "llama3",
+	}
+	match := CodeMatch{Code: halfCode, Mode: MatchNormalized}
+
+	tests := []struct {
+		name         string
+		instructType string
+		model        string
+		want         bool
+	}{
+		{name: "any model", want: true},
+		{name: "same model", model: "llama3", want: true},
+		{name: "model sharing a token", model: "llama3:8b"},
+		{name: "same instruct type", instructType: "developer", want: true},
+		{name: "instruct type sharing a token", instructType: "developer explanation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectsPrompt(prompt, match, tt.instructType, tt.model); got != tt.want {
+				t.Errorf("selectsPrompt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	other := map[string]interface{}{"code": quarterCode, "codeHash": fingerprint.CodeHash(quarterCode), "model": "llama3"}
+	if selectsPrompt(other, match, "", "llama3") {
+		t.Errorf("selectsPrompt() selected a prompt about other code")
+	}
+}
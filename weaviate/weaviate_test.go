@@ -0,0 +1,90 @@
+package weaviate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/rwth-acis/modernizer/errkind"
+	"github.com/weaviate/weaviate/entities/models"
+)
+
+func TestCreatePromptWithResponse(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeWeaviate(t)
+	prompt := PromptObject{Instruct: "explain", InstructType: "explanation", Code: halfCode, Model: "llama3"}
+
+	id, created, err := client.CreatePromptWithResponse(ctx, prompt, "halves n")
+	if err != nil || !created {
+		t.Fatalf("CreatePromptWithResponse() = %q, %v, %v", id, created, err)
+	}
+	if fake.object(PromptClass, id) == nil || fake.object(ResponseClass, ResponseUUID(id)) == nil {
+		t.Fatal("prompt or response was not stored")
+	}
+
+	again, created, err := client.CreatePromptWithResponse(ctx, prompt, "halves n")
+	if err != nil || created || again != id {
+		t.Errorf("retried CreatePromptWithResponse() = %q, %v, %v, want %q, false", again, created, err, id)
+	}
+}
+
+func TestCreatePromptWithResponseDiscards(t *testing.T) {
+	prompt := PromptObject{Instruct: "explain", InstructType: "explanation", Code: halfCode, Model: "llama3"}
+	id := PromptUUID(prompt, "halves n")
+
+	tests := []struct {
+		name string
+		// concurrent stores the prompt and the response before the batch,
+		// as an identical request would
+		concurrent bool
+		wantStored bool
+	}{
+		{name: "rejected prompt", wantStored: false},
+		{name: "concurrent identical request", concurrent: true, wantStored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake, client := newFakeWeaviate(t)
+			fake.failBatch = PromptClass
+			if tt.concurrent {
+				fake.beforeBatch = func() {
+					fake.put(&models.Object{Class: PromptClass, ID: strfmt.UUID(id)})
+					fake.put(&models.Object{Class: ResponseClass, ID: strfmt.UUID(ResponseUUID(id))})
+				}
+			}
+
+			_, _, err := client.CreatePromptWithResponse(ctx, prompt, "halves n")
+			if err == nil {
+				t.Fatal("CreatePromptWithResponse() succeeded although the prompt was rejected")
+			}
+
+			storedPrompt := fake.object(PromptClass, id) != nil
+			storedResponse := fake.object(ResponseClass, ResponseUUID(id)) != nil
+			if storedPrompt != tt.wantStored || storedResponse != tt.wantStored {
+				t.Errorf("stored prompt, response = %v, %v, want %v", storedPrompt, storedResponse, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestCreateMessageObjectConflict(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeWeaviate(t)
+	question := MessageObject{PromptID: "p", Position: 2, Role: RoleUser, Content: "Why?"}
+
+	id, err := client.CreateMessageObject(ctx, question)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != MessageUUID("p", 2) || fake.object(MessageClass, id) == nil {
+		t.Fatalf("CreateMessageObject() = %q, want the stored ID derived from the position", id)
+	}
+
+	// a concurrent follow-up computed the same position
+	question.Content = "How?"
+	if _, err := client.CreateMessageObject(ctx, question); !errkind.Is(err, errkind.Conflict) {
+		t.Errorf("CreateMessageObject() at a taken position = %v, want a conflict", err)
+	}
+}